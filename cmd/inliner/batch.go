@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"inliner/internal/inliner"
+)
+
+// runBatchProcessing processes the input files collected from inputPaths,
+// writing each under -output-dir or over itself with -in-place. Each file
+// gets the configuration of the config file overrides matching it. Files are
+// processed by -jobs workers, but progress, failures and the report follow
+// the sorted file order so output is the same for any -jobs.
+func runBatchProcessing(inlinerEngine *inliner.Inliner, source *configSource) error {
+	htmlFiles, err := collectInputs(inputPaths)
+	if err != nil {
+		return fmt.Errorf("failed to find HTML files: %w", err)
+	}
+
+	if len(htmlFiles) == 0 {
+		return fmt.Errorf("no HTML files found in %s", strings.Join(inputPaths, ", "))
+	}
+
+	if !*inPlace {
+		// Inputs from different directories must not overwrite each other
+		outputs := make(map[string]string, len(htmlFiles))
+		for _, inputPath := range htmlFiles {
+			rel := relativeInput(inputPath)
+			if other, ok := outputs[rel]; ok {
+				return fmt.Errorf("%s and %s would both be written to %s", other, inputPath, filepath.Join(*outputDir, rel))
+			}
+			outputs[rel] = inputPath
+		}
+
+		// Create output directory if it doesn't exist
+		if err := os.MkdirAll(*outputDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	var cache *buildCache
+	if *cachePath != "" {
+		if cache, err = loadBuildCache(*cachePath); err != nil {
+			return err
+		}
+	}
+
+	// Results arrive in file order
+	var totalStats inliner.ProcessingStats
+	var totalWarnings []inliner.ValidationWarning
+	var skipped int
+	entries := make(map[string]cacheEntry)
+	rep := newReport("inline", inlinerEngine)
+
+	err = processBatch(source, cache, htmlFiles, func(file batchResult) error {
+		if *verbose {
+			note := ""
+			if file.cached {
+				note = " (unchanged)"
+			}
+			fmt.Fprintf(os.Stderr, "Processing %d/%d: %s%s\n", file.index+1, len(htmlFiles), file.path, note)
+		}
+
+		if file.configErr != nil {
+			return fmt.Errorf("%s: %w", file.path, file.configErr)
+		}
+		if file.err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", file.err)
+			rep.AddFailure(file.path, file.err)
+			outcome.addFailure()
+			return nil
+		}
+
+		if cache != nil {
+			entries[file.rel] = file.entry
+		}
+		if file.cached {
+			// Findings and statistics are replayed from the manifest so
+			// thresholds and totals still apply
+			skipped++
+		}
+
+		// Accumulate statistics
+		stats := file.result.ProcessingStats
+		totalStats.CSSRulesParsed += stats.CSSRulesParsed
+		totalStats.CSSRulesRemoved += stats.CSSRulesRemoved
+		totalStats.HTMLElementsProcessed += stats.HTMLElementsProcessed
+		totalStats.SelectorsMatched += stats.SelectorsMatched
+		totalStats.ProcessingTimeMs += stats.ProcessingTimeMs
+		totalWarnings = append(totalWarnings, file.result.Warnings...)
+		rep.AddInlineResult(file.path, file.result)
+		outcome.addWarnings(file.result.Warnings)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if cache != nil {
+		inputs := make(map[string]bool, len(htmlFiles))
+		for _, inputPath := range htmlFiles {
+			inputs[relativeInput(inputPath)] = true
+		}
+		if err := cache.save(entries, inputs); err != nil {
+			return err
+		}
+	}
+
+	if structuredReport() {
+		return writeReport(rep, false)
+	}
+
+	// Show batch statistics
+	if *stats || *verbose {
+		fmt.Fprintf(os.Stderr, "\nBatch Processing Summary:\n")
+		fmt.Fprintf(os.Stderr, "Files processed: %d\n", len(htmlFiles))
+		if cache != nil {
+			fmt.Fprintf(os.Stderr, "Files unchanged (skipped): %d\n", skipped)
+		}
+		fmt.Fprintf(os.Stderr, "CSS rules parsed: %d\n", totalStats.CSSRulesParsed)
+		fmt.Fprintf(os.Stderr, "Unused CSS rules removed: %d\n", totalStats.CSSRulesRemoved)
+		fmt.Fprintf(os.Stderr, "HTML elements processed: %d\n", totalStats.HTMLElementsProcessed)
+		fmt.Fprintf(os.Stderr, "Selectors matched: %d\n", totalStats.SelectorsMatched)
+		fmt.Fprintf(os.Stderr, "Total processing time: %dms\n", totalStats.ProcessingTimeMs)
+
+		if len(totalWarnings) > 0 {
+			fmt.Fprintf(os.Stderr, "Total warnings: %d\n", len(totalWarnings))
+		}
+	}
+
+	return nil
+}
+
+// processBatch processes files with -jobs workers and passes each result to
+// handle in file order, whatever order the workers finish in. An error from
+// handle stops the batch and is returned.
+func processBatch(source *configSource, cache *buildCache, files []string, handle func(batchResult) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Workers take file indexes in order and send back one result each
+	indexes := make(chan int)
+	results := make(chan batchResult)
+	var workers sync.WaitGroup
+	for w := 0; w < min(*jobs, len(files)); w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for index := range indexes {
+				results <- processBatchFile(ctx, source, cache, index, files[index])
+			}
+		}()
+	}
+	go func() {
+		defer close(indexes)
+		for index := range files {
+			select {
+			case indexes <- index:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	// Hold back results that finish ahead of their turn
+	var fatal error
+	pending := make(map[int]batchResult)
+	next := 0
+	for result := range results {
+		pending[result.index] = result
+		for fatal == nil {
+			file, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if fatal = handle(file); fatal != nil {
+				cancel()
+			}
+		}
+	}
+	return fatal
+}
+
+// batchResult is the outcome of processing one file in a batch
+type batchResult struct {
+	index     int
+	path      string
+	rel       string // Path relative to its input directory
+	result    *inliner.InlineResult
+	err       error      // The file failed; the batch continues
+	configErr error      // The file's configuration is invalid; the batch stops
+	cached    bool       // The output was up to date and the file was skipped
+	entry     cacheEntry // Cache manifest entry, with -cache
+}
+
+// processBatchFile reads, inlines and writes one file of a batch, or skips
+// it when the cache shows its output is up to date
+func processBatchFile(ctx context.Context, source *configSource, cache *buildCache, index int, inputPath string) batchResult {
+	file := batchResult{index: index, path: inputPath, rel: relativeInput(inputPath)}
+
+	fileEngine, err := source.engineFor(inputPath)
+	if err != nil {
+		file.configErr = err
+		return file
+	}
+
+	// Read input file
+	inputContent, err := os.ReadFile(inputPath)
+	if err != nil {
+		file.err = fmt.Errorf("failed to read %s: %w", inputPath, err)
+		return file
+	}
+
+	// Generate output path
+	outputPath := filepath.Join(*outputDir, file.rel)
+	if *inPlace {
+		outputPath = inputPath
+	}
+
+	var inputHash, cfgHash string
+	if cache != nil {
+		cfg, _ := source.resolve(inputPath) // Already validated by engineFor
+		inputHash, cfgHash = hashBytes(inputContent), configHash(cfg)
+		if entry, ok := cache.lookup(file.rel, inputHash, cfgHash, outputPath); ok {
+			entry.Source = inputPath
+			file.cached, file.entry = true, entry
+			file.result = entry.result()
+			return file
+		}
+	}
+
+	// Process the HTML
+	file.result, err = inlineInput(ctx, fileEngine, string(inputContent), inputPath)
+	if err != nil {
+		file.err = fmt.Errorf("failed to process %s: %w", inputPath, err)
+		return file
+	}
+
+	// Create output subdirectory if needed
+	outputSubdir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(outputSubdir, 0755); err != nil {
+		file.err = fmt.Errorf("failed to create output directory %s: %w", outputSubdir, err)
+		return file
+	}
+
+	// Write output file
+	if err := writeOutput(file.result.HTML, outputPath); err != nil {
+		file.err = fmt.Errorf("failed to write %s: %w", outputPath, err)
+		return file
+	}
+
+	if cache != nil {
+		file.entry = cache.entry(inputPath, inputHash, cfgHash, outputPath, file.result)
+	}
+
+	// The report only needs the findings and statistics
+	file.result.HTML = ""
+	return file
+}
+
+// relativeInput returns a batch input's path relative to the input directory
+// it was found in, or its file name when it was named directly
+func relativeInput(inputPath string) string {
+	for _, root := range inputRoots {
+		rel, err := filepath.Rel(root, inputPath)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return rel
+		}
+	}
+	return filepath.Base(inputPath)
+}
+
+// inlineInput inlines one document, resolving relative stylesheet links
+// against its path (the working directory for stdin)
+func inlineInput(ctx context.Context, inlinerEngine *inliner.Inliner, content, inputPath string, opts ...inliner.Option) (*inliner.InlineResult, error) {
+	base, err := filepath.Abs(inputPath)
+	if inputPath == "" || err != nil {
+		base, _ = os.Getwd()
+		base += string(filepath.Separator)
+	}
+	opts = append([]inliner.Option{inliner.WithBaseURL(filepath.ToSlash(base))}, opts...)
+	return inlinerEngine.InlineContext(ctx, strings.NewReader(content), io.Discard, opts...)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"inliner/internal/inliner"
+)
+
+// batchInputs writes n inputs, the first few much larger than the rest so
+// workers finish out of order, and returns their paths in order
+func batchInputs(t *testing.T, n int) []string {
+	t.Helper()
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < n; i++ {
+		rows := 1
+		if i < 3 {
+			rows = 1000
+		}
+		body := strings.Repeat(`<tr><td class="cell">x</td></tr>`, rows)
+		path := filepath.Join(dir, fmt.Sprintf("%02d.html", i))
+		page := `<html><head><style>.cell { color: red }</style></head><body><table>` + body + `</table></body></html>`
+		if err := os.WriteFile(path, []byte(page), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// newTestSource returns a source with no config file
+func newTestSource() *configSource {
+	return &configSource{sheets: inliner.NewSheetCache(), engines: make(map[string]*inliner.Inliner)}
+}
+
+func TestProcessBatchKeepsFileOrder(t *testing.T) {
+	setFlag(t, outputDir, t.TempDir())
+	files := batchInputs(t, 12)
+	files[5] += ".missing" // Failures are reported in order too
+
+	for _, workers := range []int{1, 4, 16} {
+		setFlag(t, jobs, workers)
+		var order []int
+		err := processBatch(newTestSource(), nil, files, func(file batchResult) error {
+			order = append(order, file.index)
+			if file.path != files[file.index] {
+				t.Errorf("jobs %d: result %d is for %s, want %s", workers, file.index, file.path, files[file.index])
+			}
+			if (file.err != nil) != (file.index == 5) {
+				t.Errorf("jobs %d: file %d error = %v", workers, file.index, file.err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("jobs %d: %v", workers, err)
+		}
+
+		want := make([]int, len(files))
+		for i := range want {
+			want[i] = i
+		}
+		if !reflect.DeepEqual(order, want) {
+			t.Errorf("jobs %d: handled %v, want %v", workers, order, want)
+		}
+	}
+}
+
+func TestProcessBatchStopsOnHandlerError(t *testing.T) {
+	setFlag(t, outputDir, t.TempDir())
+	setFlag(t, jobs, 4)
+	files := batchInputs(t, 20)
+
+	stop := errors.New("stop")
+	var handled []int
+	err := processBatch(newTestSource(), nil, files, func(file batchResult) error {
+		handled = append(handled, file.index)
+		if file.index == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("processBatch = %v, want the handler's error", err)
+	}
+	if want := []int{0, 1, 2, 3}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+}
+
+func TestBatchReportInFileOrder(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 8; i++ {
+		rows := 1
+		if i == 0 {
+			rows = 1000
+		}
+		files[fmt.Sprintf("src/%d.html", i)] = `<html><body><table>` + strings.Repeat(`<tr><td>x</td></tr>`, rows) + `</table></body></html>`
+	}
+	dir := writeTree(t, files)
+
+	result := runCLI(t, dir, "", "inline", "-input-dir", "src", "-output-dir", "out", "-jobs", "4", "-format", "json")
+	if result.code != exitOK {
+		t.Fatalf("inline exited %d: %s", result.code, result.stderr)
+	}
+	last := -1
+	for i := 0; i < 8; i++ {
+		at := strings.Index(result.stdout, fmt.Sprintf(`"path": "src/%d.html"`, i))
+		if at < last {
+			t.Fatalf("src/%d.html is out of order in the report:\n%s", i, result.stdout)
+		}
+		last = at
+	}
+	for i := 0; i < 8; i++ {
+		if _, err := os.Stat(filepath.Join(dir, "out", fmt.Sprintf("%d.html", i))); err != nil {
+			t.Errorf("output %d.html: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"inliner/internal/config"
+	"inliner/internal/inliner"
+	"inliner/internal/version"
+)
+
+// buildCache is the manifest of an incremental -input-dir build. It records,
+// for each input, hashes of everything its output was built from, so an
+// unchanged input can be skipped and a changed stylesheet invalidates exactly
+// the inputs that use it.
+type buildCache struct {
+	path string
+
+	// Version is the tool version that wrote the manifest; a different
+	// version invalidates every entry
+	Version string `json:"version"`
+
+	// Entries are keyed by input path relative to -input-dir
+	Entries map[string]cacheEntry `json:"entries"`
+
+	hashes sync.Map // File hashes by path, computed once per run
+}
+
+// cacheEntry records how one output was built
+type cacheEntry struct {
+	Input        string            `json:"input"`                  // Hash of the input file
+	Source       string            `json:"source,omitempty"`       // Input file path
+	Config       string            `json:"config"`                 // Hash of the effective configuration
+	Output       string            `json:"output"`                 // Output file path
+	Dependencies map[string]string `json:"dependencies,omitempty"` // Stylesheet path to hash, "" when missing
+
+	// Replayed when the input is skipped, so reports, totals and thresholds
+	// match a full rebuild
+	Warnings       []inliner.ValidationWarning `json:"warnings,omitempty"`
+	Fixes          []inliner.Fix               `json:"fixes,omitempty"`
+	InlinedStyles  int                         `json:"inlinedStyles"`
+	PreservedRules int                         `json:"preservedRules"`
+	Size           inliner.SizeReport          `json:"size"`
+	Stats          inliner.ProcessingStats     `json:"stats"`
+}
+
+// loadBuildCache reads the manifest at path. A missing manifest, or one from
+// another version, starts an empty cache; an unreadable one is discarded
+// with a warning.
+func loadBuildCache(path string) (*buildCache, error) {
+	cache := &buildCache{path: path, Version: version.Version, Entries: make(map[string]cacheEntry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache manifest: %w", err)
+	}
+
+	var stored buildCache
+	if err := json.Unmarshal(data, &stored); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring unreadable cache manifest %s: %v\n", path, err)
+		return cache, nil
+	}
+	if stored.Version == version.Version && stored.Entries != nil {
+		cache.Entries = stored.Entries
+	}
+	return cache, nil
+}
+
+// configHash hashes everything besides the input that affects its output
+func configHash(cfg config.Config) string {
+	data, _ := json.Marshal(struct {
+		Version         string
+		Config          config.Config
+		LoadStylesheets bool
+	}{version.Version, cfg, *loadSheets})
+	return hashBytes(data)
+}
+
+// lookup returns the entry for an input when its output is up to date
+func (c *buildCache) lookup(rel, inputHash, cfgHash, outputPath string) (cacheEntry, bool) {
+	entry, ok := c.Entries[rel]
+	if !ok || entry.Input != inputHash || entry.Config != cfgHash || entry.Output != outputPath {
+		return entry, false
+	}
+	if _, err := os.Stat(outputPath); err != nil {
+		return entry, false
+	}
+	for dependency, hash := range entry.Dependencies {
+		if c.hashFile(dependency) != hash {
+			return entry, false
+		}
+	}
+	return entry, true
+}
+
+// entry records a freshly built output and the stylesheets it used
+func (c *buildCache) entry(inputPath, inputHash, cfgHash, outputPath string, result *inliner.InlineResult) cacheEntry {
+	entry := cacheEntry{
+		Input:          inputHash,
+		Source:         inputPath,
+		Config:         cfgHash,
+		Output:         outputPath,
+		Warnings:       result.Warnings,
+		Fixes:          result.Fixes,
+		InlinedStyles:  result.InlinedStyles,
+		PreservedRules: result.PreservedRules,
+		Size:           result.Size,
+		Stats:          result.ProcessingStats,
+	}
+	if len(result.Stylesheets) > 0 {
+		entry.Dependencies = make(map[string]string, len(result.Stylesheets))
+		for _, href := range result.Stylesheets {
+			entry.Dependencies[href] = c.hashFile(href)
+		}
+	}
+	return entry
+}
+
+// result rebuilds the findings and statistics of the build an entry records.
+// Skipping the input took no processing time, so that isn't replayed.
+func (e cacheEntry) result() *inliner.InlineResult {
+	stats := e.Stats
+	stats.ProcessingTimeMs = 0
+	return &inliner.InlineResult{
+		InlinedStyles:   e.InlinedStyles,
+		PreservedRules:  e.PreservedRules,
+		Warnings:        e.Warnings,
+		Fixes:           e.Fixes,
+		Size:            e.Size,
+		ProcessingStats: stats,
+	}
+}
+
+// hashFile hashes a stylesheet by href, or returns "" for remote or missing
+// files. Hashes are computed once per run since many inputs share sheets.
+func (c *buildCache) hashFile(href string) string {
+	if hash, ok := c.hashes.Load(href); ok {
+		return hash.(string)
+	}
+
+	hash := ""
+	if path, err := localPath(href); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			hash = hashBytes(data)
+		}
+	}
+	c.hashes.Store(href, hash)
+	return hash
+}
+
+// save replaces the manifest with entries, removing the outputs of inputs
+// that no longer exist. inputs holds the relative paths of every input
+// found this run; entries for inputs that still exist but were left out, by
+// -exclude or narrower path arguments, are kept along with their outputs.
+func (c *buildCache) save(entries map[string]cacheEntry, inputs map[string]bool) error {
+	for rel, entry := range c.Entries {
+		if inputs[rel] {
+			continue
+		}
+		// Manifests from before sources were recorded can't tell, so keep them
+		if _, err := os.Stat(entry.Source); entry.Source == "" || err == nil {
+			entries[rel] = entry
+			continue
+		}
+		if err := os.Remove(entry.Output); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove stale output %s: %v\n", entry.Output, err)
+		} else if *verbose {
+			fmt.Fprintf(os.Stderr, "Removed stale output: %s\n", entry.Output)
+		}
+	}
+	c.Entries = entries
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to write cache manifest: %w", err)
+		}
+	}
+
+	// An interrupted run leaves the old manifest
+	if err := writeFileAtomic(c.path, data); err != nil {
+		return fmt.Errorf("failed to write cache manifest: %w", err)
+	}
+	return nil
+}
+
+// hashBytes returns the hex SHA-256 of data
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
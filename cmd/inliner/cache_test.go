@@ -0,0 +1,158 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// cacheTree is a batch whose first input links a stylesheet and gets
+// accessibility fixes, and whose second input is self-contained
+var cacheTree = map[string]string{
+	"src/a.html": `<html><head><link rel="stylesheet" href="style.css"></head><body>
+<table><tr><td><img src="spacer.gif" width="1" height="1"><p class="x" style="position: absolute">Hi</p></td></tr></table>
+</body></html>`,
+	"src/style.css": `.x { color: red }`,
+	"src/b.html":    `<html><head><style>p { color: blue }</style></head><body><p>B</p></body></html>`,
+}
+
+// cachedBuild runs an incremental batch build in dir and returns the inputs
+// that were rebuilt rather than skipped, and the JSON report
+func cachedBuild(t *testing.T, dir string, args ...string) (rebuilt []string, report string) {
+	t.Helper()
+	args = append([]string{"inline", "-input-dir", "src", "-output-dir", "out", "-cache", "cache.json",
+		"-load-stylesheets", "-a11y-fix", "-verbose", "-format", "json", "-fail-on", "none"}, args...)
+	result := runCLI(t, dir, "", args...)
+	if result.code != exitOK {
+		t.Fatalf("inline exited %d: %s", result.code, result.stderr)
+	}
+
+	for _, line := range strings.Split(result.stderr, "\n") {
+		if !strings.HasPrefix(line, "Processing ") || strings.HasSuffix(line, "(unchanged)") {
+			continue
+		}
+		rebuilt = append(rebuilt, filepath.ToSlash(line[strings.LastIndex(line, " ")+1:]))
+	}
+	return rebuilt, result.stdout
+}
+
+// processingTime matches report timings, which differ between runs
+var processingTime = regexp.MustCompile(`"processingTimeMs": \d+`)
+
+func TestCacheReplaysResults(t *testing.T) {
+	dir := writeTree(t, cacheTree)
+
+	rebuilt, first := cachedBuild(t, dir)
+	if want := []string{"src/a.html", "src/b.html"}; !reflect.DeepEqual(rebuilt, want) {
+		t.Fatalf("first build rebuilt %v, want %v", rebuilt, want)
+	}
+	rebuilt, second := cachedBuild(t, dir)
+	if len(rebuilt) != 0 {
+		t.Fatalf("second build rebuilt %v, want nothing", rebuilt)
+	}
+
+	// Skipped inputs report the same findings, fixes, size and statistics
+	for _, want := range []string{`"ruleId": "css-position-absolute"`, `"ruleId": "a11y-layout-table-role"`, `"inlinedStyles": 2`} {
+		if !strings.Contains(second, want) {
+			t.Errorf("cached report is missing %s:\n%s", want, second)
+		}
+	}
+	first = processingTime.ReplaceAllString(first, `"processingTimeMs": 0`)
+	second = processingTime.ReplaceAllString(second, `"processingTimeMs": 0`)
+	if first != second {
+		t.Errorf("cached report differs from the full build\nfull:\n%s\ncached:\n%s", first, second)
+	}
+}
+
+func TestCacheReplaysThresholds(t *testing.T) {
+	dir := writeTree(t, cacheTree)
+	args := []string{"inline", "-input-dir", "src", "-output-dir", "out", "-cache", "cache.json", "-load-stylesheets", "-fail-on", "warning"}
+
+	for run := 1; run <= 2; run++ {
+		if result := runCLI(t, dir, "", args...); result.code != exitWarnings {
+			t.Errorf("run %d exited %d, want %d: %s", run, result.code, exitWarnings, result.stderr)
+		}
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	write := func(name, content string) func(t *testing.T, dir string) {
+		return func(t *testing.T, dir string) {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T, dir string)
+		args   []string
+		want   []string
+	}{
+		{name: "unchanged"},
+		{
+			name:   "input changed",
+			change: write("src/b.html", `<html><head><style>p { color: green }</style></head><body><p>B</p></body></html>`),
+			want:   []string{"src/b.html"},
+		},
+		{
+			name:   "input touched",
+			change: write("src/b.html", cacheTree["src/b.html"]),
+		},
+		{
+			name: "config changed",
+			args: []string{"-minify"},
+			want: []string{"src/a.html", "src/b.html"},
+		},
+		{
+			name:   "stylesheet changed",
+			change: write("src/style.css", `.x { color: green }`),
+			want:   []string{"src/a.html"},
+		},
+		{
+			name: "stylesheet removed",
+			change: func(t *testing.T, dir string) {
+				if err := os.Remove(filepath.Join(dir, "src/style.css")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"src/a.html"},
+		},
+		{
+			name: "output removed",
+			change: func(t *testing.T, dir string) {
+				if err := os.Remove(filepath.Join(dir, "out/b.html")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"src/b.html"},
+		},
+		{
+			name: "output directory changed",
+			args: []string{"-output-dir", "elsewhere"},
+			want: []string{"src/a.html", "src/b.html"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeTree(t, cacheTree)
+			cachedBuild(t, dir)
+			if test.change != nil {
+				test.change(t, dir)
+			}
+
+			rebuilt, _ := cachedBuild(t, dir, test.args...)
+			if !reflect.DeepEqual(rebuilt, test.want) {
+				t.Errorf("rebuilt %v, want %v", rebuilt, test.want)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "out", "b.html")); err != nil && len(test.args) == 0 {
+				t.Errorf("output missing after rebuild: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"inliner/internal/inliner"
+)
+
+// command is a CLI subcommand with its own flag set
+type command struct {
+	name      string
+	args      string // Synopsis of the arguments after the flags
+	summary   string // One line, shown in the command list
+	help      string // Shown by "inliner help <command>"
+	flags     func(fs *flag.FlagSet)
+	run       func(args []string) error
+	exitCodes bool // The command reports findings through its exit code
+}
+
+// usageError is returned by commands for invalid arguments, which exit with
+// exitUsage instead of exitIO
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+
+// usageErrorf creates a usageError
+func usageErrorf(format string, args ...any) error {
+	return usageError{err: fmt.Errorf(format, args...)}
+}
+
+// commands lists the subcommands in help order
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{
+			name:    "inline",
+			args:    "[flags] [path...]",
+			summary: "Inline CSS into HTML from files, directories or stdin",
+			help: "Inlines CSS into -input (default stdin). Files and directories given as\n" +
+				"arguments, or -input-dir, are processed into -output-dir, or over themselves\n" +
+				"with -in-place. Directories are filtered by -ext, -include, -exclude and\n" +
+				"their " + ignoreFileName + " file; files named directly are always processed.",
+			flags: func(fs *flag.FlagSet) {
+				addInputFlags(fs)
+				addBatchFlags(fs)
+				addFilterFlags(fs)
+				addConfigFlags(fs)
+				addReportFlags(fs)
+				addInlineFlags(fs)
+			},
+			run:       runInline,
+			exitCodes: true,
+		},
+		{
+			name:    "validate",
+			args:    "[flags]",
+			summary: "Check HTML for email client compatibility without inlining",
+			flags: func(fs *flag.FlagSet) {
+				fs.StringVar(inputFile, "input", "", "Input HTML file path (default: stdin)")
+				addConfigFlags(fs)
+				addReportFlags(fs)
+			},
+			run:       runValidateCommand,
+			exitCodes: true,
+		},
+		{
+			name:    "explain",
+			args:    "[rule-id...]",
+			summary: "Describe lint rules, or list them all",
+			run:     runExplain,
+		},
+		{
+			name:    "stats",
+			args:    "[flags]",
+			summary: "Show inlining statistics and the output size breakdown",
+			help:    "Inlines -input (default stdin) and reports statistics without writing HTML.",
+			flags: func(fs *flag.FlagSet) {
+				fs.StringVar(inputFile, "input", "", "Input HTML file path (default: stdin)")
+				addConfigFlags(fs)
+				addReportFlags(fs)
+			},
+			run:       runStatsCommand,
+			exitCodes: true,
+		},
+		{
+			name:    "serve",
+			args:    "-input-dir DIR [flags]",
+			summary: "Preview templates in a browser with live reload",
+			flags: func(fs *flag.FlagSet) {
+				fs.StringVar(inputDir, "input-dir", "", "Directory of templates to preview")
+				addFilterFlags(fs)
+				addAddrFlag(fs)
+				addConfigFlags(fs)
+				addWatchFlags(fs)
+			},
+			run: withSource(runServe),
+		},
+		{
+			name:    "watch",
+			args:    "-input-dir DIR -output-dir DIR [flags]",
+			summary: "Rebuild templates when they or their stylesheets change",
+			flags: func(fs *flag.FlagSet) {
+				addBatchFlags(fs)
+				addFilterFlags(fs)
+				addConfigFlags(fs)
+				addWatchFlags(fs)
+				fs.BoolVar(quiet, "quiet", false, "Only print errors")
+				fs.BoolVar(showWarnings, "warnings", true, "Show compatibility warnings")
+			},
+			run: withSource(runWatch),
+		},
+		{
+			name:    "diff",
+			args:    "[flags] [file]",
+			summary: "Show the styles and rules inlining changes",
+			help: "Compares the input with its output, or with -against-* the output with the\n" +
+				"output of another target, config file or profile. Lists the inline styles\n" +
+				"added, changed or removed on each element, and the <style> rules kept,\n" +
+				"dropped or added.",
+			flags: func(fs *flag.FlagSet) {
+				fs.StringVar(inputFile, "input", "", "Input HTML file path (default: stdin)")
+				addDiffFlags(fs)
+				addConfigFlags(fs)
+			},
+			run: runDiff,
+		},
+		{
+			name:    "clients",
+			args:    "",
+			summary: "List target email clients and their limits",
+			run:     runClients,
+		},
+		{
+			name:    "server",
+			args:    "[flags]",
+			summary: "Serve inlining and validation over HTTP",
+			help: "Serves POST /inline and POST /validate, GET /healthz and GET /metrics.\n" +
+				"Stylesheet links in requests are left as they are; -load-stylesheets is not available.",
+			flags: func(fs *flag.FlagSet) {
+				addAddrFlag(fs)
+				addConfigFlags(fs)
+				addServerFlags(fs)
+			},
+			run: withSource(runServer),
+		},
+		{
+			name:    "completion",
+			args:    "bash|zsh|fish",
+			summary: "Print a shell completion script",
+			run:     runCompletion,
+		},
+		{
+			name:    "help",
+			args:    "[command]",
+			summary: "Show help for a command",
+			run:     runHelp,
+		},
+	}
+}
+
+// legacyCommand is the flag-only invocation, kept as a deprecated alias
+var legacyCommand = &command{
+	flags:     addLegacyFlags,
+	run:       runLegacy,
+	exitCodes: true,
+}
+
+// lookupCommand finds a subcommand by name
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// dispatch runs the command named by the first argument, or the legacy
+// flag-only form when it starts with a flag, and returns the exit code
+func dispatch(args []string) int {
+	cmd := legacyCommand
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if cmd = lookupCommand(args[0]); cmd == nil {
+			fmt.Fprintf(os.Stderr, "Error: unknown command %q\n\n", args[0])
+			printOverview(os.Stderr)
+			return exitUsage
+		}
+		args = args[1:]
+	}
+	return cmd.execute(args)
+}
+
+// newFlagSet creates the command's flag set
+func (c *command) newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.displayName(), flag.ContinueOnError)
+	if c.flags != nil {
+		c.flags(fs)
+	}
+	fs.Usage = func() { c.printUsage(fs) }
+	return fs
+}
+
+// execute parses the command's flags, runs it and returns the exit code
+func (c *command) execute(args []string) int {
+	fs := c.newFlagSet()
+	activeFlags = fs
+
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if err := validateArgs(c.name); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fs.Usage()
+		return exitUsage
+	}
+
+	startTime := time.Now()
+	if err := c.run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			return exitUsage
+		}
+		return exitIO
+	}
+
+	// Show performance metrics if requested
+	if *benchmark {
+		fmt.Fprintf(os.Stderr, "Processing completed in %v\n", time.Since(startTime))
+	}
+
+	if !c.exitCodes {
+		return exitOK
+	}
+	return outcome.exitCode(*failOn, *maxWarnings)
+}
+
+// parseInterspersed parses flags that may follow arguments, as in
+// "inliner inline a.html -quiet", and returns the arguments. Everything
+// after "--" is an argument.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" && !isFlagValue(fs, args[:consumed-1]) {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// isFlagValue reports whether the argument following parsed is the value of
+// its last flag, as "--" is in "-output -- a.html"
+func isFlagValue(fs *flag.FlagSet, parsed []string) bool {
+	for i := 0; i < len(parsed); i++ {
+		if takesValue(fs, parsed[i]) {
+			i++ // The next argument is the value
+			if i == len(parsed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// takesValue reports whether arg is a flag whose value is the next argument
+func takesValue(fs *flag.FlagSet, arg string) bool {
+	if !strings.HasPrefix(arg, "-") || strings.Contains(arg, "=") {
+		return false
+	}
+	f := fs.Lookup(strings.TrimLeft(arg, "-"))
+	if f == nil {
+		return false
+	}
+	boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return !ok || !boolFlag.IsBoolFlag()
+}
+
+// displayName is the command as typed, e.g. "inliner inline"
+func (c *command) displayName() string {
+	if c.name == "" {
+		return "inliner"
+	}
+	return "inliner " + c.name
+}
+
+// printUsage prints the command's synopsis, help and flags
+func (c *command) printUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	if c == legacyCommand {
+		fmt.Fprintf(w, "Usage: inliner <command> [flags]\n\n")
+		printCommandList(w)
+		fmt.Fprintf(w, "\nThe flag-only form \"inliner [flags]\" is deprecated but still accepts:\n")
+	} else {
+		fmt.Fprintf(w, "Usage: %s %s\n\n%s\n", c.displayName(), c.args, c.summary)
+		if c.help != "" {
+			fmt.Fprintf(w, "\n%s\n", c.help)
+		}
+		if c.flags != nil {
+			fmt.Fprintf(w, "\nFlags:\n")
+		}
+	}
+	fs.PrintDefaults()
+	if c.exitCodes {
+		fmt.Fprint(w, exitCodeHelp)
+	}
+}
+
+// printOverview prints the command list
+func printOverview(w io.Writer) {
+	fmt.Fprintf(w, "Usage: inliner <command> [flags]\n\n")
+	printCommandList(w)
+	fmt.Fprintf(w, "\nRun \"inliner help <command>\" for the flags of a command.\n")
+}
+
+// printCommandList prints each command with its summary
+func printCommandList(w io.Writer) {
+	fmt.Fprintf(w, "Commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// runHelp prints the overview or one command's usage
+func runHelp(args []string) error {
+	if len(args) == 0 {
+		printOverview(os.Stdout)
+		return nil
+	}
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		return usageErrorf("unknown command %q", args[0])
+	}
+	fs := cmd.newFlagSet()
+	fs.SetOutput(os.Stdout)
+	cmd.printUsage(fs)
+	return nil
+}
+
+// withSource adapts a mode that needs the configuration source to a command
+func withSource(run func(source *configSource) error) func(args []string) error {
+	return func(args []string) error {
+		if len(args) > 0 {
+			return usageErrorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+		source, _, done, err := setup()
+		if err != nil || done {
+			return err
+		}
+		return run(source)
+	}
+}
+
+// setup resolves the configuration and creates the inliner for -input. It
+// handles -print-config, reporting done when there is nothing left to do.
+func setup() (*configSource, *inliner.Inliner, bool, error) {
+	source, err := loadConfigSource()
+	if err != nil {
+		return nil, nil, false, usageError{err: err}
+	}
+
+	if *printConfig {
+		if err := printEffectiveConfig(source); err != nil {
+			return nil, nil, false, usageError{err: err}
+		}
+		return source, nil, true, nil
+	}
+
+	inlinerEngine, err := source.engineFor(*inputFile)
+	if err != nil {
+		return nil, nil, false, usageError{err: err}
+	}
+	return source, inlinerEngine, false, nil
+}
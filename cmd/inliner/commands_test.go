@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    []string
+		quiet   bool
+		output  string
+		jobs    int
+		wantErr bool
+	}{
+		{args: nil},
+		{args: []string{"a.html"}, want: []string{"a.html"}},
+		{args: []string{"a.html", "-quiet"}, want: []string{"a.html"}, quiet: true},
+		{args: []string{"-output", "out", "a.html", "b.html", "-jobs", "3"}, want: []string{"a.html", "b.html"}, output: "out", jobs: 3},
+		{args: []string{"a.html", "-output=out", "b.html", "--quiet"}, want: []string{"a.html", "b.html"}, output: "out", quiet: true},
+		{args: []string{"a.html", "-quiet=false", "b.html"}, want: []string{"a.html", "b.html"}},
+		{args: []string{"a.html", "--", "-quiet", "b.html"}, want: []string{"a.html", "-quiet", "b.html"}},
+		{args: []string{"--", "a.html"}, want: []string{"a.html"}},
+		{args: []string{"-quiet", "--"}, quiet: true},
+		{args: []string{"a.html", "-", "b.html"}, want: []string{"a.html", "-", "b.html"}},
+		{args: []string{"-output", "--", "a.html", "-quiet"}, want: []string{"a.html"}, output: "--", quiet: true},
+		{args: []string{"-output", "-jobs", "--", "-quiet"}, want: []string{"-quiet"}, output: "-jobs"},
+		{args: []string{"-quiet", "--", "a.html", "-jobs", "2"}, want: []string{"a.html", "-jobs", "2"}, quiet: true},
+		{args: []string{"a.html", "-unknown"}, wantErr: true},
+		{args: []string{"a.html", "-output"}, wantErr: true},
+		{args: []string{"a.html", "-jobs", "many"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		quiet := fs.Bool("quiet", false, "")
+		output := fs.String("output", "", "")
+		jobs := fs.Int("jobs", 0, "")
+
+		got, err := parseInterspersed(fs, test.args)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: error = %v, want error %v", test.args, err, test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: arguments = %q, want %q", test.args, got, test.want)
+		}
+		if *quiet != test.quiet || *output != test.output || *jobs != test.jobs {
+			t.Errorf("%q: -quiet=%v -output=%q -jobs=%d, want %v %q %d", test.args, *quiet, *output, *jobs, test.quiet, test.output, test.jobs)
+		}
+	}
+}
+
+func TestInterspersedFlagsOnTheCommandLine(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"a.html": `<html><head><style>p { color: red }</style></head><body><p>A</p></body></html>`,
+	})
+
+	// Flags after the file argument still apply
+	result := runCLI(t, dir, "", "inline", "a.html", "-output-dir", "out", "-quiet")
+	if result.code != exitOK || result.stdout != "" {
+		t.Fatalf("inline exited %d, stdout %q: %s", result.code, result.stdout, result.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "a.html")); err != nil {
+		t.Errorf("output not written to -output-dir: %v", err)
+	}
+	if result := runCLI(t, dir, "", "inline", "a.html", "-bogus"); result.code != exitUsage {
+		t.Errorf("unknown trailing flag exited %d, want %d", result.code, exitUsage)
+	}
+}
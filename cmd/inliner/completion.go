@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"inliner/internal/config"
+	"inliner/internal/report"
+)
+
+// flagChoices lists the values offered when completing a flag's argument
+var flagChoices = map[string][]string{
+	"target":         config.KnownClients,
+	"format":         formatNames(),
+	"whitespace":     {config.OutputPreserve, config.OutputPretty, config.OutputCompact},
+	"fail-on":        failOnLevels,
+	"color":          {"auto", "always", "never"},
+	"against-target": config.KnownClients,
+}
+
+// formatNames lists the report format names
+func formatNames() []string {
+	names := make([]string, len(report.Formats))
+	for j, f := range report.Formats {
+		names[j] = string(f)
+	}
+	return names
+}
+
+// completionFlag is a flag as seen by the completion scripts
+type completionFlag struct {
+	name    string
+	usage   string
+	isBool  bool
+	choices []string
+}
+
+// commandFlags returns the flags a command registers, in name order
+func commandFlags(cmd *command) []completionFlag {
+	var result []completionFlag
+	cmd.newFlagSet().VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		result = append(result, completionFlag{
+			name:    f.Name,
+			usage:   f.Usage,
+			isBool:  ok && boolFlag.IsBoolFlag(),
+			choices: flagChoices[f.Name],
+		})
+	})
+	return result
+}
+
+// runCompletion prints the completion script for a shell
+func runCompletion(args []string) error {
+	if len(args) != 1 {
+		return usageErrorf("completion needs exactly one shell: bash, zsh or fish")
+	}
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(os.Stdout)
+	case "zsh":
+		writeZshCompletion(os.Stdout)
+	case "fish":
+		writeFishCompletion(os.Stdout)
+	default:
+		return usageErrorf("unsupported shell %q (want bash, zsh or fish)", args[0])
+	}
+	return nil
+}
+
+// commandNames lists the subcommand names
+func commandNames() []string {
+	names := make([]string, len(commands))
+	for j, cmd := range commands {
+		names[j] = cmd.name
+	}
+	return names
+}
+
+// writeBashCompletion writes a script for "source <(inliner completion bash)"
+func writeBashCompletion(w io.Writer) {
+	fmt.Fprintf(w, "# bash completion for inliner\n_inliner() {\n")
+	fmt.Fprintf(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "    if [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n        return\n    fi\n", strings.Join(commandNames(), " "))
+
+	fmt.Fprintf(w, "    case \"$prev\" in\n")
+	for _, name := range sortedKeys(joinedChoices()) {
+		fmt.Fprintf(w, "        -%s|--%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", name, name, joinedChoices()[name])
+	}
+	fmt.Fprintf(w, "    esac\n")
+
+	fmt.Fprintf(w, "    local flags=\"\"\n    case \"${COMP_WORDS[1]}\" in\n")
+	for _, cmd := range commands {
+		var names []string
+		for _, f := range commandFlags(cmd) {
+			names = append(names, "-"+f.name)
+		}
+		switch {
+		case cmd.name == "completion":
+			fmt.Fprintf(w, "        completion) COMPREPLY=($(compgen -W \"bash zsh fish\" -- \"$cur\")); return ;;\n")
+		case cmd.name == "help":
+			fmt.Fprintf(w, "        help) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", strings.Join(commandNames(), " "))
+		case len(names) > 0:
+			fmt.Fprintf(w, "        %s) flags=%q ;;\n", cmd.name, strings.Join(names, " "))
+		}
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "    if [[ \"$cur\" == -* ]]; then\n        COMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "    else\n        COMPREPLY=($(compgen -f -- \"$cur\"))\n    fi\n}\n")
+	fmt.Fprintf(w, "complete -o filenames -F _inliner inliner\n")
+}
+
+// joinedChoices returns flagChoices with the values joined by spaces
+func joinedChoices() map[string]string {
+	joined := make(map[string]string, len(flagChoices))
+	for name, choices := range flagChoices {
+		joined[name] = strings.Join(choices, " ")
+	}
+	return joined
+}
+
+// writeZshCompletion writes a script for a directory on $fpath, or for
+// "source <(inliner completion zsh)"
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintf(w, "#compdef inliner\n\n_inliner() {\n    local -a commands flags\n    commands=(\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "        %s\n", zshQuote(cmd.name+":"+cmd.summary))
+	}
+	fmt.Fprintf(w, "    )\n    if (( CURRENT == 2 )); then\n        _describe 'command' commands\n        return\n    fi\n\n")
+
+	fmt.Fprintf(w, "    case $words[2] in\n")
+	for _, cmd := range commands {
+		switch cmd.name {
+		case "completion":
+			fmt.Fprintf(w, "        completion) _values 'shell' bash zsh fish; return ;;\n")
+			continue
+		case "help":
+			fmt.Fprintf(w, "        help) _describe 'command' commands; return ;;\n")
+			continue
+		}
+
+		fmt.Fprintf(w, "        %s) flags=(", cmd.name)
+		for _, f := range commandFlags(cmd) {
+			spec := "-" + f.name + "[" + zshEscape(f.usage) + "]"
+			switch {
+			case f.isBool:
+			case f.choices != nil:
+				spec += ":" + f.name + ":(" + strings.Join(f.choices, " ") + ")"
+			default:
+				spec += ":" + f.name + ":_files"
+			}
+			fmt.Fprintf(w, "\n            %s", zshQuote(spec))
+		}
+		fmt.Fprintf(w, "\n        ) ;;\n")
+	}
+	fmt.Fprintf(w, "    esac\n\n    shift words\n    (( CURRENT-- ))\n    _arguments $flags '*:file:_files'\n}\n\n")
+	fmt.Fprintf(w, "if [[ $zsh_eval_context[-1] == loadautofunc ]]; then\n    _inliner \"$@\"\nelse\n    compdef _inliner inliner\nfi\n")
+}
+
+// zshEscape escapes the characters _arguments treats specially in a
+// description
+func zshEscape(s string) string {
+	return strings.NewReplacer("[", "\\[", "]", "\\]", ":", "\\:").Replace(s)
+}
+
+// zshQuote single-quotes a word for zsh
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeFishCompletion writes a script for ~/.config/fish/completions
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintf(w, "# fish completion for inliner\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "complete -c inliner -n __fish_use_subcommand -f -a %s -d %s\n", cmd.name, fishQuote(cmd.summary))
+	}
+	fmt.Fprintf(w, "complete -c inliner -n '__fish_seen_subcommand_from completion' -f -a 'bash zsh fish'\n")
+	fmt.Fprintf(w, "complete -c inliner -n '__fish_seen_subcommand_from help' -f -a %s\n", fishQuote(strings.Join(commandNames(), " ")))
+
+	for _, cmd := range commands {
+		for _, f := range commandFlags(cmd) {
+			line := fmt.Sprintf("complete -c inliner -n '__fish_seen_subcommand_from %s' -o %s", cmd.name, f.name)
+			switch {
+			case f.isBool:
+			case f.choices != nil:
+				line += " -x -a " + fishQuote(strings.Join(f.choices, " "))
+			default:
+				line += " -r -F"
+			}
+			fmt.Fprintf(w, "%s -d %s\n", line, fishQuote(f.usage))
+		}
+	}
+}
+
+// fishQuote single-quotes a word for fish
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
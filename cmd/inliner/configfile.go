@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"inliner/internal/config"
+	"inliner/internal/inliner"
+	"inliner/internal/rules"
+)
+
+// maxEngines bounds the number of distinct configurations kept ready
+const maxEngines = 64
+
+// configSource resolves the configuration for each input: defaults, then the
+// config file and -profile, then per-glob overrides, then explicit flags
+type configSource struct {
+	files  *config.FileSet // nil when no config file is used
+	sheets *inliner.SheetCache
+
+	mu      sync.Mutex
+	engines map[string]*inliner.Inliner
+}
+
+// loadConfigSource loads -config, or the config file discovered upward from
+// the first input (the working directory for stdin)
+func loadConfigSource() (*configSource, error) {
+	source := &configSource{
+		sheets:  inliner.NewSheetCache(),
+		engines: make(map[string]*inliner.Inliner),
+	}
+
+	path := *configPath
+	if path == "" {
+		start := "."
+		switch {
+		case *inputFile != "":
+			start = filepath.Dir(*inputFile)
+		case *inputDir != "":
+			start = *inputDir
+		case len(inputPaths) > 0:
+			start = inputPaths[0]
+			if info, err := os.Stat(start); err == nil && !info.IsDir() {
+				start = filepath.Dir(start)
+			}
+		}
+
+		var err error
+		if path, err = config.Discover(start); err != nil {
+			return nil, err
+		}
+	}
+
+	if path == "" {
+		if *profile != "" {
+			return nil, fmt.Errorf("-profile %q given but no config file was found (searched upward for %s)", *profile, strings.Join(config.FileNames, ", "))
+		}
+		return source, nil
+	}
+
+	files, err := config.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	source.files = files
+	return source, nil
+}
+
+// withFiles returns a source for another config file that shares s's
+// stylesheet cache
+func (s *configSource) withFiles(files *config.FileSet) *configSource {
+	return &configSource{
+		files:   files,
+		sheets:  s.sheets,
+		engines: make(map[string]*inliner.Inliner),
+	}
+}
+
+// configFor returns the validated configuration for an input file; an empty
+// path skips per-glob overrides
+func (s *configSource) configFor(inputPath string) (config.Config, error) {
+	cfg, err := s.resolve(inputPath)
+	if err != nil {
+		return cfg, err
+	}
+	return cfg, validateConfig(cfg)
+}
+
+// resolve merges the config file, profile, overrides and flags for an input
+func (s *configSource) resolve(inputPath string) (config.Config, error) {
+	cfg := config.Default()
+	if s.files != nil {
+		var err error
+		if cfg, err = s.files.Resolve(*profile, inputPath); err != nil {
+			return cfg, err
+		}
+	}
+	return buildConfig(cfg), nil
+}
+
+// validateConfig checks a resolved configuration, including rule overrides
+func validateConfig(cfg config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := rules.Default().ValidateConfig(cfg); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+// engineFor returns an inliner for an input file, shared between inputs that
+// resolve to the same configuration. It is safe for concurrent use.
+func (s *configSource) engineFor(inputPath string) (*inliner.Inliner, error) {
+	cfg, err := s.resolve(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	return s.engineForConfig(cfg)
+}
+
+// engineForConfig returns an inliner for a configuration, validating it the
+// first time it is seen. It is safe for concurrent use.
+func (s *configSource) engineForConfig(cfg config.Config) (*inliner.Inliner, error) {
+	key, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if engine, ok := s.engines[string(key)]; ok {
+		return engine, nil
+	}
+
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+	engine := inliner.New(cfg)
+	if *loadSheets {
+		engine.SetLoader(fileLoader{})
+		engine.SetSheetCache(s.sheets)
+	}
+	// Bound the cache; server requests can each carry a different config
+	if len(s.engines) < maxEngines {
+		s.engines[string(key)] = engine
+	}
+	return engine, nil
+}
+
+// printEffectiveConfig writes the merged configuration for -input (or the
+// base configuration without one) as JSON in config file form
+func printEffectiveConfig(source *configSource) error {
+	cfg, err := source.configFor(*inputFile)
+	if err != nil {
+		return err
+	}
+
+	if source.files != nil {
+		fmt.Fprintf(os.Stderr, "Config files: %s\n", strings.Join(source.files.Paths(), ", "))
+	} else {
+		fmt.Fprintln(os.Stderr, "Config files: none (defaults and flags only)")
+	}
+	if *profile != "" {
+		fmt.Fprintf(os.Stderr, "Profile: %s\n", *profile)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(config.SettingsFromConfig(cfg))
+}
+
+// buildConfig applies the flags set on the command line on top of cfg, which
+// holds the defaults or the values from a config file
+func buildConfig(cfg config.Config) config.Config {
+	activeFlags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "target":
+			cfg.TargetEmailClient = *target
+		case "preserve-media":
+			cfg.PreserveMediaQueries = *preserveMedia
+		case "preserve-pseudo":
+			cfg.PreservePseudoSelectors = *preservePseudo
+		case "remove-style-tags":
+			cfg.RemoveStyleTags = *removeStyleTags
+		case "strip-unused":
+			cfg.StripUnusedCSS = *stripUnused
+		case "safelist":
+			if *safelist != "" {
+				config.Settings{Safelist: strings.Split(*safelist, ",")}.Apply(&cfg)
+			}
+		case "passthrough-attr":
+			cfg.PassthroughAttribute = *passthrough
+		case "email-optimizations":
+			cfg.EmailClientOptimizations = *emailOptimizations
+		case "preserve-whitespace":
+			cfg.PreserveWhitespace = *preserveWhitespace
+		case "whitespace":
+			cfg.OutputFormat = *whitespace
+		case "indent":
+			cfg.Indent = *indent
+		case "a11y-fix":
+			cfg.AccessibilityFixes = *a11yFix
+		case "lang":
+			cfg.DefaultLang = *defaultLang
+		case "dark-mode":
+			cfg.DarkMode = *darkMode
+		case "color-scheme":
+			cfg.ColorScheme = *colorScheme
+		case "minify":
+			cfg.Minify = *minify
+		case "size-targets":
+			if *sizeTargets != "" {
+				cfg.SizeTargets = strings.Split(*sizeTargets, ",")
+			}
+		case "rules":
+			// Already checked by validateArgs
+			overrides, _ := rules.ParseOverrides(*ruleOverrides)
+			config.Settings{Rules: overrides}.Apply(&cfg)
+		}
+	})
+	return cfg
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"inliner/internal/config"
+	"inliner/internal/css"
+	"inliner/internal/html"
+	"inliner/internal/inliner"
+)
+
+// diffSkipTags are elements inlining may remove, which would misalign the
+// pairing of elements between the two documents
+var diffSkipTags = map[string]bool{"style": true, "link": true, "meta": true, "script": true}
+
+// ANSI escapes for colorized diff output
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+)
+
+// styleDiff is the semantic difference between two renderings of a document
+type styleDiff struct {
+	File     string        `json:"file"`
+	Before   string        `json:"before"`
+	After    string        `json:"after"`
+	Elements []elementDiff `json:"elements"`
+	Total    int           `json:"totalElements"`
+	Aligned  bool          `json:"aligned"` // Both documents have the same elements
+	Rules    ruleDiff      `json:"rules"`
+}
+
+// elementDiff lists the inline style changes of one element
+type elementDiff struct {
+	Element string        `json:"element"`
+	Line    int           `json:"line,omitempty"`
+	Changes []styleChange `json:"changes"`
+}
+
+// styleChange is one property added, changed or removed on an element
+type styleChange struct {
+	Property string `json:"property"`
+	Before   string `json:"before,omitempty"`
+	After    string `json:"after,omitempty"`
+}
+
+// ruleDiff compares the rules left in <style> blocks, identified by their
+// enclosing at-rules and selector
+type ruleDiff struct {
+	Kept    []string `json:"kept"`
+	Dropped []string `json:"dropped"`
+	Added   []string `json:"added"`
+}
+
+// runDiff inlines -input (or the file argument, default stdin) and shows the
+// inline styles and <style> rules that changed. By default the input is
+// compared with its output; -against-target, -against-config and
+// -against-profile compare the output with the output of another
+// configuration instead.
+func runDiff(args []string) error {
+	switch {
+	case len(args) > 1:
+		return usageErrorf("diff takes at most one input file")
+	case len(args) == 1 && *inputFile != "":
+		return usageErrorf("give the input as -input or as an argument, not both")
+	case len(args) == 1:
+		*inputFile = args[0]
+	}
+	if err := checkColorFlag(); err != nil {
+		return err
+	}
+	against := 0
+	for _, value := range []string{*againstTarget, *againstConfig, *againstProfile} {
+		if value != "" {
+			against++
+		}
+	}
+	if against > 1 {
+		return usageErrorf("use only one of -against-target, -against-config and -against-profile")
+	}
+
+	source, inlinerEngine, done, err := setup()
+	if err != nil || done {
+		return err
+	}
+
+	content, filename, err := readInput()
+	if err != nil {
+		return err
+	}
+	result, err := inlineInput(context.Background(), inlinerEngine, content, *inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to inline CSS: %w", err)
+	}
+
+	before, after := content, result.HTML
+	beforeLabel, afterLabel := "input", "output"
+	if against > 0 {
+		altEngine, baseLabel, altLabel, err := alternateEngine(source)
+		if err != nil {
+			return usageError{err: err}
+		}
+		altResult, err := inlineInput(context.Background(), altEngine, content, *inputFile)
+		if err != nil {
+			return fmt.Errorf("failed to inline CSS with %s: %w", altLabel, err)
+		}
+		before, after = result.HTML, altResult.HTML
+		beforeLabel, afterLabel = baseLabel, altLabel
+	}
+
+	diff, err := diffDocuments(before, after)
+	if err != nil {
+		return err
+	}
+	diff.File, diff.Before, diff.After = filename, beforeLabel, afterLabel
+
+	if structuredReport() {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(diff)
+	}
+	writeDiffText(os.Stdout, diff, useColor())
+	return nil
+}
+
+// alternateEngine returns the inliner for the configuration named by the
+// -against flag, with labels for the base and alternate configurations
+func alternateEngine(source *configSource) (*inliner.Inliner, string, string, error) {
+	switch {
+	case *againstTarget != "":
+		cfg, err := source.resolve(*inputFile)
+		if err != nil {
+			return nil, "", "", err
+		}
+		baseLabel := "target " + cfg.TargetEmailClient
+		cfg.TargetEmailClient = *againstTarget
+		engine, err := source.engineForConfig(cfg)
+		return engine, baseLabel, "target " + *againstTarget, err
+
+	case *againstConfig != "":
+		files, err := config.LoadFile(*againstConfig)
+		if err != nil {
+			return nil, "", "", err
+		}
+		baseLabel := "defaults"
+		if source.files != nil {
+			baseLabel = "config " + source.files.Paths()[0]
+		}
+		engine, err := source.withFiles(files).engineFor(*inputFile)
+		return engine, baseLabel, "config " + *againstConfig, err
+
+	default:
+		if source.files == nil {
+			return nil, "", "", fmt.Errorf("-against-profile needs a config file")
+		}
+		cfg, err := source.files.Resolve(*againstProfile, *inputFile)
+		if err != nil {
+			return nil, "", "", err
+		}
+		baseLabel := "no profile"
+		if *profile != "" {
+			baseLabel = "profile " + *profile
+		}
+		engine, err := source.engineForConfig(buildConfig(cfg))
+		return engine, baseLabel, "profile " + *againstProfile, err
+	}
+}
+
+// diffDocuments compares the inline styles of paired elements and the rules
+// of the <style> blocks of two documents
+func diffDocuments(before, after string) (*styleDiff, error) {
+	parser := html.NewParser()
+	beforeDoc, err := parser.Parse(before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	afterDoc, err := parser.Parse(after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	beforeElements, err := diffElements(beforeDoc)
+	if err != nil {
+		return nil, err
+	}
+	afterElements, err := diffElements(afterDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &styleDiff{
+		Elements: []elementDiff{},
+		Total:    len(beforeElements),
+		Aligned:  len(beforeElements) == len(afterElements),
+	}
+	for j := 0; j < len(beforeElements) && j < len(afterElements); j++ {
+		changes := diffStyles(beforeElements[j].GetInlineStyle(), afterElements[j].GetInlineStyle())
+		if len(changes) > 0 {
+			diff.Elements = append(diff.Elements, elementDiff{
+				Element: describeElement(beforeElements[j]),
+				Line:    beforeElements[j].SourceLine(),
+				Changes: changes,
+			})
+		}
+	}
+
+	beforeRules, afterRules := styleRules(beforeDoc), styleRules(afterDoc)
+	diff.Rules = ruleDiff{Kept: []string{}, Dropped: []string{}, Added: []string{}}
+	for _, rule := range beforeRules {
+		if slices.Contains(afterRules, rule) {
+			diff.Rules.Kept = append(diff.Rules.Kept, rule)
+		} else {
+			diff.Rules.Dropped = append(diff.Rules.Dropped, rule)
+		}
+	}
+	for _, rule := range afterRules {
+		if !slices.Contains(beforeRules, rule) {
+			diff.Rules.Added = append(diff.Rules.Added, rule)
+		}
+	}
+	return diff, nil
+}
+
+// diffElements returns a document's body elements in document order,
+// without the ones inlining may remove
+func diffElements(doc html.Document) ([]html.Node, error) {
+	nodes, err := doc.QuerySelectorAll("body *")
+	if err != nil {
+		return nil, err
+	}
+
+	elements := nodes[:0]
+	for _, node := range nodes {
+		if !diffSkipTags[node.TagName()] {
+			elements = append(elements, node)
+		}
+	}
+	return elements, nil
+}
+
+// styleRules returns the rules of a document's <style> blocks as their
+// enclosing at-rules and selector, in source order without duplicates
+func styleRules(doc html.Document) []string {
+	tags, err := doc.GetStyleTags()
+	if err != nil {
+		return nil
+	}
+
+	var rules []string
+	parser := css.NewParser()
+	for _, tag := range tags {
+		sheet, err := parser.Parse(tag.Text())
+		if err != nil {
+			continue
+		}
+		for _, rule := range sheet.Rules {
+			key := strings.Join(strings.Fields(strings.Join(append(append([]string{}, rule.AtRules...), rule.Selector), " ")), " ")
+			if !slices.Contains(rules, key) {
+				rules = append(rules, key)
+			}
+		}
+	}
+	return rules
+}
+
+// diffStyles compares two inline style maps, sorted by property
+func diffStyles(before, after map[string]css.Declaration) []styleChange {
+	var changes []styleChange
+	for property, decl := range after {
+		old, ok := before[property]
+		if !ok || declarationValue(old) != declarationValue(decl) {
+			changes = append(changes, styleChange{Property: property, Before: declarationValue(old), After: declarationValue(decl)})
+		}
+	}
+	for property, decl := range before {
+		if _, ok := after[property]; !ok {
+			changes = append(changes, styleChange{Property: property, Before: declarationValue(decl)})
+		}
+	}
+	sort.Slice(changes, func(a, b int) bool { return changes[a].Property < changes[b].Property })
+	return changes
+}
+
+// declarationValue formats a declaration's value with its !important flag;
+// the zero declaration formats as ""
+func declarationValue(decl css.Declaration) string {
+	if decl.Important {
+		return decl.Value + " !important"
+	}
+	return decl.Value
+}
+
+// describeElement formats an element as a short selector
+func describeElement(node html.Node) string {
+	var b strings.Builder
+	b.WriteString(node.TagName())
+	if id := node.ID(); id != "" {
+		b.WriteString("#" + id)
+	}
+	for _, class := range node.Classes() {
+		b.WriteString("." + class)
+	}
+	return b.String()
+}
+
+// writeDiffText writes a diff for reading in a terminal or a review comment
+func writeDiffText(w io.Writer, diff *styleDiff, color bool) {
+	paint := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + ansiReset
+	}
+
+	fmt.Fprintln(w, paint(ansiBold, fmt.Sprintf("--- %s", diff.Before)))
+	fmt.Fprintln(w, paint(ansiBold, fmt.Sprintf("+++ %s (%s)", diff.After, diff.File)))
+
+	for _, element := range diff.Elements {
+		header := element.Element
+		if element.Line > 0 {
+			header += fmt.Sprintf(" (line %d)", element.Line)
+		}
+		fmt.Fprintln(w, paint(ansiCyan, header))
+		for _, change := range element.Changes {
+			switch {
+			case change.Before == "":
+				fmt.Fprintln(w, paint(ansiGreen, fmt.Sprintf("  + %s: %s", change.Property, change.After)))
+			case change.After == "":
+				fmt.Fprintln(w, paint(ansiRed, fmt.Sprintf("  - %s: %s", change.Property, change.Before)))
+			default:
+				fmt.Fprintln(w, paint(ansiYellow, fmt.Sprintf("  ~ %s: %s -> %s", change.Property, change.Before, change.After)))
+			}
+		}
+	}
+
+	if len(diff.Rules.Kept)+len(diff.Rules.Dropped)+len(diff.Rules.Added) > 0 {
+		fmt.Fprintln(w, paint(ansiCyan, "<style> rules"))
+		for _, rule := range diff.Rules.Kept {
+			fmt.Fprintf(w, "  = %s\n", rule)
+		}
+		for _, rule := range diff.Rules.Dropped {
+			fmt.Fprintln(w, paint(ansiRed, "  - "+rule))
+		}
+		for _, rule := range diff.Rules.Added {
+			fmt.Fprintln(w, paint(ansiGreen, "  + "+rule))
+		}
+	}
+
+	if !diff.Aligned {
+		fmt.Fprintln(w, paint(ansiYellow, "note: the documents have different elements; later elements may be misaligned"))
+	}
+	fmt.Fprintf(w, "%d of %d elements changed; %d rules kept, %d dropped, %d added\n",
+		len(diff.Elements), diff.Total, len(diff.Rules.Kept), len(diff.Rules.Dropped), len(diff.Rules.Added))
+}
+
+// checkColorFlag validates -color
+func checkColorFlag() error {
+	switch *colorMode {
+	case "auto", "always", "never":
+		return nil
+	}
+	return usageErrorf("invalid -color %q (want auto, always or never)", *colorMode)
+}
+
+// useColor reports whether to colorize: always, never, or with auto when
+// stdout is a terminal and NO_COLOR is unset
+func useColor() bool {
+	switch *colorMode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
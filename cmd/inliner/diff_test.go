@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"inliner/internal/css"
+)
+
+// diffPage has a rule that is inlined, one that matches nothing and one that
+// must stay in a <style> block
+const diffPage = `<html><head><style>
+.a { color: red }
+.unused { color: blue }
+@media (max-width: 600px) { .a { color: green !important } }
+</style></head><body>
+<p class="a" id="intro" style="font-size: 12px">Hi</p>
+<div style="background-image: url(x.png)">bg</div>
+</body></html>`
+
+// runDiffJSON runs the diff command on diffPage and decodes its JSON output
+func runDiffJSON(t *testing.T, files map[string]string, args ...string) styleDiff {
+	t.Helper()
+	tree := map[string]string{"page.html": diffPage}
+	for name, content := range files {
+		tree[name] = content
+	}
+	dir := writeTree(t, tree)
+
+	result := runCLI(t, dir, "", append(append([]string{"diff", "-format", "json"}, args...), "page.html")...)
+	if result.code != exitOK {
+		t.Fatalf("diff exited %d: %s", result.code, result.stderr)
+	}
+	var diff styleDiff
+	if err := json.Unmarshal([]byte(result.stdout), &diff); err != nil {
+		t.Fatalf("diff output is not JSON: %v\n%s", err, result.stdout)
+	}
+	return diff
+}
+
+func TestDiffJSON(t *testing.T) {
+	diff := runDiffJSON(t, nil)
+
+	want := styleDiff{
+		File:   "page.html",
+		Before: "input",
+		After:  "output",
+		Elements: []elementDiff{
+			{Element: "p#intro.a", Line: 6, Changes: []styleChange{{Property: "color", After: "red"}}},
+		},
+		Total:   2,
+		Aligned: true,
+		Rules: ruleDiff{
+			Kept:    []string{"@media (max-width: 600px) .a"},
+			Dropped: []string{".a", ".unused"},
+			Added:   []string{},
+		},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("diff = %+v, want %+v", diff, want)
+	}
+}
+
+func TestDiffJSONAgainst(t *testing.T) {
+	config := map[string]string{"inliner.yaml": "target: generic\nprofiles:\n  compact:\n    remove-style-tags: true\n"}
+
+	tests := []struct {
+		name          string
+		args          []string
+		before, after string
+		rules         ruleDiff
+	}{
+		{
+			name:   "target",
+			args:   []string{"-against-target", "outlook"},
+			before: "target generic",
+			after:  "target outlook",
+			rules:  ruleDiff{Kept: []string{"@media (max-width: 600px) .a"}, Dropped: []string{}, Added: []string{}},
+		},
+		{
+			name:   "profile",
+			args:   []string{"-against-profile", "compact"},
+			before: "no profile",
+			after:  "profile compact",
+			rules:  ruleDiff{Kept: []string{}, Dropped: []string{"@media (max-width: 600px) .a"}, Added: []string{}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff := runDiffJSON(t, config, test.args...)
+			if diff.Before != test.before || diff.After != test.after {
+				t.Errorf("labels = %q, %q; want %q, %q", diff.Before, diff.After, test.before, test.after)
+			}
+			// Both sides are inlined, so only the rules left behind differ
+			if len(diff.Elements) != 0 || !diff.Aligned || diff.Total != 2 {
+				t.Errorf("elements = %+v (aligned %v, total %d), want no changes", diff.Elements, diff.Aligned, diff.Total)
+			}
+			if !reflect.DeepEqual(diff.Rules, test.rules) {
+				t.Errorf("rules = %+v, want %+v", diff.Rules, test.rules)
+			}
+		})
+	}
+}
+
+func TestDiffUsageErrors(t *testing.T) {
+	dir := writeTree(t, map[string]string{"page.html": diffPage, "other.html": diffPage})
+
+	tests := [][]string{
+		{"diff", "page.html", "other.html"},
+		{"diff", "-input", "page.html", "other.html"},
+		{"diff", "-against-target", "outlook", "-against-profile", "compact", "page.html"},
+		{"diff", "-format", "sarif", "page.html"},
+		{"diff", "-color", "sometimes", "page.html"},
+	}
+	for _, args := range tests {
+		if result := runCLI(t, dir, "", args...); result.code != exitUsage {
+			t.Errorf("%v exited %d, want %d: %s", args, result.code, exitUsage, result.stderr)
+		}
+	}
+}
+
+func TestDiffDocuments(t *testing.T) {
+	before := `<html><head><style>.a { color: red } @media print { .a { display: none } }</style></head><body>
+<p class="a" style="color: red; margin: 0">One</p>
+<p style="padding: 4px">Two</p>
+</body></html>`
+	after := `<html><head><style>@media print { .a { display: none } } .b { color: blue }</style></head><body>
+<p class="a" style="color: red !important; font-size: 12px">One</p>
+<p style="padding: 4px">Two</p>
+<p>Three</p>
+</body></html>`
+
+	diff, err := diffDocuments(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantElements := []elementDiff{{
+		Element: "p.a",
+		Line:    2,
+		Changes: []styleChange{
+			{Property: "color", Before: "red", After: "red !important"},
+			{Property: "font-size", After: "12px"},
+			{Property: "margin", Before: "0"},
+		},
+	}}
+	if !reflect.DeepEqual(diff.Elements, wantElements) {
+		t.Errorf("elements = %+v, want %+v", diff.Elements, wantElements)
+	}
+	if diff.Total != 2 || diff.Aligned {
+		t.Errorf("total = %d, aligned = %v; want 2, false", diff.Total, diff.Aligned)
+	}
+
+	wantRules := ruleDiff{Kept: []string{"@media print .a"}, Dropped: []string{".a"}, Added: []string{".b"}}
+	if !reflect.DeepEqual(diff.Rules, wantRules) {
+		t.Errorf("rules = %+v, want %+v", diff.Rules, wantRules)
+	}
+}
+
+func TestDiffStylesUnchanged(t *testing.T) {
+	styles := map[string]css.Declaration{"color": {Property: "color", Value: "red"}}
+	if changes := diffStyles(styles, styles); len(changes) != 0 {
+		t.Errorf("diffStyles of equal styles = %+v, want none", changes)
+	}
+
+	important := map[string]css.Declaration{"color": {Property: "color", Value: "red", Important: true}}
+	want := []styleChange{{Property: "color", Before: "red", After: "red !important"}}
+	if changes := diffStyles(styles, important); !reflect.DeepEqual(changes, want) {
+		t.Errorf("diffStyles = %+v, want %+v", changes, want)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"inliner/internal/inliner"
+)
+
+// Exit codes. Findings only affect the exit code once they reach the -fail-on
+// threshold or exceed -max-warnings; I/O failures always take precedence.
+const (
+	exitOK       = 0  // Success, no findings at or above the threshold
+	exitWarnings = 1  // Warning or info findings reached the threshold, or -max-warnings exceeded
+	exitErrors   = 2  // Error findings reached the threshold
+	exitIO       = 3  // An input could not be read, processed or written
+	exitUsage    = 64 // Invalid command line arguments
+)
+
+// exitCodeHelp documents the exit codes in -help output
+const exitCodeHelp = `
+Exit codes:
+  0   success
+  1   warnings at or above -fail-on, or more than -max-warnings warnings
+  2   errors at or above -fail-on
+  3   I/O or processing failure (unreadable input, unparseable HTML, unwritable output)
+  64  invalid command line arguments
+`
+
+// failOnLevels lists the valid -fail-on values from most to least strict
+var failOnLevels = []string{"info", "warning", "error", "none"}
+
+// severityRank orders severities so thresholds can be compared
+func severityRank(severity string) int {
+	switch strings.ToLower(severity) {
+	case "error":
+		return 3
+	case "warning":
+		return 2
+	case "info":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// validateFailOn checks a -fail-on value
+func validateFailOn(level string) error {
+	for _, valid := range failOnLevels {
+		if level == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid -fail-on level: %s (valid: %s)", level, strings.Join(failOnLevels, ", "))
+}
+
+// runOutcome accumulates findings and failures across every processed input
+type runOutcome struct {
+	errors     int
+	warnings   int
+	infos      int
+	ioFailures int
+}
+
+// addSeverity counts a single finding
+func (o *runOutcome) addSeverity(severity string) {
+	switch severityRank(severity) {
+	case 3:
+		o.errors++
+	case 2:
+		o.warnings++
+	default:
+		o.infos++
+	}
+}
+
+// addWarnings counts computed-style warnings from an inline result
+func (o *runOutcome) addWarnings(warnings []inliner.ValidationWarning) {
+	for _, warning := range warnings {
+		o.addSeverity(warning.Severity)
+	}
+}
+
+// addIssues counts validation issues
+func (o *runOutcome) addIssues(issues []inliner.ValidationIssue) {
+	for _, issue := range issues {
+		o.addSeverity(issue.Severity)
+	}
+}
+
+// addFailure records an input that could not be processed
+func (o *runOutcome) addFailure() {
+	o.ioFailures++
+}
+
+// exitCode maps the outcome to a process exit code
+func (o *runOutcome) exitCode(failOn string, maxWarnings int) int {
+	if o.ioFailures > 0 {
+		return exitIO
+	}
+
+	threshold := severityRank(failOn)
+	if threshold > 0 {
+		if o.errors > 0 && threshold <= 3 {
+			return exitErrors
+		}
+		if o.warnings > 0 && threshold <= 2 {
+			return exitWarnings
+		}
+		if o.infos > 0 && threshold <= 1 {
+			return exitWarnings
+		}
+	}
+
+	if maxWarnings >= 0 && o.warnings > maxWarnings {
+		return exitWarnings
+	}
+
+	return exitOK
+}
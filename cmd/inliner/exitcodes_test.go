@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		outcome     runOutcome
+		failOn      string
+		maxWarnings int
+		want        int
+	}{
+		{"clean", runOutcome{}, "error", -1, exitOK},
+		{"errors", runOutcome{errors: 1}, "error", -1, exitErrors},
+		{"warnings below threshold", runOutcome{warnings: 3, infos: 2}, "error", -1, exitOK},
+		{"warnings at threshold", runOutcome{warnings: 1}, "warning", -1, exitWarnings},
+		{"errors beat warnings", runOutcome{errors: 1, warnings: 5}, "warning", -1, exitErrors},
+		{"info below threshold", runOutcome{infos: 4}, "warning", -1, exitOK},
+		{"info at threshold", runOutcome{infos: 1}, "info", -1, exitWarnings},
+		{"errors at info threshold", runOutcome{errors: 1, infos: 1}, "info", -1, exitErrors},
+		{"fail-on none", runOutcome{errors: 2, warnings: 2}, "none", -1, exitOK},
+		{"max-warnings not exceeded", runOutcome{warnings: 2}, "error", 2, exitOK},
+		{"max-warnings exceeded", runOutcome{warnings: 3}, "error", 2, exitWarnings},
+		{"max-warnings zero", runOutcome{warnings: 1}, "none", 0, exitWarnings},
+		{"max-warnings ignores info", runOutcome{infos: 9}, "none", 0, exitOK},
+		{"errors beat max-warnings", runOutcome{errors: 1, warnings: 9}, "error", 0, exitErrors},
+		{"io failure beats errors", runOutcome{errors: 1, warnings: 1, ioFailures: 1}, "info", 0, exitIO},
+		{"io failure with fail-on none", runOutcome{ioFailures: 1}, "none", -1, exitIO},
+	}
+
+	for _, test := range tests {
+		if got := test.outcome.exitCode(test.failOn, test.maxWarnings); got != test.want {
+			t.Errorf("%s: exitCode(%q, %d) = %d, want %d", test.name, test.failOn, test.maxWarnings, got, test.want)
+		}
+	}
+}
+
+func TestAddSeverity(t *testing.T) {
+	var outcome runOutcome
+	for _, severity := range []string{"error", "ERROR", "warning", "info", ""} {
+		outcome.addSeverity(severity)
+	}
+	if want := (runOutcome{errors: 2, warnings: 1, infos: 2}); outcome != want {
+		t.Errorf("outcome = %+v, want %+v", outcome, want)
+	}
+}
+
+// The exit codes of whole runs, as documented in exitCodeHelp
+func TestCommandExitCodes(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"clean.html": `<!DOCTYPE html><html lang="en" dir="ltr"><head><meta charset="utf-8">` +
+			`<meta name="viewport" content="width=device-width"><title>Hi</title></head>` +
+			`<body><table role="presentation"><tr><td>Hello</td></tr></table></body></html>`,
+		"errors.html": `<html><body><img src="logo.png"></body></html>`,
+	})
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"validate", "-input", "clean.html"}, exitOK},
+		{[]string{"validate", "-input", "errors.html"}, exitErrors},
+		{[]string{"validate", "-fail-on", "none", "-input", "errors.html"}, exitOK},
+		{[]string{"validate", "-fail-on", "none", "-max-warnings", "0", "-input", "errors.html"}, exitWarnings},
+		{[]string{"validate", "-rules", "relative-url=off,a11y-img-alt=warning,a11y-html-lang=off", "-input", "errors.html"}, exitOK},
+		{[]string{"validate", "-rules", "relative-url=off,a11y-img-alt=warning,a11y-html-lang=off", "-fail-on", "warning", "-input", "errors.html"}, exitWarnings},
+		{[]string{"validate", "-input", "missing.html"}, exitIO},
+		{[]string{"validate", "-fail-on", "none", "-input", "missing.html"}, exitIO},
+		{[]string{"validate", "-fail-on", "sometimes", "-input", "clean.html"}, exitUsage},
+		{[]string{"validate", "-no-such-flag"}, exitUsage},
+		{[]string{"no-such-command"}, exitUsage},
+	}
+
+	for _, test := range tests {
+		if result := runCLI(t, dir, "", test.args...); result.code != test.want {
+			t.Errorf("inliner %q exited %d, want %d\nstderr: %s", test.args, result.code, test.want, result.stderr)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"inliner/internal/config"
+	"inliner/internal/rules"
+)
+
+// runExplain prints the documentation of the named rules, or lists every
+// rule without arguments
+func runExplain(args []string) error {
+	registry := rules.Default()
+	if len(args) == 0 {
+		printRules(registry)
+		return nil
+	}
+
+	for j, id := range args {
+		rule, ok := registry.Lookup(id)
+		if !ok {
+			return usageErrorf("unknown rule %q (run \"inliner explain\" to list rules)", id)
+		}
+		if j > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%s, default %s)\n", rule.ID, rule.Category, rule.DefaultSeverity)
+		fmt.Printf("  %s\n", rule.Description)
+		if rule.Docs != "" {
+			fmt.Printf("\n  %s\n", rule.Docs)
+		}
+		if rule.Reference != "" {
+			fmt.Printf("\n  Reference: %s\n", rule.Reference)
+		}
+		for _, name := range sortedKeys(rule.Options) {
+			fmt.Printf("  Option %s (default %s)\n", name, rule.Options[name])
+		}
+	}
+	return nil
+}
+
+// runClients lists the known target clients and their compatibility limits
+func runClients(args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	fmt.Printf("%-15s %-13s %-15s %-11s %-12s %s\n", "CLIENT", "MEDIA QUERIES", "INLINE REQUIRED", "STYLESHEET", "MESSAGE", "SHORT HEX")
+	for _, client := range config.KnownClients {
+		profile := config.GetCompatibilityProfile(client)
+		fmt.Printf("%-15s %-13s %-15s %-11s %-12s %s\n", client,
+			yesNo(profile.SupportsMediaQueries), yesNo(profile.RequiresInlineStyles),
+			sizeLimit(profile.MaxStylesheetSize), sizeLimit(profile.MaxMessageSize),
+			yesNo(profile.SupportsShortHex))
+	}
+	return nil
+}
+
+// yesNo formats a boolean for tables
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// sizeLimit formats a byte limit, where 0 means none
+func sizeLimit(bytes int) string {
+	if bytes == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("%d KB", bytes/1024)
+}
+
+// runStatsCommand inlines -input or stdin and reports statistics without
+// writing the HTML
+func runStatsCommand(args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+	_, inlinerEngine, done, err := setup()
+	if err != nil || done {
+		return err
+	}
+
+	content, filename, err := readInput()
+	if err != nil {
+		return err
+	}
+
+	result, err := inlineInput(context.Background(), inlinerEngine, content, *inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to inline CSS: %w", err)
+	}
+	outcome.addWarnings(result.Warnings)
+
+	if structuredReport() {
+		rep := newReport("inline", inlinerEngine)
+		rep.AddInlineResult(filename, result)
+		return writeReport(rep, false)
+	}
+
+	showProcessingStats(result, filename)
+	if *showWarnings && !*quiet {
+		showWarningsFunc(result.Warnings)
+	}
+	return nil
+}
+
+// readInput reads -input, or stdin without it, and returns its display name
+func readInput() (string, string, error) {
+	if *inputFile == "" {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+		return string(content), "<stdin>", nil
+	}
+
+	content, err := os.ReadFile(*inputFile)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read input file: %w", err)
+	}
+	return string(content), *inputFile, nil
+}
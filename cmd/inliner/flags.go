@@ -0,0 +1,221 @@
+package main
+
+import (
+	"flag"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Flag values. Each command registers the groups it uses on its own flag
+// set; unregistered flags keep their defaults.
+var (
+	// Input/Output flags
+	inputFile  = new(string)
+	outputFile = new(string)
+	inputDir   = new(string)
+	outputDir  = new(string)
+
+	// Configuration flags
+	configPath         = new(string)
+	profile            = new(string)
+	printConfig        = new(bool)
+	target             = new(string)
+	preserveMedia      = new(bool)
+	preservePseudo     = new(bool)
+	removeStyleTags    = new(bool)
+	stripUnused        = new(bool)
+	safelist           = new(string)
+	passthrough        = new(string)
+	emailOptimizations = new(bool)
+	preserveWhitespace = new(bool)
+	whitespace         = new(string)
+	indent             = new(string)
+	a11yFix            = new(bool)
+	defaultLang        = new(string)
+	darkMode           = new(bool)
+	colorScheme        = new(string)
+	loadSheets         = new(bool)
+	minify             = new(bool)
+	sizeTargets        = new(string)
+	ruleOverrides      = new(string)
+
+	// Output control flags
+	verbose      = new(bool)
+	quiet        = new(bool)
+	stats        = new(bool)
+	format       = new(string)
+	reportTo     = new(string)
+	showWarnings = new(bool)
+	failOn       = new(string)
+	maxWarnings  = new(int)
+
+	// Legacy mode flags
+	validate  = new(bool)
+	listRules = new(bool)
+
+	// Batch and performance flags
+	benchmark = new(bool)
+	cachePath = new(string)
+	jobs      = new(int)
+	inPlace   = new(bool)
+	includes  = new(patternList)
+	excludes  = new(patternList)
+	extList   = new(string)
+
+	// Diff flags
+	againstTarget  = new(string)
+	againstConfig  = new(string)
+	againstProfile = new(string)
+	colorMode      = new(string)
+
+	// Watch, serve and server flags
+	serveAddr      = new(string)
+	maxBody        = new(int64)
+	requestTimeout = new(time.Duration)
+	pollInterval   = new(time.Duration)
+	debounce       = new(time.Duration)
+)
+
+// inputPaths holds the files and directories given as arguments to inline
+var inputPaths []string
+
+// activeFlags is the flag set of the running command, for telling flags set
+// on the command line from defaults
+var activeFlags = flag.CommandLine
+
+// addInputFlags registers the single input and output flags
+func addInputFlags(fs *flag.FlagSet) {
+	fs.StringVar(inputFile, "input", "", "Input HTML file path (default: stdin)")
+	fs.StringVar(outputFile, "output", "", "Output HTML file path (default: stdout)")
+}
+
+// addBatchFlags registers the directory processing flags
+func addBatchFlags(fs *flag.FlagSet) {
+	fs.StringVar(inputDir, "input-dir", "", "Process all HTML files in directory")
+	fs.StringVar(outputDir, "output-dir", "", "Output directory for batch processing")
+	fs.IntVar(jobs, "jobs", runtime.GOMAXPROCS(0), "Number of files processed in parallel with -input-dir")
+}
+
+// addConfigFlags registers the flags that shape the inliner configuration
+func addConfigFlags(fs *flag.FlagSet) {
+	fs.StringVar(configPath, "config", "", "Config file (default: inliner.yaml, .yml, .json or .toml found upward from the input)")
+	fs.StringVar(profile, "profile", "", "Named profile from the config file")
+	fs.BoolVar(printConfig, "print-config", false, "Print the effective configuration as JSON and exit")
+	fs.StringVar(target, "target", "generic", "Target email client (outlook, gmail, apple_mail, outlook_online, generic)")
+	fs.BoolVar(preserveMedia, "preserve-media", true, "Preserve @media queries in <style> tags")
+	fs.BoolVar(preservePseudo, "preserve-pseudo", true, "Preserve pseudo-selectors (:hover, :focus, etc.)")
+	fs.BoolVar(removeStyleTags, "remove-style-tags", false, "Remove <style> tags after inlining")
+	fs.BoolVar(stripUnused, "strip-unused", true, "Remove CSS rules that don't match any elements")
+	fs.StringVar(safelist, "safelist", "", "Comma-separated selector patterns -strip-unused never removes, added to the built-in client hacks (/regexp/ allowed)")
+	fs.StringVar(passthrough, "passthrough-attr", "data-embed", "Attribute marking <style> tags to pass through untouched (empty disables)")
+	fs.BoolVar(emailOptimizations, "email-optimizations", true, "Apply email client optimizations")
+	fs.BoolVar(preserveWhitespace, "preserve-whitespace", true, "Preserve HTML formatting")
+	fs.StringVar(whitespace, "whitespace", "", "Output whitespace: preserve, pretty or compact (default: follows -preserve-whitespace)")
+	fs.StringVar(indent, "indent", "  ", "Indentation unit for -whitespace pretty")
+	fs.BoolVar(a11yFix, "a11y-fix", false, "Apply accessibility fixes (layout table roles, decorative image alt, lang)")
+	fs.StringVar(defaultLang, "lang", "", "Language added to <html lang> by -a11y-fix when missing")
+	fs.BoolVar(darkMode, "dark-mode", false, "Keep @media (prefers-color-scheme: dark) rules and add Outlook.com [data-ogsc]/[data-ogsb] variants")
+	fs.StringVar(colorScheme, "color-scheme", "", "Add color-scheme meta tags with this content when missing, e.g. \"light dark\"")
+	fs.BoolVar(loadSheets, "load-stylesheets", false, "Inline local <link rel=\"stylesheet\"> and @import files, resolved relative to each input")
+	fs.BoolVar(minify, "minify", false, "Collapse whitespace, strip comments and shorten CSS (keeps MSO conditionals and template tags)")
+	fs.StringVar(sizeTargets, "size-targets", "", "Comma-separated clients whose size limits are checked (default: -target and gmail)")
+	fs.StringVar(ruleOverrides, "rules", "", "Override lint rule severities, e.g. \"layout-table=off,css-float=error\"")
+}
+
+// addReportFlags registers the flags controlling findings, reports and exit codes
+func addReportFlags(fs *flag.FlagSet) {
+	fs.BoolVar(verbose, "verbose", false, "Verbose output with processing statistics")
+	fs.BoolVar(quiet, "quiet", false, "Suppress all output except errors")
+	fs.StringVar(format, "format", "text", "Report format for warnings, issues and statistics (text, json, sarif, junit)")
+	fs.StringVar(reportTo, "report", "", "Write the report to this file (default: stdout, or stderr when HTML goes to stdout)")
+	fs.BoolVar(showWarnings, "warnings", true, "Show compatibility warnings")
+	fs.StringVar(failOn, "fail-on", "error", "Exit non-zero when findings of this severity or higher occur (error, warning, info, none)")
+	fs.IntVar(maxWarnings, "max-warnings", -1, "Exit non-zero when more than N warnings occur (-1 disables)")
+}
+
+// addInlineFlags registers the flags only inlining uses
+func addInlineFlags(fs *flag.FlagSet) {
+	fs.BoolVar(stats, "stats", false, "Show processing statistics")
+	fs.BoolVar(benchmark, "benchmark", false, "Show processing time and performance metrics")
+	fs.StringVar(cachePath, "cache", "", "Cache manifest for incremental batch builds; unchanged inputs are skipped")
+	fs.BoolVar(inPlace, "in-place", false, "Replace each input with its inlined output (written atomically)")
+}
+
+// addFilterFlags registers the flags selecting files in input directories
+func addFilterFlags(fs *flag.FlagSet) {
+	fs.Var(includes, "include", "Only process files matching these globs, relative to the input directory (comma-separated or repeated; ** matches any directories)")
+	fs.Var(excludes, "exclude", "Skip files and directories matching these globs (comma-separated or repeated)")
+	fs.StringVar(extList, "ext", ".html,.htm", "Comma-separated extensions of the files processed in input directories")
+}
+
+// addDiffFlags registers the flags choosing what diff compares and how it
+// prints the result
+func addDiffFlags(fs *flag.FlagSet) {
+	fs.StringVar(againstTarget, "against-target", "", "Compare the output with the output for this target client")
+	fs.StringVar(againstConfig, "against-config", "", "Compare the output with the output using this config file")
+	fs.StringVar(againstProfile, "against-profile", "", "Compare the output with the output using this config file profile")
+	fs.StringVar(format, "format", "text", "Output format: text or json")
+	fs.StringVar(colorMode, "color", "auto", "Colorize text output: auto, always or never")
+}
+
+// addWatchFlags registers the change polling flags
+func addWatchFlags(fs *flag.FlagSet) {
+	fs.DurationVar(pollInterval, "poll-interval", 500*time.Millisecond, "How often inputs and stylesheets are checked for changes")
+	fs.DurationVar(debounce, "debounce", 200*time.Millisecond, "Quiet period to wait for after a change before rebuilding")
+}
+
+// addServerFlags registers the HTTP API flags
+func addServerFlags(fs *flag.FlagSet) {
+	fs.Int64Var(maxBody, "max-body", 5<<20, "Largest request body accepted, in bytes")
+	fs.DurationVar(requestTimeout, "request-timeout", 30*time.Second, "Time limit for each request")
+}
+
+// addAddrFlag registers the listen address
+func addAddrFlag(fs *flag.FlagSet) {
+	fs.StringVar(serveAddr, "addr", "localhost:8080", "Address to listen on")
+}
+
+// addLegacyFlags registers every flag of the flag-only invocation
+func addLegacyFlags(fs *flag.FlagSet) {
+	addInputFlags(fs)
+	addBatchFlags(fs)
+	addConfigFlags(fs)
+	addReportFlags(fs)
+	addInlineFlags(fs)
+	addFilterFlags(fs)
+	fs.BoolVar(validate, "validate", false, "Validate HTML for email compatibility (no inlining)")
+	fs.BoolVar(listRules, "list-rules", false, "List available lint rules and exit")
+}
+
+// init gives every flag its default, since registering a flag sets it and
+// commands only register the flags they use
+func init() {
+	defaults := flag.NewFlagSet("defaults", flag.ContinueOnError)
+	addLegacyFlags(defaults)
+	addWatchFlags(defaults)
+	addServerFlags(defaults)
+	addAddrFlag(defaults)
+	// diff registers -format with its own help text
+	addDiffFlags(flag.NewFlagSet("defaults", flag.ContinueOnError))
+}
+
+// patternList is a flag holding glob patterns, given comma-separated or by
+// repeating the flag
+type patternList []string
+
+func (p *patternList) String() string {
+	if p == nil {
+		return ""
+	}
+	return strings.Join(*p, ",")
+}
+
+func (p *patternList) Set(value string) error {
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			*p = append(*p, pattern)
+		}
+	}
+	return nil
+}
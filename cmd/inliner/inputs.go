@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"inliner/internal/config"
+)
+
+// ignoreFileName is read from the top of each input directory. It holds one
+// glob per line in the style of .gitignore: "#" starts a comment, "!"
+// re-includes, a trailing "/" matches only directories and a leading "/"
+// anchors a pattern without other slashes to the directory itself.
+const ignoreFileName = ".inlinerignore"
+
+// ignoreRule is one pattern of an ignore file
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// inputRoots holds the directories batch inputs were collected from, so
+// relativeInput can name outputs after the input's place in its directory
+var inputRoots []string
+
+// collectInputs returns the input files under paths. Directories are walked
+// in lexical order for files with an -ext extension that pass -include,
+// -exclude and the directory's ignore file; files named directly are always
+// included.
+func collectInputs(paths []string) ([]string, error) {
+	exts := extensions()
+	var files, roots []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			add(root)
+			continue
+		}
+		roots = append(roots, root)
+
+		ignore, err := loadIgnoreFile(filepath.Join(root, ignoreFileName))
+		if err != nil {
+			return nil, err
+		}
+
+		err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == root {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+
+			if entry.IsDir() {
+				if ignored(ignore, rel, true) || matchesAny(*excludes, rel) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			switch {
+			case !slices.Contains(exts, strings.ToLower(filepath.Ext(path))):
+			case ignored(ignore, rel, false), matchesAny(*excludes, rel):
+			case len(*includes) > 0 && !matchesAny(*includes, rel):
+			default:
+				add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	inputRoots = roots
+	return files, nil
+}
+
+// extensions returns the lower-cased -ext extensions with their leading dot
+func extensions() []string {
+	var exts []string
+	for _, ext := range strings.Split(*extList, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts = append(exts, ext)
+	}
+	return exts
+}
+
+// matchesAny reports whether rel matches one of the patterns
+func matchesAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if config.MatchPath(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// validGlobs checks that patterns parse, since matching treats malformed
+// patterns as never matching
+func validGlobs(flagName string, patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return fmt.Errorf("invalid -%s pattern %q: %w", flagName, pattern, err)
+		}
+	}
+	return nil
+}
+
+// loadIgnoreFile reads an ignore file; a missing file has no rules
+func loadIgnoreFile(filename string) ([]ignoreRule, error) {
+	file, err := os.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if rule.negate = strings.HasPrefix(text, "!"); rule.negate {
+			text = text[1:]
+		}
+		if rule.dirOnly = strings.HasSuffix(text, "/"); rule.dirOnly {
+			text = strings.TrimSuffix(text, "/")
+		}
+		if rule.anchored = strings.HasPrefix(text, "/"); rule.anchored {
+			text = strings.TrimPrefix(text, "/")
+		}
+		if _, err := path.Match(strings.ReplaceAll(text, "**", "*"), ""); text == "" || err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid pattern %q", filename, line, scanner.Text())
+		}
+		rule.pattern = text
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	return rules, nil
+}
+
+// ignored reports whether the rules exclude a path; the last matching rule
+// wins
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	result := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		var match bool
+		if rule.anchored {
+			match = config.MatchGlob(rule.pattern, rel)
+		} else {
+			match = config.MatchPath(rule.pattern, rel)
+		}
+		if match {
+			result = !rule.negate
+		}
+	}
+	return result
+}
+
+// writeFileAtomic writes data to a temporary file next to filename and
+// renames it into place, so an interrupted run never leaves a partial file.
+// An existing file keeps its permissions.
+func writeFileAtomic(filename string, data []byte) error {
+	perm := fs.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// inputFiles is a template directory with partials, drafts and other files
+var inputFiles = map[string]string{
+	"src/top.html":                 "",
+	"src/welcome.HTML":             "",
+	"src/notes.txt":                "",
+	"src/page.htm":                 "",
+	"src/header.partial.html":      "",
+	"src/keep.partial.html":        "",
+	"src/emails/top.html":          "",
+	"src/emails/promo.html":        "",
+	"src/emails/drafts/wip.html":   "",
+	"src/emails/archive/old.html":  "",
+	"src/emails/archive/2020.html": "",
+	"src/drafts.html":              "",
+}
+
+// collected runs collectInputs over src with the given filters and returns
+// the inputs relative to it
+func collected(t *testing.T, dir string, include, exclude []string, ext string) []string {
+	t.Helper()
+	setFlag(t, includes, patternList(include))
+	setFlag(t, excludes, patternList(exclude))
+	setFlag(t, extList, ext)
+	setFlag(t, &inputRoots, nil)
+
+	files, err := collectInputs([]string{filepath.Join(dir, "src")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rels []string
+	for _, file := range files {
+		rels = append(rels, filepath.ToSlash(relativeInput(file)))
+	}
+	return rels
+}
+
+func TestCollectInputsFilters(t *testing.T) {
+	dir := writeTree(t, inputFiles)
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		ext     string
+		want    []string
+	}{
+		{
+			name: "extensions",
+			ext:  ".html,htm",
+			want: []string{"drafts.html", "emails/archive/2020.html", "emails/archive/old.html", "emails/drafts/wip.html",
+				"emails/promo.html", "emails/top.html", "header.partial.html", "keep.partial.html", "page.htm", "top.html", "welcome.HTML"},
+		},
+		{
+			name:    "include by directory",
+			include: []string{"emails/**"},
+			ext:     ".html",
+			want:    []string{"emails/archive/2020.html", "emails/archive/old.html", "emails/drafts/wip.html", "emails/promo.html", "emails/top.html"},
+		},
+		{
+			name:    "include by name at any depth",
+			include: []string{"top.html"},
+			ext:     ".html",
+			want:    []string{"emails/top.html", "top.html"},
+		},
+		{
+			name:    "exclude directories and names",
+			exclude: []string{"archive", "*.partial.html"},
+			ext:     ".html",
+			want:    []string{"drafts.html", "emails/drafts/wip.html", "emails/promo.html", "emails/top.html", "top.html", "welcome.HTML"},
+		},
+		{
+			name:    "exclude wins over include",
+			include: []string{"emails/*.html"},
+			exclude: []string{"promo.html"},
+			ext:     ".html",
+			want:    []string{"emails/top.html"},
+		},
+	}
+
+	for _, test := range tests {
+		if got := collected(t, dir, test.include, test.exclude, test.ext); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s:\ngot  %q\nwant %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCollectInputsIgnoreFile(t *testing.T) {
+	files := map[string]string{
+		"src/" + ignoreFileName: `# Partials are included by other templates
+*.partial.html
+!keep.partial.html
+
+# Only the drafts directory, not drafts.html
+drafts/
+/top.html
+emails/archive/**
+!emails/archive/2020.html
+`,
+	}
+	for name, content := range inputFiles {
+		files[name] = content
+	}
+	dir := writeTree(t, files)
+
+	got := collected(t, dir, nil, nil, ".html")
+	want := []string{"drafts.html", "emails/promo.html", "emails/top.html", "keep.partial.html", "welcome.HTML"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestCollectInputsNamedFilesAlwaysIncluded(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"src/" + ignoreFileName: "*.html\n",
+		"src/a.html":            "",
+		"other.txt":             "",
+	})
+	setFlag(t, excludes, patternList{"*"})
+	setFlag(t, extList, ".html")
+	setFlag(t, &inputRoots, nil)
+
+	named := []string{filepath.Join(dir, "src", "a.html"), filepath.Join(dir, "other.txt"), filepath.Join(dir, "src", "a.html")}
+	files, err := collectInputs(named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := named[:2]; !reflect.DeepEqual(files, want) {
+		t.Errorf("collectInputs = %q, want %q", files, want)
+	}
+}
+
+func TestLoadIgnoreFileErrors(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"bad":   "ok.html\n\n[unclosed\n",
+		"empty": "!\n",
+	})
+
+	_, err := loadIgnoreFile(filepath.Join(dir, "bad"))
+	if err == nil || !strings.Contains(err.Error(), `:3: invalid pattern "[unclosed"`) {
+		t.Errorf("bad pattern: error = %v", err)
+	}
+	if _, err := loadIgnoreFile(filepath.Join(dir, "empty")); err == nil {
+		t.Error("empty negated pattern: no error")
+	}
+	if rules, err := loadIgnoreFile(filepath.Join(dir, "missing")); rules != nil || err != nil {
+		t.Errorf("missing file = %v, %v; want no rules", rules, err)
+	}
+}
+
+// tempFiles returns the temporary files writeFileAtomic left in dir
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.tmp*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.html")
+
+	if err := writeFileAtomic(path, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0644 {
+		t.Errorf("new file mode = %v, want 0644", info.Mode().Perm())
+	}
+
+	// Replacing a file keeps its permissions
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	info, _ := os.Stat(path)
+	if string(data) != "second" || info.Mode().Perm() != 0600 {
+		t.Errorf("replaced file = %q mode %v, want %q mode 0600", data, info.Mode().Perm(), "second")
+	}
+	if tmp := tempFiles(t, dir); len(tmp) != 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+}
+
+func TestWriteFileAtomicFailureLeavesTarget(t *testing.T) {
+	dir := writeTree(t, map[string]string{"target/existing.html": "kept"})
+
+	// Renaming over a non-empty directory fails after the data is written
+	target := filepath.Join(dir, "target")
+	if err := writeFileAtomic(target, []byte("new")); err == nil {
+		t.Fatal("writing over a directory succeeded")
+	}
+	if data, err := os.ReadFile(filepath.Join(target, "existing.html")); err != nil || string(data) != "kept" {
+		t.Errorf("target changed: %q, %v", data, err)
+	}
+	if tmp := tempFiles(t, dir); len(tmp) != 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "out.html"), []byte("new")); err == nil {
+		t.Error("writing into a missing directory succeeded")
+	}
+}
+
+func TestInPlace(t *testing.T) {
+	page := `<html><head><style>p { color: red }</style></head><body><p>Hi</p></body></html>`
+	dir := writeTree(t, map[string]string{
+		"src/a.html":         page,
+		"src/sub/b.html":     page,
+		"src/style.css":      `p { color: blue }`,
+		"src/skip.html":      page,
+		"src/notes.txt":      page,
+		"src/.inlinerignore": "skip.html\n",
+	})
+	if err := os.Chmod(filepath.Join(dir, "src", "sub", "b.html"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	result := runCLI(t, dir, "", "inline", "-in-place", "src")
+	if result.code != exitOK {
+		t.Fatalf("inline -in-place exited %d: %s", result.code, result.stderr)
+	}
+
+	for _, name := range []string{"a.html", "sub/b.html"} {
+		data, _ := os.ReadFile(filepath.Join(dir, "src", name))
+		if !strings.Contains(string(data), `<p style="color: red">`) {
+			t.Errorf("%s was not inlined in place:\n%s", name, data)
+		}
+	}
+	for _, name := range []string{"skip.html", "notes.txt"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, "src", name)); string(data) != page {
+			t.Errorf("%s was modified:\n%s", name, data)
+		}
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "src", "sub", "b.html")); info.Mode().Perm() != 0600 {
+		t.Errorf("sub/b.html mode = %v, want 0600 kept", info.Mode().Perm())
+	}
+	for _, sub := range []string{"src", "src/sub"} {
+		if tmp := tempFiles(t, filepath.Join(dir, sub)); len(tmp) != 0 {
+			t.Errorf("temporary files left behind: %v", tmp)
+		}
+	}
+
+	// In-place output can't be combined with an output directory
+	if result := runCLI(t, dir, "", "inline", "-in-place", "-output-dir", "out", "src"); result.code != exitUsage {
+		t.Errorf("-in-place with -output-dir exited %d, want %d", result.code, exitUsage)
+	}
+}
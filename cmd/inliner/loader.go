@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// fileLoader loads the local stylesheets linked from inputs for
+// -load-stylesheets. Remote stylesheets are left linked.
+type fileLoader struct{}
+
+// Load reads a stylesheet by path or file:// URL
+func (fileLoader) Load(ctx context.Context, href string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	path, err := localPath(href)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// localPath returns the file a stylesheet href refers to, refusing remote URLs
+func localPath(href string) (string, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "" && u.Scheme != "file" {
+		return "", fmt.Errorf("remote stylesheets are not loaded: %s", href)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
@@ -1,131 +1,189 @@
 package main
 
 import (
-	"flag"
+	"context"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
-	"time"
 
 	"inliner/internal/config"
 	"inliner/internal/inliner"
+	"inliner/internal/report"
+	"inliner/internal/rules"
 )
 
-var (
-	// Input/Output flags
-	inputFile  = flag.String("input", "", "Input HTML file path")
-	outputFile = flag.String("output", "", "Output HTML file path (default: stdout)")
-	inputDir   = flag.String("input-dir", "", "Process all HTML files in directory")
-	outputDir  = flag.String("output-dir", "", "Output directory for batch processing")
-
-	// Configuration flags
-	target             = flag.String("target", "generic", "Target email client (outlook, gmail, apple_mail, generic)")
-	preserveMedia      = flag.Bool("preserve-media", true, "Preserve @media queries in <style> tags")
-	preservePseudo     = flag.Bool("preserve-pseudo", true, "Preserve pseudo-selectors (:hover, :focus, etc.)")
-	removeStyleTags    = flag.Bool("remove-style-tags", false, "Remove <style> tags after inlining")
-	stripUnused        = flag.Bool("strip-unused", true, "Remove CSS rules that don't match any elements")
-	emailOptimizations = flag.Bool("email-optimizations", true, "Apply email client optimizations")
-	preserveWhitespace = flag.Bool("preserve-whitespace", true, "Preserve HTML formatting")
-
-	// Output control flags
-	verbose = flag.Bool("verbose", false, "Verbose output with processing statistics")
-	quiet   = flag.Bool("quiet", false, "Suppress all output except errors")
-	stats   = flag.Bool("stats", false, "Show processing statistics")
-
-	// Validation flags
-	validate     = flag.Bool("validate", false, "Validate HTML for email compatibility (no inlining)")
-	showWarnings = flag.Bool("warnings", true, "Show compatibility warnings")
-
-	// Performance flags
-	benchmark = flag.Bool("benchmark", false, "Show processing time and performance metrics")
-)
+// outcome collects findings and failures from the selected processing mode
+var outcome runOutcome
 
 func main() {
-	flag.Parse()
+	os.Exit(dispatch(os.Args[1:]))
+}
 
-	// Validate command line arguments
-	if err := validateArgs(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		flag.Usage()
-		os.Exit(1)
+// runLegacy runs the deprecated flag-only invocation, routing on -validate,
+// -list-rules, -input-dir and -input as before subcommands existed
+func runLegacy(args []string) error {
+	if !*quiet && !structuredReport() {
+		fmt.Fprintln(os.Stderr, "Warning: running inliner without a command is deprecated; use \"inliner inline\", \"inliner validate\" or \"inliner explain\"")
 	}
 
-	// Create configuration from flags
-	cfg := buildConfig()
+	switch {
+	case *listRules:
+		return runExplain(args)
+	case *validate:
+		return runValidateCommand(args)
+	default:
+		return runInline(args)
+	}
+}
 
-	// Create inliner instance
-	inlinerEngine := inliner.New(cfg)
+// runInline inlines -input, stdin, every file under -input-dir, or the files
+// and directories given as arguments. A single file argument behaves like
+// -input unless -output-dir or -in-place is given.
+func runInline(args []string) error {
+	if len(args) > 0 && (*inputFile != "" || *inputDir != "") {
+		return usageErrorf("input paths cannot be combined with -input or -input-dir")
+	}
+	switch {
+	case *inputDir != "":
+		args = []string{*inputDir}
+	case *inputFile != "" && *inPlace:
+		args = []string{*inputFile}
+	}
+	if len(args) == 1 && *outputDir == "" && !*inPlace {
+		if info, err := os.Stat(args[0]); err == nil && !info.IsDir() {
+			*inputFile, args = args[0], nil
+		}
+	}
 
-	var err error
-	startTime := time.Now()
+	if len(args) > 0 && *outputDir == "" && !*inPlace {
+		return usageErrorf("-output-dir or -in-place required when processing a directory or several files")
+	}
+	if len(args) == 0 && (*cachePath != "" || *inPlace) {
+		return usageErrorf("-cache and -in-place need input files (-input-dir or input paths)")
+	}
+	inputPaths = args
+
+	source, inlinerEngine, done, err := setup()
+	if err != nil || done {
+		return err
+	}
 
-	// Route to appropriate processing mode
 	switch {
-	case *validate:
-		err = runValidation(inlinerEngine)
-	case *inputDir != "":
-		err = runBatchProcessing(inlinerEngine)
+	case len(inputPaths) > 0:
+		return runBatchProcessing(inlinerEngine, source)
 	case *inputFile != "":
-		err = runSingleFile(inlinerEngine)
+		return runSingleFile(inlinerEngine)
 	default:
-		err = runStdin(inlinerEngine)
+		return runStdin(inlinerEngine)
 	}
+}
 
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+// runValidateCommand checks -input or stdin without inlining
+func runValidateCommand(args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected arguments: %s", strings.Join(args, " "))
 	}
-
-	// Show performance metrics if requested
-	if *benchmark {
-		duration := time.Since(startTime)
-		fmt.Fprintf(os.Stderr, "Processing completed in %v\n", duration)
+	_, inlinerEngine, done, err := setup()
+	if err != nil || done {
+		return err
 	}
+	return runValidation(inlinerEngine)
 }
 
-// validateArgs validates command line arguments
-func validateArgs() error {
+// validateArgs checks the flags of a command; the legacy invocation has an
+// empty name
+func validateArgs(command string) error {
 	if *inputFile != "" && *inputDir != "" {
 		return fmt.Errorf("cannot specify both -input and -input-dir")
 	}
 
-	if *inputDir != "" && *outputDir == "" {
+	switch command {
+	case "serve":
+		if *inputDir == "" {
+			return fmt.Errorf("serve requires -input-dir")
+		}
+	case "watch":
+		if *inputDir == "" {
+			return fmt.Errorf("watch requires -input-dir")
+		}
+	case "server":
+		// Request HTML is untrusted; loading its links would read server files
+		if *loadSheets {
+			return fmt.Errorf("-load-stylesheets is not available to server, since requests could read any file on the server")
+		}
+	}
+
+	if *inputDir != "" && *outputDir == "" && !*inPlace && command != "serve" {
 		return fmt.Errorf("-output-dir required when using -input-dir")
 	}
 
+	if *inPlace && (*outputDir != "" || *outputFile != "") {
+		return fmt.Errorf("-in-place cannot be combined with -output or -output-dir")
+	}
+
+	if *inPlace && *cachePath != "" {
+		return fmt.Errorf("-in-place cannot be combined with -cache, since outputs replace the inputs it hashes")
+	}
+
+	if len(extensions()) == 0 {
+		return fmt.Errorf("-ext needs at least one extension")
+	}
+
+	if err := validGlobs("include", *includes); err != nil {
+		return err
+	}
+	if err := validGlobs("exclude", *excludes); err != nil {
+		return err
+	}
+
+	if *jobs < 1 {
+		return fmt.Errorf("-jobs must be at least 1")
+	}
+
+	if *pollInterval <= 0 || *debounce < 0 {
+		return fmt.Errorf("-poll-interval must be positive and -debounce non-negative")
+	}
+
+	if *maxBody <= 0 || *requestTimeout <= 0 {
+		return fmt.Errorf("-max-body and -request-timeout must be positive")
+	}
+
 	if *quiet && *verbose {
 		return fmt.Errorf("cannot specify both -quiet and -verbose")
 	}
 
-	// Validate target email client
-	validTargets := []string{"outlook", "gmail", "apple_mail", "outlook_online", "generic"}
-	targetValid := false
-	for _, valid := range validTargets {
-		if *target == valid {
-			targetValid = true
-			break
-		}
+	switch *whitespace {
+	case "", config.OutputPreserve, config.OutputPretty, config.OutputCompact:
+	default:
+		return fmt.Errorf("invalid -whitespace %q (want preserve, pretty or compact)", *whitespace)
 	}
-	if !targetValid {
-		return fmt.Errorf("invalid target client: %s (valid: %s)", *target, strings.Join(validTargets, ", "))
+
+	reportFormat, err := report.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	// diff writes its own text or JSON rather than a report
+	if command == "diff" && reportFormat != report.FormatText && reportFormat != report.FormatJSON {
+		return fmt.Errorf("diff supports -format text or json, not %q", *format)
 	}
 
-	return nil
-}
+	if err := validateFailOn(*failOn); err != nil {
+		return err
+	}
 
-// buildConfig creates configuration from command line flags
-func buildConfig() config.Config {
-	return config.Config{
-		PreserveMediaQueries:     *preserveMedia,
-		PreservePseudoSelectors:  *preservePseudo,
-		RemoveStyleTags:          *removeStyleTags,
-		StripUnusedCSS:           *stripUnused,
-		EmailClientOptimizations: *emailOptimizations,
-		PreserveWhitespace:       *preserveWhitespace,
-		TargetEmailClient:        *target,
+	if _, err := rules.ParseOverrides(*ruleOverrides); err != nil {
+		return err
+	}
+
+	// Validate target email client
+	if !slices.Contains(config.KnownClients, *target) {
+		return fmt.Errorf("invalid target client: %s (valid: %s)", *target, strings.Join(config.KnownClients, ", "))
 	}
+
+	return nil
 }
 
 // runSingleFile processes a single input file
@@ -137,7 +195,7 @@ func runSingleFile(inlinerEngine *inliner.Inliner) error {
 	}
 
 	// Process the HTML
-	result, err := inlinerEngine.Inline(string(inputContent))
+	result, err := inlineInput(context.Background(), inlinerEngine, string(inputContent), *inputFile)
 	if err != nil {
 		return fmt.Errorf("failed to inline CSS: %w", err)
 	}
@@ -147,6 +205,14 @@ func runSingleFile(inlinerEngine *inliner.Inliner) error {
 		return fmt.Errorf("failed to write output: %w", err)
 	}
 
+	outcome.addWarnings(result.Warnings)
+
+	if structuredReport() {
+		rep := newReport("inline", inlinerEngine)
+		rep.AddInlineResult(*inputFile, result)
+		return writeReport(rep, *outputFile == "")
+	}
+
 	// Show statistics if requested
 	if *stats || *verbose {
 		showProcessingStats(result, *inputFile)
@@ -157,86 +223,8 @@ func runSingleFile(inlinerEngine *inliner.Inliner) error {
 		showWarningsFunc(result.Warnings)
 	}
 
-	return nil
-}
-
-// runBatchProcessing processes all HTML files in a directory
-func runBatchProcessing(inlinerEngine *inliner.Inliner) error {
-	// Find all HTML files in input directory
-	htmlFiles, err := findHTMLFiles(*inputDir)
-	if err != nil {
-		return fmt.Errorf("failed to find HTML files: %w", err)
-	}
-
-	if len(htmlFiles) == 0 {
-		return fmt.Errorf("no HTML files found in directory: %s", *inputDir)
-	}
-
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(*outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
-	}
-
-	// Process each file
-	var totalStats inliner.ProcessingStats
-	var totalWarnings []inliner.ValidationWarning
-
-	for i, inputPath := range htmlFiles {
-		if *verbose {
-			fmt.Fprintf(os.Stderr, "Processing %d/%d: %s\n", i+1, len(htmlFiles), inputPath)
-		}
-
-		// Read input file
-		inputContent, err := os.ReadFile(inputPath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to read %s: %v\n", inputPath, err)
-			continue
-		}
-
-		// Process the HTML
-		result, err := inlinerEngine.Inline(string(inputContent))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to process %s: %v\n", inputPath, err)
-			continue
-		}
-
-		// Generate output path
-		relPath, _ := filepath.Rel(*inputDir, inputPath)
-		outputPath := filepath.Join(*outputDir, relPath)
-
-		// Create output subdirectory if needed
-		outputSubdir := filepath.Dir(outputPath)
-		if err := os.MkdirAll(outputSubdir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to create output directory %s: %v\n", outputSubdir, err)
-			continue
-		}
-
-		// Write output file
-		if err := writeOutput(result.HTML, outputPath); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to write %s: %v\n", outputPath, err)
-			continue
-		}
-
-		// Accumulate statistics
-		totalStats.CSSRulesParsed += result.ProcessingStats.CSSRulesParsed
-		totalStats.HTMLElementsProcessed += result.ProcessingStats.HTMLElementsProcessed
-		totalStats.SelectorsMatched += result.ProcessingStats.SelectorsMatched
-		totalStats.ProcessingTimeMs += result.ProcessingStats.ProcessingTimeMs
-		totalWarnings = append(totalWarnings, result.Warnings...)
-	}
-
-	// Show batch statistics
-	if *stats || *verbose {
-		fmt.Fprintf(os.Stderr, "\nBatch Processing Summary:\n")
-		fmt.Fprintf(os.Stderr, "Files processed: %d\n", len(htmlFiles))
-		fmt.Fprintf(os.Stderr, "CSS rules parsed: %d\n", totalStats.CSSRulesParsed)
-		fmt.Fprintf(os.Stderr, "HTML elements processed: %d\n", totalStats.HTMLElementsProcessed)
-		fmt.Fprintf(os.Stderr, "Selectors matched: %d\n", totalStats.SelectorsMatched)
-		fmt.Fprintf(os.Stderr, "Total processing time: %dms\n", totalStats.ProcessingTimeMs)
-
-		if len(totalWarnings) > 0 {
-			fmt.Fprintf(os.Stderr, "Total warnings: %d\n", len(totalWarnings))
-		}
+	if !*quiet {
+		showFixes(result.Fixes)
 	}
 
 	return nil
@@ -251,7 +239,7 @@ func runStdin(inlinerEngine *inliner.Inliner) error {
 	}
 
 	// Process the HTML
-	result, err := inlinerEngine.Inline(string(inputContent))
+	result, err := inlineInput(context.Background(), inlinerEngine, string(inputContent), "")
 	if err != nil {
 		return fmt.Errorf("failed to inline CSS: %w", err)
 	}
@@ -261,11 +249,23 @@ func runStdin(inlinerEngine *inliner.Inliner) error {
 		return fmt.Errorf("failed to write output: %w", err)
 	}
 
+	outcome.addWarnings(result.Warnings)
+
+	if structuredReport() {
+		rep := newReport("inline", inlinerEngine)
+		rep.AddInlineResult("<stdin>", result)
+		return writeReport(rep, *outputFile == "")
+	}
+
 	// Show statistics to stderr if requested (so they don't interfere with HTML output)
 	if *stats || *verbose {
 		showProcessingStats(result, "<stdin>")
 	}
 
+	if !*quiet {
+		showFixes(result.Fixes)
+	}
+
 	return nil
 }
 
@@ -293,6 +293,14 @@ func runValidation(inlinerEngine *inliner.Inliner) error {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
+	outcome.addIssues(issues)
+
+	if structuredReport() {
+		rep := newReport("validate", inlinerEngine)
+		rep.AddValidation(filename, issues)
+		return writeReport(rep, false)
+	}
+
 	// Show validation results
 	if len(issues) == 0 {
 		if !*quiet {
@@ -306,44 +314,100 @@ func runValidation(inlinerEngine *inliner.Inliner) error {
 			if issue.Property != "" {
 				fmt.Printf("         Property: %s\n", issue.Property)
 			}
+			if issue.Reference != "" {
+				fmt.Printf("         Reference: %s\n", issue.Reference)
+			}
 		}
 	}
 
 	return nil
 }
 
-// writeOutput writes content to a file or stdout
-func writeOutput(content, filename string) error {
-	if filename == "" {
-		// Write to stdout
-		_, err := fmt.Print(content)
-		return err
+// newReport creates a report carrying the engine's rule documentation
+func newReport(mode string, inlinerEngine *inliner.Inliner) *report.Report {
+	rep := report.New(mode)
+	rep.RuleDocs = make(map[string]report.RuleDoc)
+	for _, rule := range inlinerEngine.Rules().Rules() {
+		rep.RuleDocs[rule.ID] = report.RuleDoc{
+			Description: rule.Description,
+			Docs:        rule.Docs,
+			Severity:    rule.DefaultSeverity,
+			Category:    rule.Category,
+			Reference:   rule.Reference,
+		}
 	}
+	return rep
+}
 
-	// Write to file
-	return os.WriteFile(filename, []byte(content), 0644)
+// printRules lists lint rules with their defaults and options
+func printRules(registry *rules.Registry) {
+	for _, rule := range registry.Rules() {
+		fmt.Printf("%-26s %-8s %-10s %s\n", rule.ID, rule.DefaultSeverity, rule.Category, rule.Description)
+		for _, name := range sortedKeys(rule.Options) {
+			fmt.Printf("%-26s option %s (default %s)\n", "", name, rule.Options[name])
+		}
+	}
+}
+
+// sortedKeys returns the keys of a string map in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-// findHTMLFiles finds all HTML files in a directory
-func findHTMLFiles(dir string) ([]string, error) {
-	var htmlFiles []string
+// structuredReport reports whether a machine-readable format was requested
+func structuredReport() bool {
+	f, _ := report.ParseFormat(*format)
+	return f != report.FormatText
+}
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+// writeReport serializes a report in the requested format. Reports go to the
+// -report file when given, otherwise to stdout unless stdout carries the HTML.
+func writeReport(rep *report.Report, htmlOnStdout bool) error {
+	f, err := report.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
 
-		if !info.IsDir() {
-			ext := strings.ToLower(filepath.Ext(path))
-			if ext == ".html" || ext == ".htm" {
-				htmlFiles = append(htmlFiles, path)
-			}
+	if *reportTo == "" {
+		var w io.Writer = os.Stdout
+		if htmlOnStdout {
+			w = os.Stderr
+		}
+		if err := report.Write(w, rep, f); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
 		}
-
 		return nil
-	})
+	}
+
+	file, err := os.Create(*reportTo)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	err = report.Write(file, rep, f)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
 
-	return htmlFiles, err
+// writeOutput writes content to a file or stdout
+func writeOutput(content, filename string) error {
+	if filename == "" {
+		// Write to stdout
+		_, err := fmt.Print(content)
+		return err
+	}
+
+	// Write to file
+	return writeFileAtomic(filename, []byte(content))
 }
 
 // showProcessingStats displays processing statistics
@@ -352,9 +416,25 @@ func showProcessingStats(result *inliner.InlineResult, filename string) {
 	fmt.Fprintf(os.Stderr, "  Inlined styles: %d\n", result.InlinedStyles)
 	fmt.Fprintf(os.Stderr, "  Preserved rules: %d\n", result.PreservedRules)
 	fmt.Fprintf(os.Stderr, "  CSS rules parsed: %d\n", result.ProcessingStats.CSSRulesParsed)
+	fmt.Fprintf(os.Stderr, "  Unused CSS rules removed: %d\n", result.ProcessingStats.CSSRulesRemoved)
 	fmt.Fprintf(os.Stderr, "  HTML elements processed: %d\n", result.ProcessingStats.HTMLElementsProcessed)
 	fmt.Fprintf(os.Stderr, "  Selectors matched: %d\n", result.ProcessingStats.SelectorsMatched)
 	fmt.Fprintf(os.Stderr, "  Processing time: %dms\n", result.ProcessingStats.ProcessingTimeMs)
+
+	size := result.Size
+	fmt.Fprintf(os.Stderr, "  Output size: %d bytes (<style>: %d bytes)\n", size.TotalBytes, size.StyleBytes)
+	fmt.Fprintf(os.Stderr, "    Inline styles: %d bytes (%d duplicated)\n", size.Breakdown.InlineStyles, size.Breakdown.DuplicateInlineStyles)
+	fmt.Fprintf(os.Stderr, "    Preserved CSS: %d bytes\n", size.Breakdown.PreservedCSS)
+	fmt.Fprintf(os.Stderr, "    Comments: %d bytes (+%d conditional)\n", size.Breakdown.Comments, size.Breakdown.ConditionalComments)
+	fmt.Fprintf(os.Stderr, "    Collapsible whitespace: %d bytes\n", size.Breakdown.Whitespace)
+	fmt.Fprintf(os.Stderr, "    Data URIs: %d bytes\n", size.Breakdown.DataURIs)
+	for _, limit := range size.Limits {
+		status := "ok"
+		if limit.Exceeded {
+			status = "EXCEEDED"
+		}
+		fmt.Fprintf(os.Stderr, "    %s %s limit: %d/%d bytes (%s)\n", limit.Client, limit.Kind, limit.Actual, limit.Limit, status)
+	}
 }
 
 // showWarningsFunc displays compatibility warnings
@@ -369,3 +449,19 @@ func showWarningsFunc(warnings []inliner.ValidationWarning) {
 		fmt.Fprintf(os.Stderr, "  [%s] %s: %s (%s)\n", severity, warning.Property, warning.Message, warning.Value)
 	}
 }
+
+// showFixes lists changes made by autofix passes so they can be reviewed
+func showFixes(fixes []inliner.Fix) {
+	if len(fixes) == 0 {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "\nApplied Fixes:\n")
+	for _, fix := range fixes {
+		location := ""
+		if fix.Line > 0 {
+			location = fmt.Sprintf(" (line %d)", fix.Line)
+		}
+		fmt.Fprintf(os.Stderr, "  [%s] %s%s: %s\n", fix.Rule, fix.Element, location, fix.Description)
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"inliner/internal/config"
+	"inliner/internal/inliner"
+)
+
+// previewServer renders the templates in -input-dir on request so designers
+// can review the inlined output per client, reloading pages on save
+type previewServer struct {
+	source  *configSource
+	watcher *watcher
+
+	mu          sync.Mutex
+	subscribers map[chan []string]bool
+}
+
+// runServe starts the preview server and blocks until interrupted
+func runServe(source *configSource) error {
+	s := &previewServer{
+		source:      source,
+		watcher:     newWatcher(source),
+		subscribers: make(map[chan []string]bool),
+	}
+
+	// Record the current stamps so only later changes trigger reloads
+	if _, _, err := s.watcher.scan(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	server := &http.Server{
+		Addr:              *serveAddr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go s.poll(ctx)
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Previewing %s at http://%s/ (Ctrl-C to stop)\n", *inputDir, *serveAddr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// handler routes the preview pages
+func (s *previewServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleIndex)
+	mux.HandleFunc("GET /view/{path...}", s.handleView)
+	mux.HandleFunc("GET /render/{path...}", s.handleRender)
+	mux.HandleFunc("GET /events", s.handleEvents)
+	return mux
+}
+
+// poll notifies subscribers of the templates affected by each change
+func (s *previewServer) poll(ctx context.Context) {
+	ticker := time.NewTicker(*pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		rebuild, removed, err := s.watcher.scan()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		if len(rebuild) == 0 && len(removed) == 0 {
+			continue
+		}
+
+		var changed []string
+		for _, path := range mergePaths(rebuild, removed) {
+			changed = append(changed, filepath.ToSlash(relativeInput(path)))
+		}
+		s.broadcast(changed)
+	}
+}
+
+// broadcast sends changed template paths to every live page
+func (s *previewServer) broadcast(changed []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for subscriber := range s.subscribers {
+		select {
+		case subscriber <- changed:
+		default: // The page is already due to reload
+		}
+	}
+}
+
+// handleEvents streams changes to a page as server-sent events
+func (s *previewServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events := make(chan []string, 1)
+	s.mu.Lock()
+	s.subscribers[events] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, events)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case changed := <-events:
+			data, _ := json.Marshal(changed)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+// templates returns the paths of the templates relative to -input-dir
+func (s *previewServer) templates() ([]string, error) {
+	htmlFiles, err := collectInputs([]string{*inputDir})
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(htmlFiles))
+	for j, path := range htmlFiles {
+		paths[j] = filepath.ToSlash(relativeInput(path))
+	}
+	return paths, nil
+}
+
+// render inlines a template for a client. Only templates found in
+// -input-dir are served, so paths can't escape it.
+func (s *previewServer) render(r *http.Request) (*inliner.InlineResult, string, int, error) {
+	rel := r.PathValue("path")
+	paths, err := s.templates()
+	if err != nil {
+		return nil, "", http.StatusInternalServerError, err
+	}
+	if !slices.Contains(paths, rel) {
+		return nil, "", http.StatusNotFound, fmt.Errorf("no template %q in %s", rel, *inputDir)
+	}
+	inputPath := filepath.Join(*inputDir, filepath.FromSlash(rel))
+
+	cfg, err := s.source.configFor(inputPath)
+	if err != nil {
+		return nil, "", http.StatusInternalServerError, err
+	}
+	client := r.URL.Query().Get("client")
+	if client == "" {
+		client = cfg.TargetEmailClient
+	}
+	if !slices.Contains(config.KnownClients, client) {
+		return nil, "", http.StatusBadRequest, fmt.Errorf("unknown client %q", client)
+	}
+
+	engine, err := s.source.engineFor(inputPath)
+	if err != nil {
+		return nil, "", http.StatusInternalServerError, err
+	}
+	content, err := os.ReadFile(inputPath)
+	if err != nil {
+		return nil, "", http.StatusInternalServerError, err
+	}
+	result, err := inlineInput(r.Context(), engine, string(content), inputPath, inliner.WithTargetClient(client))
+	if err != nil {
+		return nil, "", http.StatusUnprocessableEntity, err
+	}
+
+	s.watcher.recordDeps(inputPath, result.Stylesheets)
+	return result, client, http.StatusOK, nil
+}
+
+// handleIndex lists every template
+func (s *previewServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	paths, err := s.templates()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	executePage(w, indexPage, map[string]any{"Dir": *inputDir, "Templates": paths})
+}
+
+// handleRender serves a template's inlined HTML on its own
+func (s *previewServer) handleRender(w http.ResponseWriter, r *http.Request) {
+	result, _, status, err := s.render(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, result.HTML)
+}
+
+// handleView shows a template's output beside its warnings, with a switch
+// between client profiles
+func (s *previewServer) handleView(w http.ResponseWriter, r *http.Request) {
+	result, client, status, err := s.render(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	executePage(w, viewPage, map[string]any{
+		"Path":    r.PathValue("path"),
+		"Client":  client,
+		"Clients": config.KnownClients,
+		"Result":  result,
+	})
+}
+
+// executePage renders a page template
+func executePage(w http.ResponseWriter, page *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := page.Execute(w, data); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to render page: %v\n", err)
+	}
+}
+
+// pageStyle is shared by the preview pages
+const pageStyle = `<style>
+body { margin: 0; font: 14px/1.4 system-ui, sans-serif; color: #222; }
+header { padding: 8px 16px; background: #f4f4f4; border-bottom: 1px solid #ddd; }
+header a { margin-right: 8px; }
+a.current { font-weight: bold; color: #000; text-decoration: none; }
+main { display: flex; height: calc(100vh - 42px); }
+iframe { flex: 1; border: 0; }
+aside { width: 360px; overflow: auto; padding: 8px 16px; border-left: 1px solid #ddd; }
+.error { color: #b00020; } .warning { color: #a15c00; } .info { color: #555; }
+li { margin-bottom: 6px; }
+</style>`
+
+// liveReload reloads the page when a listed template changes; an empty path
+// reloads on any change
+const liveReload = `<script>
+new EventSource("/events").onmessage = (e) => {
+	const changed = JSON.parse(e.data);
+	if (path === "" || changed.includes(path)) location.reload();
+};
+</script>`
+
+var indexPage = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>inliner preview</title>` + pageStyle + `</head>
+<body><header>Templates in <code>{{.Dir}}</code></header>
+<ul>{{range .Templates}}<li><a href="/view/{{.}}">{{.}}</a></li>{{else}}<li>No HTML files found</li>{{end}}</ul>
+<script>const path = "";</script>` + liveReload + `
+</body></html>`))
+
+var viewPage = template.Must(template.New("view").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Path}} · inliner preview</title>` + pageStyle + `</head>
+<body>
+<header><a href="/">All templates</a> <code>{{.Path}}</code> &middot;
+{{$current := .Client}}{{$path := .Path}}{{range .Clients}}<a href="/view/{{$path}}?client={{.}}"{{if eq . $current}} class="current"{{end}}>{{.}}</a>{{end}}
+&middot; <a href="/render/{{.Path}}?client={{.Client}}" target="_blank">raw</a>
+</header>
+<main>
+<iframe srcdoc="{{.Result.HTML}}" title="Inlined output for {{.Client}}"></iframe>
+<aside>
+<h3>{{len .Result.Warnings}} warnings</h3>
+<ul>{{range .Result.Warnings}}<li class="{{.Severity}}">[{{.Severity}}] {{.Rule}}{{if .Line}} (line {{.Line}}){{end}}: {{.Message}}</li>{{end}}</ul>
+{{if .Result.Fixes}}<h3>Applied fixes</h3>
+<ul>{{range .Result.Fixes}}<li>[{{.Rule}}] {{.Element}}: {{.Description}}</li>{{end}}</ul>{{end}}
+<h3>Size</h3>
+<p>{{.Result.Size.TotalBytes}} bytes, {{.Result.Size.StyleBytes}} in &lt;style&gt;</p>
+<ul>{{range .Result.Size.Limits}}<li{{if .Exceeded}} class="error"{{end}}>{{.Client}} {{.Kind}}: {{.Actual}}/{{.Limit}} bytes</li>{{end}}</ul>
+</aside>
+</main>
+<script>const path = {{.Path}};</script>` + liveReload + `
+</body></html>`))
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// previewTree writes templates and starts a preview server for them
+func previewTree(t *testing.T, files map[string]string) (*previewServer, *httptest.Server) {
+	t.Helper()
+	dir := writeTree(t, files)
+	setFlag(t, inputDir, filepath.Join(dir, "src"))
+	setFlag(t, extList, ".html")
+	setFlag(t, &inputRoots, nil)
+
+	source := newTestSource()
+	s := &previewServer{source: source, watcher: newWatcher(source), subscribers: make(map[chan []string]bool)}
+	server := httptest.NewServer(s.handler())
+	t.Cleanup(server.Close)
+	return s, server
+}
+
+// previewFiles has one template that only Outlook warns about, and one in a
+// subdirectory
+var previewFiles = map[string]string{
+	"src/hero.html":       `<html><head><style>.hero { background-image: url(hero.png); color: red }</style></head><body><div class="hero">Hi</div></body></html>`,
+	"src/news/plain.html": `<html><body><p>Plain</p></body></html>`,
+	"secret.html":         `<p>Outside the template directory</p>`,
+}
+
+// get fetches a preview page and returns its status and body
+func get(t *testing.T, server *httptest.Server, path string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestPreviewIndex(t *testing.T) {
+	_, server := previewTree(t, previewFiles)
+
+	status, body := get(t, server, "/")
+	if status != http.StatusOK {
+		t.Fatalf("GET / = %d: %s", status, body)
+	}
+	for _, want := range []string{`href="/view/hero.html"`, `href="/view/news/plain.html"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("index is missing %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "secret.html") {
+		t.Errorf("index lists a file outside -input-dir:\n%s", body)
+	}
+}
+
+func TestPreviewViewPerClient(t *testing.T) {
+	_, server := previewTree(t, previewFiles)
+
+	tests := []struct {
+		query    string
+		client   string
+		warnings string
+	}{
+		{"", "generic", "0 warnings"},
+		{"?client=outlook", "outlook", "1 warnings"},
+		{"?client=gmail", "gmail", "0 warnings"},
+		{"?client=apple_mail", "apple_mail", "0 warnings"},
+	}
+
+	for _, test := range tests {
+		status, body := get(t, server, "/view/hero.html"+test.query)
+		if status != http.StatusOK {
+			t.Fatalf("view%s = %d: %s", test.query, status, body)
+		}
+		if want := `class="current">` + test.client + `</a>`; !strings.Contains(body, want) {
+			t.Errorf("view%s does not mark %s as current:\n%s", test.query, test.client, body)
+		}
+		if !strings.Contains(body, test.warnings) {
+			t.Errorf("view%s: want %q:\n%s", test.query, test.warnings, body)
+		}
+		if want := `href="/render/hero.html?client=` + test.client + `"`; !strings.Contains(body, want) {
+			t.Errorf("view%s is missing the raw link %s", test.query, want)
+		}
+		// The output is shown escaped inside the frame
+		if !strings.Contains(body, `srcdoc="&lt;html&gt;`) || !strings.Contains(body, "color: red") {
+			t.Errorf("view%s is missing the inlined output:\n%s", test.query, body)
+		}
+	}
+
+	_, body := get(t, server, "/view/hero.html?client=outlook")
+	if !strings.Contains(body, `class="warning">[warning] css-background-image`) {
+		t.Errorf("outlook view is missing the background image warning:\n%s", body)
+	}
+	if !strings.Contains(body, "gmail message: ") || !strings.Contains(body, "/104448 bytes") {
+		t.Errorf("outlook view is missing the gmail size limit:\n%s", body)
+	}
+}
+
+func TestPreviewRender(t *testing.T) {
+	_, server := previewTree(t, previewFiles)
+
+	status, body := get(t, server, "/render/news/plain.html?client=gmail")
+	if status != http.StatusOK {
+		t.Fatalf("render = %d: %s", status, body)
+	}
+	if body != `<html><head></head><body><p>Plain</p></body></html>` {
+		t.Errorf("render = %q, want the inlined document alone", body)
+	}
+
+	_, body = get(t, server, "/render/hero.html")
+	if !strings.Contains(body, `<div class="hero" style="`) {
+		t.Errorf("render did not inline styles:\n%s", body)
+	}
+}
+
+func TestPreviewErrors(t *testing.T) {
+	_, server := previewTree(t, previewFiles)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/view/missing.html", http.StatusNotFound},
+		{"/render/missing.html", http.StatusNotFound},
+		{"/view/..%2fsecret.html", http.StatusNotFound},
+		{"/render/..%2Fsecret.html", http.StatusNotFound},
+		{"/view/hero.html?client=lotus_notes", http.StatusBadRequest},
+		{"/render/hero.html?client=lotus_notes", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		status, body := get(t, server, test.path)
+		if status != test.status {
+			t.Errorf("GET %s = %d, want %d: %s", test.path, status, test.status, body)
+		}
+		if strings.Contains(body, "Outside the template directory") {
+			t.Errorf("GET %s served a file outside -input-dir", test.path)
+		}
+	}
+}
+
+func TestPreviewEvents(t *testing.T) {
+	s, server := previewTree(t, previewFiles)
+
+	resp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", got)
+	}
+
+	// The subscription is registered before the headers are flushed
+	s.broadcast([]string{"hero.html", "news/plain.html"})
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				lines <- line
+			}
+		}
+		close(lines)
+	}()
+	select {
+	case line := <-lines:
+		if want := `data: ["hero.html","news/plain.html"]`; line != want {
+			t.Errorf("event = %q, want %q", line, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"inliner/internal/config"
+	"inliner/internal/inliner"
+	"inliner/internal/report"
+)
+
+// requestPath names the input in responses, where there is no file
+const requestPath = "<request>"
+
+// latencyBuckets are the upper bounds, in seconds, of the latency histogram
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// apiRequest is the JSON form of an /inline or /validate request. Config
+// uses the config file setting names and applies on top of the server's
+// configuration.
+type apiRequest struct {
+	HTML   string          `json:"html"`
+	Config config.Settings `json:"config"`
+}
+
+// inlineResponse is the body returned by /inline
+type inlineResponse struct {
+	HTML string `json:"html"`
+	report.FileReport
+}
+
+// errorResponse is the body returned for failed requests
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// apiServer exposes inlining and validation over HTTP for other services
+type apiServer struct {
+	source  *configSource
+	metrics *serverMetrics
+}
+
+// runServer starts the HTTP API and blocks until interrupted
+func runServer(source *configSource) error {
+	s := &apiServer{source: source, metrics: newServerMetrics()}
+
+	// Container runtimes stop the server with SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:              *serveAddr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       *requestTimeout,
+		WriteTimeout:      *requestTimeout + 10*time.Second, // Processing plus time to send the response
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), *requestTimeout)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving the inliner API at http://%s/ (Ctrl-C to stop)\n", *serveAddr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// handler routes the API endpoints
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST /inline", s.metrics.instrument("/inline", http.HandlerFunc(s.handleInline)))
+	mux.Handle("POST /validate", s.metrics.instrument("/validate", http.HandlerFunc(s.handleValidate)))
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /metrics", s.metrics.handle)
+	return mux
+}
+
+// handleInline inlines the request's HTML and returns it with its findings
+func (s *apiServer) handleInline(w http.ResponseWriter, r *http.Request) {
+	engine, html, status, err := s.readRequest(w, r)
+	if err != nil {
+		writeJSON(w, status, errorResponse{Error: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), *requestTimeout)
+	defer cancel()
+
+	result, err := engine.InlineContext(ctx, strings.NewReader(html), io.Discard)
+	if err != nil {
+		writeJSON(w, processingStatus(err), errorResponse{Error: err.Error()})
+		return
+	}
+
+	rep := newReport("inline", engine)
+	rep.AddInlineResult(requestPath, result)
+	writeJSON(w, http.StatusOK, inlineResponse{HTML: result.HTML, FileReport: rep.Files[0]})
+}
+
+// handleValidate checks the request's HTML for email compatibility issues
+func (s *apiServer) handleValidate(w http.ResponseWriter, r *http.Request) {
+	engine, html, status, err := s.readRequest(w, r)
+	if err != nil {
+		writeJSON(w, status, errorResponse{Error: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), *requestTimeout)
+	defer cancel()
+
+	issues, err := engine.ValidateHTMLContext(ctx, html)
+	if err != nil {
+		writeJSON(w, processingStatus(err), errorResponse{Error: err.Error()})
+		return
+	}
+
+	rep := newReport("validate", engine)
+	rep.AddValidation(requestPath, issues)
+	writeJSON(w, http.StatusOK, rep.Files[0])
+}
+
+// readRequest reads raw HTML, or JSON carrying HTML and config overrides,
+// and returns the inliner for the request's configuration
+func (s *apiServer) readRequest(w http.ResponseWriter, r *http.Request) (*inliner.Inliner, string, int, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, *maxBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, "", http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", tooLarge.Limit)
+		}
+		return nil, "", http.StatusBadRequest, fmt.Errorf("failed to read request: %w", err)
+	}
+
+	request := apiRequest{HTML: string(body)}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		request = apiRequest{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&request); err != nil {
+			return nil, "", http.StatusBadRequest, fmt.Errorf("invalid JSON request: %w", err)
+		}
+	}
+	if strings.TrimSpace(request.HTML) == "" {
+		return nil, "", http.StatusBadRequest, errors.New("request has no HTML")
+	}
+
+	cfg, err := s.source.configFor("")
+	if err != nil {
+		return nil, "", http.StatusInternalServerError, err
+	}
+	request.Config.Apply(&cfg)
+
+	engine, err := s.source.engineForConfig(cfg)
+	if err != nil {
+		return nil, "", http.StatusBadRequest, err
+	}
+	return engine, request.HTML, http.StatusOK, nil
+}
+
+// processingStatus maps an inlining error to a response status
+func processingStatus(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusUnprocessableEntity
+}
+
+// writeJSON writes a JSON response body
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false) // Responses carry HTML; keep it readable
+	if err := encoder.Encode(body); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write response: %v\n", err)
+	}
+}
+
+// serverMetrics collects request metrics in Prometheus form
+type serverMetrics struct {
+	mu        sync.Mutex
+	requests  map[[2]string]uint64 // By path and status code
+	latency   map[string]*histogram
+	bytesIn   map[string]uint64
+	bytesOut  map[string]uint64
+	startTime time.Time
+}
+
+// histogram counts observations into cumulative latency buckets
+type histogram struct {
+	counts []uint64 // One per latencyBuckets entry
+	count  uint64
+	sum    float64
+}
+
+// newServerMetrics creates empty metrics
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		requests:  make(map[[2]string]uint64),
+		latency:   make(map[string]*histogram),
+		bytesIn:   make(map[string]uint64),
+		bytesOut:  make(map[string]uint64),
+		startTime: time.Now(),
+	}
+}
+
+// instrument records the count, latency and sizes of requests to a handler
+func (m *serverMetrics) instrument(path string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		m.observe(path, recorder.status, time.Since(start), body.n, recorder.n)
+	})
+}
+
+// observe records one finished request
+func (m *serverMetrics) observe(path string, status int, elapsed time.Duration, in, out int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[[2]string{path, fmt.Sprint(status)}]++
+	m.bytesIn[path] += uint64(in)
+	m.bytesOut[path] += uint64(out)
+
+	h, ok := m.latency[path]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.latency[path] = h
+	}
+	seconds := elapsed.Seconds()
+	for j, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[j]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// handle writes the metrics in the Prometheus text exposition format
+func (m *serverMetrics) handle(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	fmt.Fprintln(w, "# HELP inliner_http_requests_total HTTP requests by path and status code.")
+	fmt.Fprintln(w, "# TYPE inliner_http_requests_total counter")
+	keys := make([][2]string, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if keys[a][0] != keys[b][0] {
+			return keys[a][0] < keys[b][0]
+		}
+		return keys[a][1] < keys[b][1]
+	})
+	for _, key := range keys {
+		fmt.Fprintf(w, "inliner_http_requests_total{path=%q,code=%q} %d\n", key[0], key[1], m.requests[key])
+	}
+
+	fmt.Fprintln(w, "# HELP inliner_http_request_duration_seconds HTTP request latency.")
+	fmt.Fprintln(w, "# TYPE inliner_http_request_duration_seconds histogram")
+	for _, path := range sortedPaths(m.latency) {
+		h := m.latency[path]
+		for j, bound := range latencyBuckets {
+			fmt.Fprintf(w, "inliner_http_request_duration_seconds_bucket{path=%q,le=\"%g\"} %d\n", path, bound, h.counts[j])
+		}
+		fmt.Fprintf(w, "inliner_http_request_duration_seconds_bucket{path=%q,le=\"+Inf\"} %d\n", path, h.count)
+		fmt.Fprintf(w, "inliner_http_request_duration_seconds_sum{path=%q} %g\n", path, h.sum)
+		fmt.Fprintf(w, "inliner_http_request_duration_seconds_count{path=%q} %d\n", path, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP inliner_http_request_bytes_total Request body bytes received.")
+	fmt.Fprintln(w, "# TYPE inliner_http_request_bytes_total counter")
+	for _, path := range sortedPaths(m.bytesIn) {
+		fmt.Fprintf(w, "inliner_http_request_bytes_total{path=%q} %d\n", path, m.bytesIn[path])
+	}
+
+	fmt.Fprintln(w, "# HELP inliner_http_response_bytes_total Response body bytes sent.")
+	fmt.Fprintln(w, "# TYPE inliner_http_response_bytes_total counter")
+	for _, path := range sortedPaths(m.bytesOut) {
+		fmt.Fprintf(w, "inliner_http_response_bytes_total{path=%q} %d\n", path, m.bytesOut[path])
+	}
+
+	fmt.Fprintln(w, "# HELP inliner_uptime_seconds Time since the server started.")
+	fmt.Fprintln(w, "# TYPE inliner_uptime_seconds gauge")
+	fmt.Fprintf(w, "inliner_uptime_seconds %g\n", time.Since(m.startTime).Seconds())
+}
+
+// sortedPaths returns the keys of a per-path metric in sorted order
+func sortedPaths[V any](m map[string]V) []string {
+	paths := make([]string, 0, len(m))
+	for path := range m {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read reads from the body and counts the bytes
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// statusRecorder records the status code and body size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	n      int64
+}
+
+// WriteHeader records the status code
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes to the response and counts the bytes
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	n, err := s.ResponseWriter.Write(p)
+	s.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// apiTestServer starts the API with the default configuration
+func apiTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	setFlag(t, maxBody, 1<<20)
+	setFlag(t, requestTimeout, 30*time.Second)
+	s := &apiServer{source: newTestSource(), metrics: newServerMetrics()}
+	server := httptest.NewServer(s.handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// post sends a request body and returns the status and response body
+func post(t *testing.T, server *httptest.Server, path, contentType, body string) (int, string) {
+	t.Helper()
+	resp, err := http.Post(server.URL+path, contentType, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+const apiPage = `<html><head><style>p { color: red }</style></head><body><p>Hi</p></body></html>`
+
+func TestServerInline(t *testing.T) {
+	server := apiTestServer(t)
+
+	status, body := post(t, server, "/inline", "text/html", apiPage)
+	if status != http.StatusOK {
+		t.Fatalf("POST /inline = %d: %s", status, body)
+	}
+	var response inlineResponse
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(response.HTML, `<p style="color: red">`) || response.Path != requestPath || response.Stats.InlinedStyles != 1 {
+		t.Errorf("response = %+v", response)
+	}
+
+	// JSON requests carry config overrides using the config file names
+	request := `{"html": "<html><body><div style=\"background-image: url(a.png)\">x</div></body></html>", "config": {"target": "outlook"}}`
+	status, body = post(t, server, "/inline", "application/json; charset=utf-8", request)
+	if status != http.StatusOK || !strings.Contains(body, `"ruleId":"css-background-image"`) {
+		t.Errorf("POST /inline with an outlook target = %d: %s", status, body)
+	}
+}
+
+func TestServerRejectsBadRequests(t *testing.T) {
+	server := apiTestServer(t)
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		status      int
+		message     string
+	}{
+		{"empty body", "/inline", "text/html", "  ", http.StatusBadRequest, "request has no HTML"},
+		{"malformed JSON", "/inline", "application/json", `{"html": `, http.StatusBadRequest, "invalid JSON request"},
+		{"unknown JSON field", "/validate", "application/json", `{"html": "<p>x</p>", "target": "outlook"}`, http.StatusBadRequest, "unknown field"},
+		{"invalid config", "/inline", "application/json", `{"html": "<p>x</p>", "config": {"target": "lotus_notes"}}`, http.StatusBadRequest, "lotus_notes"},
+	}
+	for _, test := range tests {
+		status, body := post(t, server, test.path, test.contentType, test.body)
+		if status != test.status || !strings.Contains(body, test.message) {
+			t.Errorf("%s: %d %s, want %d containing %q", test.name, status, body, test.status, test.message)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/inline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /inline = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestServerMaxBody(t *testing.T) {
+	server := apiTestServer(t)
+	setFlag(t, maxBody, int64(len(apiPage)))
+
+	if status, body := post(t, server, "/inline", "text/html", apiPage); status != http.StatusOK {
+		t.Errorf("body at the limit = %d: %s", status, body)
+	}
+	status, body := post(t, server, "/inline", "text/html", apiPage+" ")
+	if status != http.StatusRequestEntityTooLarge || !strings.Contains(body, "exceeds") {
+		t.Errorf("body over the limit = %d: %s", status, body)
+	}
+	status, _ = post(t, server, "/validate", "text/html", apiPage+strings.Repeat(" ", 1<<16))
+	if status != http.StatusRequestEntityTooLarge {
+		t.Errorf("validate body over the limit = %d", status)
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	server := apiTestServer(t)
+	setFlag(t, requestTimeout, time.Nanosecond)
+
+	page := `<html><head><style>td { color: red }</style></head><body><table>` +
+		strings.Repeat(`<tr><td>x</td></tr>`, 2000) + `</table></body></html>`
+	for _, path := range []string{"/inline", "/validate"} {
+		status, body := post(t, server, path, "text/html", page)
+		if status != http.StatusGatewayTimeout || !strings.Contains(body, "deadline exceeded") {
+			t.Errorf("%s past its deadline = %d: %s", path, status, body)
+		}
+	}
+}
+
+func TestServerMetrics(t *testing.T) {
+	server := apiTestServer(t)
+
+	post(t, server, "/inline", "text/html", apiPage)
+	post(t, server, "/inline", "text/html", apiPage)
+	post(t, server, "/inline", "text/html", "")
+	post(t, server, "/validate", "text/html", apiPage)
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	metrics := string(data)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	for _, want := range []string{
+		`inliner_http_requests_total{path="/inline",code="200"} 2`,
+		`inliner_http_requests_total{path="/inline",code="400"} 1`,
+		`inliner_http_requests_total{path="/validate",code="200"} 1`,
+		`inliner_http_request_duration_seconds_bucket{path="/inline",le="+Inf"} 3`,
+		`inliner_http_request_duration_seconds_count{path="/inline"} 3`,
+		`inliner_http_request_duration_seconds_count{path="/validate"} 1`,
+		fmt.Sprintf(`inliner_http_request_bytes_total{path="/inline"} %d`, 2*len(apiPage)),
+		fmt.Sprintf(`inliner_http_request_bytes_total{path="/validate"} %d`, len(apiPage)),
+		"# TYPE inliner_uptime_seconds gauge",
+	} {
+		if !strings.Contains(metrics, want+"\n") {
+			t.Errorf("metrics are missing %s:\n%s", want, metrics)
+		}
+	}
+	if strings.Contains(metrics, `path="/metrics"`) || strings.Contains(metrics, `path="/healthz"`) {
+		t.Errorf("metrics and health checks should not be instrumented:\n%s", metrics)
+	}
+	if !strings.Contains(metrics, `inliner_http_response_bytes_total{path="/inline"} `) ||
+		strings.Contains(metrics, `inliner_http_response_bytes_total{path="/inline"} 0`+"\n") {
+		t.Errorf("response bytes were not counted:\n%s", metrics)
+	}
+}
+
+func TestServerHealthz(t *testing.T) {
+	server := apiTestServer(t)
+	resp, err := http.Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok\n" {
+		t.Errorf("GET /healthz = %d %q", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fileStamp identifies a version of a file; the zero value means missing
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// statFile returns the current stamp of a file
+func statFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// watcher rebuilds the outputs of -input-dir as templates and the
+// stylesheets they use change. It polls, so it works on any filesystem.
+type watcher struct {
+	source *configSource
+
+	mu     sync.Mutex           // Guards the maps; the preview server renders concurrently
+	inputs map[string]fileStamp // HTML files by path
+	sheets map[string]fileStamp // Stylesheets by href
+	deps   map[string][]string  // Stylesheet hrefs used by each input
+}
+
+// newWatcher creates a watcher that knows no files yet
+func newWatcher(source *configSource) *watcher {
+	return &watcher{
+		source: source,
+		inputs: make(map[string]fileStamp),
+		sheets: make(map[string]fileStamp),
+		deps:   make(map[string][]string),
+	}
+}
+
+// runWatch builds every input, then rebuilds the affected inputs whenever an
+// input or a stylesheet it uses changes, until interrupted
+func runWatch(source *configSource) error {
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	w := newWatcher(source)
+
+	// The first scan finds every input as new
+	rebuild, _, err := w.scan()
+	if err != nil {
+		return err
+	}
+	w.build(rebuild)
+	fmt.Fprintf(os.Stderr, "Watching %s for changes (Ctrl-C to stop)\n", *inputDir)
+
+	ticker := time.NewTicker(*pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		rebuild, removed, err := w.scan()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		if len(rebuild) == 0 && len(removed) == 0 {
+			continue
+		}
+
+		rebuild, removed, ok := w.settle(ctx, rebuild, removed)
+		if !ok {
+			return nil
+		}
+
+		w.remove(removed)
+		rebuild = slices.DeleteFunc(rebuild, func(path string) bool { return slices.Contains(removed, path) })
+		w.build(rebuild)
+	}
+}
+
+// scan compares the inputs and stylesheets with their last known stamps and
+// returns the inputs to rebuild and the inputs that were deleted
+func (w *watcher) scan() (rebuild, removed []string, err error) {
+	htmlFiles, err := collectInputs([]string{*inputDir})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find HTML files: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	seen := make(map[string]bool, len(htmlFiles))
+	for _, path := range htmlFiles {
+		seen[path] = true
+		stamp := statFile(path)
+		if previous, ok := w.inputs[path]; !ok || previous != stamp {
+			w.inputs[path] = stamp
+			rebuild = append(rebuild, path)
+		}
+	}
+	for path := range w.inputs {
+		if !seen[path] {
+			delete(w.inputs, path)
+			delete(w.deps, path)
+			removed = append(removed, path)
+		}
+	}
+
+	// A changed stylesheet rebuilds exactly the inputs that use it
+	for href, previous := range w.sheets {
+		stamp := fileStamp{}
+		if path, err := localPath(href); err == nil {
+			stamp = statFile(path)
+		}
+		if stamp == previous {
+			continue
+		}
+		w.sheets[href] = stamp
+		w.source.sheets.Forget(href)
+		for input, hrefs := range w.deps {
+			if slices.Contains(hrefs, href) {
+				rebuild = mergePaths(rebuild, []string{input})
+			}
+		}
+	}
+
+	sort.Strings(rebuild)
+	sort.Strings(removed)
+	return rebuild, removed, nil
+}
+
+// settle waits until a -debounce period passes with no further changes,
+// since editors often write several times in quick succession, and returns
+// every input changed or removed meanwhile. It returns false if ctx is done
+// first.
+func (w *watcher) settle(ctx context.Context, rebuild, removed []string) ([]string, []string, bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil, false
+		case <-time.After(*debounce):
+		}
+		more, gone, err := w.scan()
+		if err != nil || len(more) == 0 && len(gone) == 0 {
+			return rebuild, removed, true
+		}
+		rebuild = mergePaths(rebuild, more)
+		removed = mergePaths(removed, gone)
+	}
+}
+
+// build inlines the given inputs, printing each file's warnings as it finishes
+func (w *watcher) build(files []string) {
+	if len(files) == 0 {
+		return
+	}
+	start := time.Now()
+
+	// Handler errors are never returned, so neither is processBatch's
+	_ = processBatch(w.source, nil, files, func(file batchResult) error {
+		stamp := time.Now().Format("15:04:05")
+		switch {
+		case file.configErr != nil:
+			fmt.Fprintf(os.Stderr, "[%s] %s: %v\n", stamp, file.path, file.configErr)
+			return nil
+		case file.err != nil:
+			fmt.Fprintf(os.Stderr, "[%s] %v\n", stamp, file.err)
+			return nil
+		}
+
+		w.recordDeps(file.path, file.result.Stylesheets)
+
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "[%s] Built %s (%d warnings)\n", stamp, file.path, len(file.result.Warnings))
+		}
+		if *showWarnings && len(file.result.Warnings) > 0 {
+			showWarningsFunc(file.result.Warnings)
+		}
+		return nil
+	})
+
+	if !*quiet && len(files) > 1 {
+		fmt.Fprintf(os.Stderr, "Rebuilt %d files in %v\n", len(files), time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// recordDeps remembers the stylesheets an input used in its latest build
+func (w *watcher) recordDeps(inputPath string, hrefs []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.deps[inputPath] = hrefs
+	for _, href := range hrefs {
+		if _, ok := w.sheets[href]; !ok {
+			stamp := fileStamp{}
+			if path, err := localPath(href); err == nil {
+				stamp = statFile(path)
+			}
+			w.sheets[href] = stamp
+		}
+	}
+}
+
+// remove deletes the outputs of deleted inputs
+func (w *watcher) remove(inputs []string) {
+	for _, inputPath := range inputs {
+		outputPath := filepath.Join(*outputDir, relativeInput(inputPath))
+		if err := os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove %s: %v\n", outputPath, err)
+			continue
+		}
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "[%s] Removed %s\n", time.Now().Format("15:04:05"), outputPath)
+		}
+	}
+}
+
+// mergePaths returns the sorted union of two path lists
+func mergePaths(a, b []string) []string {
+	merged := slices.Clone(a)
+	for _, path := range b {
+		if !slices.Contains(merged, path) {
+			merged = append(merged, path)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
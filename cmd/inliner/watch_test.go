@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// watchTree writes a template directory and points the watch flags at it
+func watchTree(t *testing.T, files map[string]string) (src, out string) {
+	t.Helper()
+	dir := writeTree(t, files)
+	src, out = filepath.Join(dir, "src"), filepath.Join(dir, "out")
+	setFlag(t, inputDir, src)
+	setFlag(t, outputDir, out)
+	setFlag(t, extList, ".html")
+	setFlag(t, jobs, 1)
+	setFlag(t, quiet, true)
+	setFlag(t, loadSheets, true)
+	setFlag(t, debounce, 50*time.Millisecond)
+	setFlag(t, &inputRoots, nil)
+	return src, out
+}
+
+// scanNames scans and returns the base names of the inputs to rebuild and remove
+func scanNames(t *testing.T, w *watcher) (rebuild, removed []string) {
+	t.Helper()
+	paths, gone, err := w.scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return baseNames(paths), baseNames(gone)
+}
+
+func baseNames(paths []string) []string {
+	var names []string
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	return names
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherScan(t *testing.T) {
+	src, _ := watchTree(t, map[string]string{
+		"src/a.html":      `<p>a</p>`,
+		"src/b.html":      `<p>b</p>`,
+		"src/notes.txt":   `not a template`,
+		"src/sub/c.html":  `<p>c</p>`,
+		"src/sub/d.html~": `backup`,
+	})
+	w := newWatcher(newTestSource())
+
+	steps := []struct {
+		name    string
+		change  func()
+		rebuild []string
+		removed []string
+	}{
+		{name: "first scan finds every input", rebuild: []string{"a.html", "b.html", "c.html"}},
+		{name: "nothing changed"},
+		{
+			name:    "input edited",
+			change:  func() { writeFile(t, filepath.Join(src, "b.html"), `<p>b, edited</p>`) },
+			rebuild: []string{"b.html"},
+		},
+		{
+			name:    "input added",
+			change:  func() { writeFile(t, filepath.Join(src, "sub", "e.html"), `<p>e</p>`) },
+			rebuild: []string{"e.html"},
+		},
+		{
+			name: "input removed",
+			change: func() {
+				if err := os.Remove(filepath.Join(src, "a.html")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			removed: []string{"a.html"},
+		},
+		{
+			name:   "other files ignored",
+			change: func() { writeFile(t, filepath.Join(src, "notes.txt"), `still not a template`) },
+		},
+	}
+
+	for _, step := range steps {
+		if step.change != nil {
+			step.change()
+		}
+		rebuild, removed := scanNames(t, w)
+		if !reflect.DeepEqual(rebuild, step.rebuild) || !reflect.DeepEqual(removed, step.removed) {
+			t.Errorf("%s: rebuild %v, removed %v; want %v, %v", step.name, rebuild, removed, step.rebuild, step.removed)
+		}
+	}
+}
+
+func TestWatcherRebuildsStylesheetDependents(t *testing.T) {
+	page := func(href string) string {
+		return `<html><head><link rel="stylesheet" href="` + href + `"></head><body><p class="x">Hi</p></body></html>`
+	}
+	src, out := watchTree(t, map[string]string{
+		"src/a.html":        page("shared.css"),
+		"src/b.html":        page("other.css"),
+		"src/c.html":        `<p>No stylesheet</p>`,
+		"src/sub/d.html":    page("../shared.css"),
+		"src/shared.css":    `.x { color: red }`,
+		"src/other.css":     `.x { color: blue }`,
+		"src/unrelated.css": `.x { color: green }`,
+	})
+	w := newWatcher(newTestSource())
+
+	rebuild, _, err := w.scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.build(rebuild)
+	if output, _ := os.ReadFile(filepath.Join(out, "a.html")); !strings.Contains(string(output), "color: red") {
+		t.Fatalf("first build of a.html:\n%s", output)
+	}
+
+	steps := []struct {
+		name    string
+		change  func()
+		rebuild []string
+	}{
+		{
+			name:    "shared stylesheet edited",
+			change:  func() { writeFile(t, filepath.Join(src, "shared.css"), `.x { color: purple }`) },
+			rebuild: []string{"a.html", "d.html"},
+		},
+		{
+			name:    "other stylesheet edited",
+			change:  func() { writeFile(t, filepath.Join(src, "other.css"), `.x { color: darkblue }`) },
+			rebuild: []string{"b.html"},
+		},
+		{
+			name:   "unused stylesheet edited",
+			change: func() { writeFile(t, filepath.Join(src, "unrelated.css"), `.x { color: lime }`) },
+		},
+		{
+			name: "stylesheet removed",
+			change: func() {
+				if err := os.Remove(filepath.Join(src, "other.css")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			rebuild: []string{"b.html"},
+		},
+		{
+			name:    "input switches stylesheets",
+			change:  func() { writeFile(t, filepath.Join(src, "c.html"), page("shared.css")) },
+			rebuild: []string{"c.html"},
+		},
+		{
+			name:    "new dependency is tracked",
+			change:  func() { writeFile(t, filepath.Join(src, "shared.css"), `.x { color: darkorange }`) },
+			rebuild: []string{"a.html", "c.html", "d.html"},
+		},
+	}
+
+	for _, step := range steps {
+		step.change()
+		paths, _, err := w.scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := baseNames(paths); !reflect.DeepEqual(got, step.rebuild) {
+			t.Errorf("%s: rebuild %v, want %v", step.name, got, step.rebuild)
+		}
+		w.build(paths)
+	}
+
+	// Rebuilds read the edited stylesheet rather than a cached copy
+	if output, _ := os.ReadFile(filepath.Join(out, "a.html")); !strings.Contains(string(output), "color: darkorange") {
+		t.Errorf("a.html after stylesheet edits:\n%s", output)
+	}
+}
+
+func TestWatcherSettle(t *testing.T) {
+	src, _ := watchTree(t, map[string]string{
+		"src/a.html": `<p>a</p>`,
+		"src/b.html": `<p>b</p>`,
+		"src/c.html": `<p>c</p>`,
+	})
+	setFlag(t, debounce, 100*time.Millisecond)
+	w := newWatcher(newTestSource())
+	scanNames(t, w)
+
+	// A burst of writes, each within the debounce period of the one before
+	writeFile(t, filepath.Join(src, "a.html"), `<p>a, saved</p>`)
+	rebuild, removed, err := w.scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		time.Sleep(10 * time.Millisecond)
+		if err := os.WriteFile(filepath.Join(src, "b.html"), []byte(`<p>b, saved</p>`), 0644); err != nil {
+			t.Error(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if err := os.Remove(filepath.Join(src, "c.html")); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	start := time.Now()
+	rebuild, removed, ok := w.settle(context.Background(), rebuild, removed)
+	<-done
+	if !ok {
+		t.Fatal("settle was cancelled")
+	}
+	if got, want := baseNames(rebuild), []string{"a.html", "b.html"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rebuild %v, want %v", got, want)
+	}
+	if got, want := baseNames(removed), []string{"c.html"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removed %v, want %v", got, want)
+	}
+	if elapsed := time.Since(start); elapsed < 2**debounce {
+		t.Errorf("settled after %v, before a quiet %v period followed the last change", elapsed, *debounce)
+	}
+
+	// Nothing pending after settling
+	if rebuild, removed := scanNames(t, w); len(rebuild) != 0 || len(removed) != 0 {
+		t.Errorf("after settle: rebuild %v, removed %v", rebuild, removed)
+	}
+}
+
+func TestWatcherSettleCancelled(t *testing.T) {
+	watchTree(t, map[string]string{"src/a.html": `<p>a</p>`})
+	setFlag(t, debounce, time.Hour)
+	w := newWatcher(newTestSource())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, ok := w.settle(ctx, []string{"a.html"}, nil); ok {
+		t.Error("settle returned ok after its context was cancelled")
+	}
+}
+
+func TestMergePaths(t *testing.T) {
+	got := mergePaths([]string{"b", "a"}, []string{"c", "a"})
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergePaths = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,132 @@
+package inliner
+
+import (
+	"inliner/internal/config"
+)
+
+// Output formats for Config.OutputFormat
+const (
+	OutputPreserve = config.OutputPreserve
+	OutputPretty   = config.OutputPretty
+	OutputCompact  = config.OutputCompact
+)
+
+// Config controls how documents are inlined and validated
+type Config struct {
+	// TargetClient is the email client to optimize for: "generic", "outlook",
+	// "gmail", "apple_mail" or "outlook_online"
+	TargetClient string
+
+	// PreserveMediaQueries keeps @media rules in <style> tags
+	PreserveMediaQueries bool
+
+	// PreservePseudoSelectors keeps :hover, :focus, etc. in <style> tags
+	PreservePseudoSelectors bool
+
+	// RemoveStyleTags removes <style> tags after inlining
+	RemoveStyleTags bool
+
+	// StripUnusedCSS removes preserved rules that match no element
+	StripUnusedCSS bool
+
+	// CSSSafelist lists selector patterns StripUnusedCSS never removes:
+	// substrings of the selector, or regular expressions written as /.../
+	CSSSafelist []string
+
+	// PassthroughAttribute marks <style> tags that are neither inlined nor
+	// rewritten; empty disables passthrough
+	PassthroughAttribute string
+
+	// EmailClientOptimizations applies email client specific optimizations
+	EmailClientOptimizations bool
+
+	// OutputFormat is OutputPreserve, OutputPretty or OutputCompact
+	OutputFormat string
+
+	// Indent is the indentation unit for OutputPretty
+	Indent string
+
+	// Minify collapses whitespace, strips non-conditional comments and
+	// shortens CSS; it takes precedence over OutputFormat
+	Minify bool
+
+	// AccessibilityFixes adds role="presentation" to layout tables, alt="" to
+	// decorative images and DefaultLang to <html> during inlining
+	AccessibilityFixes bool
+
+	// DefaultLang is added as <html lang> by AccessibilityFixes when missing
+	DefaultLang string
+
+	// DarkMode keeps @media (prefers-color-scheme: dark) rules even when
+	// PreserveMediaQueries is off, and adds Outlook.com [data-ogsc] and
+	// [data-ogsb] variants of their color and background declarations
+	DarkMode bool
+
+	// ColorScheme is added as color-scheme and supported-color-schemes meta
+	// tags when missing, e.g. "light dark"; empty adds neither
+	ColorScheme string
+
+	// SizeTargets lists the clients whose size limits the output is checked
+	// against; empty means TargetClient plus gmail
+	SizeTargets []string
+
+	// RuleSeverities overrides lint rule severities by rule ID: "error",
+	// "warning", "info" or "off"
+	RuleSeverities map[string]string
+
+	// RuleOptions sets lint rule options by rule ID, then option name
+	RuleOptions map[string]map[string]string
+}
+
+// DefaultConfig returns a configuration optimized for email clients
+func DefaultConfig() Config {
+	return fromInternalConfig(config.Default())
+}
+
+// fromInternalConfig converts the engine configuration to the public one
+func fromInternalConfig(c config.Config) Config {
+	return Config{
+		TargetClient:             c.TargetEmailClient,
+		PreserveMediaQueries:     c.PreserveMediaQueries,
+		PreservePseudoSelectors:  c.PreservePseudoSelectors,
+		RemoveStyleTags:          c.RemoveStyleTags,
+		StripUnusedCSS:           c.StripUnusedCSS,
+		CSSSafelist:              c.CSSSafelist,
+		PassthroughAttribute:     c.PassthroughAttribute,
+		EmailClientOptimizations: c.EmailClientOptimizations,
+		OutputFormat:             c.EffectiveOutputFormat(),
+		Indent:                   c.Indent,
+		Minify:                   c.Minify,
+		AccessibilityFixes:       c.AccessibilityFixes,
+		DefaultLang:              c.DefaultLang,
+		DarkMode:                 c.DarkMode,
+		ColorScheme:              c.ColorScheme,
+		SizeTargets:              c.SizeTargets,
+		RuleSeverities:           c.Rules,
+		RuleOptions:              c.RuleOptions,
+	}
+}
+
+// toInternal converts the public configuration to the engine configuration
+func (c Config) toInternal() config.Config {
+	cfg := config.Default()
+	cfg.TargetEmailClient = c.TargetClient
+	cfg.PreserveMediaQueries = c.PreserveMediaQueries
+	cfg.PreservePseudoSelectors = c.PreservePseudoSelectors
+	cfg.RemoveStyleTags = c.RemoveStyleTags
+	cfg.StripUnusedCSS = c.StripUnusedCSS
+	cfg.CSSSafelist = c.CSSSafelist
+	cfg.PassthroughAttribute = c.PassthroughAttribute
+	cfg.EmailClientOptimizations = c.EmailClientOptimizations
+	cfg.OutputFormat = c.OutputFormat
+	cfg.Indent = c.Indent
+	cfg.Minify = c.Minify
+	cfg.AccessibilityFixes = c.AccessibilityFixes
+	cfg.DefaultLang = c.DefaultLang
+	cfg.DarkMode = c.DarkMode
+	cfg.ColorScheme = c.ColorScheme
+	cfg.SizeTargets = c.SizeTargets
+	cfg.Rules = c.RuleSeverities
+	cfg.RuleOptions = c.RuleOptions
+	return cfg
+}
@@ -0,0 +1,56 @@
+// Package inliner inlines CSS into email HTML and checks it against the
+// quirks of email clients.
+//
+// The types in this package are the stable API; everything under internal/
+// may change between releases.
+//
+// Inline a document with the defaults:
+//
+//	out, err := inliner.Inline(html)
+//
+// Reuse one Inliner across goroutines, with per-engine options:
+//
+//	engine, err := inliner.New(
+//		inliner.WithTargetClient("outlook"),
+//		inliner.WithMinify(true),
+//		inliner.WithLoader(inliner.FileLoader("templates/css")),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	result, err := engine.InlineReader(ctx, r, w)
+//
+// Add a custom lint rule and validate:
+//
+//	engine, err := inliner.New(inliner.WithRules(inliner.Rule{
+//		ID:       "brand-footer",
+//		Category: "structure",
+//		Severity: inliner.SeverityError,
+//		Check: func(ctx *inliner.RuleContext) []inliner.Issue {
+//			if len(ctx.Elements("#footer")) == 0 {
+//				return []inliner.Issue{{Message: "Missing #footer"}}
+//			}
+//			return nil
+//		},
+//	}))
+//	issues, err := engine.Validate(ctx, html)
+//
+// Transform the document between pipeline stages:
+//
+//	engine, err := inliner.New(
+//		inliner.WithAfterStage(inliner.StageInlineStyles, func(ctx context.Context, doc *inliner.Document) error {
+//			for _, img := range doc.Elements("img[src^='/']") {
+//				src, _ := img.Attr("src")
+//				if err := img.SetAttr("src", "https://cdn.example.com"+src); err != nil {
+//					return err
+//				}
+//			}
+//			return nil
+//		}),
+//		inliner.WithoutStage(inliner.StageAccessibilityFixes),
+//	)
+//
+// Hooks and custom stages see the parsed document. Parsing happens before
+// the first stage and serializing after the last, and neither can be
+// hooked; see the Stage constants.
+package inliner
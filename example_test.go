@@ -0,0 +1,205 @@
+package inliner_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"inliner"
+)
+
+func Example() {
+	out, err := inliner.Inline(`<html><head><style>p { color: #336699 }</style></head><body><p>Hello</p></body></html>`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(out)
+	// Output:
+	// <html><head></head><body><p style="color: #336699">Hello</p></body></html>
+}
+
+func ExampleNew() {
+	engine, err := inliner.New(
+		inliner.WithTargetClient("outlook"),
+		inliner.WithMinify(true),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	result, err := engine.Inline(context.Background(), `<html><head><style>
+  .button { background-color: #ff6600; padding: 0px 12px }
+  @media (max-width: 600px) { .button { display: block } }
+</style></head><body><a class="button" href="https://example.com">Buy</a></body></html>`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(result.HTML)
+	fmt.Println("inlined:", result.InlinedStyles, "preserved:", result.PreservedRules)
+	// Output:
+	// <html><head><style>@media (max-width: 600px){.button{display:block}}</style></head><body><a class="button" href="https://example.com" style="background-color:#f60;padding:0 12px">Buy</a></body></html>
+	// inlined: 2 preserved: 1
+}
+
+func ExampleWithLoader() {
+	sheets := map[string]string{
+		"theme.css": `@import "base.css"; h1 { color: navy }`,
+		"base.css":  `body { margin: 0 }`,
+	}
+	loader := inliner.LoaderFunc(func(ctx context.Context, href string) (string, error) {
+		if css, ok := sheets[href]; ok {
+			return css, nil
+		}
+		return "", fmt.Errorf("no stylesheet %s", href)
+	})
+
+	out, err := inliner.Inline(`<html><head><link rel="stylesheet" href="theme.css"></head><body><h1>News</h1></body></html>`,
+		inliner.WithLoader(loader))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(out)
+	// Output:
+	// <html><head></head><body style="margin: 0"><h1 style="color: navy">News</h1></body></html>
+}
+
+func ExampleFileLoader() {
+	dir, err := os.MkdirTemp("", "styles")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "email.css"), []byte(`td { font-family: Arial }`), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := inliner.Inline(`<html><head><link rel="stylesheet" href="email.css"></head><body><table><tr><td>Hi</td></tr></table></body></html>`,
+		inliner.WithLoader(inliner.FileLoader(dir)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(out)
+	// Output:
+	// <html><head></head><body><table><tbody><tr><td style="font-family: Arial">Hi</td></tr></tbody></table></body></html>
+}
+
+func ExampleWithRules() {
+	engine, err := inliner.New(inliner.WithRules(inliner.Rule{
+		ID:          "brand-footer",
+		Category:    "structure",
+		Severity:    inliner.SeverityError,
+		Description: "Emails need the #footer with the unsubscribe link",
+		Check: func(ctx *inliner.RuleContext) []inliner.Issue {
+			if len(ctx.Elements("#footer")) == 0 {
+				return []inliner.Issue{{Message: "Missing #footer"}}
+			}
+			return nil
+		},
+	}))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	issues, err := engine.Validate(context.Background(), `<html lang="en"><body><p>No footer</p></body></html>`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, issue := range issues {
+		if issue.Rule == "brand-footer" {
+			fmt.Printf("%s %s: %s\n", issue.Severity, issue.Rule, issue.Message)
+		}
+	}
+	// Output:
+	// error brand-footer: Missing #footer
+}
+
+func ExampleInliner_Validate() {
+	engine, err := inliner.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	issues, err := engine.Validate(context.Background(), `<html><body>
+<img src="logo.png">
+</body></html>`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, issue := range issues {
+		if issue.Severity == inliner.SeverityError {
+			fmt.Printf("line %d: %s: %s\n", issue.Line, issue.Rule, issue.Message)
+		}
+	}
+	// Output:
+	// line 2: relative-url: Relative URL "logo.png" will not resolve in email clients
+	// line 2: a11y-img-alt: <img> has no alt attribute; use alt="" if it is decorative
+	// line 1: a11y-html-lang: <html> has no lang attribute
+}
+
+func ExampleWithAfterStage() {
+	engine, err := inliner.New(
+		inliner.WithAfterStage(inliner.StageInlineStyles, func(ctx context.Context, doc *inliner.Document) error {
+			for _, img := range doc.Elements("img[src^='/']") {
+				src, _ := img.Attr("src")
+				if err := img.SetAttr("src", "https://cdn.example.com"+src); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	result, err := engine.Inline(context.Background(), `<html><head><style>img { border: 0 }</style></head><body><img src="/logo.png" alt="Logo"></body></html>`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(result.HTML)
+	// Output:
+	// <html><head></head><body><img src="https://cdn.example.com/logo.png" alt="Logo" style="border: 0"/></body></html>
+}
+
+func ExampleWithoutStage() {
+	engine, err := inliner.New(
+		inliner.WithoutStage(inliner.StageAccessibilityFixes),
+		inliner.WithoutStage(inliner.StageVMLFallbacks),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(strings.Join(engine.Stages(), "\n"))
+	// Output:
+	// extract-css
+	// inline-styles
+	// dark-mode
+	// style-tags
+	// minify
+	// format-whitespace
+	// strip-directives
+}
+
+func ExampleInliner_InlineReader() {
+	engine, err := inliner.New(inliner.WithOutputFormat(inliner.OutputCompact, ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var out strings.Builder
+	in := strings.NewReader(`<html>
+  <head><style>.total { font-weight: bold }</style></head>
+  <body>
+    <p class="total">Total: $10</p>
+  </body>
+</html>`)
+	if _, err := engine.InlineReader(context.Background(), in, &out); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(out.String())
+	// Output:
+	// <html><head></head><body><p class="total" style="font-weight: bold">Total: $10</p></body></html>
+}
@@ -0,0 +1,190 @@
+package inliner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	engine "inliner/internal/inliner"
+)
+
+// Inliner inlines and validates email HTML. It is safe for concurrent use
+// by multiple goroutines.
+type Inliner struct {
+	engine *engine.Inliner
+}
+
+// Result is the outcome of inlining a document
+type Result struct {
+	HTML           string    // Document with styles inlined
+	InlinedStyles  int       // Declarations written to style attributes
+	PreservedRules int       // Rules kept in <style> tags
+	RemovedRules   int       // Preserved rules dropped because they matched nothing
+	Warnings       []Warning // Compatibility problems in the inlined styles
+	Fixes          []Fix     // Changes made by autofix passes
+	Size           Size      // Size of the output against client limits
+}
+
+// Warning is a compatibility problem found while inlining
+type Warning struct {
+	Rule     string // Stable identifier, e.g. "css-viewport-units"
+	Severity string // SeverityError, SeverityWarning or SeverityInfo
+	Message  string
+	Property string
+	Value    string
+	Element  string // Tag name of the element the style applies to
+	Line     int    // 1-based source line, 0 if unknown
+}
+
+// Issue is a problem found by a lint rule
+type Issue struct {
+	Rule      string // ID of the rule that reported it; set by Validate
+	Category  string
+	Severity  string // Set by Validate from the rule and any override
+	Message   string
+	Element   string
+	Property  string
+	Line      int    // 1-based source line, 0 if unknown
+	Reference string // Standard the rule enforces, e.g. "WCAG 2.1 SC 1.1.1"
+}
+
+// Fix is a change made to the document by an autofix pass
+type Fix struct {
+	Rule        string
+	Element     string
+	Line        int
+	Description string
+}
+
+// Size describes the size of the output
+type Size struct {
+	TotalBytes int         // Serialized document
+	StyleBytes int         // Contents of all <style> tags
+	Limits     []SizeLimit // One per target client and kind of limit
+}
+
+// SizeLimit is a client size limit checked against the output
+type SizeLimit struct {
+	Client   string
+	Kind     string // "message" or "stylesheet"
+	Limit    int
+	Actual   int
+	Exceeded bool
+}
+
+// New creates an Inliner. Without options it uses DefaultConfig.
+func New(opts ...Option) (*Inliner, error) {
+	s := settings{config: DefaultConfig()}
+	for _, opt := range opts {
+		if err := opt(&s); err != nil {
+			return nil, err
+		}
+	}
+
+	e := engine.New(s.config.toInternal())
+	if s.loader != nil {
+		e.SetLoader(s.loader)
+	}
+	for _, change := range s.pipeline {
+		if err := change(e.Pipeline()); err != nil {
+			return nil, fmt.Errorf("failed to configure pipeline (stages: %s): %w", stageList(e.Pipeline().Stages()), err)
+		}
+	}
+	for _, rule := range s.rules {
+		if err := e.Rules().Register(rule.toInternal()); err != nil {
+			return nil, fmt.Errorf("failed to register rule: %w", err)
+		}
+	}
+	if err := e.Rules().ValidateConfig(s.config.toInternal()); err != nil {
+		return nil, err
+	}
+
+	return &Inliner{engine: e}, nil
+}
+
+// Inline inlines the CSS in an HTML document
+func (in *Inliner) Inline(ctx context.Context, html string) (*Result, error) {
+	var out strings.Builder
+	return in.InlineReader(ctx, strings.NewReader(html), &out)
+}
+
+// InlineReader reads HTML from r, inlines its CSS and writes the document
+// to w. Nothing is written if ctx is done before inlining finishes.
+func (in *Inliner) InlineReader(ctx context.Context, r io.Reader, w io.Writer) (*Result, error) {
+	result, err := in.engine.InlineContext(ctx, r, w)
+	if err != nil {
+		return nil, err
+	}
+	return fromInternalResult(result), nil
+}
+
+// Validate runs the lint rules against an HTML document without inlining it
+func (in *Inliner) Validate(ctx context.Context, html string) ([]Issue, error) {
+	found, err := in.engine.ValidateHTMLContext(ctx, html)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]Issue, len(found))
+	for j, issue := range found {
+		issues[j] = Issue{
+			Rule:      issue.Rule,
+			Category:  issue.Type,
+			Severity:  issue.Severity,
+			Message:   issue.Message,
+			Element:   issue.Element,
+			Property:  issue.Property,
+			Line:      issue.Line,
+			Reference: issue.Reference,
+		}
+	}
+	return issues, nil
+}
+
+// Inline inlines the CSS in an HTML document with a one-off Inliner
+func Inline(html string, opts ...Option) (string, error) {
+	in, err := New(opts...)
+	if err != nil {
+		return "", err
+	}
+	result, err := in.Inline(context.Background(), html)
+	if err != nil {
+		return "", err
+	}
+	return result.HTML, nil
+}
+
+// fromInternalResult converts an engine result to the public one
+func fromInternalResult(r *engine.InlineResult) *Result {
+	result := &Result{
+		HTML:           r.HTML,
+		InlinedStyles:  r.InlinedStyles,
+		PreservedRules: r.PreservedRules,
+		RemovedRules:   r.ProcessingStats.CSSRulesRemoved,
+		Size: Size{
+			TotalBytes: r.Size.TotalBytes,
+			StyleBytes: r.Size.StyleBytes,
+		},
+	}
+
+	for _, w := range r.Warnings {
+		result.Warnings = append(result.Warnings, Warning{
+			Rule:     w.Rule,
+			Severity: w.Severity,
+			Message:  w.Message,
+			Property: w.Property,
+			Value:    w.Value,
+			Element:  w.Element,
+			Line:     w.Line,
+		})
+	}
+	for _, f := range r.Fixes {
+		result.Fixes = append(result.Fixes, Fix(f))
+	}
+	for _, l := range r.Size.Limits {
+		result.Size.Limits = append(result.Size.Limits, SizeLimit(l))
+	}
+
+	return result
+}
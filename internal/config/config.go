@@ -1,6 +1,10 @@
 package config
 
-import "strings"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
 
 // Config holds configuration options for the inlining process
 type Config struct {
@@ -16,26 +20,203 @@ type Config struct {
 	// StripUnusedCSS removes CSS rules that don't match any elements
 	StripUnusedCSS bool
 
+	// CSSSafelist lists selector patterns StripUnusedCSS never removes:
+	// substrings of the selector, or regular expressions written as /.../
+	CSSSafelist []string
+
+	// PassthroughAttribute marks <style> tags that are neither inlined nor
+	// rewritten, e.g. "data-embed"; empty disables passthrough
+	PassthroughAttribute string
+
 	// EmailClientOptimizations applies email client specific optimizations
 	EmailClientOptimizations bool
 
-	// PreserveWhitespace maintains original HTML formatting
+	// PreserveWhitespace maintains original HTML formatting; when false the
+	// output is compacted. Ignored when OutputFormat is set.
 	PreserveWhitespace bool
 
+	// OutputFormat controls document whitespace: "preserve" keeps the source
+	// formatting, "pretty" re-indents block structure and "compact" collapses
+	// insignificant whitespace. Empty follows PreserveWhitespace.
+	OutputFormat string
+
+	// Indent is the indentation unit for the "pretty" output format
+	Indent string
+
 	// TargetEmailClient optimizes for specific email client
 	TargetEmailClient string
+
+	// Rules overrides lint rule severities by rule ID: "error", "warning", "info" or "off"
+	Rules map[string]string
+
+	// RuleOptions sets lint rule options by rule ID, then option name
+	RuleOptions map[string]map[string]string
+
+	// AccessibilityFixes adds role="presentation" to layout tables, alt="" to
+	// decorative images and a lang attribute to <html> during inlining
+	AccessibilityFixes bool
+
+	// DefaultLang is added as <html lang> when missing; empty leaves it alone
+	DefaultLang string
+
+	// DecorativeImageMaxSize marks images this many px or smaller in both
+	// dimensions as decorative (spacers, tracking pixels)
+	DecorativeImageMaxSize int
+
+	// DecorativeImagePatterns marks images whose src contains any of these as decorative
+	DecorativeImagePatterns []string
+
+	// Minify collapses whitespace, strips non-conditional comments and
+	// shortens CSS in the output
+	Minify bool
+
+	// DarkMode keeps @media (prefers-color-scheme: dark) rules in <style>
+	// tags even when PreserveMediaQueries is off, and adds the Outlook.com
+	// [data-ogsc]/[data-ogsb] variants of their color and background
+	// declarations
+	DarkMode bool
+
+	// ColorScheme is added to <head> as color-scheme and
+	// supported-color-schemes meta tags when they are missing, e.g.
+	// "light dark"; empty adds neither
+	ColorScheme string
+
+	// BaseURL is the URL or file path of the document, which relative
+	// <link rel="stylesheet"> hrefs are resolved against
+	BaseURL string
+
+	// SizeTargets lists the clients whose size limits the output is checked
+	// against; empty means TargetEmailClient plus gmail
+	SizeTargets []string
+}
+
+// EffectiveSizeTargets returns the clients to check size limits for
+func (c Config) EffectiveSizeTargets() []string {
+	targets := c.SizeTargets
+	if len(targets) == 0 {
+		targets = []string{c.TargetEmailClient, "gmail"}
+	}
+
+	seen := make(map[string]bool)
+	var unique []string
+	for _, target := range targets {
+		target = strings.ToLower(strings.TrimSpace(target))
+		if target != "" && !seen[target] {
+			seen[target] = true
+			unique = append(unique, target)
+		}
+	}
+	return unique
+}
+
+// Output formats for OutputFormat
+const (
+	OutputPreserve = "preserve"
+	OutputPretty   = "pretty"
+	OutputCompact  = "compact"
+)
+
+// EffectiveOutputFormat returns OutputFormat, falling back to
+// PreserveWhitespace when it is empty
+func (c Config) EffectiveOutputFormat() string {
+	if c.OutputFormat != "" {
+		return strings.ToLower(c.OutputFormat)
+	}
+	if c.PreserveWhitespace {
+		return OutputPreserve
+	}
+	return OutputCompact
+}
+
+// KnownClients lists the email clients accepted as targets
+var KnownClients = []string{"outlook", "gmail", "apple_mail", "outlook_online", "generic"}
+
+// Validate checks values the engine cannot interpret. Rule IDs and
+// severities are checked against the rule registry separately.
+func (c Config) Validate() error {
+	if !isKnownClient(c.TargetEmailClient) {
+		return fmt.Errorf("invalid target client: %s (valid: %s)", c.TargetEmailClient, strings.Join(KnownClients, ", "))
+	}
+	for _, target := range c.SizeTargets {
+		if !isKnownClient(strings.ToLower(strings.TrimSpace(target))) {
+			return fmt.Errorf("invalid size target: %s (valid: %s)", target, strings.Join(KnownClients, ", "))
+		}
+	}
+
+	switch strings.ToLower(c.OutputFormat) {
+	case "", OutputPreserve, OutputPretty, OutputCompact:
+	default:
+		return fmt.Errorf("invalid whitespace format %q (want preserve, pretty or compact)", c.OutputFormat)
+	}
+
+	for _, scheme := range strings.Fields(c.ColorScheme) {
+		switch strings.ToLower(scheme) {
+		case "light", "dark", "only", "normal":
+		default:
+			return fmt.Errorf("invalid color scheme %q (want keywords from light, dark, only and normal)", c.ColorScheme)
+		}
+	}
+
+	if c.DecorativeImageMaxSize < 0 {
+		return fmt.Errorf("invalid decorative image max size %d (must not be negative)", c.DecorativeImageMaxSize)
+	}
+
+	for _, pattern := range c.CSSSafelist {
+		if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			if _, err := regexp.Compile(pattern[1 : len(pattern)-1]); err != nil {
+				return fmt.Errorf("invalid safelist pattern %s: %w", pattern, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isKnownClient reports whether client is one of KnownClients
+func isKnownClient(client string) bool {
+	for _, known := range KnownClients {
+		if client == known {
+			return true
+		}
+	}
+	return false
 }
 
 // Default returns a configuration optimized for email clients
 func Default() Config {
 	return Config{
-		PreserveMediaQueries:     true,      // Needed for responsive emails
-		PreservePseudoSelectors:  true,      // :hover states for buttons
-		RemoveStyleTags:          false,     // Keep for email client compatibility
-		StripUnusedCSS:           true,      // Reduce email size
-		EmailClientOptimizations: true,      // Apply email-specific fixes
-		PreserveWhitespace:       true,      // Maintain email formatting
+		PreserveMediaQueries:     true,  // Needed for responsive emails
+		PreservePseudoSelectors:  true,  // :hover states for buttons
+		RemoveStyleTags:          false, // Keep for email client compatibility
+		StripUnusedCSS:           true,  // Reduce email size
+		EmailClientOptimizations: true,  // Apply email-specific fixes
+		PreserveWhitespace:       true,  // Maintain email formatting
+		Indent:                   "  ",
 		TargetEmailClient:        "generic", // Conservative defaults
+		AccessibilityFixes:       false,     // Opt-in, changes markup
+		DecorativeImageMaxSize:   2,         // 1x1 tracking pixels and 2px spacers
+		DecorativeImagePatterns:  []string{"spacer", "pixel.gif", "blank.gif", "shim.gif", "clear.gif"},
+		CSSSafelist:              DefaultCSSSafelist(),
+		PassthroughAttribute:     "data-embed",
+	}
+}
+
+// DefaultCSSSafelist returns selector patterns for client hacks that target
+// markup added by the email client rather than present in the template
+func DefaultCSSSafelist() []string {
+	return []string{
+		"u + .body",                // Gmail
+		"u ~ div",                  // Gmail
+		"#MessageViewBody",         // Samsung Mail, Outlook.com
+		"#MessageWebViewDiv",       // Samsung Mail
+		".ExternalClass",           // Outlook.com
+		".ReadMsgBody",             // Outlook.com
+		"[owa]",                    // Outlook Web App
+		"[x-apple-data-detectors]", // Apple Mail data detectors
+		"[data-ogsc]",              // Outlook.com dark mode
+		"[data-ogsb]",              // Outlook.com dark mode
+		".yshortcuts",              // Yahoo! Mail
+		"#outlook",                 // Outlook.com
 	}
 }
 
@@ -44,7 +225,9 @@ type EmailClientCompatibility struct {
 	SupportsMediaQueries    bool
 	SupportsPseudoSelectors map[string]bool // :hover, :focus, etc.
 	RequiresInlineStyles    bool
-	MaxStylesheetSize       int // in bytes, 0 = no limit
+	MaxStylesheetSize       int  // in bytes, 0 = no limit
+	MaxMessageSize          int  // in bytes, 0 = no limit; larger messages are clipped
+	SupportsShortHex        bool // #abc color shorthand
 }
 
 // GetCompatibilityProfile returns compatibility info for major email clients
@@ -56,13 +239,16 @@ func GetCompatibilityProfile(client string) EmailClientCompatibility {
 			SupportsPseudoSelectors: map[string]bool{":hover": false, ":focus": false},
 			RequiresInlineStyles:    true,
 			MaxStylesheetSize:       65536, // 64KB limit
+			SupportsShortHex:        true,
 		}
 	case "gmail", "gmail_web":
 		return EmailClientCompatibility{
 			SupportsMediaQueries:    true,
 			SupportsPseudoSelectors: map[string]bool{":hover": true, ":focus": true},
-			RequiresInlineStyles:    false, // But still recommended
-			MaxStylesheetSize:       0,     // No hard limit
+			RequiresInlineStyles:    false,      // But still recommended
+			MaxStylesheetSize:       0,          // No hard limit
+			MaxMessageSize:          102 * 1024, // "[Message clipped]" beyond ~102KB
+			SupportsShortHex:        true,
 		}
 	case "apple_mail", "mail_app":
 		return EmailClientCompatibility{
@@ -70,6 +256,7 @@ func GetCompatibilityProfile(client string) EmailClientCompatibility {
 			SupportsPseudoSelectors: map[string]bool{":hover": true, ":focus": true},
 			RequiresInlineStyles:    false,
 			MaxStylesheetSize:       0,
+			SupportsShortHex:        true,
 		}
 	case "outlook_online", "outlook_web":
 		return EmailClientCompatibility{
@@ -77,6 +264,7 @@ func GetCompatibilityProfile(client string) EmailClientCompatibility {
 			SupportsPseudoSelectors: map[string]bool{":hover": true, ":focus": false},
 			RequiresInlineStyles:    true, // Still recommended
 			MaxStylesheetSize:       65536,
+			SupportsShortHex:        true,
 		}
 	default:
 		// Conservative defaults for unknown clients
@@ -85,6 +273,7 @@ func GetCompatibilityProfile(client string) EmailClientCompatibility {
 			SupportsPseudoSelectors: map[string]bool{},
 			RequiresInlineStyles:    true,
 			MaxStylesheetSize:       32768, // 32KB conservative limit
+			SupportsShortHex:        false, // Some legacy clients (Lotus Notes) need six digits
 		}
 	}
 }
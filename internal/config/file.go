@@ -0,0 +1,566 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// FileNames lists the config file names searched for, in order of preference
+var FileNames = []string{"inliner.yaml", "inliner.yml", "inliner.json", "inliner.toml"}
+
+// maxExtendsDepth bounds extends chains between config files
+const maxExtendsDepth = 16
+
+// Settings is the file form of Config. Fields are pointers so a file only
+// overrides what it sets; names mirror the command line flags.
+type Settings struct {
+	Target                  *string                      `json:"target,omitempty"`
+	PreserveMedia           *bool                        `json:"preserve-media,omitempty"`
+	PreservePseudo          *bool                        `json:"preserve-pseudo,omitempty"`
+	RemoveStyleTags         *bool                        `json:"remove-style-tags,omitempty"`
+	StripUnused             *bool                        `json:"strip-unused,omitempty"`
+	Safelist                StringList                   `json:"safelist,omitempty"` // Added to the built-in safelist
+	PassthroughAttr         *string                      `json:"passthrough-attr,omitempty"`
+	EmailOptimizations      *bool                        `json:"email-optimizations,omitempty"`
+	PreserveWhitespace      *bool                        `json:"preserve-whitespace,omitempty"`
+	Whitespace              *string                      `json:"whitespace,omitempty"`
+	Indent                  *string                      `json:"indent,omitempty"`
+	Minify                  *bool                        `json:"minify,omitempty"`
+	A11yFix                 *bool                        `json:"a11y-fix,omitempty"`
+	Lang                    *string                      `json:"lang,omitempty"`
+	DarkMode                *bool                        `json:"dark-mode,omitempty"`
+	ColorScheme             *string                      `json:"color-scheme,omitempty"`
+	SizeTargets             StringList                   `json:"size-targets,omitempty"`
+	DecorativeImageMaxSize  *int                         `json:"decorative-image-max-size,omitempty"`
+	DecorativeImagePatterns StringList                   `json:"decorative-image-patterns,omitempty"`
+	Rules                   map[string]string            `json:"rules,omitempty"`        // Merged by rule ID
+	RuleOptions             map[string]map[string]Scalar `json:"rule-options,omitempty"` // Merged by rule ID, then option
+}
+
+// Apply overrides cfg with the settings that are set
+func (s Settings) Apply(cfg *Config) {
+	setString(&cfg.TargetEmailClient, s.Target)
+	setBool(&cfg.PreserveMediaQueries, s.PreserveMedia)
+	setBool(&cfg.PreservePseudoSelectors, s.PreservePseudo)
+	setBool(&cfg.RemoveStyleTags, s.RemoveStyleTags)
+	setBool(&cfg.StripUnusedCSS, s.StripUnused)
+	setString(&cfg.PassthroughAttribute, s.PassthroughAttr)
+	setBool(&cfg.EmailClientOptimizations, s.EmailOptimizations)
+	setBool(&cfg.PreserveWhitespace, s.PreserveWhitespace)
+	setString(&cfg.OutputFormat, s.Whitespace)
+	setString(&cfg.Indent, s.Indent)
+	setBool(&cfg.Minify, s.Minify)
+	setBool(&cfg.AccessibilityFixes, s.A11yFix)
+	setString(&cfg.DefaultLang, s.Lang)
+	setBool(&cfg.DarkMode, s.DarkMode)
+	setString(&cfg.ColorScheme, s.ColorScheme)
+
+	if s.DecorativeImageMaxSize != nil {
+		cfg.DecorativeImageMaxSize = *s.DecorativeImageMaxSize
+	}
+	if s.SizeTargets != nil {
+		cfg.SizeTargets = slices.Clone(s.SizeTargets)
+	}
+	if s.DecorativeImagePatterns != nil {
+		cfg.DecorativeImagePatterns = slices.Clone(s.DecorativeImagePatterns)
+	}
+
+	for _, pattern := range s.Safelist {
+		if !slices.Contains(cfg.CSSSafelist, pattern) {
+			cfg.CSSSafelist = append(cfg.CSSSafelist, pattern)
+		}
+	}
+
+	if len(s.Rules) > 0 {
+		rules := make(map[string]string, len(cfg.Rules)+len(s.Rules))
+		for id, severity := range cfg.Rules {
+			rules[id] = severity
+		}
+		for id, severity := range s.Rules {
+			rules[id] = strings.ToLower(strings.TrimSpace(severity))
+		}
+		cfg.Rules = rules
+	}
+
+	if len(s.RuleOptions) > 0 {
+		options := make(map[string]map[string]string, len(cfg.RuleOptions)+len(s.RuleOptions))
+		for id, values := range cfg.RuleOptions {
+			options[id] = make(map[string]string, len(values))
+			for name, value := range values {
+				options[id][name] = value
+			}
+		}
+		for id, values := range s.RuleOptions {
+			if options[id] == nil {
+				options[id] = make(map[string]string, len(values))
+			}
+			for name, value := range values {
+				options[id][name] = string(value)
+			}
+		}
+		cfg.RuleOptions = options
+	}
+}
+
+// SettingsFromConfig returns settings that reproduce cfg in full, for
+// printing the effective configuration
+func SettingsFromConfig(cfg Config) Settings {
+	s := Settings{
+		Target:                  &cfg.TargetEmailClient,
+		PreserveMedia:           &cfg.PreserveMediaQueries,
+		PreservePseudo:          &cfg.PreservePseudoSelectors,
+		RemoveStyleTags:         &cfg.RemoveStyleTags,
+		StripUnused:             &cfg.StripUnusedCSS,
+		Safelist:                StringList(cfg.CSSSafelist),
+		PassthroughAttr:         &cfg.PassthroughAttribute,
+		EmailOptimizations:      &cfg.EmailClientOptimizations,
+		PreserveWhitespace:      &cfg.PreserveWhitespace,
+		Whitespace:              &cfg.OutputFormat,
+		Indent:                  &cfg.Indent,
+		Minify:                  &cfg.Minify,
+		A11yFix:                 &cfg.AccessibilityFixes,
+		Lang:                    &cfg.DefaultLang,
+		DarkMode:                &cfg.DarkMode,
+		ColorScheme:             &cfg.ColorScheme,
+		SizeTargets:             StringList(cfg.SizeTargets),
+		DecorativeImageMaxSize:  &cfg.DecorativeImageMaxSize,
+		DecorativeImagePatterns: StringList(cfg.DecorativeImagePatterns),
+		Rules:                   cfg.Rules,
+	}
+
+	if len(cfg.RuleOptions) > 0 {
+		s.RuleOptions = make(map[string]map[string]Scalar, len(cfg.RuleOptions))
+		for id, values := range cfg.RuleOptions {
+			s.RuleOptions[id] = make(map[string]Scalar, len(values))
+			for name, value := range values {
+				s.RuleOptions[id][name] = Scalar(value)
+			}
+		}
+	}
+	return s
+}
+
+// setString copies a set string setting
+func setString(dst *string, value *string) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+// setBool copies a set boolean setting
+func setBool(dst *bool, value *bool) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+// StringList is a list of strings that may be written as a single string
+type StringList []string
+
+// UnmarshalJSON accepts "a" as well as ["a", "b"]
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = StringList{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("expected a string or a list of strings, got %s", data)
+	}
+	*l = list
+	return nil
+}
+
+// Scalar is a rule option value; numbers and booleans are kept as text
+type Scalar string
+
+// UnmarshalJSON accepts strings, numbers and booleans
+func (s *Scalar) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch value := value.(type) {
+	case string:
+		*s = Scalar(value)
+	case float64, bool:
+		*s = Scalar(strings.TrimSpace(string(data)))
+	default:
+		return fmt.Errorf("expected a string, number or boolean, got %s", data)
+	}
+	return nil
+}
+
+// Profile is a named set of settings applied on top of the file, optionally
+// extending another profile
+type Profile struct {
+	Extends string `json:"extends,omitempty"`
+	Settings
+}
+
+// Override applies settings to inputs matching any of its glob patterns,
+// relative to the directory of the file that declares it
+type Override struct {
+	Files StringList `json:"files"`
+	Settings
+}
+
+// File is a parsed config file
+type File struct {
+	// Extends lists config files applied before this one, relative to it
+	Extends StringList `json:"extends,omitempty"`
+
+	Settings
+
+	// Profiles are selected by name with -profile
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+
+	// Overrides apply in order to matching inputs
+	Overrides []Override `json:"overrides,omitempty"`
+
+	// Path is the file the settings were read from
+	Path string `json:"-"`
+}
+
+// ParseFile parses a YAML, JSON or TOML config file, chosen by extension
+func ParseFile(filename string, data []byte) (*File, error) {
+	var raw []byte
+
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json":
+		raw = data
+	case ".yaml", ".yml", ".toml":
+		var (
+			values map[string]any
+			err    error
+		)
+		if ext == ".toml" {
+			values, err = parseTOML(string(data))
+		} else {
+			values, err = parseYAML(string(data))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		if raw, err = json.Marshal(values); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported config format %q (use .yaml, .yml, .json or .toml)", filename, ext)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+
+	file := &File{Path: filename}
+	if err := decoder.Decode(file); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, describeDecodeError(err))
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("%s: unexpected content after the config object", filename)
+	}
+
+	for j, override := range file.Overrides {
+		if len(override.Files) == 0 {
+			return nil, fmt.Errorf("%s: overrides[%d] has no files patterns", filename, j)
+		}
+	}
+	return file, nil
+}
+
+// describeDecodeError rewrites JSON decoding errors in terms of settings
+func describeDecodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Errorf("expected a mapping of settings, got %s", typeErr.Value)
+		}
+		return fmt.Errorf("setting %q: expected %s, got %s", typeErr.Field, describeType(typeErr.Type.String()), typeErr.Value)
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("invalid JSON at offset %d: %v", syntaxErr.Offset, err)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Errorf("unknown setting %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	}
+	return errors.New(strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// describeType names a Go type the way a config author would
+func describeType(goType string) string {
+	switch strings.TrimPrefix(goType, "*") {
+	case "bool":
+		return "true or false"
+	case "int":
+		return "a number"
+	case "string":
+		return "a string"
+	case "config.Profile":
+		return "a mapping of settings"
+	}
+	if strings.HasPrefix(goType, "map[") {
+		return "a mapping"
+	}
+	if strings.HasPrefix(goType, "[]") {
+		return "a list"
+	}
+	return goType
+}
+
+// Discover searches dir and its parents for a config file and returns its
+// path, or "" when there is none
+func Discover(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		var found []string
+		for _, name := range FileNames {
+			candidate := filepath.Join(dir, name)
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				found = append(found, candidate)
+			}
+		}
+
+		switch len(found) {
+		case 0:
+		case 1:
+			return found[0], nil
+		default:
+			return "", fmt.Errorf("multiple config files in %s: %s", dir, strings.Join(found, ", "))
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// FileSet is a config file together with the files it extends, base first
+type FileSet struct {
+	Files []*File
+}
+
+// LoadFile reads a config file and every file it extends
+func LoadFile(filename string) (*FileSet, error) {
+	set := &FileSet{}
+	if err := set.load(filename, nil); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// load appends the extended files and then filename itself; chain holds the
+// files currently being loaded, for cycle detection
+func (s *FileSet) load(filename string, chain []string) error {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(chain, abs) {
+		return fmt.Errorf("config extends cycle: %s", strings.Join(append(chain, abs), " -> "))
+	}
+	if len(chain) >= maxExtendsDepth {
+		return fmt.Errorf("%s: extends chain is deeper than %d files", filename, maxExtendsDepth)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	file, err := ParseFile(filename, data)
+	if err != nil {
+		return err
+	}
+
+	for _, base := range file.Extends {
+		if !filepath.IsAbs(base) {
+			base = filepath.Join(filepath.Dir(filename), base)
+		}
+		if err := s.load(base, append(chain, abs)); err != nil {
+			return err
+		}
+	}
+
+	s.Files = append(s.Files, file)
+	return nil
+}
+
+// Paths returns the files in the set, base first
+func (s *FileSet) Paths() []string {
+	paths := make([]string, len(s.Files))
+	for j, file := range s.Files {
+		paths[j] = file.Path
+	}
+	return paths
+}
+
+// Profiles returns the names of every profile in the set, sorted
+func (s *FileSet) Profiles() []string {
+	var names []string
+	for _, file := range s.Files {
+		for name := range file.Profiles {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the effective configuration for an input file: defaults,
+// then each file base first, then the named profile, then every override
+// matching inputPath. Empty profile or inputPath skip those steps.
+func (s *FileSet) Resolve(profile, inputPath string) (Config, error) {
+	cfg := Default()
+	for _, file := range s.Files {
+		file.Settings.Apply(&cfg)
+	}
+
+	if profile != "" {
+		chain, err := s.profileChain(profile)
+		if err != nil {
+			return cfg, err
+		}
+		for j := len(chain) - 1; j >= 0; j-- {
+			chain[j].Apply(&cfg)
+		}
+	}
+
+	if inputPath != "" {
+		for _, file := range s.Files {
+			for _, override := range file.Overrides {
+				if override.matches(file.Path, inputPath) {
+					override.Settings.Apply(&cfg)
+				}
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// profileChain returns the named profile followed by the profiles it extends
+func (s *FileSet) profileChain(name string) ([]Settings, error) {
+	var chain []Settings
+	var seen []string
+
+	for name != "" {
+		if slices.Contains(seen, name) {
+			return nil, fmt.Errorf("profile extends cycle: %s", strings.Join(append(seen, name), " -> "))
+		}
+		seen = append(seen, name)
+
+		profile, ok := s.lookupProfile(name)
+		if !ok {
+			available := "none defined"
+			if names := s.Profiles(); len(names) > 0 {
+				available = "available: " + strings.Join(names, ", ")
+			}
+			if len(seen) > 1 {
+				return nil, fmt.Errorf("profile %q extends unknown profile %q (%s)", seen[len(seen)-2], name, available)
+			}
+			return nil, fmt.Errorf("unknown profile %q (%s)", name, available)
+		}
+
+		chain = append(chain, profile.Settings)
+		name = profile.Extends
+	}
+	return chain, nil
+}
+
+// lookupProfile finds a profile, preferring the most derived file
+func (s *FileSet) lookupProfile(name string) (Profile, bool) {
+	for j := len(s.Files) - 1; j >= 0; j-- {
+		if profile, ok := s.Files[j].Profiles[name]; ok {
+			return profile, true
+		}
+	}
+	return Profile{}, false
+}
+
+// matches reports whether inputPath matches one of the override's patterns,
+// taken relative to the directory of configPath
+func (o Override) matches(configPath, inputPath string) bool {
+	base, err := filepath.Abs(filepath.Dir(configPath))
+	if err != nil {
+		return false
+	}
+	input, err := filepath.Abs(inputPath)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(base, input)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+
+	for _, pattern := range o.Files {
+		if MatchPath(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPath reports whether a slash-separated relative path matches a glob
+// pattern. Patterns without a slash also match the file name in any
+// directory.
+func MatchPath(pattern, rel string) bool {
+	if MatchGlob(pattern, rel) {
+		return true
+	}
+	return !strings.Contains(pattern, "/") && MatchGlob(pattern, path.Base(rel))
+}
+
+// MatchGlob reports whether a slash-separated name matches a glob pattern.
+// Besides the path.Match syntax, a "**" segment matches any number of
+// directories.
+func MatchGlob(pattern, name string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches pattern segments against name segments
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(name); skip++ {
+				if matchSegments(pattern[1:], name[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
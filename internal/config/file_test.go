@@ -0,0 +1,327 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// equivalentFiles describe the same settings in each supported format
+var equivalentFiles = map[string]string{
+	"inliner.yaml": `
+target: outlook # Desktop Outlook first
+minify: true
+safelist: [.brand-*, '#footer']
+decorative-image-max-size: 2
+rules:
+  css-viewport-units: off
+  a11y-img-alt: warning
+rule-options:
+  a11y-contrast:
+    min-ratio: 4.5
+profiles:
+  dev:
+    whitespace: pretty
+    indent: "\t"
+  ci:
+    extends: dev
+    minify: false
+overrides:
+  - files: ["promo/**/*.html"]
+    dark-mode: true
+`,
+	"inliner.toml": `
+target = "outlook" # Desktop Outlook first
+minify = true
+safelist = [".brand-*", '#footer']
+decorative-image-max-size = 2
+
+[rules]
+css-viewport-units = "off"
+a11y-img-alt = "warning"
+
+[rule-options.a11y-contrast]
+min-ratio = 4.5
+
+[profiles.dev]
+whitespace = "pretty"
+indent = "\t"
+
+[profiles.ci]
+extends = "dev"
+minify = false
+
+[[overrides]]
+files = [
+  "promo/**/*.html",
+]
+dark-mode = true
+`,
+	"inliner.json": `{
+  "target": "outlook",
+  "minify": true,
+  "safelist": [".brand-*", "#footer"],
+  "decorative-image-max-size": 2,
+  "rules": {"css-viewport-units": "off", "a11y-img-alt": "warning"},
+  "rule-options": {"a11y-contrast": {"min-ratio": 4.5}},
+  "profiles": {
+    "dev": {"whitespace": "pretty", "indent": "\t"},
+    "ci": {"extends": "dev", "minify": false}
+  },
+  "overrides": [{"files": "promo/**/*.html", "dark-mode": true}]
+}`,
+}
+
+// writeFiles writes files into a new temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFormatsProduceSameConfig(t *testing.T) {
+	dir := writeFiles(t, equivalentFiles)
+	input := filepath.Join(dir, "promo", "2024", "sale.html")
+
+	var first Config
+	var firstName string
+	for name := range equivalentFiles {
+		set, err := LoadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		cfg, err := set.Resolve("ci", input)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if cfg.TargetEmailClient != "outlook" || cfg.Minify || cfg.OutputFormat != OutputPretty ||
+			cfg.Indent != "\t" || !cfg.DarkMode || cfg.DecorativeImageMaxSize != 2 {
+			t.Errorf("%s: resolved %+v", name, cfg)
+		}
+		if !slices.Contains(cfg.CSSSafelist, ".brand-*") || !slices.Contains(cfg.CSSSafelist, "#footer") {
+			t.Errorf("%s: safelist = %q", name, cfg.CSSSafelist)
+		}
+		if cfg.Rules["css-viewport-units"] != "off" || cfg.RuleOptions["a11y-contrast"]["min-ratio"] != "4.5" {
+			t.Errorf("%s: rules = %v, options = %v", name, cfg.Rules, cfg.RuleOptions)
+		}
+
+		if firstName == "" {
+			first, firstName = cfg, name
+		} else if !reflect.DeepEqual(cfg, first) {
+			t.Errorf("%s resolves to\n%+v\n%s to\n%+v", name, cfg, firstName, first)
+		}
+	}
+}
+
+func TestParseFileErrors(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"inliner.yaml", "targt: outlook", `inliner.yaml: unknown setting "targt"`},
+		{"inliner.toml", "[profiles.dev]\nminfy = true", `inliner.toml: unknown setting "minfy"`},
+		{"inliner.json", `{"overrides": [{"files": "*.html", "colour": "x"}]}`, `inliner.json: unknown setting "colour"`},
+		{"inliner.yaml", "minify: yes please", `setting "minify": expected true or false, got string`},
+		{"inliner.yaml", "decorative-image-max-size: big", `setting "decorative-image-max-size": expected a number, got string`},
+		{"inliner.yaml", "rules: [a, b]", `setting "rules": expected a mapping, got array`},
+		{"inliner.toml", "target = \"a\"\ntarget = \"b\"", `inliner.toml: line 2: duplicate key "target"`},
+		{"inliner.toml", "target = outlook", `line 1: invalid value "outlook" (strings must be quoted)`},
+		{"inliner.yaml", "overrides:\n  - minify: true", "overrides[0] has no files patterns"},
+		{"inliner.json", `{"minify": true} {}`, "unexpected content after the config object"},
+		{"inliner.ini", "minify = true", `unsupported config format ".ini"`},
+	}
+
+	for _, test := range tests {
+		_, err := ParseFile(test.name, []byte(test.content))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s %q: error = %v, want %q", test.name, test.content, err, test.want)
+		}
+	}
+}
+
+func TestLoadFileExtends(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base/inliner.yaml": "target: gmail\nminify: true\nsafelist: [.base]",
+		"team.toml":         "extends = \"base/inliner.yaml\"\ntarget = \"apple_mail\"",
+		"inliner.yaml":      "extends: [team.toml]\nsafelist: [.local]",
+	})
+
+	set, err := LoadFile(filepath.Join(dir, "inliner.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, path := range set.Paths() {
+		rel, _ := filepath.Rel(dir, path)
+		names = append(names, filepath.ToSlash(rel))
+	}
+	if want := []string{"base/inliner.yaml", "team.toml", "inliner.yaml"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Paths = %q, want %q (base first)", names, want)
+	}
+
+	cfg, err := set.Resolve("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TargetEmailClient != "apple_mail" || !cfg.Minify {
+		t.Errorf("target = %s, minify = %v; want the derived target and the base minify", cfg.TargetEmailClient, cfg.Minify)
+	}
+	if !slices.Contains(cfg.CSSSafelist, ".base") || !slices.Contains(cfg.CSSSafelist, ".local") {
+		t.Errorf("safelist = %q, want both files' entries", cfg.CSSSafelist)
+	}
+}
+
+func TestLoadFileExtendsCycle(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.yaml": "extends: b.yaml",
+		"b.yaml": "extends: c.json",
+		"c.json": `{"extends": "a.yaml"}`,
+	})
+
+	_, err := LoadFile(filepath.Join(dir, "a.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "config extends cycle: ") {
+		t.Fatalf("error = %v, want an extends cycle", err)
+	}
+	for _, name := range []string{"a.yaml -> ", "b.yaml -> ", "c.json -> ", "a.yaml"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not show %s in the cycle", err, name)
+		}
+	}
+}
+
+func TestResolveProfiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.yaml": `
+minify: true
+profiles:
+  email: {target: gmail, lang: en}
+  loop: {extends: loop}
+`,
+		"inliner.yaml": `
+extends: base.yaml
+target: outlook
+profiles:
+  email: {target: apple_mail}
+  campaign: {extends: email, lang: de, whitespace: compact}
+  broken: {extends: missing}
+overrides:
+  - files: "*.html"
+    lang: fr
+`,
+	})
+	set, err := LoadFile(filepath.Join(dir, "inliner.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		profile, input     string
+		target, lang, mode string
+	}{
+		{"", "", "outlook", "", ""},
+		{"email", "", "apple_mail", "", ""}, // The derived file's profile replaces the base's
+		{"campaign", "", "apple_mail", "de", OutputCompact},
+		{"campaign", filepath.Join(dir, "news.html"), "apple_mail", "fr", OutputCompact}, // Overrides beat profiles
+	}
+	for _, test := range tests {
+		cfg, err := set.Resolve(test.profile, test.input)
+		if err != nil {
+			t.Errorf("Resolve(%q, %q): %v", test.profile, test.input, err)
+			continue
+		}
+		if cfg.TargetEmailClient != test.target || cfg.DefaultLang != test.lang || cfg.OutputFormat != test.mode || !cfg.Minify {
+			t.Errorf("Resolve(%q, %q): target %s, lang %q, whitespace %q, minify %v; want %s, %q, %q, true",
+				test.profile, test.input, cfg.TargetEmailClient, cfg.DefaultLang, cfg.OutputFormat, cfg.Minify,
+				test.target, test.lang, test.mode)
+		}
+	}
+
+	errors := map[string]string{
+		"nope":   `unknown profile "nope" (available: broken, campaign, email, loop)`,
+		"broken": `profile "broken" extends unknown profile "missing"`,
+		"loop":   "profile extends cycle: loop -> loop",
+	}
+	for profile, want := range errors {
+		if _, err := set.Resolve(profile, ""); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Resolve(%q) error = %v, want %q", profile, err, want)
+		}
+	}
+}
+
+func TestOverridesApplyInOrder(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"inliner.yaml": `
+overrides:
+  - files: ["**/*.html"]
+    target: gmail
+    lang: en
+  - files: ["promo/**"]
+    target: apple_mail
+  - files: ["./promo/*/sale.html", "other/*.html"]
+    lang: de
+`,
+	})
+	set, err := LoadFile(filepath.Join(dir, "inliner.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input, target, lang string
+	}{
+		{"news.html", "gmail", "en"},
+		{"promo/sale.html", "apple_mail", "en"},
+		{"promo/2024/sale.html", "apple_mail", "de"},
+		{"promo/2024/deep/sale.html", "apple_mail", "en"},
+		{"other/a.html", "gmail", "de"},
+		{"../outside.html", Default().TargetEmailClient, ""},
+	}
+	for _, test := range tests {
+		cfg, err := set.Resolve("", filepath.Join(dir, filepath.FromSlash(test.input)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.TargetEmailClient != test.target || cfg.DefaultLang != test.lang {
+			t.Errorf("%s: target %s, lang %q; want %s, %q", test.input, cfg.TargetEmailClient, cfg.DefaultLang, test.target, test.lang)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"*.html", "a.html", true},
+		{"*.html", "dir/a.html", true}, // No slash: matches the name in any directory
+		{"dir/*.html", "dir/a.html", true},
+		{"dir/*.html", "dir/sub/a.html", false},
+		{"dir/**/*.html", "dir/a.html", true},
+		{"dir/**/*.html", "dir/sub/deep/a.html", true},
+		{"**/a.html", "a.html", true},
+		{"**", "any/thing.txt", true},
+		{"dir/**", "dir", true},
+		{"dir/**", "other/a.html", false},
+		{"./dir/a.html", "dir/a.html", true},
+		{"a?.htm[lx]", "ab.htmx", true},
+		{"[", "[", false}, // Bad pattern
+	}
+
+	for _, test := range tests {
+		if got := MatchPath(test.pattern, test.rel); got != test.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", test.pattern, test.rel, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// flowScanner parses inline values shared by the YAML and TOML readers:
+// quoted strings, numbers, booleans, [lists] and {tables}
+type flowScanner struct {
+	text  string
+	pos   int
+	line  int
+	yaml  bool // YAML plain scalars and "key: value"; otherwise TOML rules
+	depth int  // Nesting inside [ ] or { }
+}
+
+// value parses the value at the current position
+func (s *flowScanner) value() (any, error) {
+	s.skipSpace()
+	if s.pos >= len(s.text) {
+		return nil, s.errorf("missing value")
+	}
+
+	switch s.text[s.pos] {
+	case '[':
+		return s.list()
+	case '{':
+		return s.table()
+	case '"':
+		return s.doubleQuoted()
+	case '\'':
+		return s.singleQuoted()
+	}
+	return s.plain()
+}
+
+// list parses [a, b, c]
+func (s *flowScanner) list() ([]any, error) {
+	s.pos++ // [
+	s.depth++
+	defer func() { s.depth-- }()
+
+	result := []any{}
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.text) {
+			return nil, s.errorf("unterminated list")
+		}
+		if s.text[s.pos] == ']' {
+			s.pos++
+			return result, nil
+		}
+
+		item, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+
+		if err := s.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// table parses {a: 1, b: 2} (YAML) or {a = 1, b = 2} (TOML)
+func (s *flowScanner) table() (map[string]any, error) {
+	s.pos++ // {
+	s.depth++
+	defer func() { s.depth-- }()
+
+	assign := byte('=')
+	if s.yaml {
+		assign = ':'
+	}
+
+	result := make(map[string]any)
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.text) {
+			return nil, s.errorf("unterminated table")
+		}
+		if s.text[s.pos] == '}' {
+			s.pos++
+			return result, nil
+		}
+
+		key, err := s.key(assign)
+		if err != nil {
+			return nil, err
+		}
+		s.skipSpace()
+		if s.pos >= len(s.text) || s.text[s.pos] != assign {
+			return nil, s.errorf("expected %q after key %q", assign, key)
+		}
+		s.pos++
+
+		value, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := result[key]; exists {
+			return nil, s.errorf("duplicate key %q", key)
+		}
+		result[key] = value
+
+		if err := s.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// key parses a quoted or bare key ending before the assignment character
+func (s *flowScanner) key(assign byte) (string, error) {
+	s.skipSpace()
+	if s.pos < len(s.text) && (s.text[s.pos] == '"' || s.text[s.pos] == '\'') {
+		value, err := s.value()
+		if err != nil {
+			return "", err
+		}
+		return value.(string), nil
+	}
+
+	start := s.pos
+	for s.pos < len(s.text) && s.text[s.pos] != assign && s.text[s.pos] != ',' && s.text[s.pos] != '}' {
+		s.pos++
+	}
+	key := strings.TrimSpace(s.text[start:s.pos])
+	if key == "" {
+		return "", s.errorf("missing key")
+	}
+	return key, nil
+}
+
+// separator consumes the comma between items, or stops before the closing bracket
+func (s *flowScanner) separator(closing byte) error {
+	s.skipSpace()
+	if s.pos >= len(s.text) {
+		return s.errorf("missing %q", closing)
+	}
+	switch s.text[s.pos] {
+	case ',':
+		s.pos++
+		return nil
+	case closing:
+		return nil
+	}
+	return s.errorf("expected ',' or %q, got %q", closing, s.text[s.pos:])
+}
+
+// doubleQuoted parses a "string" with backslash escapes
+func (s *flowScanner) doubleQuoted() (string, error) {
+	start := s.pos
+	for j := s.pos + 1; j < len(s.text); j++ {
+		switch s.text[j] {
+		case '\\':
+			j++
+		case '"':
+			s.pos = j + 1
+			value, err := strconv.Unquote(s.text[start:s.pos])
+			if err != nil {
+				return "", s.errorf("invalid string %s", s.text[start:s.pos])
+			}
+			return value, nil
+		}
+	}
+	return "", s.errorf("unterminated string")
+}
+
+// singleQuoted parses a 'string' without escapes, except that YAML writes a
+// quote inside it as two quotes
+func (s *flowScanner) singleQuoted() (string, error) {
+	var out strings.Builder
+	for j := s.pos + 1; j < len(s.text); j++ {
+		if s.text[j] != '\'' {
+			out.WriteByte(s.text[j])
+			continue
+		}
+		if s.yaml && j+1 < len(s.text) && s.text[j+1] == '\'' {
+			out.WriteByte('\'')
+			j++
+			continue
+		}
+		s.pos = j + 1
+		return out.String(), nil
+	}
+	return "", s.errorf("unterminated string")
+}
+
+// plain parses an unquoted scalar: a boolean, number, null or (YAML only) string
+func (s *flowScanner) plain() (any, error) {
+	start := s.pos
+	for s.pos < len(s.text) {
+		c := s.text[s.pos]
+		if s.depth > 0 && (c == ',' || c == ']' || c == '}') {
+			break
+		}
+		if !s.yaml && (c == ' ' || c == '\t' || c == ',' || c == ']' || c == '}') {
+			break
+		}
+		s.pos++
+	}
+	token := strings.TrimSpace(s.text[start:s.pos])
+
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if s.yaml {
+		switch token {
+		case "True", "TRUE":
+			return true, nil
+		case "False", "FALSE":
+			return false, nil
+		case "null", "Null", "NULL", "~":
+			return nil, nil
+		}
+		if strings.HasPrefix(token, "|") || strings.HasPrefix(token, ">") {
+			return nil, s.errorf("block scalars are not supported")
+		}
+		if strings.HasPrefix(token, "&") || strings.HasPrefix(token, "*") || strings.HasPrefix(token, "!") {
+			return nil, s.errorf("anchors, aliases and tags are not supported")
+		}
+	}
+
+	number := strings.ReplaceAll(token, "_", "")
+	if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil {
+		return f, nil
+	}
+
+	if !s.yaml {
+		return nil, s.errorf("invalid value %q (strings must be quoted)", token)
+	}
+	return token, nil
+}
+
+// skipSpace skips spaces and tabs
+func (s *flowScanner) skipSpace() {
+	for s.pos < len(s.text) && (s.text[s.pos] == ' ' || s.text[s.pos] == '\t') {
+		s.pos++
+	}
+}
+
+// errorf reports an error at the scanner's line
+func (s *flowScanner) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", s.line, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseTOML parses the subset of TOML used by config files: [tables],
+// [[arrays of tables]], dotted and quoted keys, strings, numbers, booleans,
+// arrays (which may span lines) and inline tables. Multi-line strings and
+// dates are not supported.
+func parseTOML(src string) (map[string]any, error) {
+	root := make(map[string]any)
+	current := root
+
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	for j := 0; j < len(lines); j++ {
+		num := j + 1
+		text := strings.TrimSpace(stripTOMLComment(lines[j]))
+		if text == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(text, "[["):
+			if !strings.HasSuffix(text, "]]") {
+				return nil, fmt.Errorf("line %d: unterminated array of tables header", num)
+			}
+			path, err := parseTOMLKey(text[2:len(text)-2], num)
+			if err != nil {
+				return nil, err
+			}
+			parent, err := tomlTable(root, path[:len(path)-1], num)
+			if err != nil {
+				return nil, err
+			}
+			last := path[len(path)-1]
+			var tables []any
+			if existing, ok := parent[last]; ok {
+				if tables, ok = existing.([]any); !ok {
+					return nil, fmt.Errorf("line %d: %q is already defined", num, strings.Join(path, "."))
+				}
+			}
+			current = make(map[string]any)
+			parent[last] = append(tables, current)
+
+		case strings.HasPrefix(text, "["):
+			if !strings.HasSuffix(text, "]") {
+				return nil, fmt.Errorf("line %d: unterminated table header", num)
+			}
+			path, err := parseTOMLKey(text[1:len(text)-1], num)
+			if err != nil {
+				return nil, err
+			}
+			if current, err = tomlTable(root, path, num); err != nil {
+				return nil, err
+			}
+
+		default:
+			eq := indexUnquoted(text, '=')
+			if eq < 0 {
+				return nil, fmt.Errorf("line %d: expected \"key = value\", got %q", num, text)
+			}
+			path, err := parseTOMLKey(text[:eq], num)
+			if err != nil {
+				return nil, err
+			}
+
+			raw := strings.TrimSpace(text[eq+1:])
+			if strings.HasPrefix(raw, `"""`) || strings.HasPrefix(raw, "'''") {
+				return nil, fmt.Errorf("line %d: multi-line strings are not supported", num)
+			}
+			// Arrays may continue over following lines until brackets balance
+			for bracketDepth(raw) > 0 && j+1 < len(lines) {
+				j++
+				raw += " " + strings.TrimSpace(stripTOMLComment(lines[j]))
+			}
+
+			s := &flowScanner{text: raw, line: num}
+			value, err := s.value()
+			if err != nil {
+				return nil, err
+			}
+			s.skipSpace()
+			if s.pos < len(s.text) {
+				return nil, s.errorf("unexpected %q", s.text[s.pos:])
+			}
+
+			table, err := tomlTable(current, path[:len(path)-1], num)
+			if err != nil {
+				return nil, err
+			}
+			key := path[len(path)-1]
+			if _, exists := table[key]; exists {
+				return nil, fmt.Errorf("line %d: duplicate key %q", num, strings.Join(path, "."))
+			}
+			table[key] = value
+		}
+	}
+
+	return root, nil
+}
+
+// tomlTable walks to the table at path, creating missing tables. A path
+// through an array of tables continues from its last element.
+func tomlTable(table map[string]any, path []string, line int) (map[string]any, error) {
+	for _, key := range path {
+		switch next := table[key].(type) {
+		case nil:
+			created := make(map[string]any)
+			table[key] = created
+			table = created
+		case map[string]any:
+			table = next
+		case []any:
+			last, ok := next[len(next)-1].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("line %d: %q is not a table", line, key)
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("line %d: %q is not a table", line, key)
+		}
+	}
+	return table, nil
+}
+
+// parseTOMLKey splits a dotted key such as a."b.c".d into its parts
+func parseTOMLKey(text string, line int) ([]string, error) {
+	s := &flowScanner{text: strings.TrimSpace(text), line: line}
+	var path []string
+
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.text) {
+			return nil, s.errorf("missing key")
+		}
+
+		switch s.text[s.pos] {
+		case '"':
+			part, err := s.doubleQuoted()
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, part)
+		case '\'':
+			part, err := s.singleQuoted()
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, part)
+		default:
+			start := s.pos
+			for s.pos < len(s.text) && isTOMLBareKeyChar(s.text[s.pos]) {
+				s.pos++
+			}
+			if s.pos == start {
+				return nil, s.errorf("invalid key %q", s.text)
+			}
+			path = append(path, s.text[start:s.pos])
+		}
+
+		s.skipSpace()
+		if s.pos >= len(s.text) {
+			return path, nil
+		}
+		if s.text[s.pos] != '.' {
+			return nil, s.errorf("invalid key %q", s.text)
+		}
+		s.pos++
+	}
+}
+
+// isTOMLBareKeyChar reports whether c may appear in an unquoted key
+func isTOMLBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// stripTOMLComment removes a trailing # comment outside quotes
+func stripTOMLComment(line string) string {
+	if j := indexUnquoted(line, '#'); j >= 0 {
+		return line[:j]
+	}
+	return line
+}
+
+// indexUnquoted returns the index of the first c outside quoted strings, or -1
+func indexUnquoted(text string, c byte) int {
+	var quote byte
+	for j := 0; j < len(text); j++ {
+		switch {
+		case quote == '"' && text[j] == '\\':
+			j++
+		case quote != 0:
+			if text[j] == quote {
+				quote = 0
+			}
+		case text[j] == '"' || text[j] == '\'':
+			quote = text[j]
+		case text[j] == c:
+			return j
+		}
+	}
+	return -1
+}
+
+// bracketDepth returns the number of unclosed [ and { outside quoted strings
+func bracketDepth(text string) int {
+	depth := 0
+	var quote byte
+	for j := 0; j < len(text); j++ {
+		switch c := text[j]; {
+		case quote == '"' && c == '\\':
+			j++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
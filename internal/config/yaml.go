@@ -0,0 +1,253 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// yamlLine is a non-blank line of YAML with comments removed
+type yamlLine struct {
+	indent int
+	text   string
+	num    int
+}
+
+// parseYAML parses the subset of YAML used by config files: nested block
+// mappings and sequences, flow [lists] and {maps}, quoted and plain
+// scalars, and comments. Anchors, tags and block scalars are not supported.
+func parseYAML(src string) (map[string]any, error) {
+	var lines []yamlLine
+	for j, raw := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		text := strings.TrimRight(stripYAMLComment(raw), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", j+1)
+		}
+		lines = append(lines, yamlLine{indent: len(text) - len(trimmed), text: trimmed, num: j + 1})
+	}
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+
+	p := &yamlParser{lines: lines}
+	value, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+
+	root, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("line %d: top level must be a mapping", lines[0].num)
+	}
+	return root, nil
+}
+
+// yamlParser walks the lines of a YAML document
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// block parses the mapping or sequence starting at the current line
+func (p *yamlParser) block(indent int) (any, error) {
+	if isYAMLSequenceItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+// mapping parses "key: value" lines at the given indent
+func (p *yamlParser) mapping(indent int) (map[string]any, error) {
+	result := make(map[string]any)
+
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && !isYAMLSequenceItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		key, rest, err := splitYAMLKey(line)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := result[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+
+		if rest != "" {
+			value, err := parseFlowValue(rest, line.num)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+			continue
+		}
+
+		// Nested block: deeper indent, or a sequence at the same indent
+		switch {
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			value, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSequenceItem(p.lines[p.pos].text):
+			value, err := p.sequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+		default:
+			result[key] = nil
+		}
+	}
+
+	return result, nil
+}
+
+// sequence parses "- item" lines at the given indent
+func (p *yamlParser) sequence(indent int) ([]any, error) {
+	var result []any
+
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSequenceItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+
+		switch {
+		case rest == "":
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				value, err := p.block(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, value)
+			} else {
+				result = append(result, nil)
+			}
+
+		case isYAMLMappingEntry(rest):
+			// "- key: value" starts a mapping indented to the key
+			p.lines[p.pos] = yamlLine{indent: indent + len(line.text) - len(rest), text: rest, num: line.num}
+			value, err := p.mapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+
+		default:
+			value, err := parseFlowValue(rest, line.num)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+			p.pos++
+		}
+	}
+
+	return result, nil
+}
+
+// isYAMLSequenceItem reports whether a line starts a sequence item
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// isYAMLMappingEntry reports whether text is "key: value" or "key:"
+func isYAMLMappingEntry(text string) bool {
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return false
+	}
+	_, _, err := splitYAMLKey(yamlLine{text: text})
+	return err == nil
+}
+
+// splitYAMLKey splits "key: value" into the key and the raw value
+func splitYAMLKey(line yamlLine) (string, string, error) {
+	text := line.text
+	end := -1
+	if text[0] == '"' || text[0] == '\'' {
+		closing := strings.IndexByte(text[1:], text[0])
+		if closing >= 0 {
+			end = closing + 2
+		}
+	} else {
+		for j := 0; j < len(text); j++ {
+			if text[j] == ':' && (j+1 == len(text) || text[j+1] == ' ') {
+				end = j
+				break
+			}
+		}
+	}
+	if end < 0 || end >= len(text) || text[end] != ':' {
+		return "", "", fmt.Errorf("line %d: expected \"key: value\", got %q", line.num, text)
+	}
+
+	key, err := parseFlowValue(text[:end], line.num)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprint(key), strings.TrimSpace(text[end+1:]), nil
+}
+
+// stripYAMLComment removes a trailing # comment outside quoted strings. A
+// quote only opens a string at the start of a scalar or flow item, so the
+// apostrophe in "target: don't # comment" does not hide the comment.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for j := 0; j < len(line); j++ {
+		switch c := line[j]; {
+		case quote == '"':
+			if c == '\\' {
+				j++
+			} else if c == '"' {
+				quote = 0
+			}
+		case quote == '\'':
+			if c == '\'' && j+1 < len(line) && line[j+1] == '\'' {
+				j++ // Escaped quote
+			} else if c == '\'' {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && startsYAMLScalar(line[:j]):
+			quote = c
+		case c == '#' && (j == 0 || line[j-1] == ' ' || line[j-1] == '\t'):
+			return line[:j]
+		}
+	}
+	return line
+}
+
+// startsYAMLScalar reports whether a scalar or flow item starts after
+// before: at the start of the line, after "key: " or "- ", or after the
+// "[", "{" or "," of a flow collection
+func startsYAMLScalar(before string) bool {
+	trimmed := strings.TrimRight(before, " \t")
+	if trimmed == "" {
+		return true
+	}
+	switch trimmed[len(trimmed)-1] {
+	case '[', '{', ',':
+		return true
+	case ':', '-':
+		return len(trimmed) < len(before)
+	}
+	return false
+}
+
+// parseFlowValue parses a scalar or a flow collection on a single line
+func parseFlowValue(text string, line int) (any, error) {
+	s := &flowScanner{text: strings.TrimSpace(text), line: line, yaml: true}
+	value, err := s.value()
+	if err != nil {
+		return nil, err
+	}
+	s.skipSpace()
+	if s.pos < len(s.text) {
+		return nil, s.errorf("unexpected %q", s.text[s.pos:])
+	}
+	return value, nil
+}
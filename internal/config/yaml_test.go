@@ -0,0 +1,92 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStripYAMLComment(t *testing.T) {
+	tests := []struct {
+		line, want string
+	}{
+		{"target: outlook # comment", "target: outlook "},
+		{"target: don't # comment", "target: don't "},
+		{`lang: "en # not a comment" # comment`, `lang: "en # not a comment" `},
+		{`lang: 'it''s # quoted' # comment`, `lang: 'it''s # quoted' `},
+		{`lang: "say \"#\"" # comment`, `lang: "say \"#\"" `},
+		{`safelist: ['#a', "#b", c'd] # comment`, `safelist: ['#a', "#b", c'd] `},
+		{`- '#x' # comment`, `- '#x' `},
+		{"color: '#fff'", "color: '#fff'"},
+		{"# whole line", ""},
+		{"url: a#b", "url: a#b"},
+	}
+
+	for _, test := range tests {
+		if got := stripYAMLComment(test.line); got != test.want {
+			t.Errorf("stripYAMLComment(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	values, err := parseYAML(`
+# Comment
+target: don't # trailing comment
+quoted: "a: b"
+single: 'it''s'
+numbers: [1, 2.5, -3]
+flags: {on: true, off: no, nothing: ~}
+nested:
+  list:
+    - one
+    - key: value
+      other: 2
+  empty:
+sequence:
+- a
+- b
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"target":  "don't",
+		"quoted":  "a: b",
+		"single":  "it's",
+		"numbers": []any{int64(1), 2.5, int64(-3)},
+		"flags":   map[string]any{"on": true, "off": "no", "nothing": nil},
+		"nested": map[string]any{
+			"list":  []any{"one", map[string]any{"key": "value", "other": int64(2)}},
+			"empty": nil,
+		},
+		"sequence": []any{"a", "b"},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("parseYAML =\n%#v\nwant\n%#v", values, want)
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"duplicate key", "target: outlook\ntarget: gmail", `line 2: duplicate key "target"`},
+		{"duplicate flow key", "rules: {a: off, a: info}", `line 1: duplicate key "a"`},
+		{"tab indentation", "rules:\n\ta: off", "line 2: tabs are not allowed for indentation"},
+		{"bad indentation", "rules:\n    a: off\n  b: info", "line 3: unexpected indentation"},
+		{"missing colon", "target outlook", `line 1: expected "key: value", got "target outlook"`},
+		{"top level list", "- a\n- b", "line 1: top level must be a mapping"},
+		{"block scalar", "lang: |", "line 1: block scalars are not supported"},
+		{"anchor", "lang: &x en", "line 1: anchors, aliases and tags are not supported"},
+		{"unterminated list", "safelist: [a, b", "line 1: missing ']'"},
+	}
+
+	for _, test := range tests {
+		_, err := parseYAML(test.src)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: error = %v, want %q", test.name, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package css
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Color is an sRGB color with alpha
+type Color struct {
+	R, G, B uint8
+	A       float64 // 0 (transparent) to 1 (opaque)
+}
+
+// ParseColor parses hex, rgb(), rgba(), hsl(), hsla() and named colors.
+// The second return value is false for values that aren't a color, such as
+// "inherit" or a gradient.
+func ParseColor(value string) (Color, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	value = strings.TrimSpace(strings.TrimSuffix(value, "!important"))
+
+	switch {
+	case value == "transparent":
+		return Color{A: 0}, true
+	case strings.HasPrefix(value, "#"):
+		return parseHexColor(value[1:])
+	case strings.HasPrefix(value, "rgb"):
+		return parseRGBColor(value)
+	case strings.HasPrefix(value, "hsl"):
+		return parseHSLColor(value)
+	}
+
+	if hex, ok := namedColors[value]; ok {
+		return parseHexColor(hex)
+	}
+	return Color{}, false
+}
+
+// Hex returns the color as #rrggbb, ignoring alpha
+func (c Color) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Blend composites c over an opaque background
+func (c Color) Blend(background Color) Color {
+	if c.A >= 1 {
+		return c
+	}
+	mix := func(fg, bg uint8) uint8 {
+		return uint8(math.Round(float64(fg)*c.A + float64(bg)*(1-c.A)))
+	}
+	return Color{R: mix(c.R, background.R), G: mix(c.G, background.G), B: mix(c.B, background.B), A: 1}
+}
+
+// RelativeLuminance computes the WCAG 2.x relative luminance of the color
+func (c Color) RelativeLuminance() float64 {
+	channel := func(v uint8) float64 {
+		s := float64(v) / 255
+		if s <= 0.03928 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*channel(c.R) + 0.7152*channel(c.G) + 0.0722*channel(c.B)
+}
+
+// ContrastRatio computes the WCAG contrast ratio between two opaque colors,
+// from 1 (no contrast) to 21 (black on white)
+func ContrastRatio(a, b Color) float64 {
+	la, lb := a.RelativeLuminance(), b.RelativeLuminance()
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// BackgroundColor extracts the color from a background or background-color value
+func BackgroundColor(value string) (Color, bool) {
+	if color, ok := ParseColor(value); ok {
+		return color, true
+	}
+
+	// Shorthand: look for a color token, keeping functional notation together
+	for _, token := range splitTokens(value) {
+		if color, ok := ParseColor(token); ok {
+			return color, true
+		}
+	}
+	return Color{}, false
+}
+
+// splitTokens splits a value on whitespace outside parentheses
+func splitTokens(value string) []string {
+	var tokens []string
+	var current strings.Builder
+	depth := 0
+
+	for _, c := range value {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case depth == 0 && (c == ' ' || c == '\t' || c == '\n'):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteRune(c)
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// parseHexColor parses rgb, rgba, rrggbb and rrggbbaa hex digits
+func parseHexColor(hex string) (Color, bool) {
+	if len(hex) == 3 || len(hex) == 4 {
+		var expanded strings.Builder
+		for _, c := range hex {
+			expanded.WriteRune(c)
+			expanded.WriteRune(c)
+		}
+		hex = expanded.String()
+	}
+	if len(hex) != 6 && len(hex) != 8 {
+		return Color{}, false
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return Color{}, false
+	}
+
+	alpha := 1.0
+	if len(hex) == 8 {
+		alpha = float64(value&0xff) / 255
+		value >>= 8
+	}
+	return Color{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: alpha}, true
+}
+
+// colorArgs returns the comma- or space-separated arguments of a color function
+func colorArgs(value string) ([]string, bool) {
+	open := strings.Index(value, "(")
+	closeIndex := strings.LastIndex(value, ")")
+	if open == -1 || closeIndex < open {
+		return nil, false
+	}
+	inner := strings.NewReplacer(",", " ", "/", " ").Replace(value[open+1 : closeIndex])
+	return strings.Fields(inner), true
+}
+
+// parseRGBColor parses rgb() and rgba()
+func parseRGBColor(value string) (Color, bool) {
+	args, ok := colorArgs(value)
+	if !ok || len(args) < 3 {
+		return Color{}, false
+	}
+
+	var channels [3]uint8
+	for i := 0; i < 3; i++ {
+		v, ok := parseComponent(args[i], 255)
+		if !ok {
+			return Color{}, false
+		}
+		channels[i] = uint8(math.Round(clamp(v, 0, 255)))
+	}
+
+	alpha := 1.0
+	if len(args) > 3 {
+		a, ok := parseComponent(args[3], 1)
+		if !ok {
+			return Color{}, false
+		}
+		alpha = clamp(a, 0, 1)
+	}
+	return Color{R: channels[0], G: channels[1], B: channels[2], A: alpha}, true
+}
+
+// parseHSLColor parses hsl() and hsla()
+func parseHSLColor(value string) (Color, bool) {
+	args, ok := colorArgs(value)
+	if !ok || len(args) < 3 {
+		return Color{}, false
+	}
+
+	hue, err := strconv.ParseFloat(strings.TrimSuffix(args[0], "deg"), 64)
+	if err != nil {
+		return Color{}, false
+	}
+	saturation, ok1 := parseComponent(args[1], 1)
+	lightness, ok2 := parseComponent(args[2], 1)
+	if !ok1 || !ok2 {
+		return Color{}, false
+	}
+
+	alpha := 1.0
+	if len(args) > 3 {
+		a, ok := parseComponent(args[3], 1)
+		if !ok {
+			return Color{}, false
+		}
+		alpha = clamp(a, 0, 1)
+	}
+
+	hue = math.Mod(math.Mod(hue, 360)+360, 360) / 360
+	return fromHSL(hue, clamp(saturation, 0, 1), clamp(lightness, 0, 1), alpha), true
+}
+
+// fromHSL converts hue, saturation and lightness, each 0 to 1, to a color
+func fromHSL(hue, saturation, lightness, alpha float64) Color {
+	var q float64
+	if lightness < 0.5 {
+		q = lightness * (1 + saturation)
+	} else {
+		q = lightness + saturation - lightness*saturation
+	}
+	p := 2*lightness - q
+	toRGB := func(t float64) uint8 {
+		t = math.Mod(t+1, 1)
+		var v float64
+		switch {
+		case t < 1.0/6:
+			v = p + (q-p)*6*t
+		case t < 0.5:
+			v = q
+		case t < 2.0/3:
+			v = p + (q-p)*(2.0/3-t)*6
+		default:
+			v = p
+		}
+		return uint8(math.Round(v * 255))
+	}
+
+	return Color{R: toRGB(hue + 1.0/3), G: toRGB(hue), B: toRGB(hue - 1.0/3), A: alpha}
+}
+
+// HSL returns the hue, saturation and lightness of the color, each 0 to 1
+func (c Color) HSL() (hue, saturation, lightness float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	high, low := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	lightness = (high + low) / 2
+	if high == low {
+		return 0, 0, lightness
+	}
+
+	delta := high - low
+	if lightness > 0.5 {
+		saturation = delta / (2 - high - low)
+	} else {
+		saturation = delta / (high + low)
+	}
+	switch high {
+	case r:
+		hue = (g - b) / delta
+		if g < b {
+			hue += 6
+		}
+	case g:
+		hue = (b-r)/delta + 2
+	default:
+		hue = (r-g)/delta + 4
+	}
+	return hue / 6, saturation, lightness
+}
+
+// WithLightness returns the color with its HSL lightness replaced, keeping
+// hue, saturation and alpha
+func (c Color) WithLightness(lightness float64) Color {
+	hue, saturation, _ := c.HSL()
+	return fromHSL(hue, saturation, clamp(lightness, 0, 1), c.A)
+}
+
+// parseComponent parses a number or percentage; percentages scale to max
+func parseComponent(value string, max float64) (float64, bool) {
+	if strings.HasSuffix(value, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return 0, false
+		}
+		return v / 100 * max, true
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	return v, err == nil
+}
+
+// clamp limits v to [lo, hi]
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+// namedColors maps CSS named colors to hex
+var namedColors = map[string]string{
+	"aliceblue": "f0f8ff", "antiquewhite": "faebd7", "aqua": "00ffff", "aquamarine": "7fffd4",
+	"azure": "f0ffff", "beige": "f5f5dc", "bisque": "ffe4c4", "black": "000000",
+	"blanchedalmond": "ffebcd", "blue": "0000ff", "blueviolet": "8a2be2", "brown": "a52a2a",
+	"burlywood": "deb887", "cadetblue": "5f9ea0", "chartreuse": "7fff00", "chocolate": "d2691e",
+	"coral": "ff7f50", "cornflowerblue": "6495ed", "cornsilk": "fff8dc", "crimson": "dc143c",
+	"cyan": "00ffff", "darkblue": "00008b", "darkcyan": "008b8b", "darkgoldenrod": "b8860b",
+	"darkgray": "a9a9a9", "darkgreen": "006400", "darkgrey": "a9a9a9", "darkkhaki": "bdb76b",
+	"darkmagenta": "8b008b", "darkolivegreen": "556b2f", "darkorange": "ff8c00", "darkorchid": "9932cc",
+	"darkred": "8b0000", "darksalmon": "e9967a", "darkseagreen": "8fbc8f", "darkslateblue": "483d8b",
+	"darkslategray": "2f4f4f", "darkslategrey": "2f4f4f", "darkturquoise": "00ced1", "darkviolet": "9400d3",
+	"deeppink": "ff1493", "deepskyblue": "00bfff", "dimgray": "696969", "dimgrey": "696969",
+	"dodgerblue": "1e90ff", "firebrick": "b22222", "floralwhite": "fffaf0", "forestgreen": "228b22",
+	"fuchsia": "ff00ff", "gainsboro": "dcdcdc", "ghostwhite": "f8f8ff", "gold": "ffd700",
+	"goldenrod": "daa520", "gray": "808080", "green": "008000", "greenyellow": "adff2f",
+	"grey": "808080", "honeydew": "f0fff0", "hotpink": "ff69b4", "indianred": "cd5c5c",
+	"indigo": "4b0082", "ivory": "fffff0", "khaki": "f0e68c", "lavender": "e6e6fa",
+	"lavenderblush": "fff0f5", "lawngreen": "7cfc00", "lemonchiffon": "fffacd", "lightblue": "add8e6",
+	"lightcoral": "f08080", "lightcyan": "e0ffff", "lightgoldenrodyellow": "fafad2", "lightgray": "d3d3d3",
+	"lightgreen": "90ee90", "lightgrey": "d3d3d3", "lightpink": "ffb6c1", "lightsalmon": "ffa07a",
+	"lightseagreen": "20b2aa", "lightskyblue": "87cefa", "lightslategray": "778899", "lightslategrey": "778899",
+	"lightsteelblue": "b0c4de", "lightyellow": "ffffe0", "lime": "00ff00", "limegreen": "32cd32",
+	"linen": "faf0e6", "magenta": "ff00ff", "maroon": "800000", "mediumaquamarine": "66cdaa",
+	"mediumblue": "0000cd", "mediumorchid": "ba55d3", "mediumpurple": "9370db", "mediumseagreen": "3cb371",
+	"mediumslateblue": "7b68ee", "mediumspringgreen": "00fa9a", "mediumturquoise": "48d1cc", "mediumvioletred": "c71585",
+	"midnightblue": "191970", "mintcream": "f5fffa", "mistyrose": "ffe4e1", "moccasin": "ffe4b5",
+	"navajowhite": "ffdead", "navy": "000080", "oldlace": "fdf5e6", "olive": "808000",
+	"olivedrab": "6b8e23", "orange": "ffa500", "orangered": "ff4500", "orchid": "da70d6",
+	"palegoldenrod": "eee8aa", "palegreen": "98fb98", "paleturquoise": "afeeee", "palevioletred": "db7093",
+	"papayawhip": "ffefd5", "peachpuff": "ffdab9", "peru": "cd853f", "pink": "ffc0cb",
+	"plum": "dda0dd", "powderblue": "b0e0e6", "purple": "800080", "rebeccapurple": "663399",
+	"red": "ff0000", "rosybrown": "bc8f8f", "royalblue": "4169e1", "saddlebrown": "8b4513",
+	"salmon": "fa8072", "sandybrown": "f4a460", "seagreen": "2e8b57", "seashell": "fff5ee",
+	"sienna": "a0522d", "silver": "c0c0c0", "skyblue": "87ceeb", "slateblue": "6a5acd",
+	"slategray": "708090", "slategrey": "708090", "snow": "fffafa", "springgreen": "00ff7f",
+	"steelblue": "4682b4", "tan": "d2b48c", "teal": "008080", "thistle": "d8bfd8",
+	"tomato": "ff6347", "turquoise": "40e0d0", "violet": "ee82ee", "wheat": "f5deb3",
+	"white": "ffffff", "whitesmoke": "f5f5f5", "yellow": "ffff00", "yellowgreen": "9acd32",
+}
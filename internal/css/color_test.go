@@ -0,0 +1,130 @@
+package css
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Color
+	}{
+		{"#fff", Color{255, 255, 255, 1}},
+		{"#FFFFFF", Color{255, 255, 255, 1}},
+		{"#336699", Color{0x33, 0x66, 0x99, 1}},
+		{"#00000080", Color{0, 0, 0, 128.0 / 255}},
+		{"#f008", Color{255, 0, 0, 0x88 / 255.0}},
+		{"rgb(255, 0, 0)", Color{255, 0, 0, 1}},
+		{"rgb(100%, 50%, 0%)", Color{255, 128, 0, 1}},
+		{"rgb(0 128 255 / 50%)", Color{0, 128, 255, 0.5}},
+		{"rgba(0, 0, 0, 0.5)", Color{0, 0, 0, 0.5}},
+		{"rgba(300, -5, 0, 2)", Color{255, 0, 0, 1}},
+		{"hsl(0, 100%, 50%)", Color{255, 0, 0, 1}},
+		{"hsl(120deg, 100%, 50%)", Color{0, 255, 0, 1}},
+		{"hsl(-120, 100%, 50%)", Color{0, 0, 255, 1}},
+		{"hsl(0, 0%, 50%)", Color{128, 128, 128, 1}},
+		{"hsla(240, 100%, 50%, 0.25)", Color{0, 0, 255, 0.25}},
+		{"black", Color{0, 0, 0, 1}},
+		{"White", Color{255, 255, 255, 1}},
+		{"rebeccapurple", Color{0x66, 0x33, 0x99, 1}},
+		{"transparent", Color{}},
+		{"  #000 !important ", Color{0, 0, 0, 1}},
+	}
+
+	for _, test := range tests {
+		got, ok := ParseColor(test.value)
+		if !ok {
+			t.Errorf("ParseColor(%q) failed", test.value)
+			continue
+		}
+		if got.R != test.want.R || got.G != test.want.G || got.B != test.want.B || math.Abs(got.A-test.want.A) > 1e-9 {
+			t.Errorf("ParseColor(%q) = %+v, want %+v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseColorRejectsNonColors(t *testing.T) {
+	for _, value := range []string{"", "inherit", "currentcolor", "#ggg", "#12345", "rgb(1, 2)", "hsl(red, 1, 1)", "linear-gradient(#fff, #000)", "notacolor"} {
+		if color, ok := ParseColor(value); ok {
+			t.Errorf("ParseColor(%q) = %+v, want no color", value, color)
+		}
+	}
+}
+
+func TestContrastRatio(t *testing.T) {
+	white := Color{255, 255, 255, 1}
+	tests := []struct {
+		fg, bg string
+		want   float64
+	}{
+		{"#000", "#fff", 21},
+		{"#fff", "#000", 21},
+		{"#777", "#fff", 4.48},
+		{"#767676", "#fff", 4.54},
+		{"#fff", "#fff", 1},
+		{"#0000ee", "#fff", 9.40},
+		{"red", "white", 4},
+	}
+
+	for _, test := range tests {
+		fg, _ := ParseColor(test.fg)
+		bg, _ := ParseColor(test.bg)
+		got := ContrastRatio(fg.Blend(white), bg)
+		if math.Abs(got-test.want) > 0.01 {
+			t.Errorf("ContrastRatio(%s, %s) = %.3f, want %.2f", test.fg, test.bg, got, test.want)
+		}
+	}
+}
+
+func TestBlend(t *testing.T) {
+	white := Color{255, 255, 255, 1}
+	tests := []struct {
+		color, background Color
+		want              Color
+	}{
+		{Color{0, 0, 0, 0.5}, white, Color{128, 128, 128, 1}},
+		{Color{255, 0, 0, 0.25}, Color{0, 0, 0, 1}, Color{64, 0, 0, 1}},
+		{Color{10, 20, 30, 1}, white, Color{10, 20, 30, 1}},
+		{Color{10, 20, 30, 0}, white, white},
+	}
+
+	for _, test := range tests {
+		if got := test.color.Blend(test.background); got != test.want {
+			t.Errorf("%+v.Blend(%+v) = %+v, want %+v", test.color, test.background, got, test.want)
+		}
+	}
+}
+
+func TestBackgroundColor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"#f0f0f0", "#f0f0f0", true},
+		{"#336699 url(bg.png) no-repeat", "#336699", true},
+		{"url(bg.png) rgb(0, 0, 0) center", "#000000", true},
+		{"url(bg.png) hsl(0, 100%, 50%)", "#ff0000", true},
+		{"url(bg.png) no-repeat", "", false},
+	}
+
+	for _, test := range tests {
+		color, ok := BackgroundColor(test.value)
+		if ok != test.ok || (ok && color.Hex() != test.want) {
+			t.Errorf("BackgroundColor(%q) = %s, %v; want %s, %v", test.value, color.Hex(), ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestWithLightnessKeepsHue(t *testing.T) {
+	color, _ := ParseColor("#336699")
+	hue, saturation, _ := color.HSL()
+
+	lighter := color.WithLightness(0.8)
+	gotHue, gotSaturation, gotLightness := lighter.HSL()
+	if math.Abs(gotHue-hue) > 0.01 || math.Abs(gotSaturation-saturation) > 0.02 || math.Abs(gotLightness-0.8) > 0.01 {
+		t.Errorf("WithLightness(0.8) = %s (h=%.3f s=%.3f l=%.3f), want h=%.3f s=%.3f l=0.8",
+			lighter.Hex(), gotHue, gotSaturation, gotLightness, hue, saturation)
+	}
+}
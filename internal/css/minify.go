@@ -0,0 +1,142 @@
+package css
+
+import (
+	"regexp"
+	"strings"
+)
+
+// zeroUnitRegex matches zero lengths with a unit, e.g. "0px" in "0px 10px"
+var zeroUnitRegex = regexp.MustCompile(`(^|[\s,])-?0+(?:\.0+)?(?:px|em|rem|ex|ch|pt|pc|cm|mm|in|vw|vh|vmin|vmax)\b`)
+
+// hexColorRegex matches six digit hex colors
+var hexColorRegex = regexp.MustCompile(`#[0-9a-fA-F]{6}\b`)
+
+// selectorCombinatorRegex matches whitespace around combinators and selector list commas
+var selectorCombinatorRegex = regexp.MustCompile(`\s*([>+~,])\s*`)
+
+// MinifyValue shortens a declaration value: whitespace is collapsed, zero
+// lengths lose their unit and, when shortHex is set, #aabbcc becomes #abc.
+// Quoted strings and url() contents are left as written.
+func MinifyValue(value string, shortHex bool) string {
+	depth := 0
+	return mapUnquoted(value, func(segment string) string {
+		segment = collapseSpace(segment)
+		segment = strings.ReplaceAll(segment, ", ", ",")
+		segment = stripZeroUnits(segment, &depth)
+		if shortHex {
+			segment = hexColorRegex.ReplaceAllStringFunc(segment, shortenHex)
+		}
+		return segment
+	})
+}
+
+// MinifySelector collapses whitespace in a selector and around its combinators
+func MinifySelector(selector string) string {
+	return mapUnquoted(selector, func(segment string) string {
+		return selectorCombinatorRegex.ReplaceAllString(collapseSpace(segment), "$1")
+	})
+}
+
+// MinifyBlock collapses whitespace in an unparsed at-rule body such as
+// @keyframes, removing it around braces, colons and semicolons
+func MinifyBlock(body string, shortHex bool) string {
+	depth := 0
+	return mapUnquoted(body, func(segment string) string {
+		segment = collapseSpace(segment)
+		for _, punct := range []string{"{", "}", ";", ":"} {
+			segment = strings.ReplaceAll(segment, " "+punct, punct)
+			segment = strings.ReplaceAll(segment, punct+" ", punct)
+		}
+		segment = strings.ReplaceAll(segment, ";}", "}")
+		segment = stripZeroUnits(segment, &depth)
+		if shortHex {
+			segment = hexColorRegex.ReplaceAllStringFunc(segment, shortenHex)
+		}
+		return segment
+	})
+}
+
+// stripZeroUnits drops the unit of zero lengths outside parentheses. Math
+// functions such as calc() require units on zero lengths, so function
+// arguments are copied unchanged. depth carries the parenthesis nesting
+// across the segments of one value.
+func stripZeroUnits(segment string, depth *int) string {
+	var out strings.Builder
+	start := 0
+	for j := 0; j < len(segment); j++ {
+		switch segment[j] {
+		case '(':
+			if *depth == 0 {
+				out.WriteString(zeroUnitRegex.ReplaceAllString(segment[start:j], "${1}0"))
+				start = j
+			}
+			*depth++
+		case ')':
+			if *depth > 0 {
+				*depth--
+				if *depth == 0 {
+					out.WriteString(segment[start : j+1])
+					start = j + 1
+				}
+			}
+		}
+	}
+	if *depth > 0 {
+		out.WriteString(segment[start:])
+	} else {
+		out.WriteString(zeroUnitRegex.ReplaceAllString(segment[start:], "${1}0"))
+	}
+	return out.String()
+}
+
+// shortenHex turns #aabbcc into #abc; other colors are returned unchanged
+func shortenHex(hex string) string {
+	h := strings.ToLower(hex)
+	if h[1] == h[2] && h[3] == h[4] && h[5] == h[6] {
+		return "#" + string(h[1]) + string(h[3]) + string(h[5])
+	}
+	return hex
+}
+
+// spaceRunRegex matches runs of whitespace
+var spaceRunRegex = regexp.MustCompile(`\s+`)
+
+// collapseSpace collapses whitespace runs to a single space
+func collapseSpace(s string) string {
+	return spaceRunRegex.ReplaceAllString(s, " ")
+}
+
+// mapUnquoted applies fn to the parts of s outside quoted strings and url()
+// arguments, which are copied through unchanged
+func mapUnquoted(s string, fn func(string) string) string {
+	var out strings.Builder
+	start := 0
+	lower := strings.ToLower(s)
+
+	for j := 0; j < len(s); j++ {
+		end := -1
+		switch {
+		case s[j] == '"' || s[j] == '\'':
+			end = strings.IndexByte(s[j+1:], s[j])
+			if end >= 0 {
+				end += j + 2
+			}
+		case strings.HasPrefix(lower[j:], "url("):
+			end = strings.IndexByte(s[j:], ')')
+			if end >= 0 {
+				end += j + 1
+			}
+		}
+		if end < 0 {
+			continue
+		}
+
+		out.WriteString(fn(s[start:j]))
+		out.WriteString(s[j:end])
+		start = end
+		j = end - 1
+	}
+	out.WriteString(fn(s[start:]))
+
+	return strings.TrimSpace(out.String())
+}
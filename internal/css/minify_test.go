@@ -0,0 +1,38 @@
+package css
+
+import "testing"
+
+func TestMinifyValue(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"0px 10px", "0 10px"},
+		{"10px  0em, 0.0rem", "10px 0,0"},
+		{"#AABBCC", "#abc"},
+		{"url(data:image/png;base64,0px) 0px", "url(data:image/png;base64,0px) 0"},
+		{`"0px" 0px`, `"0px" 0`},
+
+		// A unitless zero is invalid inside math functions
+		{"calc(100% - 0px)", "calc(100% - 0px)"},
+		{"max(0px, 10px)", "max(0px,10px)"},
+		{"clamp(0rem, 2vw, 1rem) 0px", "clamp(0rem,2vw,1rem) 0"},
+		{"calc(1px + min(0px, 2px)) 0px", "calc(1px + min(0px,2px)) 0"},
+		{`var(--gap, "x") 0px`, `var(--gap,"x") 0`},
+		{`calc(var(--a, "b") - 0px) 0px`, `calc(var(--a,"b") - 0px) 0`},
+		{"rgba(0, 0, 0, 0.5)", "rgba(0,0,0,0.5)"},
+	}
+
+	for _, test := range tests {
+		if got := MinifyValue(test.value, true); got != test.want {
+			t.Errorf("MinifyValue(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestMinifyBlock(t *testing.T) {
+	body := "from { transform: translateX(0px) }\n to { width: calc(100% - 0px) }"
+	want := "from{transform:translateX(0px)}to{width:calc(100% - 0px)}"
+	if got := MinifyBlock(body, true); got != want {
+		t.Errorf("MinifyBlock = %q, want %q", got, want)
+	}
+}
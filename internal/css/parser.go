@@ -8,9 +8,10 @@ import (
 // Parser handles CSS parsing and specificity calculation
 type Parser struct {
 	// Regular expressions for CSS parsing
-	ruleRegex        *regexp.Regexp
 	declarationRegex *regexp.Regexp
 	importantRegex   *regexp.Regexp
+	commentRegex     *regexp.Regexp
+	keepCommentRegex *regexp.Regexp
 
 	// Specificity calculation regexes
 	idRegex            *regexp.Regexp
@@ -24,12 +25,11 @@ type Parser struct {
 // NewParser creates a new CSS parser with compiled regexes
 func NewParser() *Parser {
 	return &Parser{
-		// CSS rule parsing: selector { declarations }
-		ruleRegex: regexp.MustCompile(`([^{]+)\{([^}]*)\}`),
-
 		// Declaration parsing: property: value; or property: value !important;
 		declarationRegex: regexp.MustCompile(`([^:]+):\s*([^;]+);?`),
 		importantRegex:   regexp.MustCompile(`!\s*important\s*$`),
+		commentRegex:     regexp.MustCompile(`/\*[^*]*\*+([^/*][^*]*\*+)*/`),
+		keepCommentRegex: regexp.MustCompile(`(?i)^/\*\s*inliner:\s*keep\s*\*/$`),
 
 		// Specificity calculation regexes (RE2 compatible)
 		idRegex:            regexp.MustCompile(`#[a-zA-Z0-9_-]+`),
@@ -47,50 +47,210 @@ func (p *Parser) Parse(cssText string) (*Stylesheet, error) {
 		Rules: make([]Rule, 0),
 	}
 
-	// Remove comments
+	// Remove comments, keeping line breaks so rule lines stay accurate
 	cssText = p.removeComments(cssText)
 
-	// Handle @media, @keyframes, etc. - for now, preserve them as-is
-	// TODO: Add proper at-rule parsing in next iteration
-	cssText = p.extractAtRules(cssText)
+	p.parseBlock(cssText, nil, 1, stylesheet)
 
-	// Find all CSS rules
-	matches := p.ruleRegex.FindAllStringSubmatch(cssText, -1)
+	return stylesheet, nil
+}
 
-	for i, match := range matches {
-		if len(match) != 3 {
+// parseBlock parses a sequence of rules. Conditional group rules (@media,
+// @supports) are parsed recursively with their prelude pushed onto atRules;
+// other at-rules are kept whole so they can be written back out unchanged.
+func (p *Parser) parseBlock(cssText string, atRules []string, line int, stylesheet *Stylesheet) {
+	pos := 0
+
+	for pos < len(cssText) {
+		// Skip whitespace and stray closing braces
+		for pos < len(cssText) && (isSpace(cssText[pos]) || cssText[pos] == '}') {
+			if cssText[pos] == '\n' {
+				line++
+			}
+			pos++
+		}
+		if pos >= len(cssText) {
+			return
+		}
+
+		end := p.findUnquotedAny(cssText[pos:], "{;")
+		if end == -1 {
+			return
+		}
+		end += pos
+		prelude := strings.TrimSpace(cssText[pos:end])
+		ruleLine := line
+
+		// A keep directive before the selector or inside the block marks the rule
+		keep := strings.Contains(prelude, keepMarker)
+		prelude = strings.TrimSpace(strings.ReplaceAll(prelude, keepMarker, ""))
+
+		// Statement at-rules: @import, @charset, @namespace
+		if cssText[end] == ';' {
+			if strings.HasPrefix(strings.ToLower(prelude), "@import") {
+				if url := parseImportURL(prelude); url != "" {
+					stylesheet.Imports = append(stylesheet.Imports, url)
+				}
+			}
+			line += strings.Count(cssText[pos:end+1], "\n")
+			pos = end + 1
 			continue
 		}
 
-		selector := strings.TrimSpace(match[1])
-		declarations := strings.TrimSpace(match[2])
+		closeIndex := p.findMatchingBrace(cssText, end)
+		body := cssText[end+1 : closeIndex]
+		bodyLine := line + strings.Count(cssText[pos:end+1], "\n")
 
-		// Skip empty rules
-		if selector == "" || declarations == "" {
-			continue
+		// Directives inside a conditional group belong to its nested rules
+		if !isConditionalGroup(prelude) && strings.Contains(body, keepMarker) {
+			keep = true
+			body = strings.ReplaceAll(body, keepMarker, "")
 		}
 
-		// Parse declarations
-		parsedDeclarations, err := p.parseDeclarations(declarations)
-		if err != nil {
-			// Log error but continue parsing other rules
-			continue
+		switch {
+		case isConditionalGroup(prelude):
+			nested := make([]string, len(atRules), len(atRules)+1)
+			copy(nested, atRules)
+			first := len(stylesheet.Rules)
+			p.parseBlock(body, append(nested, prelude), bodyLine, stylesheet)
+			if keep {
+				for j := first; j < len(stylesheet.Rules); j++ {
+					stylesheet.Rules[j].Keep = true
+				}
+			}
+
+		case strings.HasPrefix(prelude, "@"):
+			rule := Rule{
+				Selector:     prelude,
+				Declarations: make(map[string]Declaration),
+				SourceOrder:  len(stylesheet.Rules),
+				AtRules:      atRules,
+				Line:         ruleLine,
+				Keep:         keep,
+			}
+			if strings.Contains(body, "{") {
+				rule.Raw = strings.TrimSpace(body)
+			} else {
+				rule.Declarations, _ = p.parseDeclarations(body)
+			}
+			stylesheet.Rules = append(stylesheet.Rules, rule)
+
+		default:
+			declarations := strings.TrimSpace(body)
+
+			// Skip empty rules
+			if prelude == "" || declarations == "" {
+				break
+			}
+
+			// Parse declarations
+			parsedDeclarations, err := p.parseDeclarations(declarations)
+			if err != nil {
+				// Log error but continue parsing other rules
+				break
+			}
+
+			// Calculate specificity
+			specificity := p.calculateSpecificity(prelude)
+
+			stylesheet.Rules = append(stylesheet.Rules, Rule{
+				Selector:     prelude,
+				Specificity:  specificity,
+				Declarations: parsedDeclarations,
+				SourceOrder:  len(stylesheet.Rules),
+				AtRules:      atRules,
+				Line:         ruleLine,
+				Keep:         keep,
+			})
 		}
 
-		// Calculate specificity
-		specificity := p.calculateSpecificity(selector)
+		if closeIndex >= len(cssText) {
+			return
+		}
+		line += strings.Count(cssText[pos:closeIndex+1], "\n")
+		pos = closeIndex + 1
+	}
+}
 
-		rule := Rule{
-			Selector:     selector,
-			Specificity:  specificity,
-			Declarations: parsedDeclarations,
-			SourceOrder:  i,
+// isConditionalGroup reports whether an at-rule prelude opens a block of nested rules
+func isConditionalGroup(prelude string) bool {
+	lower := strings.ToLower(prelude)
+	for _, name := range []string{"@media", "@supports", "@document", "@-moz-document", "@layer", "@container"} {
+		if strings.HasPrefix(lower, name) {
+			return true
 		}
+	}
+	return false
+}
 
-		stylesheet.Rules = append(stylesheet.Rules, rule)
+// parseImportURL extracts the URL from an @import prelude
+func parseImportURL(prelude string) string {
+	rest := strings.TrimSpace(prelude[len("@import"):])
+	if strings.HasPrefix(strings.ToLower(rest), "url(") {
+		end := strings.Index(rest, ")")
+		if end == -1 {
+			return ""
+		}
+		rest = strings.TrimSpace(rest[4:end])
+	} else if fields := strings.Fields(rest); len(fields) > 0 {
+		rest = fields[0]
 	}
+	return strings.Trim(rest, `"'`)
+}
 
-	return stylesheet, nil
+// isSpace reports whether b is CSS whitespace
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
+
+// findMatchingBrace returns the index of the '}' closing the '{' at open,
+// or len(s) if the block is unterminated
+func (p *Parser) findMatchingBrace(s string, open int) int {
+	depth := 0
+	var inQuotes bool
+	var quoteChar byte
+
+	for i := open; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inQuotes && c == '\\':
+			i++
+		case !inQuotes && (c == '"' || c == '\''):
+			inQuotes = true
+			quoteChar = c
+		case inQuotes && c == quoteChar:
+			inQuotes = false
+		case !inQuotes && c == '{':
+			depth++
+		case !inQuotes && c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return len(s)
+}
+
+// findUnquotedAny finds the first occurrence of any of chars that's not in quotes
+func (p *Parser) findUnquotedAny(s string, chars string) int {
+	var inQuotes bool
+	var quoteChar rune
+
+	for i, c := range s {
+		switch {
+		case !inQuotes && (c == '"' || c == '\''):
+			inQuotes = true
+			quoteChar = c
+		case inQuotes && c == quoteChar:
+			inQuotes = false
+		case !inQuotes && strings.ContainsRune(chars, c):
+			return i
+		}
+	}
+
+	return -1
 }
 
 // parseDeclarations parses CSS declarations from a declaration block
@@ -98,7 +258,7 @@ func (p *Parser) parseDeclarations(declarationsText string) (map[string]Declarat
 	declarations := make(map[string]Declaration)
 
 	// Split by semicolon, but handle semicolons in quoted strings
-	parts := p.smartSplit(declarationsText, ';')
+	parts := smartSplit(declarationsText, ';')
 
 	for _, part := range parts {
 		part = strings.TrimSpace(part)
@@ -199,27 +359,43 @@ func (p *Parser) isCSSKeyword(s string) bool {
 	return keywords[s]
 }
 
-// removeComments removes CSS comments /* ... */
+// keepMarker stands in for a /* inliner: keep */ comment while parsing
+const keepMarker = "\x00"
+
+// removeComments removes CSS comments /* ... */, keeping their line breaks.
+// Keep directives are replaced with keepMarker so parseBlock can flag the
+// rule they belong to.
 func (p *Parser) removeComments(css string) string {
-	commentRegex := regexp.MustCompile(`/\*[^*]*\*+([^/*][^*]*\*+)*/`)
-	return commentRegex.ReplaceAllString(css, "")
+	return p.commentRegex.ReplaceAllStringFunc(css, func(comment string) string {
+		breaks := strings.Repeat("\n", strings.Count(comment, "\n"))
+		if p.keepCommentRegex.MatchString(comment) {
+			return keepMarker + breaks
+		}
+		return breaks
+	})
 }
 
-// extractAtRules handles @media, @keyframes, etc. - for now just remove them
-// TODO: Implement proper at-rule preservation for media queries
-func (p *Parser) extractAtRules(css string) string {
-	// Simple implementation for now - remove @import, @charset, etc.
-	// Preserve @media for later processing
-	atRuleRegex := regexp.MustCompile(`@(import|charset|namespace)[^;]+;`)
-	return atRuleRegex.ReplaceAllString(css, "")
+// SplitSelectorList splits a selector list on its top-level commas, so
+// commas inside :is() or :not() arguments and quoted attribute values stay
+// with their selector. Selectors are trimmed and empty ones dropped.
+func SplitSelectorList(selector string) []string {
+	var selectors []string
+	for _, part := range smartSplit(selector, ',') {
+		if part = strings.TrimSpace(part); part != "" {
+			selectors = append(selectors, part)
+		}
+	}
+	return selectors
 }
 
-// smartSplit splits a string by delimiter, respecting quoted strings
-func (p *Parser) smartSplit(s string, delimiter rune) []string {
+// smartSplit splits a string by delimiter, respecting quoted strings and
+// parentheses (so data URIs inside url() survive)
+func smartSplit(s string, delimiter rune) []string {
 	var parts []string
 	var current strings.Builder
 	var inQuotes bool
 	var quoteChar rune
+	var parenDepth int
 
 	for _, char := range s {
 		switch {
@@ -230,7 +406,13 @@ func (p *Parser) smartSplit(s string, delimiter rune) []string {
 		case inQuotes && char == quoteChar:
 			inQuotes = false
 			current.WriteRune(char)
-		case !inQuotes && char == delimiter:
+		case !inQuotes && char == '(':
+			parenDepth++
+			current.WriteRune(char)
+		case !inQuotes && char == ')' && parenDepth > 0:
+			parenDepth--
+			current.WriteRune(char)
+		case !inQuotes && parenDepth == 0 && char == delimiter:
 			if current.Len() > 0 {
 				parts = append(parts, current.String())
 				current.Reset()
@@ -0,0 +1,148 @@
+package css
+
+import (
+	"reflect"
+	"testing"
+)
+
+func parse(t *testing.T, cssText string) *Stylesheet {
+	t.Helper()
+	stylesheet, err := NewParser().Parse(cssText)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return stylesheet
+}
+
+func selectors(rules []Rule) []string {
+	var names []string
+	for _, rule := range rules {
+		names = append(names, rule.Selector)
+	}
+	return names
+}
+
+// The regex parser this replaced matched "selector { declarations }" pairs
+// and so read "@media (max-width: 600px) { .b { color: blue }" as a rule
+// with the @media prelude as its selector, then surfaced .c as a top-level
+// rule that was inlined unconditionally.
+func TestParseNestedMediaRules(t *testing.T) {
+	stylesheet := parse(t, `
+.a { color: red }
+@media (max-width: 600px) {
+  .b { color: blue }
+  .c { color: green }
+}
+.d { color: black }
+`)
+
+	want := []string{".a", ".b", ".c", ".d"}
+	if got := selectors(stylesheet.Rules); !reflect.DeepEqual(got, want) {
+		t.Fatalf("selectors = %q, want %q", got, want)
+	}
+
+	media := []string{"@media (max-width: 600px)"}
+	for _, rule := range stylesheet.Rules {
+		var want []string
+		if rule.Selector == ".b" || rule.Selector == ".c" {
+			want = media
+		}
+		if !reflect.DeepEqual(rule.AtRules, want) {
+			t.Errorf("%s: AtRules = %q, want %q", rule.Selector, rule.AtRules, want)
+		}
+		if rule.IsConditional() != (want != nil) || rule.InMedia() != (want != nil) {
+			t.Errorf("%s: IsConditional = %v, InMedia = %v", rule.Selector, rule.IsConditional(), rule.InMedia())
+		}
+	}
+	if got := stylesheet.Rules[1].Declarations["color"].Value; got != "blue" {
+		t.Errorf(".b color = %q, want blue", got)
+	}
+}
+
+func TestParseNestedConditionalGroups(t *testing.T) {
+	stylesheet := parse(t, `@supports (display: grid) { @media screen { .grid { display: grid } } }`)
+
+	if len(stylesheet.Rules) != 1 {
+		t.Fatalf("got %d rules, want 1", len(stylesheet.Rules))
+	}
+	rule := stylesheet.Rules[0]
+	want := []string{"@supports (display: grid)", "@media screen"}
+	if !reflect.DeepEqual(rule.AtRules, want) {
+		t.Errorf("AtRules = %q, want %q (outermost first)", rule.AtRules, want)
+	}
+}
+
+func TestParseAtRulesKeptWhole(t *testing.T) {
+	stylesheet := parse(t, `
+@font-face { font-family: Brand; src: url(brand.woff) }
+@keyframes pulse { from { opacity: 0 } to { opacity: 1 } }
+`)
+
+	if len(stylesheet.Rules) != 2 {
+		t.Fatalf("got %d rules, want 2: %q", len(stylesheet.Rules), selectors(stylesheet.Rules))
+	}
+
+	fontFace := stylesheet.Rules[0]
+	if !fontFace.IsAtRule() || fontFace.Declarations["font-family"].Value != "Brand" {
+		t.Errorf("@font-face = %+v, want an at-rule with its declarations", fontFace)
+	}
+
+	keyframes := stylesheet.Rules[1]
+	if keyframes.Selector != "@keyframes pulse" || keyframes.Raw != "from { opacity: 0 } to { opacity: 1 }" {
+		t.Errorf("@keyframes = %q with body %q, want the body kept unparsed", keyframes.Selector, keyframes.Raw)
+	}
+}
+
+func TestParseImports(t *testing.T) {
+	stylesheet := parse(t, `@charset "utf-8"; @import url("base.css"); @import 'theme.css' screen; .a { color: red }`)
+
+	want := []string{"base.css", "theme.css"}
+	if !reflect.DeepEqual(stylesheet.Imports, want) {
+		t.Errorf("Imports = %q, want %q", stylesheet.Imports, want)
+	}
+	if got := selectors(stylesheet.Rules); !reflect.DeepEqual(got, []string{".a"}) {
+		t.Errorf("selectors = %q, want only .a", got)
+	}
+}
+
+func TestParseRuleLines(t *testing.T) {
+	stylesheet := parse(t, ".a { color: red }\n/* two\nlines */\n@media print {\n  .b { color: black }\n}\n")
+
+	lines := map[string]int{".a": 1, ".b": 5}
+	for _, rule := range stylesheet.Rules {
+		if rule.Line != lines[rule.Selector] {
+			t.Errorf("%s: Line = %d, want %d", rule.Selector, rule.Line, lines[rule.Selector])
+		}
+	}
+}
+
+func TestParseSemicolonInsideURL(t *testing.T) {
+	stylesheet := parse(t, `.a { background: url(data:image/png;base64,AAAA) no-repeat; color: red }`)
+
+	declarations := stylesheet.Rules[0].Declarations
+	if got := declarations["background"].Value; got != "url(data:image/png;base64,AAAA) no-repeat" {
+		t.Errorf("background = %q, want the data URI intact", got)
+	}
+	if got := declarations["color"].Value; got != "red" {
+		t.Errorf("color = %q, want red", got)
+	}
+}
+
+func TestSplitSelectorList(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     []string
+	}{
+		{".a, .b", []string{".a", ".b"}},
+		{":is(.a, .b) p, .c", []string{":is(.a, .b) p", ".c"}},
+		{"p:not(.a,.b)", []string{"p:not(.a,.b)"}},
+		{`[title="a,b"], a[href='x,y']`, []string{`[title="a,b"]`, `a[href='x,y']`}},
+		{" .a ,, ", []string{".a"}},
+	}
+
+	for _, test := range tests {
+		if got := SplitSelectorList(test.selector); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("SplitSelectorList(%q) = %q, want %q", test.selector, got, test.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package css
 
 import (
 	"fmt"
+	"strings"
 )
 
 // Specificity represents CSS specificity with individual components
@@ -64,10 +65,52 @@ func (s Specificity) String() string {
 
 // Rule represents a single CSS rule with its selector and declarations
 type Rule struct {
-	Selector     string                 // Original selector text
+	Selector     string                 // Original selector text, or the prelude of an at-rule
 	Specificity  Specificity            // Calculated specificity
 	Declarations map[string]Declaration // property -> declaration mapping
 	SourceOrder  int                    // Order in original CSS (for tie-breaking)
+	AtRules      []string               // Enclosing conditional group rules (@media, @supports), outermost first
+	Raw          string                 // Unparsed body of at-rules whose contents aren't declarations (@keyframes)
+	Line         int                    // 1-based line of the rule in the parsed CSS text
+	Keep         bool                   // Marked with /* inliner: keep */; always preserved in <style>
+}
+
+// IsAtRule reports whether the rule is an at-rule such as @font-face or @keyframes
+func (r Rule) IsAtRule() bool {
+	return strings.HasPrefix(r.Selector, "@")
+}
+
+// IsConditional reports whether the rule is nested inside @media, @supports or similar
+func (r Rule) IsConditional() bool {
+	return len(r.AtRules) > 0
+}
+
+// InMedia reports whether the rule is nested inside an @media rule
+func (r Rule) InMedia() bool {
+	for _, atRule := range r.AtRules {
+		if strings.HasPrefix(strings.ToLower(atRule), "@media") {
+			return true
+		}
+	}
+	return false
+}
+
+// InDarkScheme reports whether the rule is nested inside an
+// @media (prefers-color-scheme: dark) rule
+func (r Rule) InDarkScheme() bool {
+	for _, atRule := range r.AtRules {
+		if IsDarkSchemeQuery(atRule) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDarkSchemeQuery reports whether an at-rule prelude is a media query
+// matching the dark color scheme
+func IsDarkSchemeQuery(atRule string) bool {
+	query := strings.Join(strings.Fields(strings.ToLower(atRule)), "")
+	return strings.HasPrefix(query, "@media") && strings.Contains(query, "prefers-color-scheme:dark")
 }
 
 // Declaration represents a single CSS property declaration
@@ -79,7 +122,8 @@ type Declaration struct {
 
 // Stylesheet represents the complete parsed CSS with all rules
 type Stylesheet struct {
-	Rules []Rule // All CSS rules in source order
+	Rules   []Rule   // All CSS rules in source order
+	Imports []string // URLs referenced by @import, in source order
 }
 
 // MatchResult represents the result of matching CSS rules against an HTML element
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 
 	"inliner/internal/css"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
 	"golang.org/x/net/html"
 )
 
@@ -19,7 +21,11 @@ var _ Parser = (*GoQueryParser)(nil)
 
 // GoQueryDocument wraps goquery.Document to implement our Document interface
 type GoQueryDocument struct {
-	doc *goquery.Document
+	doc    *goquery.Document
+	source string
+
+	// lines maps elements to their start tag lines, built on first use
+	lines map[*html.Node]int
 }
 
 // GoQueryNode wraps goquery.Selection to implement our Node interface
@@ -43,7 +49,7 @@ func (p *GoQueryParser) Parse(htmlStr string) (Document, error) {
 		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	return &GoQueryDocument{doc: doc}, nil
+	return &GoQueryDocument{doc: doc, source: htmlStr}, nil
 }
 
 // ParseFile parses HTML file into a Document
@@ -58,7 +64,7 @@ func (p *GoQueryParser) ParseFile(filename string) (Document, error) {
 		return nil, fmt.Errorf("failed to parse HTML file: %w", err)
 	}
 
-	return &GoQueryDocument{doc: doc}, nil
+	return &GoQueryDocument{doc: doc, source: string(content)}, nil
 }
 
 // Document implementation
@@ -96,7 +102,12 @@ func (d *GoQueryDocument) QuerySelector(selector string) (Node, error) {
 
 // QuerySelectorAll returns all elements matching the selector
 func (d *GoQueryDocument) QuerySelectorAll(selector string) ([]Node, error) {
-	selection := d.doc.Find(selector)
+	// goquery silently matches nothing for selectors it can't compile
+	matcher, err := cascadia.Compile(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+	selection := d.doc.FindMatcher(matcher)
 	nodes := make([]Node, selection.Length())
 
 	selection.Each(func(i int, s *goquery.Selection) {
@@ -128,6 +139,38 @@ func (d *GoQueryDocument) CreateStyleTag(content string) (Node, error) {
 	return &GoQueryNode{selection: newStyle, doc: d}, nil
 }
 
+// Doctype returns the doctype name, or an empty string if there is none
+func (d *GoQueryDocument) Doctype() string {
+	for _, root := range d.doc.Nodes {
+		for c := root.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.DoctypeNode {
+				return c.Data
+			}
+		}
+	}
+	return ""
+}
+
+// Comments returns the text of every comment node in document order
+func (d *GoQueryDocument) Comments() []string {
+	var comments []string
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.CommentNode {
+				comments = append(comments, c.Data)
+			}
+			walk(c)
+		}
+	}
+	for _, root := range d.doc.Nodes {
+		walk(root)
+	}
+
+	return comments
+}
+
 // HTML returns the complete HTML document as string
 func (d *GoQueryDocument) HTML() (string, error) {
 	html, err := d.doc.Html()
@@ -137,6 +180,114 @@ func (d *GoQueryDocument) HTML() (string, error) {
 	return html, nil
 }
 
+// lineOf returns an element's start tag line. Lines are mapped for every
+// element in one pass the first time one is asked for, so looking up many
+// elements stays linear in the document size.
+func (d *GoQueryDocument) lineOf(target *html.Node) int {
+	if d.source == "" || target == nil || target.Type != html.ElementNode {
+		return 0
+	}
+	if d.lines == nil {
+		d.lines = d.mapLines()
+	}
+	return d.lines[target]
+}
+
+// mapLines pairs each element's ordinal among same-named elements with the
+// matching start tag in the source. Elements synthesized by the parser
+// (implied <html>, <tbody>, ...) can shift the pairing, so the result is
+// best effort.
+func (d *GoQueryDocument) mapLines() map[*html.Node]int {
+	tagLines := d.startTagLines()
+	lines := make(map[*html.Node]int)
+	seen := make(map[string]int)
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode {
+				ordinal := seen[c.Data]
+				seen[c.Data]++
+				if tags := tagLines[c.Data]; ordinal < len(tags) {
+					lines[c] = tags[ordinal]
+				}
+			}
+			walk(c)
+		}
+	}
+	for _, root := range d.doc.Nodes {
+		walk(root)
+	}
+	return lines
+}
+
+// startTagLines returns the lines of the start tags outside comments, by
+// lowercase tag name
+func (d *GoQueryDocument) startTagLines() map[string][]int {
+	tags := make(map[string][]int)
+	src := d.source
+	line := 1
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '\n':
+			line++
+		case strings.HasPrefix(src[i:], "<!--"):
+			end := strings.Index(src[i+4:], "-->")
+			if end < 0 {
+				i = len(src)
+				continue
+			}
+			line += strings.Count(src[i:i+4+end], "\n")
+			i += 4 + end + 2
+		case src[i] == '<':
+			if name := tagName(src[i+1:]); name != "" {
+				tags[name] = append(tags[name], line)
+			}
+		}
+	}
+	return tags
+}
+
+// tagName returns the lowercase name of the start tag s begins with, or ""
+// when s doesn't start with one
+func tagName(s string) string {
+	end := 0
+	for end < len(s) && isTagNameByte(s[end], end == 0) {
+		end++
+	}
+	if end == 0 || !hasTagPrefix(s, s[:end]) {
+		return ""
+	}
+	return strings.ToLower(s[:end])
+}
+
+// isTagNameByte reports whether b can appear in a tag name; names start
+// with a letter
+func isTagNameByte(b byte, first bool) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z':
+		return true
+	case first:
+		return false
+	}
+	return '0' <= b && b <= '9' || b == '-' || b == ':' || b == '_' || b == '.'
+}
+
+// hasTagPrefix reports whether s starts with tag followed by a tag delimiter
+func hasTagPrefix(s, tag string) bool {
+	if len(s) < len(tag) || !strings.EqualFold(s[:len(tag)], tag) {
+		return false
+	}
+	if len(s) == len(tag) {
+		return true
+	}
+	switch s[len(tag)] {
+	case ' ', '\t', '\n', '\r', '\f', '>', '/':
+		return true
+	}
+	return false
+}
+
 // Node implementation
 
 // TagName returns the element's tag name
@@ -184,6 +335,21 @@ func (n *GoQueryNode) Text() string {
 	return n.selection.Text()
 }
 
+// OwnText returns the text of the element's direct child text nodes
+func (n *GoQueryNode) OwnText() string {
+	if n.selection.Length() == 0 {
+		return ""
+	}
+
+	var text strings.Builder
+	for c := n.selection.Get(0).FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			text.WriteString(c.Data)
+		}
+	}
+	return text.String()
+}
+
 // InnerHTML returns the inner HTML content
 func (n *GoQueryNode) InnerHTML() string {
 	html, _ := n.selection.Html()
@@ -304,8 +470,17 @@ func (n *GoQueryNode) formatStyleString(styles map[string]css.Declaration) strin
 		return ""
 	}
 
+	// Sort properties so output is deterministic; shorthands sort before
+	// their longhands, which is the order authors nearly always intend
+	properties := make([]string, 0, len(styles))
+	for property := range styles {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+
 	var parts []string
-	for _, declaration := range styles {
+	for _, property := range properties {
+		declaration := styles[property]
 		value := declaration.Value
 		if declaration.Important {
 			value += " !important"
@@ -326,6 +501,14 @@ func (n *GoQueryNode) Matches(selector string) (bool, error) {
 	return n.selection.Is(selector), nil
 }
 
+// SourceLine returns the line of the element's start tag in the parsed source
+func (n *GoQueryNode) SourceLine() int {
+	if n.selection.Length() == 0 || n.doc == nil {
+		return 0
+	}
+	return n.doc.lineOf(n.selection.Get(0))
+}
+
 // SetAttribute sets an attribute on the element
 func (n *GoQueryNode) SetAttribute(name, value string) error {
 	if n.selection.Length() == 0 {
@@ -376,6 +559,26 @@ func (n *GoQueryNode) SetHTML(content string) error {
 	return nil
 }
 
+// AppendHTML appends HTML to the element's children
+func (n *GoQueryNode) AppendHTML(content string) error {
+	if n.selection.Length() == 0 {
+		return fmt.Errorf("no element to append to")
+	}
+
+	n.selection.AppendHtml(content)
+	return nil
+}
+
+// ReplaceWithHTML replaces the element with the given HTML
+func (n *GoQueryNode) ReplaceWithHTML(content string) error {
+	if n.selection.Length() == 0 {
+		return fmt.Errorf("no element to replace")
+	}
+
+	n.selection.ReplaceWithHtml(content)
+	return nil
+}
+
 // Helper functions for CSS selector matching
 
 // SelectorComplexity estimates the complexity of a CSS selector
@@ -0,0 +1,22 @@
+package html
+
+import "testing"
+
+func TestSourceLine(t *testing.T) {
+	source := "<html>\n<body>\n<!-- <p>commented</p>\n-->\n<p class=\"a\">one</p>\n<P class=\"b\">\ntwo</P>\n<p class=\"c\">three</p><pre>x</pre>\n</body>\n</html>\n"
+	doc, err := NewParser().Parse(source)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	lines := map[string]int{"p.a": 5, "p.b": 6, "p.c": 8, "pre": 8}
+	for selector, want := range lines {
+		node, err := doc.QuerySelector(selector)
+		if err != nil || node == nil {
+			t.Fatalf("QuerySelector(%q) = %v, %v", selector, node, err)
+		}
+		if got := node.SourceLine(); got != want {
+			t.Errorf("%s: SourceLine = %d, want %d", selector, got, want)
+		}
+	}
+}
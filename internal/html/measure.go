@@ -0,0 +1,123 @@
+package html
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Measurement breaks serialized HTML down by what contributes the bytes
+type Measurement struct {
+	TotalBytes            int // Length of the document
+	StyleTagBytes         int // Contents of <style> elements
+	InlineStyleBytes      int // Values of style="" attributes
+	DuplicateStyleBytes   int // style="" values that repeat an earlier identical value
+	CommentBytes          int // Comments other than conditional comments, including delimiters
+	ConditionalBytes      int // <!--[if mso]> conditional comments, which clients need
+	CollapsibleSpaceBytes int // Whitespace that could be collapsed to a single space
+	DataURIBytes          int // data: URIs in attributes and inline styles
+}
+
+// Measure tokenizes serialized HTML and attributes its size to categories
+func Measure(src string) Measurement {
+	m := Measurement{TotalBytes: len(src)}
+	seenStyles := make(map[string]bool)
+	inStyle := false
+
+	tokenizer := html.NewTokenizer(strings.NewReader(src))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			// io.EOF or unparseable input; unparsed bytes still count towards the total
+			return m
+		}
+
+		raw := string(tokenizer.Raw())
+
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.Data == "style" && tokenType == html.StartTagToken {
+				inStyle = true
+			}
+			for _, attr := range token.Attr {
+				if attr.Key == "style" {
+					m.InlineStyleBytes += len(attr.Val)
+					if seenStyles[attr.Val] {
+						m.DuplicateStyleBytes += len(attr.Val)
+					}
+					seenStyles[attr.Val] = true
+				}
+				m.DataURIBytes += dataURIBytes(attr.Val)
+			}
+
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "style" {
+				inStyle = false
+			}
+
+		case html.TextToken:
+			if inStyle {
+				m.StyleTagBytes += len(raw)
+				m.DataURIBytes += dataURIBytes(raw)
+				continue
+			}
+			m.CollapsibleSpaceBytes += collapsibleSpace(raw)
+
+		case html.CommentToken:
+			comment := tokenizer.Token().Data
+			if IsConditionalComment(comment) {
+				m.ConditionalBytes += len(raw)
+			} else {
+				m.CommentBytes += len(raw)
+			}
+		}
+	}
+}
+
+// IsConditionalComment reports whether comment text is an MSO conditional
+// comment (<!--[if mso]> ... <![endif]-->), which Outlook relies on
+func IsConditionalComment(comment string) bool {
+	trimmed := strings.TrimSpace(comment)
+	return strings.HasPrefix(trimmed, "[if") || strings.HasPrefix(trimmed, "<![endif]") ||
+		strings.HasSuffix(trimmed, "<![endif]") || strings.HasPrefix(trimmed, "[endif]")
+}
+
+// dataURIBytes sums the length of data: URIs in a value
+func dataURIBytes(value string) int {
+	total := 0
+	lower := strings.ToLower(value)
+	for {
+		start := strings.Index(lower, "data:")
+		if start == -1 {
+			return total
+		}
+		end := strings.IndexAny(lower[start:], "\"') \t\n")
+		if end == -1 {
+			end = len(lower) - start
+		}
+		total += end
+		lower = lower[start+end:]
+	}
+}
+
+// collapsibleSpace counts whitespace bytes beyond the first in each run
+func collapsibleSpace(text string) int {
+	total := 0
+	run := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case ' ', '\t', '\n', '\r', '\f':
+			run++
+		default:
+			if run > 1 {
+				total += run - 1
+			}
+			run = 0
+		}
+	}
+	if run > 1 {
+		total += run - 1
+	}
+	return total
+}
@@ -13,6 +13,7 @@ type Node interface {
 
 	// Content access
 	Text() string
+	OwnText() string // Text of direct child text nodes only
 	InnerHTML() string
 	OuterHTML() string
 
@@ -30,12 +31,17 @@ type Node interface {
 	// Selector matching support
 	Matches(selector string) (bool, error)
 
+	// Source location
+	SourceLine() int // 1-based line of the start tag in the parsed source, 0 if unknown
+
 	// Modification
 	SetAttribute(name, value string) error
 	RemoveAttribute(name string) error
 	Remove() error
 	SetText(content string) error
 	SetHTML(content string) error
+	AppendHTML(content string) error
+	ReplaceWithHTML(content string) error
 }
 
 // Document represents the complete HTML document
@@ -53,6 +59,15 @@ type Document interface {
 	GetStyleTags() ([]Node, error)
 	CreateStyleTag(content string) (Node, error)
 
+	// Non-element content
+	Doctype() string    // Doctype name, e.g. "html"; empty if the document has none
+	Comments() []string // Text of every comment in document order
+
+	// Whitespace and comment handling
+	CollapseWhitespace() int                           // Returns bytes removed
+	RemoveComments(keep func(comment string) bool) int // Returns comments removed
+	Indent(indent string)                              // Pretty-print block structure
+
 	// Serialization
 	HTML() (string, error)
 }
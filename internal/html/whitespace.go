@@ -0,0 +1,257 @@
+package html
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// templateTagRegex matches template and ESP merge tags, whose contents must
+// reach the template engine byte for byte
+var templateTagRegex = regexp.MustCompile(`(?s)\{\{.*?\}\}|\{%.*?%\}|\{#.*?#\}|<%.*?%>|\*\|.*?\|\*|%%.*?%%`)
+
+// whitespaceRunRegex matches runs of HTML whitespace
+var whitespaceRunRegex = regexp.MustCompile(`[ \t\n\r\f]+`)
+
+// preformattedTags keep their whitespace exactly as written
+var preformattedTags = map[string]bool{
+	"pre": true, "textarea": true, "script": true, "style": true, "listing": true, "plaintext": true, "xmp": true,
+}
+
+// blockTags are elements whose boundaries make adjacent whitespace insignificant
+var blockTags = map[string]bool{
+	"html": true, "head": true, "body": true, "title": true, "meta": true, "link": true, "base": true,
+	"style": true, "script": true, "noscript": true,
+	"div": true, "p": true, "pre": true, "center": true, "blockquote": true, "hr": true, "br": true,
+	"table": true, "thead": true, "tbody": true, "tfoot": true, "tr": true, "td": true, "th": true,
+	"caption": true, "colgroup": true, "col": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"section": true, "article": true, "header": true, "footer": true, "nav": true, "main": true, "aside": true,
+	"form": true, "fieldset": true, "figure": true, "figcaption": true, "address": true,
+}
+
+// CollapseWhitespace collapses runs of whitespace in text to a single space
+// and drops whitespace next to block boundaries. Preformatted elements,
+// elements styled with white-space: pre*, and template tags are untouched.
+// Returns the number of bytes removed.
+func (d *GoQueryDocument) CollapseWhitespace() int {
+	removed := 0
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		var next *html.Node
+		for c := n.FirstChild; c != nil; c = next {
+			next = c.NextSibling
+
+			switch c.Type {
+			case html.ElementNode:
+				if !preservesWhitespace(c) {
+					walk(c)
+				}
+			case html.TextNode:
+				before := len(c.Data)
+				c.Data = collapseText(c)
+				removed += before - len(c.Data)
+				if c.Data == "" {
+					n.RemoveChild(c)
+				}
+			}
+		}
+	}
+	for _, root := range d.doc.Nodes {
+		walk(root)
+	}
+
+	return removed
+}
+
+// RemoveComments removes comment nodes for which keep returns false and
+// returns how many were removed
+func (d *GoQueryDocument) RemoveComments(keep func(comment string) bool) int {
+	removed := 0
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		var next *html.Node
+		for c := n.FirstChild; c != nil; c = next {
+			next = c.NextSibling
+			if c.Type == html.CommentNode && !keep(c.Data) {
+				n.RemoveChild(c)
+				removed++
+				continue
+			}
+			walk(c)
+		}
+	}
+	for _, root := range d.doc.Nodes {
+		walk(root)
+	}
+
+	return removed
+}
+
+// preservesWhitespace reports whether an element's text must keep its whitespace
+func preservesWhitespace(n *html.Node) bool {
+	if preformattedTags[n.Data] {
+		return true
+	}
+	for _, attr := range n.Attr {
+		if attr.Key == "style" && whiteSpacePreRegex.MatchString(strings.ToLower(attr.Val)) {
+			return true
+		}
+	}
+	return false
+}
+
+// whiteSpacePreRegex matches white-space values that keep whitespace
+var whiteSpacePreRegex = regexp.MustCompile(`white-space\s*:\s*(pre|pre-wrap|pre-line|break-spaces)\b`)
+
+// collapseText returns the collapsed content of a text node
+func collapseText(n *html.Node) string {
+	text := collapseOutsideTemplates(n.Data)
+
+	if isBlockBoundary(n.PrevSibling, n.Parent, false) {
+		text = strings.TrimLeft(text, " ")
+	}
+	if isBlockBoundary(n.NextSibling, n.Parent, true) {
+		text = strings.TrimRight(text, " ")
+	}
+	return text
+}
+
+// isBlockBoundary reports whether whitespace next to sibling is insignificant:
+// the nearest sibling in that direction is a block element, or there is none
+// and the parent is a block. Comments and whitespace-only text are looked
+// through, so a gap either side of an MSO conditional between two
+// inline-block columns survives.
+func isBlockBoundary(sibling, parent *html.Node, forward bool) bool {
+	for sibling != nil && (sibling.Type == html.CommentNode ||
+		(sibling.Type == html.TextNode && strings.TrimSpace(sibling.Data) == "")) {
+		if forward {
+			sibling = sibling.NextSibling
+		} else {
+			sibling = sibling.PrevSibling
+		}
+	}
+
+	switch {
+	case sibling == nil:
+		return parent != nil && (parent.Type == html.DocumentNode || isBlockElement(parent))
+	case sibling.Type == html.ElementNode:
+		return isBlockElement(sibling)
+	case sibling.Type == html.DoctypeNode:
+		return true
+	}
+	return false
+}
+
+// displayRegex extracts the display value from an inline style
+var displayRegex = regexp.MustCompile(`(?:^|;)\s*display\s*:\s*([a-z-]+)`)
+
+// isBlockElement reports whether an element lays out as a block. An inline
+// display style wins over the tag, so whitespace between inline-block
+// columns in hybrid layouts is treated as the gap it renders as.
+func isBlockElement(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	for _, attr := range n.Attr {
+		if attr.Key != "style" {
+			continue
+		}
+		if m := displayRegex.FindStringSubmatch(strings.ToLower(attr.Val)); m != nil {
+			switch {
+			case strings.HasPrefix(m[1], "inline"):
+				return false
+			case m[1] == "block" || m[1] == "list-item" || m[1] == "flex" || m[1] == "grid" || strings.HasPrefix(m[1], "table"):
+				return true
+			}
+		}
+	}
+	return blockTags[n.Data]
+}
+
+// Indent collapses whitespace and then puts each block-level child of a
+// block container on its own line, indented by depth. Elements with any
+// text or inline content are left on one line so no whitespace is added
+// where it would render; preformatted elements are untouched.
+func (d *GoQueryDocument) Indent(indent string) {
+	d.CollapseWhitespace()
+
+	var walk func(n *html.Node, depth int)
+	walk = func(n *html.Node, depth int) {
+		if n.Type == html.ElementNode && preservesWhitespace(n) {
+			return
+		}
+		if !isBlockContainer(n) {
+			return
+		}
+
+		var children []*html.Node
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			children = append(children, c)
+		}
+		for j, c := range children {
+			if n.Type != html.DocumentNode || j > 0 {
+				n.InsertBefore(&html.Node{Type: html.TextNode, Data: "\n" + strings.Repeat(indent, depth+1)}, c)
+			}
+			if c.Type == html.ElementNode {
+				walk(c, depth+1)
+			}
+		}
+		if n.Type == html.ElementNode {
+			n.AppendChild(&html.Node{Type: html.TextNode, Data: "\n" + strings.Repeat(indent, depth)})
+		}
+	}
+	for _, root := range d.doc.Nodes {
+		walk(root, -1)
+	}
+}
+
+// isBlockContainer reports whether every child of n is a block element or
+// a comment, so whitespace can be added between them without rendering
+func isBlockContainer(n *html.Node) bool {
+	if n.FirstChild == nil {
+		return false
+	}
+	if n.Type != html.DocumentNode && !isBlockElement(n) {
+		return false
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.ElementNode:
+			if !isBlockElement(c) {
+				return false
+			}
+		case html.CommentNode, html.DoctypeNode:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// collapseOutsideTemplates collapses whitespace runs, leaving template tags as written
+func collapseOutsideTemplates(text string) string {
+	locations := templateTagRegex.FindAllStringIndex(text, -1)
+	if len(locations) == 0 {
+		return whitespaceRunRegex.ReplaceAllString(text, " ")
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, loc := range locations {
+		out.WriteString(whitespaceRunRegex.ReplaceAllString(text[last:loc[0]], " "))
+		out.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	out.WriteString(whitespaceRunRegex.ReplaceAllString(text[last:], " "))
+	return out.String()
+}
+
+// ContainsTemplateTag reports whether text contains a template or ESP merge tag
+func ContainsTemplateTag(text string) bool {
+	return templateTagRegex.MatchString(text)
+}
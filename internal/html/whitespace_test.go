@@ -0,0 +1,193 @@
+package html
+
+import "testing"
+
+// formatted parses page, applies format and returns the serialized document
+func formatted(t *testing.T, page string, format func(Document)) string {
+	t.Helper()
+	doc, err := NewParser().Parse(page)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	format(doc)
+	out, err := doc.HTML()
+	if err != nil {
+		t.Fatalf("HTML: %v", err)
+	}
+	return out
+}
+
+func collapse(doc Document) { doc.CollapseWhitespace() }
+
+func TestCollapseWhitespace(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "runs collapse and block edges trim",
+			body: "\n  <div>\n   <p>  Hello \t\n <b> big </b>  world  </p>\n  </div>\n",
+			want: "<div><p>Hello <b> big </b> world</p></div>",
+		},
+		{
+			name: "inline siblings keep their gap",
+			body: "<p><span>x</span>   <span>y</span></p>",
+			want: "<p><span>x</span> <span>y</span></p>",
+		},
+		{
+			name: "whitespace between blocks is dropped",
+			body: "<table>\n <tr>\n  <td> A </td>\n  <td>B</td>\n </tr>\n</table>",
+			want: "<table><tbody><tr><td>A</td><td>B</td></tr></tbody></table>",
+		},
+		{
+			name: "pre and textarea are untouched",
+			body: "<pre>  keep\n   this </pre>\n<textarea>  a\n  b</textarea>",
+			want: "<pre>  keep\n   this </pre><textarea>  a\n  b</textarea>",
+		},
+		{
+			name: "white-space pre styles are untouched",
+			body: `<p style="white-space: pre-wrap">  a   b </p><div style="WHITE-SPACE:pre-line"><span>  c  </span></div><p style="white-space: nowrap">  d   e </p>`,
+			want: `<p style="white-space: pre-wrap">  a   b </p><div style="WHITE-SPACE:pre-line"><span>  c  </span></div><p style="white-space: nowrap">d e</p>`,
+		},
+		{
+			name: "template tags are kept byte for byte",
+			body: "<p>Hi   {{  first_name   }},  {% if  vip %}  VIP {% endif %}  *|FNAME  |*  <%=  x %>  %%Name  %%</p>",
+			want: "<p>Hi {{  first_name   }}, {% if  vip %} VIP {% endif %} *|FNAME  |* &lt;%=  x %&gt; %%Name  %%</p>",
+		},
+		{
+			name: "inline-block columns keep their gap",
+			body: `<div style="display: inline-block">A</div>
+<div style="display:inline-block">B</div>
+<span style="display: block">C</span>
+<span>D</span>`,
+			want: `<div style="display: inline-block">A</div> <div style="display:inline-block">B</div><span style="display: block">C</span><span>D</span>`,
+		},
+		{
+			name: "gap around MSO conditional between columns survives",
+			body: `<div style="display:inline-block">A</div>
+<!--[if mso]></td><td><![endif]-->
+<div style="display:inline-block">B</div>`,
+			want: `<div style="display:inline-block">A</div> <!--[if mso]></td><td><![endif]--> <div style="display:inline-block">B</div>`,
+		},
+		{
+			name: "MSO conditional between blocks",
+			body: "<div>A</div>\n<!--[if mso]><table><tr><td><![endif]-->\n<div>B</div>",
+			want: "<div>A</div><!--[if mso]><table><tr><td><![endif]--><div>B</div>",
+		},
+	}
+
+	for _, test := range tests {
+		got := formatted(t, "<html><head></head><body>"+test.body+"</body></html>", collapse)
+		want := "<html><head></head><body>" + test.want + "</body></html>"
+		if got != want {
+			t.Errorf("%s:\ngot  %q\nwant %q", test.name, got, want)
+		}
+	}
+}
+
+func TestCollapseWhitespaceReportsBytesRemoved(t *testing.T) {
+	doc, err := NewParser().Parse("<html><head></head><body>\n  <p>a   b</p>\n</body></html>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed := doc.CollapseWhitespace(); removed != 6 {
+		t.Errorf("removed %d bytes, want 6", removed)
+	}
+	if removed := doc.CollapseWhitespace(); removed != 0 {
+		t.Errorf("second pass removed %d bytes, want 0", removed)
+	}
+}
+
+func TestIndent(t *testing.T) {
+	page := `<html><head><title> T </title><style>
+  p { color: red }
+</style></head><body>
+<table><tr><td>  Hi  <b>there</b> </td><td><div><p>One</p><p>Two</p></div></td></tr></table>
+<!--[if mso]><p>Outlook</p><![endif]-->
+<pre>  a
+ b</pre>
+</body></html>`
+
+	want := `<html>
+  <head>
+    <title>T</title>
+    <style>
+  p { color: red }
+</style>
+  </head>
+  <body>
+    <table>
+      <tbody>
+        <tr>
+          <td>Hi <b>there</b></td>
+          <td>
+            <div>
+              <p>One</p>
+              <p>Two</p>
+            </div>
+          </td>
+        </tr>
+      </tbody>
+    </table>
+    <!--[if mso]><p>Outlook</p><![endif]-->
+    <pre>  a
+ b</pre>
+  </body>
+</html>`
+
+	got := formatted(t, page, func(doc Document) { doc.Indent("  ") })
+	if got != want {
+		t.Errorf("Indent:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Indenting again changes nothing
+	if again := formatted(t, got, func(doc Document) { doc.Indent("  ") }); again != got {
+		t.Errorf("Indent is not idempotent:\n%s", again)
+	}
+}
+
+func TestIndentKeepsInlineContentOnOneLine(t *testing.T) {
+	page := `<html><head></head><body><div><span>a</span> <span>b</span></div><p>Text <a href="#">link</a></p>` +
+		`<div style="display:inline-block">A</div> <div style="display:inline-block">B</div></body></html>`
+
+	// The body has inline-block children, so nothing is added inside it
+	want := "<html>\n\t<head></head>\n\t<body>" +
+		`<div><span>a</span> <span>b</span></div><p>Text <a href="#">link</a></p>` +
+		`<div style="display:inline-block">A</div> <div style="display:inline-block">B</div></body>` + "\n</html>"
+	if got := formatted(t, page, func(doc Document) { doc.Indent("\t") }); got != want {
+		t.Errorf("Indent:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestCollapseOutsideTemplates(t *testing.T) {
+	tests := map[string]string{
+		"a  \n\t b":                 "a b",
+		"{{  x  }}":                 "{{  x  }}",
+		"  {{ a\n  b }}  c  ":       " {{ a\n  b }} c ",
+		"{#  note  #}  {%  raw  %}": "{#  note  #} {%  raw  %}",
+		"x  {{ unterminated   text": "x {{ unterminated text",
+	}
+	for input, want := range tests {
+		if got := collapseOutsideTemplates(input); got != want {
+			t.Errorf("collapseOutsideTemplates(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestContainsTemplateTag(t *testing.T) {
+	tests := map[string]bool{
+		"Hello {{ name }}": true,
+		"{% if x %}":       true,
+		"*|FNAME|*":        true,
+		"<%= x %>":         true,
+		"%%Name%%":         true,
+		"{ not a tag }":    false,
+		"100% off":         false,
+	}
+	for text, want := range tests {
+		if got := ContainsTemplateTag(text); got != want {
+			t.Errorf("ContainsTemplateTag(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package inliner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"inliner/internal/html"
+	"inliner/internal/rules"
+)
+
+// Fix records a change made to the document by an autofix pass
+type Fix struct {
+	Rule        string // Rule ID the fix addresses, e.g. "a11y-img-alt"
+	Element     string // Tag name of the modified element
+	Line        int    // Source line of the element, 0 if unknown
+	Description string // What was changed
+}
+
+// layoutTableAttributes are added to layout tables alongside the presentation role
+var layoutTableAttributes = []string{"cellpadding", "cellspacing", "border"}
+
+// applyAccessibilityFixes makes conservative accessibility fixes and records
+// each change in the result
+func (i *Inliner) applyAccessibilityFixes(doc html.Document, result *InlineResult) error {
+	if err := i.fixHTMLLang(doc, result); err != nil {
+		return err
+	}
+	if err := i.fixLayoutTables(doc, result); err != nil {
+		return err
+	}
+	return i.fixDecorativeImages(doc, result)
+}
+
+// fixHTMLLang adds the configured language to <html> when it has none
+func (i *Inliner) fixHTMLLang(doc html.Document, result *InlineResult) error {
+	if i.config.DefaultLang == "" {
+		return nil
+	}
+
+	root := doc.Root()
+	if !strings.EqualFold(root.TagName(), "html") || strings.TrimSpace(root.Attributes()["lang"]) != "" {
+		return nil
+	}
+
+	if err := root.SetAttribute("lang", i.config.DefaultLang); err != nil {
+		return fmt.Errorf("failed to set lang: %w", err)
+	}
+	result.Fixes = append(result.Fixes, Fix{
+		Rule:        "a11y-html-lang",
+		Element:     "html",
+		Line:        root.SourceLine(),
+		Description: fmt.Sprintf("added lang=%q", i.config.DefaultLang),
+	})
+	return nil
+}
+
+// fixLayoutTables marks tables without header cells as presentational and
+// zeroes the spacing attributes that default to non-zero in Outlook
+func (i *Inliner) fixLayoutTables(doc html.Document, result *InlineResult) error {
+	tables, err := doc.QuerySelectorAll("table")
+	if err != nil {
+		return fmt.Errorf("failed to query tables: %w", err)
+	}
+
+	for _, table := range tables {
+		attrs := table.Attributes()
+		if rules.IsDataTable(table) {
+			continue
+		}
+		if role := strings.ToLower(strings.TrimSpace(attrs["role"])); role == "presentation" || role == "none" {
+			continue
+		}
+
+		added := []string{`role="presentation"`}
+		if err := table.SetAttribute("role", "presentation"); err != nil {
+			return fmt.Errorf("failed to set table role: %w", err)
+		}
+		for _, name := range layoutTableAttributes {
+			if _, ok := attrs[name]; ok {
+				continue
+			}
+			if err := table.SetAttribute(name, "0"); err != nil {
+				return fmt.Errorf("failed to set table %s: %w", name, err)
+			}
+			added = append(added, fmt.Sprintf(`%s="0"`, name))
+		}
+
+		result.Fixes = append(result.Fixes, Fix{
+			Rule:        "a11y-layout-table-role",
+			Element:     "table",
+			Line:        table.SourceLine(),
+			Description: "added " + strings.Join(added, " "),
+		})
+	}
+
+	return nil
+}
+
+// fixDecorativeImages adds alt="" to images without alt that the heuristic
+// considers decorative. Content images are left for a human to describe.
+func (i *Inliner) fixDecorativeImages(doc html.Document, result *InlineResult) error {
+	images, err := doc.QuerySelectorAll("img:not([alt])")
+	if err != nil {
+		return fmt.Errorf("failed to query images: %w", err)
+	}
+
+	for _, img := range images {
+		if !i.isDecorativeImage(img) {
+			continue
+		}
+
+		if err := img.SetAttribute("alt", ""); err != nil {
+			return fmt.Errorf("failed to set alt: %w", err)
+		}
+		result.Fixes = append(result.Fixes, Fix{
+			Rule:        "a11y-img-alt",
+			Element:     "img",
+			Line:        img.SourceLine(),
+			Description: fmt.Sprintf(`added alt="" to decorative image %s`, img.Attributes()["src"]),
+		})
+	}
+
+	return nil
+}
+
+// isDecorativeImage applies the configured size and src heuristics
+func (i *Inliner) isDecorativeImage(img html.Node) bool {
+	attrs := img.Attributes()
+
+	if role := strings.ToLower(attrs["role"]); role == "presentation" || role == "none" {
+		return true
+	}
+	if strings.EqualFold(attrs["aria-hidden"], "true") {
+		return true
+	}
+
+	src := strings.ToLower(attrs["src"])
+	for _, pattern := range i.config.DecorativeImagePatterns {
+		if pattern != "" && strings.Contains(src, strings.ToLower(pattern)) {
+			return true
+		}
+	}
+
+	if maxSize := i.config.DecorativeImageMaxSize; maxSize > 0 {
+		width, widthOK := imageDimension(img, "width")
+		height, heightOK := imageDimension(img, "height")
+		if widthOK && heightOK && width <= maxSize && height <= maxSize {
+			return true
+		}
+	}
+
+	return false
+}
+
+// imageDimension reads a pixel dimension from the attribute or inline style
+func imageDimension(img html.Node, name string) (int, bool) {
+	value := img.Attributes()[name]
+	if declaration, ok := img.GetInlineStyle()[name]; ok {
+		value = declaration.Value
+	}
+
+	value = strings.TrimSuffix(strings.TrimSpace(strings.ToLower(value)), "px")
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
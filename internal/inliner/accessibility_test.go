@@ -0,0 +1,193 @@
+package inliner
+
+import (
+	"reflect"
+	"testing"
+
+	"inliner/internal/config"
+	"inliner/internal/html"
+)
+
+// fixPage inlines page with accessibility fixes and returns the result and
+// the attributes of each output element with an id
+func fixPage(t *testing.T, cfg config.Config, page string) (*InlineResult, map[string]map[string]string) {
+	t.Helper()
+	cfg.AccessibilityFixes = true
+	result, err := New(cfg).Inline(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := html.NewParser().Parse(result.HTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := doc.QuerySelectorAll("[id]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	elements := make(map[string]map[string]string, len(nodes))
+	for _, node := range nodes {
+		attrs := node.Attributes()
+		elements[attrs["id"]] = attrs
+	}
+	return result, elements
+}
+
+// fixedRules returns the rule and element of each fix
+func fixedRules(fixes []Fix) []string {
+	var found []string
+	for _, fix := range fixes {
+		found = append(found, fix.Rule+" "+fix.Element)
+	}
+	return found
+}
+
+func TestFixLayoutTables(t *testing.T) {
+	result, elements := fixPage(t, config.Default(), `<html><body>
+<table id="layout"><tr><td>Layout</td></tr></table>
+<table id="spaced" cellpadding="10" border="1"><tr><td>Spaced</td></tr></table>
+<table id="marked" role="presentation"><tr><td>Marked</td></tr></table>
+<table id="none" role="none"><tr><td>None</td></tr></table>
+<table id="headers"><tr><th>Name</th></tr><tr><td>Value</td></tr></table>
+<table id="caption"><caption>Totals</caption><tr><td>1</td></tr></table>
+<table id="summary" summary="Totals"><tr><td>1</td></tr></table>
+<table id="grid" role="grid"><tr><td>1</td></tr></table>
+<table id="outer"><tr><td><table id="inner"><thead><tr><th>H</th></tr></thead></table></td></tr></table>
+</body></html>`)
+
+	tests := []struct {
+		id    string
+		attrs map[string]string
+	}{
+		{"layout", map[string]string{"role": "presentation", "cellpadding": "0", "cellspacing": "0", "border": "0"}},
+		{"spaced", map[string]string{"role": "presentation", "cellpadding": "10", "cellspacing": "0", "border": "1"}},
+		{"marked", map[string]string{"role": "presentation"}},
+		{"none", map[string]string{"role": "none"}},
+		{"headers", map[string]string{}},
+		{"caption", map[string]string{}},
+		{"summary", map[string]string{"summary": "Totals"}},
+		{"grid", map[string]string{"role": "grid"}},
+		{"outer", map[string]string{"role": "presentation", "cellpadding": "0", "cellspacing": "0", "border": "0"}},
+		{"inner", map[string]string{}},
+	}
+	for _, test := range tests {
+		got := elements[test.id]
+		delete(got, "id")
+		if !reflect.DeepEqual(got, test.attrs) {
+			t.Errorf("#%s attributes = %v, want %v", test.id, got, test.attrs)
+		}
+	}
+
+	want := []Fix{
+		{Rule: "a11y-layout-table-role", Element: "table", Line: 2, Description: `added role="presentation" cellpadding="0" cellspacing="0" border="0"`},
+		{Rule: "a11y-layout-table-role", Element: "table", Line: 3, Description: `added role="presentation" cellspacing="0"`},
+		{Rule: "a11y-layout-table-role", Element: "table", Line: 10, Description: `added role="presentation" cellpadding="0" cellspacing="0" border="0"`},
+	}
+	if !reflect.DeepEqual(result.Fixes, want) {
+		t.Errorf("fixes = %+v, want %+v", result.Fixes, want)
+	}
+}
+
+func TestFixDecorativeImages(t *testing.T) {
+	result, elements := fixPage(t, config.Default(), `<html><body>
+<img id="pixel" src="https://t.example.com/open.png" width="1" height="1">
+<img id="spacer" src="/images/Spacer.gif" width="20" height="10">
+<img id="styled" src="dot.png" style="width: 2px; height: 2px">
+<img id="hidden" src="flourish.png" aria-hidden="true">
+<img id="presentation" src="divider.png" role="presentation">
+<img id="content" src="hero.jpg" width="600" height="300">
+<img id="wide" src="rule.png" width="600" height="1">
+<img id="unsized" src="logo.png">
+<img id="described" src="spacer.gif" alt="Spacer" width="1" height="1">
+</body></html>`)
+
+	tests := map[string]struct {
+		alt string
+		ok  bool
+	}{
+		"pixel":        {"", true},
+		"spacer":       {"", true},
+		"styled":       {"", true},
+		"hidden":       {"", true},
+		"presentation": {"", true},
+		"content":      {ok: false},
+		"wide":         {ok: false},
+		"unsized":      {ok: false},
+		"described":    {"Spacer", true},
+	}
+	for id, want := range tests {
+		alt, ok := elements[id]["alt"]
+		if alt != want.alt || ok != want.ok {
+			t.Errorf("#%s alt = %q (present %v), want %q (present %v)", id, alt, ok, want.alt, want.ok)
+		}
+	}
+
+	wantFixes := []string{"a11y-img-alt img", "a11y-img-alt img", "a11y-img-alt img", "a11y-img-alt img", "a11y-img-alt img"}
+	if got := fixedRules(result.Fixes); !reflect.DeepEqual(got, wantFixes) {
+		t.Errorf("fixes = %v, want %v", got, wantFixes)
+	}
+	if want := `added alt="" to decorative image https://t.example.com/open.png`; result.Fixes[0].Description != want || result.Fixes[0].Line != 2 {
+		t.Errorf("first fix = %+v, want %q on line 2", result.Fixes[0], want)
+	}
+}
+
+func TestDecorativeImageHeuristicsFollowConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.DecorativeImageMaxSize = 0
+	cfg.DecorativeImagePatterns = []string{"divider"}
+
+	_, elements := fixPage(t, cfg, `<html><body>
+<img id="pixel" src="open.png" width="1" height="1">
+<img id="spacer" src="spacer.gif">
+<img id="divider" src="/img/DIVIDER-2.png">
+</body></html>`)
+
+	for id, decorative := range map[string]bool{"pixel": false, "spacer": false, "divider": true} {
+		if _, ok := elements[id]["alt"]; ok != decorative {
+			t.Errorf("#%s alt added = %v, want %v", id, ok, decorative)
+		}
+	}
+}
+
+func TestFixHTMLLang(t *testing.T) {
+	tests := []struct {
+		name  string
+		lang  string
+		page  string
+		want  string
+		fixed bool
+	}{
+		{name: "added", lang: "en", page: `<html id="root"><body></body></html>`, want: "en", fixed: true},
+		{name: "kept", lang: "en", page: `<html id="root" lang="de"><body></body></html>`, want: "de"},
+		{name: "blank replaced", lang: "en", page: `<html id="root" lang=" "><body></body></html>`, want: "en", fixed: true},
+		{name: "no default", page: `<html id="root"><body></body></html>`},
+	}
+
+	for _, test := range tests {
+		cfg := config.Default()
+		cfg.DefaultLang = test.lang
+		result, elements := fixPage(t, cfg, test.page)
+
+		if got := elements["root"]["lang"]; got != test.want {
+			t.Errorf("%s: lang = %q, want %q", test.name, got, test.want)
+		}
+		var fixed bool
+		for _, fix := range result.Fixes {
+			fixed = fixed || fix.Rule == "a11y-html-lang"
+		}
+		if fixed != test.fixed {
+			t.Errorf("%s: lang fix recorded = %v, want %v", test.name, fixed, test.fixed)
+		}
+	}
+}
+
+func TestAccessibilityFixesOffByDefault(t *testing.T) {
+	result, err := New(config.Default()).Inline(`<html><body><table><tr><td><img src="spacer.gif"></td></tr></table></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Fixes) != 0 {
+		t.Errorf("fixes = %+v, want none", result.Fixes)
+	}
+}
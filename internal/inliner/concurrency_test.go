@@ -0,0 +1,172 @@
+package inliner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"inliner/internal/config"
+)
+
+const concurrencyHTML = `<html><head>
+<link rel="stylesheet" href="theme.css">
+<style>
+p { color: #aabbcc; margin: 0px }
+.note { font-weight: bold }
+@media (max-width: 600px) { p { color: red } }
+</style>
+</head><body><p class="note">one</p><p>two</p></body></html>`
+
+// mapLoader serves stylesheets from memory and counts loads
+type mapLoader struct {
+	sheets map[string]string
+	loads  atomic.Int32
+}
+
+func (l *mapLoader) Load(ctx context.Context, href string) (string, error) {
+	l.loads.Add(1)
+	if text, ok := l.sheets[href]; ok {
+		return text, nil
+	}
+	return "", fmt.Errorf("no stylesheet %s", href)
+}
+
+func newConcurrencyInliner() (*Inliner, *mapLoader) {
+	loader := &mapLoader{sheets: map[string]string{
+		"theme.css": `@import "base.css"; p { padding: 4px }`,
+		"base.css":  `body { background: #ffffff }`,
+	}}
+	i := New(config.Default())
+	i.SetLoader(loader)
+	i.SetSheetCache(NewSheetCache())
+	return i, loader
+}
+
+// Calls with different per-call options share one Inliner, its loader and
+// its sheet cache, and must each produce what the same call does alone
+func TestInlineContextConcurrent(t *testing.T) {
+	i, loader := newConcurrencyInliner()
+
+	variants := [][]Option{
+		nil,
+		{WithMinify(true)},
+		{WithTargetClient("outlook")},
+		{WithOutputFormat("compact"), WithMinify(true)},
+	}
+	want := make([]string, len(variants))
+	for j, opts := range variants {
+		var out bytes.Buffer
+		if _, err := i.InlineContext(context.Background(), strings.NewReader(concurrencyHTML), &out, opts...); err != nil {
+			t.Fatalf("variant %d: %v", j, err)
+		}
+		want[j] = out.String()
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for n := 0; n < 64; n++ {
+		wg.Add(1)
+		go func(j int) {
+			defer wg.Done()
+			var out bytes.Buffer
+			result, err := i.InlineContext(context.Background(), strings.NewReader(concurrencyHTML), &out, variants[j]...)
+			switch {
+			case err != nil:
+				errs <- fmt.Errorf("variant %d: %w", j, err)
+			case out.String() != want[j]:
+				errs <- fmt.Errorf("variant %d: output differs from the sequential run:\n%s", j, out.String())
+			case result.InlinedStyles == 0:
+				errs <- fmt.Errorf("variant %d: nothing inlined", j)
+			}
+		}(n % len(variants))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// theme.css and base.css are each loaded once, by the first sequential call
+	if got := loader.loads.Load(); got != 2 {
+		t.Errorf("loader called %d times, want 2 with a shared sheet cache", got)
+	}
+}
+
+// Changing the pipeline while documents are being inlined must neither race
+// nor disturb calls already running
+func TestPipelineMutationDuringInline(t *testing.T) {
+	i, _ := newConcurrencyInliner()
+	pipeline := i.Pipeline()
+
+	var hooks atomic.Int32
+	count := func(s *State) error {
+		hooks.Add(1)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var inliners sync.WaitGroup
+	var completed atomic.Int32
+	errs := make(chan error, 32)
+	for n := 0; n < 8; n++ {
+		inliners.Add(1)
+		go func() {
+			defer inliners.Done()
+			for ctx.Err() == nil {
+				var out bytes.Buffer
+				if _, err := i.InlineContext(ctx, strings.NewReader(concurrencyHTML), &out, WithMinify(n%2 == 0)); err != nil {
+					if ctx.Err() == nil {
+						errs <- err
+					}
+					return
+				}
+				if !strings.Contains(out.String(), "font-weight") {
+					errs <- fmt.Errorf("styles not inlined:\n%s", out.String())
+					return
+				}
+				completed.Add(1)
+			}
+		}()
+	}
+
+	// Keep mutating until calls have run against the changing pipeline
+	for n := 0; n < 50 || (completed.Load() < 20 && len(errs) == 0); n++ {
+		name := fmt.Sprintf("custom-%d", n)
+		steps := []error{
+			pipeline.Insert(StageInlineStyles, Stage{Name: name, Run: count}),
+			pipeline.Before(StageMinify, count),
+			pipeline.After(name, count),
+			pipeline.Replace(name, func(s *State) error {
+				s.Result.Warnings = append(s.Result.Warnings, ValidationWarning{Rule: name})
+				return nil
+			}),
+			pipeline.Remove(name),
+		}
+		for _, err := range steps {
+			if err != nil {
+				t.Fatalf("mutating pipeline: %v", err)
+			}
+		}
+		_ = pipeline.Stages()
+	}
+
+	cancel()
+	inliners.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if got := strings.Join(pipeline.Stages(), ","); got != strings.Join(DefaultPipeline().Stages(), ",") {
+		t.Errorf("stages = %s, want the defaults after every custom stage was removed", got)
+	}
+	if hooks.Load() == 0 {
+		t.Error("no hook ran while inlining")
+	}
+}
@@ -0,0 +1,120 @@
+package inliner
+
+import (
+	"fmt"
+	stdhtml "html"
+	"strings"
+
+	"inliner/internal/css"
+	"inliner/internal/html"
+)
+
+// colorSchemeMetas are the meta tags ColorScheme adds; Apple Mail reads the
+// standard name and older clients the supported-color-schemes one
+var colorSchemeMetas = []string{"color-scheme", "supported-color-schemes"}
+
+// outlookDarkVariants builds the Outlook.com versions of the color and
+// background declarations in dark scheme rules. Outlook.com ignores
+// prefers-color-scheme but marks elements it recolors with data-ogsc (text)
+// and data-ogsb (background) on an ancestor.
+func outlookDarkVariants(rules []css.Rule) []css.Rule {
+	var variants []css.Rule
+
+	for _, rule := range rules {
+		if rule.IsAtRule() || !rule.InDarkScheme() {
+			continue
+		}
+
+		// The variants apply under the remaining conditions only
+		var atRules []string
+		for _, atRule := range rule.AtRules {
+			if !css.IsDarkSchemeQuery(atRule) {
+				atRules = append(atRules, atRule)
+			}
+		}
+
+		selectors := css.SplitSelectorList(rule.Selector)
+
+		if color, ok := rule.Declarations["color"]; ok {
+			variants = append(variants, outlookVariant("[data-ogsc]", selectors, atRules,
+				css.Declaration{Property: "color", Value: color.Value, Important: true}))
+		}
+
+		background, ok := rule.Declarations["background-color"]
+		if !ok {
+			background, ok = rule.Declarations["background"]
+		}
+		if ok {
+			value := background.Value
+			if color, found := css.BackgroundColor(value); found {
+				value = color.Hex()
+			}
+			variants = append(variants, outlookVariant("[data-ogsb]", selectors, atRules,
+				css.Declaration{Property: "background-color", Value: value, Important: true}))
+		}
+	}
+
+	return variants
+}
+
+// outlookVariant builds a rule applying one declaration to each selector
+// under the given Outlook.com attribute
+func outlookVariant(attribute string, selectors, atRules []string, declaration css.Declaration) css.Rule {
+	prefixed := make([]string, len(selectors))
+	for j, selector := range selectors {
+		prefixed[j] = attribute + " " + selector
+	}
+	return css.Rule{
+		Selector:     strings.Join(prefixed, ", "),
+		Declarations: map[string]css.Declaration{declaration.Property: declaration},
+		AtRules:      atRules,
+	}
+}
+
+// darkModeStage adds the configured color scheme meta tags
+func (i *Inliner) darkModeStage(s *State) error {
+	if i.config.ColorScheme == "" {
+		return nil
+	}
+	if err := i.addColorSchemeMeta(s.Document, s.Result); err != nil {
+		return fmt.Errorf("failed to add color scheme meta tags: %w", err)
+	}
+	return nil
+}
+
+// addColorSchemeMeta adds the color scheme meta tags missing from <head>
+func (i *Inliner) addColorSchemeMeta(doc html.Document, result *InlineResult) error {
+	head := doc.Head()
+	if !strings.EqualFold(head.TagName(), "head") {
+		return nil
+	}
+
+	metas, err := doc.QuerySelectorAll("meta[name]")
+	if err != nil {
+		return fmt.Errorf("failed to query meta tags: %w", err)
+	}
+
+	for _, name := range colorSchemeMetas {
+		present := false
+		for _, meta := range metas {
+			if strings.EqualFold(strings.TrimSpace(meta.Attributes()["name"]), name) {
+				present = true
+				break
+			}
+		}
+		if present {
+			continue
+		}
+
+		tag := fmt.Sprintf(`<meta name="%s" content="%s">`, name, stdhtml.EscapeString(i.config.ColorScheme))
+		if err := head.AppendHTML(tag); err != nil {
+			return err
+		}
+		result.Fixes = append(result.Fixes, Fix{
+			Rule:        "dark-mode-meta",
+			Element:     "meta",
+			Description: fmt.Sprintf("added <meta name=%q content=%q>", name, i.config.ColorScheme),
+		})
+	}
+	return nil
+}
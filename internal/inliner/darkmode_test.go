@@ -0,0 +1,28 @@
+package inliner
+
+import (
+	"testing"
+
+	"inliner/internal/css"
+)
+
+func TestOutlookDarkVariantsSplitTopLevelCommas(t *testing.T) {
+	stylesheet, err := css.NewParser().Parse(`@media (prefers-color-scheme: dark) {
+  :is(.a, .b) p, [title="x,y"] { color: #ffffff !important }
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	variants := outlookDarkVariants(stylesheet.Rules)
+	if len(variants) != 1 {
+		t.Fatalf("got %d variants, want 1: %+v", len(variants), variants)
+	}
+	want := `[data-ogsc] :is(.a, .b) p, [data-ogsc] [title="x,y"]`
+	if got := variants[0].Selector; got != want {
+		t.Errorf("selector = %q, want %q", got, want)
+	}
+	if len(variants[0].AtRules) != 0 {
+		t.Errorf("AtRules = %q, want none outside the dark scheme query", variants[0].AtRules)
+	}
+}
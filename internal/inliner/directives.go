@@ -0,0 +1,105 @@
+package inliner
+
+import (
+	"fmt"
+	"strings"
+
+	"inliner/internal/html"
+)
+
+// Directive attributes. data-inliner="ignore" leaves an element's styles
+// untouched, "ignore-subtree" extends that to its descendants,
+// "vml-button" and "vml-background" add Outlook VML fallbacks, and
+// data-inliner-preserve keeps a <style> tag exactly as written. The
+// attributes are removed from the output.
+const (
+	directiveAttribute = "data-inliner"
+	preserveAttribute  = "data-inliner-preserve"
+
+	directiveIgnore        = "ignore"
+	directiveIgnoreSubtree = "ignore-subtree"
+	directiveVMLButton     = "vml-button"
+	directiveVMLBackground = "vml-background"
+)
+
+// hasDirective reports whether an element's data-inliner attribute lists directive
+func hasDirective(element html.Node, directive string) bool {
+	value, ok := element.Attributes()[directiveAttribute]
+	if !ok {
+		return false
+	}
+	for _, field := range strings.Fields(strings.ToLower(value)) {
+		if field == directive {
+			return true
+		}
+	}
+	return false
+}
+
+// isIgnored reports whether inlining is switched off for an element, either
+// directly or by an ignore-subtree ancestor. Ancestors are only walked when
+// the document uses ignore-subtree at all.
+func isIgnored(element html.Node, checkAncestors bool) bool {
+	if hasDirective(element, directiveIgnore) || hasDirective(element, directiveIgnoreSubtree) {
+		return true
+	}
+	if !checkAncestors {
+		return false
+	}
+	for parent := element.Parent(); parent != nil; parent = parent.Parent() {
+		if hasDirective(parent, directiveIgnoreSubtree) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPreservedStyleTag reports whether a <style> tag opted out of inlining
+func isPreservedStyleTag(styleTag html.Node) bool {
+	_, ok := styleTag.Attributes()[preserveAttribute]
+	return ok
+}
+
+// isPassthroughStyleTag reports whether a <style> tag carries the configured
+// passthrough attribute. Unlike data-inliner-preserve the attribute is kept,
+// since other email tooling uses the same convention.
+func (i *Inliner) isPassthroughStyleTag(styleTag html.Node) bool {
+	if i.config.PassthroughAttribute == "" {
+		return false
+	}
+	_, ok := styleTag.Attributes()[strings.ToLower(i.config.PassthroughAttribute)]
+	return ok
+}
+
+// inlinableStyleTags returns the document's <style> tags without those
+// marked data-inliner-preserve or carrying the configured passthrough attribute
+func (i *Inliner) inlinableStyleTags(doc html.Document) ([]html.Node, error) {
+	styleTags, err := doc.GetStyleTags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get style tags: %w", err)
+	}
+
+	var inlinable []html.Node
+	for _, styleTag := range styleTags {
+		if !isPreservedStyleTag(styleTag) && !i.isPassthroughStyleTag(styleTag) {
+			inlinable = append(inlinable, styleTag)
+		}
+	}
+	return inlinable, nil
+}
+
+// stripDirectives removes directive attributes from the output
+func stripDirectives(doc html.Document) error {
+	for _, attribute := range []string{directiveAttribute, preserveAttribute} {
+		elements, err := doc.QuerySelectorAll("[" + attribute + "]")
+		if err != nil {
+			return fmt.Errorf("failed to find %s attributes: %w", attribute, err)
+		}
+		for _, element := range elements {
+			if err := element.RemoveAttribute(attribute); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", attribute, err)
+			}
+		}
+	}
+	return nil
+}
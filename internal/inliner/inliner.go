@@ -1,20 +1,33 @@
 package inliner
 
 import (
+	"context"
 	"fmt"
+	stdhtml "html"
+	"sort"
 	"strings"
 
 	"inliner/internal/config"
 	"inliner/internal/css"
 	"inliner/internal/html"
 	"inliner/internal/resolver"
+	"inliner/internal/rules"
 )
 
-// Inliner is the main CSS inlining engine for email HTML
+// Inliner is the main CSS inlining engine for email HTML.
+//
+// An Inliner is safe for concurrent use by multiple goroutines: its
+// configuration is never modified after New, every call parses into its own
+// document and stylesheet, and the rule registry and pipeline are locked
+// internally.
 type Inliner struct {
 	config     config.Config
 	parser     *css.Parser
 	htmlParser html.Parser
+	rules      *rules.Registry
+	pipeline   *Pipeline
+	loader     Loader
+	sheets     *SheetCache
 }
 
 // New creates a new CSS inliner with the given configuration
@@ -23,6 +36,8 @@ func New(cfg config.Config) *Inliner {
 		config:     cfg,
 		parser:     css.NewParser(),
 		htmlParser: html.NewParser(),
+		rules:      rules.Default(),
+		pipeline:   DefaultPipeline(),
 	}
 }
 
@@ -31,21 +46,38 @@ func NewWithDefaults() *Inliner {
 	return New(config.Default())
 }
 
+// Pipeline returns the stages run by Inline, for adding hooks and custom stages
+func (i *Inliner) Pipeline() *Pipeline {
+	return i.pipeline
+}
+
+// Rules returns the lint rule registry used by ValidateHTML. Custom rules
+// must be registered before the inliner is used.
+func (i *Inliner) Rules() *rules.Registry {
+	return i.rules
+}
+
 // ValidationWarning represents a potential issue with computed styles
 type ValidationWarning struct {
+	Rule     string // Stable rule identifier, e.g. "css-viewport-units"
 	Property string
 	Value    string
 	Message  string
 	Severity string // "error", "warning", "info"
+	Element  string // Tag name of the element the style was computed for
+	Line     int    // Source line of the element, 0 if unknown
 }
 
 // ValidationIssue represents an email compatibility issue
 type ValidationIssue struct {
-	Type     string // "structure", "css", "attribute"
-	Severity string // "error", "warning", "info"
-	Message  string
-	Element  string
-	Property string // for CSS issues
+	Rule      string // Stable rule identifier, e.g. "layout-table"
+	Type      string // "structure", "css", "attribute"
+	Severity  string // "error", "warning", "info"
+	Message   string
+	Element   string
+	Property  string // for CSS issues
+	Line      int    // Source line of the offending element, 0 if unknown
+	Reference string // Standard the rule enforces, e.g. "WCAG 2.1 SC 1.4.3"
 }
 
 // InlineResult contains the result of CSS inlining operation
@@ -54,12 +86,16 @@ type InlineResult struct {
 	InlinedStyles   int                 // Number of styles successfully inlined
 	PreservedRules  int                 // Number of CSS rules preserved in <style> tags
 	Warnings        []ValidationWarning // Any validation warnings
+	Fixes           []Fix               // Changes made by autofix passes
+	Stylesheets     []string            // External stylesheets requested from the Loader, including @imports
+	Size            SizeReport          // Size of the final HTML against client limits
 	ProcessingStats ProcessingStats     // Performance and processing statistics
 }
 
 // ProcessingStats contains performance metrics from the inlining process
 type ProcessingStats struct {
 	CSSRulesParsed        int   // Total CSS rules parsed
+	CSSRulesRemoved       int   // Preserved rules dropped by StripUnusedCSS
 	HTMLElementsProcessed int   // HTML elements that had styles applied
 	SelectorsMatched      int   // Total selector matches found
 	ProcessingTimeMs      int64 // Processing time in milliseconds
@@ -67,47 +103,135 @@ type ProcessingStats struct {
 
 // Inline processes HTML with embedded or external CSS and inlines styles
 func (i *Inliner) Inline(htmlContent string) (*InlineResult, error) {
+	return i.inline(context.Background(), htmlContent)
+}
+
+// inline runs the inlining pipeline, checking ctx between stages and while
+// processing elements
+func (i *Inliner) inline(ctx context.Context, htmlContent string) (*InlineResult, error) {
 	// Parse the HTML document
 	doc, err := i.htmlParser.Parse(htmlContent)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	// Extract CSS from <style> tags and external stylesheets
-	cssContent, err := i.extractCSS(doc)
-	if err != nil {
-		return nil, fmt.Errorf("failed to extract CSS: %w", err)
+	// Run the pipeline stages against the document
+	state := &State{
+		Context:    ctx,
+		Config:     i.config,
+		Document:   doc,
+		Stylesheet: &css.Stylesheet{},
+		Result:     &InlineResult{Warnings: []ValidationWarning{}},
+		inliner:    i,
+	}
+	if err := i.pipeline.Run(state); err != nil {
+		return nil, err
 	}
+	result := state.Result
 
-	// Parse the CSS
-	stylesheet, err := i.parser.Parse(cssContent)
+	// Generate final HTML
+	finalHTML, err := doc.HTML()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse CSS: %w", err)
+		return nil, fmt.Errorf("failed to serialize HTML: %w", err)
 	}
 
-	// Create style resolver
-	styleResolver := resolver.New(stylesheet, i.config)
+	// Check the final size against client limits
+	size, sizeWarnings := i.measureSize(finalHTML)
+	result.Size = size
+	result.Warnings = append(result.Warnings, sizeWarnings...)
+	result.Warnings = i.overrideSeverities(result.Warnings)
+
+	result.HTML = finalHTML
+	result.ProcessingStats.CSSRulesParsed = len(state.Stylesheet.Rules)
+	return result, nil
+}
+
+// overrideSeverities applies the configured rule severities to warnings,
+// dropping those whose rule is turned off, so that a rule is reported the
+// same way by Inline and ValidateHTML
+func (i *Inliner) overrideSeverities(warnings []ValidationWarning) []ValidationWarning {
+	kept := warnings[:0]
+	for _, warning := range warnings {
+		if severity, ok := i.config.Rules[warning.Rule]; ok {
+			if severity == rules.SeverityOff {
+				continue
+			}
+			warning.Severity = severity
+		}
+		kept = append(kept, warning)
+	}
+	return kept
+}
 
-	// Process all elements in the document
-	result, err := i.processDocument(doc, styleResolver)
+// extractStage parses CSS from each <style> tag and loaded stylesheet into one cascade
+func (i *Inliner) extractStage(s *State) error {
+	loader := &sheetLoader{inliner: i}
+	blocks, stylesheet, err := i.extractCSS(s.Context, s.Document, loader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to process document: %w", err)
+		return fmt.Errorf("failed to extract CSS: %w", err)
 	}
+	s.blocks = blocks
+	s.Stylesheet = stylesheet
+	s.Result.Warnings = append(s.Result.Warnings, loader.warnings...)
+	s.Result.Stylesheets = loader.requested
+	return nil
+}
 
-	// Handle style tag cleanup/preservation
-	if err := i.handleStyleTags(doc, stylesheet); err != nil {
-		return nil, fmt.Errorf("failed to handle style tags: %w", err)
+// inlineStage applies the cascade to every element
+func (i *Inliner) inlineStage(s *State) error {
+	styleResolver := resolver.New(s.Stylesheet, i.config)
+	if err := i.processDocument(s.Context, s.Document, styleResolver, s.Result); err != nil {
+		return fmt.Errorf("failed to process document: %w", err)
 	}
+	return nil
+}
 
-	// Generate final HTML
-	finalHTML, err := doc.HTML()
-	if err != nil {
-		return nil, fmt.Errorf("failed to serialize HTML: %w", err)
+// accessibilityStage applies opt-in accessibility fixes
+func (i *Inliner) accessibilityStage(s *State) error {
+	if !i.config.AccessibilityFixes {
+		return nil
+	}
+	if err := i.applyAccessibilityFixes(s.Document, s.Result); err != nil {
+		return fmt.Errorf("failed to apply accessibility fixes: %w", err)
 	}
+	return nil
+}
 
-	result.HTML = finalHTML
-	result.ProcessingStats.CSSRulesParsed = len(stylesheet.Rules)
-	return result, nil
+// styleTagsStage handles style tag cleanup/preservation
+func (i *Inliner) styleTagsStage(s *State) error {
+	if err := i.handleStyleTags(s.Document, s.blocks, s.Result); err != nil {
+		return fmt.Errorf("failed to handle style tags: %w", err)
+	}
+	return nil
+}
+
+// minifyStage minifies the output when configured
+func (i *Inliner) minifyStage(s *State) error {
+	if !i.config.Minify {
+		return nil
+	}
+	if err := i.minifyDocument(s.Document); err != nil {
+		return fmt.Errorf("failed to minify: %w", err)
+	}
+	return nil
+}
+
+// formatStage applies the configured whitespace format unless minifying
+func (i *Inliner) formatStage(s *State) error {
+	if !i.config.Minify {
+		i.formatWhitespace(s.Document)
+	}
+	return nil
+}
+
+// formatWhitespace applies the configured output format to the document
+func (i *Inliner) formatWhitespace(doc html.Document) {
+	switch i.config.EffectiveOutputFormat() {
+	case config.OutputPretty:
+		doc.Indent(i.config.Indent)
+	case config.OutputCompact:
+		doc.CollapseWhitespace()
+	}
 }
 
 // InlineString is a convenience method that inlines CSS in an HTML string
@@ -119,54 +243,113 @@ func (i *Inliner) InlineString(htmlContent string) (string, error) {
 	return result.HTML, nil
 }
 
-// extractCSS extracts all CSS content from the document
-func (i *Inliner) extractCSS(doc html.Document) (string, error) {
-	var cssContent strings.Builder
+// styleBlock is a <style> or <link> tag and its rules within the combined stylesheet
+type styleBlock struct {
+	tag     html.Node
+	rules   []css.Rule
+	media   string   // "@media ..." implied by the tag's media attribute, if any
+	imports []string // @import URLs that weren't loaded, written back out
+	link    bool     // tag is a <link> whose stylesheet was loaded
+}
 
-	// Extract from <style> tags
-	styleTags, err := doc.GetStyleTags()
+// tagMediaAtRule returns the @media prelude implied by a <style media="...">
+// attribute, or "" when the tag applies to every screen
+func tagMediaAtRule(styleTag html.Node) string {
+	media := strings.TrimSpace(styleTag.Attributes()["media"])
+	switch strings.ToLower(media) {
+	case "", "all", "screen", "only screen":
+		return ""
+	}
+	return "@media " + media
+}
+
+// extractCSS parses each inlinable <style> tag, and with a Loader each
+// <link rel="stylesheet"> and @import, and combines the rules in document
+// order into the stylesheet the cascade is resolved against. The returned
+// blocks let each tag be rewritten separately afterwards.
+func (i *Inliner) extractCSS(ctx context.Context, doc html.Document, loader *sheetLoader) ([]styleBlock, *css.Stylesheet, error) {
+	elements, err := doc.QuerySelectorAll("style, link")
 	if err != nil {
-		return "", fmt.Errorf("failed to get style tags: %w", err)
+		return nil, nil, fmt.Errorf("failed to get style tags: %w", err)
 	}
 
-	for _, styleTag := range styleTags {
-		content := styleTag.Text()
-		if content != "" {
-			cssContent.WriteString(content)
-			cssContent.WriteString("\n")
+	combined := &css.Stylesheet{Rules: make([]css.Rule, 0)}
+	var blocks []styleBlock
+
+	for _, element := range elements {
+		if isPreservedStyleTag(element) || i.isPassthroughStyleTag(element) {
+			continue
 		}
-	}
 
-	// TODO: Extract from <link rel="stylesheet"> tags
-	// TODO: Extract from external CSS files
+		block := styleBlock{tag: element}
+		var sheetRules []css.Rule
 
-	return cssContent.String(), nil
-}
+		if strings.EqualFold(element.TagName(), "link") {
+			if i.loader == nil || !isStylesheetLink(element) {
+				continue
+			}
+			var ok bool
+			if sheetRules, ok = loader.loadLink(ctx, element); !ok {
+				continue
+			}
+			block.link = true
+		} else {
+			sheetRules, block.imports = loader.parse(ctx, element.Text(), i.config.BaseURL, element.SourceLine(), 1)
+		}
 
-// processDocument processes all elements in the document and applies inline styles
-func (i *Inliner) processDocument(doc html.Document, styleResolver *resolver.Resolver) (*InlineResult, error) {
-	result := &InlineResult{
-		ProcessingStats: ProcessingStats{},
-		Warnings:        []ValidationWarning{},
+		// Rules in <style media="..."> apply conditionally, like @media
+		block.media = tagMediaAtRule(element)
+
+		start := len(combined.Rules)
+		for _, rule := range sheetRules {
+			rule.SourceOrder = len(combined.Rules)
+			if block.media != "" {
+				rule.AtRules = append([]string{block.media}, rule.AtRules...)
+			}
+			combined.Rules = append(combined.Rules, rule)
+		}
+		combined.Imports = append(combined.Imports, block.imports...)
+		block.rules = combined.Rules[start:]
+		blocks = append(blocks, block)
+
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
 	}
 
+	return blocks, combined, nil
+}
+
+// processDocument processes all elements in the document and applies inline styles
+func (i *Inliner) processDocument(ctx context.Context, doc html.Document, styleResolver *resolver.Resolver, result *InlineResult) error {
 	// Get all elements in the document
 	allElements, err := doc.QuerySelectorAll("*")
 	if err != nil {
-		return nil, fmt.Errorf("failed to query all elements: %w", err)
+		return fmt.Errorf("failed to query all elements: %w", err)
 	}
 
 	result.ProcessingStats.HTMLElementsProcessed = len(allElements)
 
+	ignoredSubtrees, err := doc.QuerySelectorAll(`[` + directiveAttribute + `~="` + directiveIgnoreSubtree + `"]`)
+	if err != nil {
+		return fmt.Errorf("failed to query ignored subtrees: %w", err)
+	}
+
 	// Process each element
 	for _, element := range allElements {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if isIgnored(element, len(ignoredSubtrees) > 0) {
+			continue
+		}
 		if err := i.processElement(element, styleResolver, result); err != nil {
 			// Log error but continue processing other elements
 			continue
 		}
 	}
 
-	return result, nil
+	return nil
 }
 
 // processElement processes a single HTML element and applies computed styles
@@ -198,10 +381,13 @@ func (i *Inliner) processElement(element html.Node, styleResolver *resolver.Reso
 	resolverWarnings := styleResolver.ValidateStyles(finalStyles)
 	for _, rw := range resolverWarnings {
 		result.Warnings = append(result.Warnings, ValidationWarning{
+			Rule:     rw.Rule,
 			Property: rw.Property,
 			Value:    rw.Value,
 			Message:  rw.Message,
 			Severity: rw.Severity,
+			Element:  tagName,
+			Line:     element.SourceLine(),
 		})
 	}
 
@@ -232,61 +418,88 @@ func (i *Inliner) shouldSkipElement(tagName string) bool {
 	return skipTags[tagName]
 }
 
-// handleStyleTags manages <style> tags based on configuration
-func (i *Inliner) handleStyleTags(doc html.Document, stylesheet *css.Stylesheet) error {
-	styleTags, err := doc.GetStyleTags()
-	if err != nil {
-		return fmt.Errorf("failed to get style tags: %w", err)
-	}
-
+// handleStyleTags manages <style> tags based on configuration. Each tag is
+// rewritten in place with the rules from it that must stay in a stylesheet,
+// keeping its attributes and position; tags left with nothing are removed.
+// Loaded <link> tags are replaced by a <style> tag in the same way. Tags
+// marked data-inliner-preserve or with the passthrough attribute are never
+// passed in.
+func (i *Inliner) handleStyleTags(doc html.Document, blocks []styleBlock, result *InlineResult) error {
 	if i.config.RemoveStyleTags {
 		// Remove all style tags
-		for _, styleTag := range styleTags {
-			if err := styleTag.Remove(); err != nil {
+		for _, block := range blocks {
+			if err := block.tag.Remove(); err != nil {
 				return fmt.Errorf("failed to remove style tag: %w", err)
 			}
 		}
 		return nil
 	}
 
-	// Preserve certain CSS rules in style tags
-	preservedCSS := i.buildPreservedCSS(stylesheet)
+	for _, block := range blocks {
+		// Preserve certain CSS rules in style tags
+		preserved := i.buildPreservedRules(block.rules)
+		if i.config.StripUnusedCSS {
+			var removed int
+			preserved, removed = i.purgeUnusedRules(doc, preserved)
+			result.ProcessingStats.CSSRulesRemoved += removed
+		}
+		result.PreservedRules += len(preserved)
 
-	if preservedCSS != "" {
-		// Update the first style tag with preserved CSS, remove others
-		if len(styleTags) > 0 {
-			if err := styleTags[0].SetText(preservedCSS); err != nil {
-				return fmt.Errorf("failed to update style tag: %w", err)
+		if len(preserved) == 0 && len(block.imports) == 0 {
+			// No CSS to preserve, remove the tag
+			if err := block.tag.Remove(); err != nil {
+				return fmt.Errorf("failed to remove style tag: %w", err)
 			}
+			continue
+		}
 
-			// Remove additional style tags
-			for j := 1; j < len(styleTags); j++ {
-				if err := styleTags[j].Remove(); err != nil {
-					return fmt.Errorf("failed to remove extra style tag: %w", err)
-				}
+		// The tag's media attribute already wraps its rules
+		if block.media != "" {
+			for j := range preserved {
+				preserved[j].AtRules = preserved[j].AtRules[1:]
 			}
 		}
-	} else {
-		// No CSS to preserve, remove all style tags
-		for _, styleTag := range styleTags {
-			if err := styleTag.Remove(); err != nil {
-				return fmt.Errorf("failed to remove style tag: %w", err)
+		if i.config.DarkMode {
+			variants := outlookDarkVariants(preserved)
+			preserved = append(preserved, variants...)
+			result.PreservedRules += len(variants)
+		}
+		text := formatImports(block.imports) + i.formatCSSRules(preserved)
+
+		if block.link {
+			if err := block.tag.ReplaceWithHTML(styleElement(block.tag, text)); err != nil {
+				return fmt.Errorf("failed to replace stylesheet link: %w", err)
 			}
+			continue
+		}
+
+		if err := block.tag.SetText(text); err != nil {
+			return fmt.Errorf("failed to update style tag: %w", err)
 		}
 	}
 
 	return nil
 }
 
-// buildPreservedCSS builds CSS that should be preserved in <style> tags
-func (i *Inliner) buildPreservedCSS(stylesheet *css.Stylesheet) string {
-	var preservedRules []string
+// styleElement builds the <style> tag that replaces a loaded <link>,
+// carrying over its media attribute
+func styleElement(link html.Node, text string) string {
+	if media := link.Attributes()["media"]; media != "" {
+		return fmt.Sprintf("<style media=\"%s\">%s</style>", stdhtml.EscapeString(media), text)
+	}
+	return "<style>" + text + "</style>"
+}
 
-	for _, rule := range stylesheet.Rules {
+// buildPreservedRules selects the rules that should be preserved in <style> tags
+func (i *Inliner) buildPreservedRules(rules []css.Rule) []css.Rule {
+	var preserved []css.Rule
+	safelist := compileSafelist(i.config.CSSSafelist)
+
+	for _, rule := range rules {
 		shouldPreserve := false
 
 		// Preserve media queries if configured
-		if i.config.PreserveMediaQueries && i.isMediaQueryRule(rule.Selector) {
+		if i.config.PreserveMediaQueries && i.isMediaQueryRule(rule) {
 			shouldPreserve = true
 		}
 
@@ -295,39 +508,57 @@ func (i *Inliner) buildPreservedCSS(stylesheet *css.Stylesheet) string {
 			shouldPreserve = true
 		}
 
+		// Preserve dark color scheme rules in dark mode
+		if i.config.DarkMode && rule.InDarkScheme() {
+			shouldPreserve = true
+		}
+
+		// Preserve rules marked /* inliner: keep */
+		if rule.Keep {
+			shouldPreserve = true
+		}
+
+		// Preserve client hacks, which target markup the template doesn't contain
+		if isSafelisted(rule.Selector, safelist) {
+			shouldPreserve = true
+		}
+
 		// Preserve rules that can't be inlined
-		if i.isUninlinableRule(rule.Selector) {
+		if i.isUninlinableRule(rule) {
 			shouldPreserve = true
 		}
 
 		if shouldPreserve {
-			cssRule := i.formatCSSRule(rule)
-			preservedRules = append(preservedRules, cssRule)
+			preserved = append(preserved, rule)
 		}
 	}
 
-	return strings.Join(preservedRules, "\n")
+	return preserved
 }
 
 // isMediaQueryRule checks if a rule is inside a media query
-func (i *Inliner) isMediaQueryRule(selector string) bool {
-	// This is a simplified check - in practice, you'd need to track
-	// the context during CSS parsing
-	return strings.Contains(selector, "@media")
+func (i *Inliner) isMediaQueryRule(rule css.Rule) bool {
+	return rule.InMedia()
 }
 
 // isUninlinableRule checks if a rule cannot be inlined
-func (i *Inliner) isUninlinableRule(selector string) bool {
-	// Rules that must stay in <style> tags
+func (i *Inliner) isUninlinableRule(rule css.Rule) bool {
+	// Rules nested in non-media conditional groups (@supports) must stay in <style> tags
+	if rule.IsConditional() && !rule.InMedia() {
+		return true
+	}
+
+	// At-rules that must stay in <style> tags
 	uninlinablePatterns := []string{
 		"@keyframes",
+		"@-webkit-keyframes",
 		"@font-face",
-		"@import",
-		"@charset",
+		"@page",
 	}
 
+	selector := strings.ToLower(rule.Selector)
 	for _, pattern := range uninlinablePatterns {
-		if strings.Contains(selector, pattern) {
+		if strings.HasPrefix(selector, pattern) {
 			return true
 		}
 	}
@@ -335,11 +566,64 @@ func (i *Inliner) isUninlinableRule(selector string) bool {
 	return false
 }
 
+// formatCSSRules converts rules back to CSS text, wrapping consecutive rules
+// that share the same enclosing @media/@supports blocks in a single block
+func (i *Inliner) formatCSSRules(rules []css.Rule) string {
+	var parts []string
+
+	for start := 0; start < len(rules); {
+		end := start + 1
+		for end < len(rules) && sameAtRules(rules[start].AtRules, rules[end].AtRules) {
+			end++
+		}
+
+		var group []string
+		for _, rule := range rules[start:end] {
+			group = append(group, i.formatCSSRule(rule))
+		}
+		block := strings.Join(group, "\n")
+
+		atRules := rules[start].AtRules
+		for j := len(atRules) - 1; j >= 0; j-- {
+			block = fmt.Sprintf("%s {\n%s\n}", atRules[j], block)
+		}
+
+		parts = append(parts, block)
+		start = end
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// sameAtRules reports whether two rules are nested in the same at-rule blocks
+func sameAtRules(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for j := range a {
+		if a[j] != b[j] {
+			return false
+		}
+	}
+	return true
+}
+
 // formatCSSRule converts a CSS rule back to CSS text
 func (i *Inliner) formatCSSRule(rule css.Rule) string {
+	if rule.Raw != "" {
+		return fmt.Sprintf("%s {\n%s\n}", rule.Selector, rule.Raw)
+	}
+
+	properties := make([]string, 0, len(rule.Declarations))
+	for property := range rule.Declarations {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+
 	var declarations []string
 
-	for _, declaration := range rule.Declarations {
+	for _, property := range properties {
+		declaration := rule.Declarations[property]
 		value := declaration.Value
 		if declaration.Important {
 			value += " !important"
@@ -350,71 +634,49 @@ func (i *Inliner) formatCSSRule(rule css.Rule) string {
 	return fmt.Sprintf("%s {\n%s;\n}", rule.Selector, strings.Join(declarations, ";\n"))
 }
 
-// ValidateHTML validates HTML for email client compatibility
+// ValidateHTML validates HTML for email client compatibility by running
+// every enabled lint rule against the parsed document and its <style> tags
 func (i *Inliner) ValidateHTML(htmlContent string) ([]ValidationIssue, error) {
+	return i.ValidateHTMLContext(context.Background(), htmlContent)
+}
+
+// ValidateHTMLContext is ValidateHTML, returning the context's error if it
+// is done before every rule has run
+func (i *Inliner) ValidateHTMLContext(ctx context.Context, htmlContent string) ([]ValidationIssue, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	doc, err := i.htmlParser.Parse(htmlContent)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	var issues []ValidationIssue
-
-	// Check for problematic HTML elements
-	issues = append(issues, i.validateHTMLStructure(doc)...)
-
-	// Check for email-unsafe CSS
-	issues = append(issues, i.validateEmbeddedCSS(doc)...)
-
-	return issues, nil
-}
-
-// validateHTMLStructure checks for HTML structure issues
-func (i *Inliner) validateHTMLStructure(doc html.Document) []ValidationIssue {
-	var issues []ValidationIssue
-
-	// Check for missing table structure in emails
-	bodyElements, _ := doc.QuerySelectorAll("body *")
-	hasTable := false
+	ruleContext, err := rules.NewContext(doc, i.parser, i.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare validation: %w", err)
+	}
 
-	for _, element := range bodyElements {
-		if strings.ToLower(element.TagName()) == "table" {
-			hasTable = true
-			break
-		}
+	found, err := i.rules.RunContext(ctx, ruleContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run rules: %w", err)
 	}
 
-	if !hasTable {
+	issues := make([]ValidationIssue, 0, len(found))
+	for _, issue := range found {
 		issues = append(issues, ValidationIssue{
-			Type:     "structure",
-			Severity: "warning",
-			Message:  "Email should use table-based layout for better client compatibility",
-			Element:  "body",
+			Rule:      issue.Rule,
+			Type:      issue.Category,
+			Severity:  issue.Severity,
+			Message:   issue.Message,
+			Element:   issue.Element,
+			Property:  issue.Property,
+			Line:      issue.Line,
+			Reference: issue.Reference,
 		})
 	}
 
-	return issues
-}
-
-// validateEmbeddedCSS checks for problematic CSS in style tags
-func (i *Inliner) validateEmbeddedCSS(doc html.Document) []ValidationIssue {
-	var issues []ValidationIssue
-
-	styleTags, _ := doc.GetStyleTags()
-	for _, styleTag := range styleTags {
-		content := styleTag.Text()
-
-		// Check for problematic CSS features
-		if strings.Contains(content, "position:") && strings.Contains(content, "fixed") {
-			issues = append(issues, ValidationIssue{
-				Type:     "css",
-				Severity: "error",
-				Message:  "position: fixed is not supported in email clients",
-				Element:  "style",
-			})
-		}
-	}
-
-	return issues
+	return issues, nil
 }
 
 // InlineCSS is a convenience function that inlines CSS with default configuration
@@ -0,0 +1,179 @@
+package inliner
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"inliner/internal/css"
+	"inliner/internal/html"
+)
+
+// maxImportDepth bounds @import nesting so import cycles terminate
+const maxImportDepth = 8
+
+// Loader fetches the external stylesheets referenced by <link rel="stylesheet">
+// and @import. Implementations must be safe for concurrent use.
+type Loader interface {
+	Load(ctx context.Context, href string) (string, error)
+}
+
+// SetLoader sets the loader used for external stylesheets. Without one,
+// <link> tags and @import rules are left in the output untouched. It must be
+// called before the inliner is used.
+func (i *Inliner) SetLoader(loader Loader) {
+	i.loader = loader
+}
+
+// isStylesheetLink reports whether an element is a <link rel="stylesheet" href>
+func isStylesheetLink(element html.Node) bool {
+	attributes := element.Attributes()
+	if strings.TrimSpace(attributes["href"]) == "" {
+		return false
+	}
+	for _, rel := range strings.Fields(strings.ToLower(attributes["rel"])) {
+		if rel == "stylesheet" {
+			return true
+		}
+	}
+	return false
+}
+
+// sheetLoader loads external stylesheets for one document, collecting
+// failures as warnings instead of aborting the inlining
+type sheetLoader struct {
+	inliner   *Inliner
+	warnings  []ValidationWarning
+	requested []string // Resolved hrefs, whether or not they loaded
+}
+
+// loadLink fetches and parses the stylesheet a <link> tag refers to. The
+// href is resolved against the configured BaseURL.
+func (l *sheetLoader) loadLink(ctx context.Context, link html.Node) ([]css.Rule, bool) {
+	href := resolveHref(l.inliner.config.BaseURL, strings.TrimSpace(link.Attributes()["href"]))
+	sheet, err := l.fetch(ctx, href)
+	if err != nil {
+		l.warn(link.SourceLine(), href, err)
+		return nil, false
+	}
+
+	rules, unresolved := l.resolveImports(ctx, sheet, href, link.SourceLine(), 1)
+	return rules, len(unresolved) == 0
+}
+
+// fetch loads and parses an external stylesheet, through the SheetCache
+// when the inliner has one
+func (l *sheetLoader) fetch(ctx context.Context, href string) (*css.Stylesheet, error) {
+	if !slices.Contains(l.requested, href) {
+		l.requested = append(l.requested, href)
+	}
+
+	load := func() (*css.Stylesheet, error) {
+		text, err := l.inliner.loader.Load(ctx, href)
+		if err != nil {
+			return nil, err
+		}
+		sheet, _ := l.inliner.parser.Parse(text)
+		return sheet, nil
+	}
+
+	if l.inliner.sheets == nil {
+		return load()
+	}
+	return l.inliner.sheets.get(ctx, href, load)
+}
+
+// parse parses CSS text, replacing its @import rules with the rules of the
+// imported sheets. Imports that couldn't be loaded are returned so they can
+// be written back out.
+func (l *sheetLoader) parse(ctx context.Context, text, base string, line, depth int) ([]css.Rule, []string) {
+	sheet, _ := l.inliner.parser.Parse(text)
+	return l.resolveImports(ctx, sheet, base, line, depth)
+}
+
+// resolveImports returns the rules of a parsed sheet preceded by those of
+// the sheets it imports. The sheet itself is not modified, since it may be
+// shared through the SheetCache.
+func (l *sheetLoader) resolveImports(ctx context.Context, sheet *css.Stylesheet, base string, line, depth int) ([]css.Rule, []string) {
+	if l.inliner.loader == nil || len(sheet.Imports) == 0 {
+		return sheet.Rules, sheet.Imports
+	}
+
+	var rules []css.Rule
+	var unresolved []string
+	for _, href := range sheet.Imports {
+		resolved := resolveHref(base, href)
+		if depth > maxImportDepth {
+			l.warn(line, resolved, fmt.Errorf("@import nested more than %d deep", maxImportDepth))
+			unresolved = append(unresolved, href)
+			continue
+		}
+
+		imported, err := l.fetch(ctx, resolved)
+		if err != nil {
+			l.warn(line, resolved, err)
+			unresolved = append(unresolved, href)
+			continue
+		}
+
+		importedRules, nested := l.resolveImports(ctx, imported, resolved, line, depth+1)
+		rules = append(rules, importedRules...)
+		unresolved = append(unresolved, nested...)
+	}
+
+	// Imported rules come first in the cascade, as if written in place
+	return append(rules, sheet.Rules...), unresolved
+}
+
+// warn records a stylesheet that couldn't be loaded
+func (l *sheetLoader) warn(line int, href string, err error) {
+	l.warnings = append(l.warnings, ValidationWarning{
+		Rule:     "stylesheet-load",
+		Value:    href,
+		Message:  fmt.Sprintf("Failed to load stylesheet: %v", err),
+		Severity: "warning",
+		Element:  "link",
+		Line:     line,
+	})
+}
+
+// resolveHref resolves an @import URL against the stylesheet that imports it
+func resolveHref(base, href string) string {
+	if base == "" {
+		return href
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+
+	// ResolveReference roots a relative base at "/", which would turn
+	// "css/theme.css" importing "base.css" into "/css/base.css"
+	if isRelativePath(baseURL) && isRelativePath(ref) {
+		ref.Path = path.Join(path.Dir(baseURL.Path), ref.Path)
+		return ref.String()
+	}
+	return baseURL.ResolveReference(ref).String()
+}
+
+// isRelativePath reports whether u is a path relative to the current
+// document, with no scheme or host
+func isRelativePath(u *url.URL) bool {
+	return u.Scheme == "" && u.Host == "" && u.Path != "" && !path.IsAbs(u.Path)
+}
+
+// formatImports writes @import rules that weren't inlined back out
+func formatImports(imports []string) string {
+	var out strings.Builder
+	for _, href := range imports {
+		fmt.Fprintf(&out, "@import url(%q);\n", href)
+	}
+	return out.String()
+}
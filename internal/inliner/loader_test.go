@@ -0,0 +1,25 @@
+package inliner
+
+import "testing"
+
+func TestResolveHref(t *testing.T) {
+	tests := []struct {
+		base, href, want string
+	}{
+		{"", "theme.css", "theme.css"},
+		{"theme.css", "base.css", "base.css"},
+		{"css/theme.css", "base.css", "css/base.css"},
+		{"css/theme.css", "../fonts.css?v=2", "fonts.css?v=2"},
+		{"../shared/theme.css", "base.css", "../shared/base.css"},
+		{"css/theme.css", "/base.css", "/base.css"},
+		{"/srv/mail/welcome.html", "css/theme.css", "/srv/mail/css/theme.css"},
+		{"https://example.com/mail/welcome.html", "theme.css", "https://example.com/mail/theme.css"},
+		{"css/theme.css", "https://cdn.example.com/base.css", "https://cdn.example.com/base.css"},
+	}
+
+	for _, test := range tests {
+		if got := resolveHref(test.base, test.href); got != test.want {
+			t.Errorf("resolveHref(%q, %q) = %q, want %q", test.base, test.href, got, test.want)
+		}
+	}
+}
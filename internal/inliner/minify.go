@@ -0,0 +1,131 @@
+package inliner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"inliner/internal/config"
+	"inliner/internal/css"
+	"inliner/internal/html"
+)
+
+// minifyDocument strips non-conditional comments, collapses whitespace and
+// rewrites inline styles and <style> blocks in their shortest form. MSO
+// conditional comments, template tags and preserved or passthrough <style>
+// tags are left exactly as written.
+func (i *Inliner) minifyDocument(doc html.Document) error {
+	shortHex := config.GetCompatibilityProfile(i.config.TargetEmailClient).SupportsShortHex
+
+	doc.RemoveComments(html.IsConditionalComment)
+	doc.CollapseWhitespace()
+
+	styled, err := doc.QuerySelectorAll("[style]")
+	if err != nil {
+		return fmt.Errorf("failed to find styled elements: %w", err)
+	}
+	for _, element := range styled {
+		style := element.Attributes()["style"]
+		if html.ContainsTemplateTag(style) {
+			continue
+		}
+		if err := element.SetAttribute("style", formatCompactDeclarations(element.GetInlineStyle(), shortHex)); err != nil {
+			return fmt.Errorf("failed to minify inline style: %w", err)
+		}
+	}
+
+	styleTags, err := i.inlinableStyleTags(doc)
+	if err != nil {
+		return err
+	}
+	for _, styleTag := range styleTags {
+		text := styleTag.Text()
+		if html.ContainsTemplateTag(text) {
+			continue
+		}
+		stylesheet, err := i.parser.Parse(text)
+		if err != nil {
+			return fmt.Errorf("failed to parse style tag: %w", err)
+		}
+		minified := strings.ReplaceAll(formatImports(stylesheet.Imports), "\n", "") +
+			formatCompactRules(dedupeRules(stylesheet.Rules), shortHex)
+		if err := styleTag.SetText(minified); err != nil {
+			return fmt.Errorf("failed to minify style tag: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// dedupeRules drops rules that are repeated verbatim later in the list.
+// The last copy is kept so the cascade order of the survivors is unchanged.
+func dedupeRules(rules []css.Rule) []css.Rule {
+	keys := make([]string, len(rules))
+	last := make(map[string]int)
+	for j, rule := range rules {
+		keys[j] = strings.Join(rule.AtRules, "\x00") + "\x00" + css.MinifySelector(rule.Selector) +
+			"\x00" + rule.Raw + "\x00" + formatCompactDeclarations(rule.Declarations, false)
+		last[keys[j]] = j
+	}
+
+	var unique []css.Rule
+	for j, rule := range rules {
+		if last[keys[j]] == j {
+			unique = append(unique, rule)
+		}
+	}
+	return unique
+}
+
+// formatCompactRules is formatCSSRules without optional whitespace
+func formatCompactRules(rules []css.Rule, shortHex bool) string {
+	var out strings.Builder
+
+	for start := 0; start < len(rules); {
+		end := start + 1
+		for end < len(rules) && sameAtRules(rules[start].AtRules, rules[end].AtRules) {
+			end++
+		}
+
+		var block strings.Builder
+		for _, rule := range rules[start:end] {
+			selector := css.MinifySelector(rule.Selector)
+			switch {
+			case rule.Raw != "":
+				fmt.Fprintf(&block, "%s{%s}", selector, css.MinifyBlock(rule.Raw, shortHex))
+			case len(rule.Declarations) > 0:
+				fmt.Fprintf(&block, "%s{%s}", selector, formatCompactDeclarations(rule.Declarations, shortHex))
+			}
+		}
+
+		text := block.String()
+		atRules := rules[start].AtRules
+		for j := len(atRules) - 1; j >= 0; j-- {
+			text = fmt.Sprintf("%s{%s}", strings.Join(strings.Fields(atRules[j]), " "), text)
+		}
+		out.WriteString(text)
+		start = end
+	}
+
+	return out.String()
+}
+
+// formatCompactDeclarations formats declarations as "a:b;c:d" in property order
+func formatCompactDeclarations(declarations map[string]css.Declaration, shortHex bool) string {
+	properties := make([]string, 0, len(declarations))
+	for property := range declarations {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+
+	parts := make([]string, 0, len(properties))
+	for _, property := range properties {
+		declaration := declarations[property]
+		value := css.MinifyValue(declaration.Value, shortHex)
+		if declaration.Important {
+			value += "!important"
+		}
+		parts = append(parts, declaration.Property+":"+value)
+	}
+	return strings.Join(parts, ";")
+}
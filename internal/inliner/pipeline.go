@@ -0,0 +1,226 @@
+package inliner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"inliner/internal/config"
+	"inliner/internal/css"
+	"inliner/internal/html"
+)
+
+// Built-in stage names, in their default order. Parsing the HTML comes
+// before the first stage and serializing it after the last; they are not
+// stages, since every stage needs the parsed document and inline measures
+// the serialized output against client size limits.
+const (
+	StageExtractCSS         = "extract-css"
+	StageInlineStyles       = "inline-styles"
+	StageVMLFallbacks       = "vml-fallbacks"
+	StageAccessibilityFixes = "accessibility-fixes"
+	StageDarkMode           = "dark-mode"
+	StageStyleTags          = "style-tags"
+	StageMinify             = "minify"
+	StageFormatWhitespace   = "format-whitespace"
+	StageStripDirectives    = "strip-directives"
+)
+
+// State is the document being inlined, shared by the stages of one call
+type State struct {
+	Context    context.Context
+	Config     config.Config   // Configuration for this call; read-only
+	Document   html.Document   // Parsed document, modified in place
+	Stylesheet *css.Stylesheet // Combined CSS, set by extract-css
+	Result     *InlineResult   // Counters, warnings and fixes collected so far
+
+	inliner *Inliner
+	blocks  []styleBlock
+}
+
+// StageFunc runs a stage or hook against the shared state
+type StageFunc func(state *State) error
+
+// Stage is a named step of the pipeline
+type Stage struct {
+	Name string
+	Run  StageFunc
+}
+
+// Pipeline is the ordered list of stages run by Inline, with hooks before
+// and after each one. It is safe for concurrent use; changes apply to calls
+// that start afterwards.
+type Pipeline struct {
+	mu     sync.RWMutex
+	stages []Stage
+	before map[string][]StageFunc
+	after  map[string][]StageFunc
+}
+
+// NewPipeline creates a pipeline running the given stages in order
+func NewPipeline(stages ...Stage) *Pipeline {
+	return &Pipeline{
+		stages: stages,
+		before: make(map[string][]StageFunc),
+		after:  make(map[string][]StageFunc),
+	}
+}
+
+// DefaultPipeline returns a new pipeline with the built-in stages
+func DefaultPipeline() *Pipeline {
+	return NewPipeline(
+		Stage{StageExtractCSS, func(s *State) error { return s.inliner.extractStage(s) }},
+		Stage{StageInlineStyles, func(s *State) error { return s.inliner.inlineStage(s) }},
+		Stage{StageVMLFallbacks, func(s *State) error { return s.inliner.vmlStage(s) }},
+		Stage{StageAccessibilityFixes, func(s *State) error { return s.inliner.accessibilityStage(s) }},
+		Stage{StageDarkMode, func(s *State) error { return s.inliner.darkModeStage(s) }},
+		Stage{StageStyleTags, func(s *State) error { return s.inliner.styleTagsStage(s) }},
+		Stage{StageMinify, func(s *State) error { return s.inliner.minifyStage(s) }},
+		Stage{StageFormatWhitespace, func(s *State) error { return s.inliner.formatStage(s) }},
+		Stage{StageStripDirectives, func(s *State) error { return stripDirectives(s.Document) }},
+	)
+}
+
+// Stages returns the stage names in run order
+func (p *Pipeline) Stages() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	names := make([]string, len(p.stages))
+	for j, stage := range p.stages {
+		names[j] = stage.Name
+	}
+	return names
+}
+
+// Insert adds a stage after the named one; an empty name inserts it first
+func (p *Pipeline) Insert(after string, stage Stage) error {
+	if stage.Name == "" || stage.Run == nil {
+		return fmt.Errorf("stage needs a name and a run function")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.index(stage.Name) >= 0 {
+		return fmt.Errorf("stage %s already exists", stage.Name)
+	}
+	position := 0
+	if after != "" {
+		j := p.index(after)
+		if j < 0 {
+			return fmt.Errorf("unknown stage: %s", after)
+		}
+		position = j + 1
+	}
+
+	p.stages = append(p.stages[:position], append([]Stage{stage}, p.stages[position:]...)...)
+	return nil
+}
+
+// Replace swaps the run function of a stage, keeping its position and hooks
+func (p *Pipeline) Replace(name string, run StageFunc) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	j := p.index(name)
+	if j < 0 {
+		return fmt.Errorf("unknown stage: %s", name)
+	}
+	p.stages[j].Run = run
+	return nil
+}
+
+// Remove disables a stage. Its hooks are kept in case it is re-added.
+func (p *Pipeline) Remove(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	j := p.index(name)
+	if j < 0 {
+		return fmt.Errorf("unknown stage: %s", name)
+	}
+	p.stages = append(p.stages[:j], p.stages[j+1:]...)
+	return nil
+}
+
+// Reorder sets the run order. names must list every current stage once.
+func (p *Pipeline) Reorder(names ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(names) != len(p.stages) {
+		return fmt.Errorf("reorder lists %d stages, pipeline has %d", len(names), len(p.stages))
+	}
+	reordered := make([]Stage, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		j := p.index(name)
+		if j < 0 || seen[name] {
+			return fmt.Errorf("unknown or repeated stage: %s", name)
+		}
+		seen[name] = true
+		reordered = append(reordered, p.stages[j])
+	}
+	p.stages = reordered
+	return nil
+}
+
+// Before registers a hook run before the named stage
+func (p *Pipeline) Before(name string, hook StageFunc) error {
+	return p.addHook(p.before, name, hook)
+}
+
+// After registers a hook run after the named stage
+func (p *Pipeline) After(name string, hook StageFunc) error {
+	return p.addHook(p.after, name, hook)
+}
+
+// addHook registers a hook for an existing stage
+func (p *Pipeline) addHook(hooks map[string][]StageFunc, name string, hook StageFunc) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.index(name) < 0 {
+		return fmt.Errorf("unknown stage: %s", name)
+	}
+	hooks[name] = append(hooks[name], hook)
+	return nil
+}
+
+// index returns the position of a stage, or -1; callers hold the lock
+func (p *Pipeline) index(name string) int {
+	for j, stage := range p.stages {
+		if stage.Name == name {
+			return j
+		}
+	}
+	return -1
+}
+
+// Run runs every stage with its hooks, stopping at the first error or when
+// the state's context is done
+func (p *Pipeline) Run(state *State) error {
+	p.mu.RLock()
+	stages := append([]Stage(nil), p.stages...)
+	before := make(map[string][]StageFunc, len(p.before))
+	after := make(map[string][]StageFunc, len(p.after))
+	for _, stage := range stages {
+		before[stage.Name] = append([]StageFunc(nil), p.before[stage.Name]...)
+		after[stage.Name] = append([]StageFunc(nil), p.after[stage.Name]...)
+	}
+	p.mu.RUnlock()
+
+	for _, stage := range stages {
+		steps := append(append(before[stage.Name], stage.Run), after[stage.Name]...)
+		for _, step := range steps {
+			if err := state.Context.Err(); err != nil {
+				return err
+			}
+			if err := step(state); err != nil {
+				return fmt.Errorf("%s: %w", stage.Name, err)
+			}
+		}
+	}
+	return state.Context.Err()
+}
@@ -0,0 +1,103 @@
+package inliner
+
+import (
+	"regexp"
+	"strings"
+
+	"inliner/internal/css"
+	"inliner/internal/html"
+)
+
+// dynamicPseudoRegex matches pseudo-classes and pseudo-elements that depend
+// on user interaction or generated content, which a static DOM can't match.
+// Structural pseudo-classes such as :first-child are left in place.
+var dynamicPseudoRegex = regexp.MustCompile(`(?i)::?(?:hover|focus|focus-within|focus-visible|active|visited|link|target|checked|disabled|enabled|placeholder|selection|before|after|first-line|first-letter|marker|-webkit-[a-z-]+|-moz-[a-z-]+|-ms-[a-z-]+)(?:\([^)]*\))?`)
+
+// purgeUnusedRules drops preserved rules whose selectors match nothing in the
+// document, and unmatched selectors from selector lists. At-rules such as
+// @font-face, rules marked /* inliner: keep */, safelisted selectors and
+// selectors the matcher can't parse are always kept. Returns the surviving rules and how many were removed.
+func (i *Inliner) purgeUnusedRules(doc html.Document, rules []css.Rule) ([]css.Rule, int) {
+	safelist := compileSafelist(i.config.CSSSafelist)
+	matched := make(map[string]bool)
+
+	var kept []css.Rule
+	removed := 0
+	for _, rule := range rules {
+		if rule.IsAtRule() || rule.Keep {
+			kept = append(kept, rule)
+			continue
+		}
+
+		var used []string
+		for _, selector := range css.SplitSelectorList(rule.Selector) {
+			if isSafelisted(selector, safelist) || selectorMatches(doc, selector, matched) {
+				used = append(used, selector)
+			}
+		}
+
+		if len(used) == 0 {
+			removed++
+			continue
+		}
+		rule.Selector = strings.Join(used, ", ")
+		kept = append(kept, rule)
+	}
+
+	return kept, removed
+}
+
+// selectorMatches reports whether a selector, with dynamic pseudo-classes
+// stripped, matches an element. Unparseable selectors count as matching.
+func selectorMatches(doc html.Document, selector string, cache map[string]bool) bool {
+	static := strings.TrimSpace(dynamicPseudoRegex.ReplaceAllString(selector, ""))
+	if static == "" || strings.HasSuffix(static, ">") || strings.HasSuffix(static, "+") || strings.HasSuffix(static, "~") {
+		static += "*"
+	}
+
+	if result, ok := cache[static]; ok {
+		return result
+	}
+
+	nodes, err := doc.QuerySelectorAll(static)
+	result := err != nil || len(nodes) > 0
+	cache[static] = result
+	return result
+}
+
+// safelistEntry is a compiled CSSSafelist pattern
+type safelistEntry struct {
+	substring string
+	pattern   *regexp.Regexp
+}
+
+// compileSafelist compiles safelist patterns: /regexp/ entries are regular
+// expressions, anything else matches as a substring of the selector
+func compileSafelist(patterns []string) []safelistEntry {
+	var entries []safelistEntry
+	for _, pattern := range patterns {
+		if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			if re, err := regexp.Compile(pattern[1 : len(pattern)-1]); err == nil {
+				entries = append(entries, safelistEntry{pattern: re})
+				continue
+			}
+		}
+		if pattern != "" {
+			entries = append(entries, safelistEntry{substring: pattern})
+		}
+	}
+	return entries
+}
+
+// isSafelisted reports whether a selector matches any safelist entry
+func isSafelisted(selector string, safelist []safelistEntry) bool {
+	for _, entry := range safelist {
+		if entry.pattern != nil && entry.pattern.MatchString(selector) {
+			return true
+		}
+		if entry.substring != "" && strings.Contains(selector, entry.substring) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package inliner
+
+import (
+	"testing"
+
+	"inliner/internal/config"
+	"inliner/internal/css"
+	"inliner/internal/html"
+)
+
+func TestPurgeKeepsSelectorListArguments(t *testing.T) {
+	doc, err := html.NewParser().Parse(`<html><body><p class="b" title="a,b">text</p></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stylesheet, err := css.NewParser().Parse(`
+p:is(.a, .b):hover { color: red }
+p:not(.x,.y):hover { color: blue }
+[title="a,b"]:hover { color: green }
+.missing:hover, .b:hover { color: black }
+.gone:hover { color: white }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.CSSSafelist = nil
+	kept, removed := New(cfg).purgeUnusedRules(doc, stylesheet.Rules)
+
+	var got []string
+	for _, rule := range kept {
+		got = append(got, rule.Selector)
+	}
+	want := []string{"p:is(.a, .b):hover", "p:not(.x,.y):hover", `[title="a,b"]:hover`, ".b:hover"}
+	if len(got) != len(want) || removed != 1 {
+		t.Fatalf("kept %q (removed %d), want %q (removed 1)", got, removed, want)
+	}
+	for j := range want {
+		if got[j] != want[j] {
+			t.Errorf("kept[%d] = %q, want %q", j, got[j], want[j])
+		}
+	}
+}
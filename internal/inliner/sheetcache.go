@@ -0,0 +1,74 @@
+package inliner
+
+import (
+	"context"
+	"sync"
+
+	"inliner/internal/css"
+)
+
+// SheetCache holds external stylesheets after they are loaded and parsed, so
+// a sheet linked from many documents is fetched and parsed once. Parsed
+// sheets are shared read-only between documents and goroutines. Failed loads
+// are not cached.
+type SheetCache struct {
+	mu     sync.Mutex
+	sheets map[string]*cachedSheet
+}
+
+// cachedSheet is a cache entry; done is closed once sheet and err are set
+type cachedSheet struct {
+	done  chan struct{}
+	sheet *css.Stylesheet
+	err   error
+}
+
+// NewSheetCache creates an empty stylesheet cache
+func NewSheetCache() *SheetCache {
+	return &SheetCache{sheets: make(map[string]*cachedSheet)}
+}
+
+// SetSheetCache shares parsed external stylesheets through cache, which may
+// be shared by several inliners. Without one every document loads and parses
+// its stylesheets afresh, which picks up changes to them. It must be called
+// before the inliner is used.
+func (i *Inliner) SetSheetCache(cache *SheetCache) {
+	i.sheets = cache
+}
+
+// get returns the sheet for href, calling load when it isn't cached yet.
+// Concurrent requests for the same href wait for the first load.
+func (c *SheetCache) get(ctx context.Context, href string, load func() (*css.Stylesheet, error)) (*css.Stylesheet, error) {
+	c.mu.Lock()
+	entry, ok := c.sheets[href]
+	if !ok {
+		entry = &cachedSheet{done: make(chan struct{})}
+		c.sheets[href] = entry
+		c.mu.Unlock()
+
+		entry.sheet, entry.err = load()
+		if entry.err != nil {
+			c.mu.Lock()
+			delete(c.sheets, href)
+			c.mu.Unlock()
+		}
+		close(entry.done)
+		return entry.sheet, entry.err
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-entry.done:
+		return entry.sheet, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Forget drops a stylesheet from the cache so the next document that links it
+// loads it again, e.g. after the file changed
+func (c *SheetCache) Forget(href string) {
+	c.mu.Lock()
+	delete(c.sheets, href)
+	c.mu.Unlock()
+}
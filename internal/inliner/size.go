@@ -0,0 +1,129 @@
+package inliner
+
+import (
+	"fmt"
+
+	"inliner/internal/config"
+	"inliner/internal/html"
+)
+
+// sizeWarningRatio is the fraction of a limit at which a warning is raised
+const sizeWarningRatio = 0.9
+
+// SizeReport describes the size of the final HTML and where the bytes go
+type SizeReport struct {
+	TotalBytes int             // Size of the serialized document
+	StyleBytes int             // Size of all <style> block contents
+	Breakdown  SizeBreakdown   // Bytes attributable to reducible content
+	Limits     []SizeLimitInfo // Limits checked, one per target client and kind
+}
+
+// SizeBreakdown attributes bytes to content that can usually be reduced
+type SizeBreakdown struct {
+	InlineStyles          int // style="" attribute values
+	DuplicateInlineStyles int // style="" values repeated verbatim on other elements
+	PreservedCSS          int // CSS kept in <style> tags
+	Comments              int // Non-conditional comments
+	ConditionalComments   int // MSO conditional comments (needed by Outlook)
+	Whitespace            int // Whitespace that could be collapsed
+	DataURIs              int // Images and fonts embedded as data: URIs
+}
+
+// SizeLimitInfo is a single limit check against a client profile
+type SizeLimitInfo struct {
+	Client   string
+	Kind     string // "message" or "stylesheet"
+	Limit    int
+	Actual   int
+	Exceeded bool
+}
+
+// measureSize measures the final HTML, checks it against every size target
+// and returns warnings for limits that are exceeded or nearly exceeded
+func (i *Inliner) measureSize(finalHTML string) (SizeReport, []ValidationWarning) {
+	measurement := html.Measure(finalHTML)
+
+	report := SizeReport{
+		TotalBytes: measurement.TotalBytes,
+		StyleBytes: measurement.StyleTagBytes,
+		Breakdown: SizeBreakdown{
+			InlineStyles:          measurement.InlineStyleBytes,
+			DuplicateInlineStyles: measurement.DuplicateStyleBytes,
+			PreservedCSS:          measurement.StyleTagBytes,
+			Comments:              measurement.CommentBytes,
+			ConditionalComments:   measurement.ConditionalBytes,
+			Whitespace:            measurement.CollapsibleSpaceBytes,
+			DataURIs:              measurement.DataURIBytes,
+		},
+	}
+
+	var warnings []ValidationWarning
+	for _, client := range i.config.EffectiveSizeTargets() {
+		profile := config.GetCompatibilityProfile(client)
+
+		checks := []struct {
+			kind   string
+			label  string
+			rule   string
+			limit  int
+			actual int
+		}{
+			{"message", "Message", "size-message-limit", profile.MaxMessageSize, report.TotalBytes},
+			{"stylesheet", "Stylesheet", "size-stylesheet-limit", profile.MaxStylesheetSize, report.StyleBytes},
+		}
+
+		for _, check := range checks {
+			if check.limit <= 0 {
+				continue
+			}
+
+			limit := SizeLimitInfo{
+				Client:   client,
+				Kind:     check.kind,
+				Limit:    check.limit,
+				Actual:   check.actual,
+				Exceeded: check.actual > check.limit,
+			}
+			report.Limits = append(report.Limits, limit)
+
+			switch {
+			case limit.Exceeded:
+				warnings = append(warnings, ValidationWarning{
+					Rule:     check.rule,
+					Property: check.kind,
+					Value:    formatBytes(check.actual),
+					Message: fmt.Sprintf("%s size %s exceeds the %s limit of %s%s",
+						check.label, formatBytes(check.actual), client, formatBytes(check.limit), clipNote(check.kind)),
+					Severity: "error",
+				})
+			case float64(check.actual) > float64(check.limit)*sizeWarningRatio:
+				warnings = append(warnings, ValidationWarning{
+					Rule:     check.rule,
+					Property: check.kind,
+					Value:    formatBytes(check.actual),
+					Message: fmt.Sprintf("%s size %s is within 10%% of the %s limit of %s",
+						check.label, formatBytes(check.actual), client, formatBytes(check.limit)),
+					Severity: "warning",
+				})
+			}
+		}
+	}
+
+	return report, warnings
+}
+
+// clipNote explains the consequence of exceeding a limit
+func clipNote(kind string) string {
+	if kind == "message" {
+		return "; the message will be clipped"
+	}
+	return "; the styles may be dropped"
+}
+
+// formatBytes renders a byte count for messages
+func formatBytes(n int) string {
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	return fmt.Sprintf("%.1fKB", float64(n)/1024)
+}
@@ -0,0 +1,89 @@
+package inliner
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"inliner/internal/config"
+)
+
+// Option adjusts the configuration for a single InlineContext call. Options
+// receive a copy of the inliner's configuration; they should replace map
+// and slice fields rather than modify them in place, since those are shared.
+type Option func(*config.Config)
+
+// WithConfig replaces the configuration for the call
+func WithConfig(cfg config.Config) Option {
+	return func(c *config.Config) {
+		*c = cfg
+	}
+}
+
+// WithTargetClient sets the target email client for the call
+func WithTargetClient(client string) Option {
+	return func(c *config.Config) {
+		c.TargetEmailClient = client
+	}
+}
+
+// WithMinify turns output minification on or off for the call
+func WithMinify(minify bool) Option {
+	return func(c *config.Config) {
+		c.Minify = minify
+	}
+}
+
+// WithOutputFormat sets the output whitespace format for the call
+func WithOutputFormat(format string) Option {
+	return func(c *config.Config) {
+		c.OutputFormat = format
+	}
+}
+
+// WithBaseURL sets the document URL or path that relative stylesheet links
+// are resolved against for the call
+func WithBaseURL(base string) Option {
+	return func(c *config.Config) {
+		c.BaseURL = base
+	}
+}
+
+// InlineContext reads HTML from r, inlines its CSS and writes the result to
+// w. It stops early with ctx.Err() when ctx is cancelled or its deadline
+// passes; nothing is written to w in that case. Like every other method it
+// may be called concurrently on a shared Inliner.
+func (i *Inliner) InlineContext(ctx context.Context, r io.Reader, w io.Writer, opts ...Option) (*InlineResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	input, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTML: %w", err)
+	}
+
+	result, err := i.withOptions(opts).inline(ctx, string(input))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.WriteString(w, result.HTML); err != nil {
+		return nil, fmt.Errorf("failed to write HTML: %w", err)
+	}
+	return result, nil
+}
+
+// withOptions returns an inliner sharing i's parsers and rules with the
+// options applied to a copy of its configuration
+func (i *Inliner) withOptions(opts []Option) *Inliner {
+	if len(opts) == 0 {
+		return i
+	}
+
+	call := *i
+	for _, opt := range opts {
+		opt(&call.config)
+	}
+	return &call
+}
@@ -0,0 +1,359 @@
+package inliner
+
+import (
+	"fmt"
+	stdhtml "html"
+	"math"
+	"strconv"
+	"strings"
+
+	"inliner/internal/css"
+	"inliner/internal/html"
+	"inliner/internal/resolver"
+)
+
+// Outlook desktop renders with Word, which ignores border-radius,
+// background-image and padding on links. Elements marked
+// data-inliner="vml-button" or "vml-background" get a VML version drawn from
+// the same resolved styles, shown only to Outlook by conditional comments.
+const (
+	vmlNamespaces = `xmlns:v="urn:schemas-microsoft-com:vml" xmlns:w="urn:schemas-microsoft-com:office:word"`
+
+	msoOnly      = "<!--[if mso]>"
+	msoOnlyEnd   = "<![endif]-->"
+	nonMSOStart  = "<!--[if !mso]><!-->"
+	nonMSOEnd    = "<!--<![endif]-->"
+	vmlCondition = "<!--[if gte mso 9]>"
+)
+
+// vmlStage adds VML fallbacks to the elements marked for them
+func (i *Inliner) vmlStage(s *State) error {
+	marked, err := s.Document.QuerySelectorAll("[" + directiveAttribute + "]")
+	if err != nil {
+		return fmt.Errorf("failed to query directives: %w", err)
+	}
+
+	// Unfiltered styles: Outlook needs the look the HTML was designed with,
+	// even where the target client drops the properties from style attributes
+	var styleResolver *resolver.Resolver
+	if s.Stylesheet != nil {
+		cfg := s.Config
+		cfg.EmailClientOptimizations = false
+		styleResolver = resolver.New(s.Stylesheet, cfg)
+	}
+
+	for _, element := range marked {
+		button := hasDirective(element, directiveVMLButton)
+		background := hasDirective(element, directiveVMLBackground)
+		if !button && !background {
+			continue
+		}
+
+		styles := element.GetInlineStyle()
+		if styleResolver != nil {
+			if resolved, err := styleResolver.ResolveStyles(element); err == nil {
+				styles = resolved
+			}
+		}
+
+		if button {
+			err = i.addVMLButton(element, styles, s.Result)
+		} else {
+			err = i.addVMLBackground(element, styles, s.Result)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add VML fallback: %w", err)
+		}
+	}
+	return nil
+}
+
+// addVMLButton puts a v:roundrect with the button's size, colors, corner
+// radius and text before it, and hides the HTML button from Outlook
+func (i *Inliner) addVMLButton(element html.Node, styles map[string]css.Declaration, result *InlineResult) error {
+	tag := strings.ToLower(element.TagName())
+	attrs := element.Attributes()
+	line := element.SourceLine() // The element is replaced below
+
+	fontSize := 16.0
+	if size, ok := pxLength(declarationValue(styles, "font-size")); ok {
+		fontSize = size
+	}
+
+	// The HTML box: content size plus padding and border on each side
+	padding := boxSides(styles, "padding")
+	borderWidth, borderColor := vmlBorder(styles)
+	width, hasWidth := pxLength(declarationValue(styles, "width"))
+	if !hasWidth {
+		width, hasWidth = pxLength(attrs["width"])
+	}
+	height, hasHeight := pxLength(declarationValue(styles, "height"))
+	if !hasHeight {
+		height, hasHeight = lineHeightPx(declarationValue(styles, "line-height"), fontSize)
+	}
+	if !hasWidth || !hasHeight {
+		i.vmlWarning(element, result, directiveVMLButton,
+			"VML button fallback needs a width and a height or line-height in px; none was added")
+		return nil
+	}
+	width += padding[1] + padding[3] + 2*borderWidth
+	height += padding[0] + padding[2] + 2*borderWidth
+
+	shape := []string{
+		vmlNamespaces,
+		fmt.Sprintf(`style="height:%spx;v-text-anchor:middle;width:%spx;"`, formatPx(height), formatPx(width)),
+	}
+	if href := attrs["href"]; href != "" {
+		shape = append(shape, fmt.Sprintf(`href="%s"`, stdhtml.EscapeString(href)))
+	}
+	if radius, ok := pxLength(firstToken(declarationValue(styles, "border-radius"))); ok && radius > 0 {
+		arc := math.Min(radius/math.Min(width, height), 0.5)
+		shape = append(shape, fmt.Sprintf(`arcsize="%d%%"`, int(math.Round(arc*100))))
+	}
+	if borderWidth > 0 {
+		shape = append(shape, fmt.Sprintf(`strokecolor="%s" strokeweight="%spx"`, borderColor, formatPx(borderWidth)))
+	} else {
+		shape = append(shape, `stroke="f"`)
+	}
+	if fill, ok := vmlBackgroundColor(styles, attrs); ok {
+		shape = append(shape, fmt.Sprintf(`fillcolor="%s"`, fill))
+	} else {
+		shape = append(shape, `fill="f"`)
+	}
+
+	// Text styles Word applies to the label
+	var text []string
+	for _, property := range []string{"color", "font-family", "font-size", "font-weight"} {
+		if value := declarationValue(styles, property); value != "" {
+			text = append(text, fmt.Sprintf("%s:%s;", property, value))
+		}
+	}
+
+	vml := fmt.Sprintf(`%s<v:roundrect %s><w:anchorlock/><center style="%s">%s</center></v:roundrect>%s`,
+		msoOnly, strings.Join(shape, " "), stdhtml.EscapeString(strings.Join(text, "")),
+		stdhtml.EscapeString(strings.TrimSpace(element.Text())), msoOnlyEnd)
+	if err := element.ReplaceWithHTML(vml + nonMSOStart + element.OuterHTML() + nonMSOEnd); err != nil {
+		return err
+	}
+
+	result.Fixes = append(result.Fixes, Fix{
+		Rule:        directiveVMLButton,
+		Element:     tag,
+		Line:        line,
+		Description: fmt.Sprintf("added VML roundrect fallback (%sx%spx)", formatPx(width), formatPx(height)),
+	})
+	return nil
+}
+
+// addVMLBackground wraps the element's content in a v:rect filled with its
+// background image and color, so Outlook shows the image behind the content
+func (i *Inliner) addVMLBackground(element html.Node, styles map[string]css.Declaration, result *InlineResult) error {
+	tag := strings.ToLower(element.TagName())
+	attrs := element.Attributes()
+
+	switch tag {
+	case "table", "thead", "tbody", "tfoot", "tr":
+		i.vmlWarning(element, result, directiveVMLBackground,
+			fmt.Sprintf("VML background fallback can't wrap the rows of a <%s>; mark the cell instead", tag))
+		return nil
+	}
+
+	src := cssURL(declarationValue(styles, "background-image"))
+	if src == "" {
+		src = cssURL(declarationValue(styles, "background"))
+	}
+	if src == "" {
+		src = strings.TrimSpace(attrs["background"])
+	}
+	width, hasWidth := pxLength(declarationValue(styles, "width"))
+	if !hasWidth {
+		width, hasWidth = pxLength(attrs["width"])
+	}
+	if src == "" || !hasWidth {
+		i.vmlWarning(element, result, directiveVMLBackground,
+			"VML background fallback needs a background image and a width in px; none was added")
+		return nil
+	}
+	height, hasHeight := pxLength(declarationValue(styles, "height"))
+	if !hasHeight {
+		height, hasHeight = pxLength(attrs["height"])
+	}
+
+	// Without a height the shape grows with its content
+	size := fmt.Sprintf("width:%spx;", formatPx(width))
+	textbox := `inset="0,0,0,0"`
+	if hasHeight {
+		size += fmt.Sprintf("height:%spx;", formatPx(height))
+	} else {
+		textbox += ` style="mso-fit-shape-to-text:true"`
+	}
+	fill := fmt.Sprintf(`type="tile" src="%s"`, stdhtml.EscapeString(src))
+	if color, ok := vmlBackgroundColor(styles, attrs); ok {
+		fill += fmt.Sprintf(` color="%s"`, color)
+	}
+
+	open := fmt.Sprintf(`%s<v:rect %s fill="true" stroke="false" style="%s"><v:fill %s /><v:textbox %s>%s`,
+		vmlCondition, vmlNamespaces, size, fill, textbox, msoOnlyEnd)
+	closing := vmlCondition + "</v:textbox></v:rect>" + msoOnlyEnd
+	if err := element.SetHTML(open + element.InnerHTML() + closing); err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("added VML background fallback (%spx wide)", formatPx(width))
+	if hasHeight {
+		description = fmt.Sprintf("added VML background fallback (%sx%spx)", formatPx(width), formatPx(height))
+	}
+	result.Fixes = append(result.Fixes, Fix{
+		Rule:        directiveVMLBackground,
+		Element:     tag,
+		Line:        element.SourceLine(),
+		Description: description,
+	})
+	return nil
+}
+
+// vmlWarning reports a marked element that got no VML fallback
+func (i *Inliner) vmlWarning(element html.Node, result *InlineResult, rule, message string) {
+	result.Warnings = append(result.Warnings, ValidationWarning{
+		Rule:     rule,
+		Value:    rule,
+		Message:  message,
+		Severity: "warning",
+		Element:  strings.ToLower(element.TagName()),
+		Line:     element.SourceLine(),
+	})
+}
+
+// declarationValue returns a property's value, or "" when it isn't set
+func declarationValue(styles map[string]css.Declaration, property string) string {
+	return strings.TrimSpace(styles[property].Value)
+}
+
+// vmlBackgroundColor returns the element's background color as hex, from
+// CSS or the bgcolor attribute
+func vmlBackgroundColor(styles map[string]css.Declaration, attrs map[string]string) (string, bool) {
+	for _, property := range []string{"background-color", "background"} {
+		if color, ok := css.BackgroundColor(declarationValue(styles, property)); ok && color.A > 0 {
+			return color.Hex(), true
+		}
+	}
+	if color, ok := css.ParseColor(attrs["bgcolor"]); ok && color.A > 0 {
+		return color.Hex(), true
+	}
+	return "", false
+}
+
+// vmlBorder returns the border width in px and its color from the border
+// shorthand and longhands; a zero width means no stroke
+func vmlBorder(styles map[string]css.Declaration) (float64, string) {
+	width, color := 0.0, "#000000"
+	style := ""
+	for _, token := range strings.Fields(declarationValue(styles, "border")) {
+		if px, ok := pxLength(token); ok {
+			width = px
+		} else if parsed, ok := css.ParseColor(token); ok {
+			color = parsed.Hex()
+		} else {
+			style = strings.ToLower(token)
+		}
+	}
+	if px, ok := pxLength(firstToken(declarationValue(styles, "border-width"))); ok {
+		width = px
+	}
+	if parsed, ok := css.ParseColor(firstToken(declarationValue(styles, "border-color"))); ok {
+		color = parsed.Hex()
+	}
+	if value := declarationValue(styles, "border-style"); value != "" {
+		style = strings.ToLower(firstToken(value))
+	}
+	if style == "none" || style == "hidden" {
+		width = 0
+	}
+	return width, color
+}
+
+// boxSides returns the top, right, bottom and left px values of a box
+// property such as padding, from its shorthand and longhands
+func boxSides(styles map[string]css.Declaration, property string) [4]float64 {
+	var sides [4]float64
+	var values []float64
+	for _, token := range strings.Fields(declarationValue(styles, property)) {
+		px, _ := pxLength(token)
+		values = append(values, px)
+	}
+	switch len(values) {
+	case 1:
+		sides = [4]float64{values[0], values[0], values[0], values[0]}
+	case 2:
+		sides = [4]float64{values[0], values[1], values[0], values[1]}
+	case 3:
+		sides = [4]float64{values[0], values[1], values[2], values[1]}
+	case 4:
+		sides = [4]float64{values[0], values[1], values[2], values[3]}
+	}
+	for j, side := range []string{"top", "right", "bottom", "left"} {
+		if px, ok := pxLength(declarationValue(styles, property+"-"+side)); ok {
+			sides[j] = px
+		}
+	}
+	return sides
+}
+
+// pxLength converts a px, pt or unitless length to px
+func pxLength(value string) (float64, bool) {
+	value = strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important")))
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(value, "px"):
+		value = strings.TrimSuffix(value, "px")
+	case strings.HasSuffix(value, "pt"):
+		value, scale = strings.TrimSuffix(value, "pt"), 4.0/3
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n * scale, true
+}
+
+// lineHeightPx converts a line-height to px; unitless values scale the font size
+func lineHeightPx(value string, fontSize float64) (float64, bool) {
+	if n, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && n > 0 {
+		return n * fontSize, true
+	}
+	if strings.HasSuffix(strings.TrimSpace(value), "%") {
+		n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+		if err == nil && n > 0 {
+			return n / 100 * fontSize, true
+		}
+		return 0, false
+	}
+	return pxLength(value)
+}
+
+// firstToken returns the first whitespace-separated token of a value
+func firstToken(value string) string {
+	if fields := strings.Fields(value); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// cssURL extracts the address from the first url() in a value
+func cssURL(value string) string {
+	start := strings.Index(strings.ToLower(value), "url(")
+	if start < 0 {
+		return ""
+	}
+	rest := value[start+len("url("):]
+	end := strings.Index(rest, ")")
+	if end < 0 {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(rest[:end]), `"'`)
+}
+
+// formatPx formats a px size without trailing zeros
+func formatPx(px float64) string {
+	return strconv.FormatFloat(math.Round(px*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,125 @@
+package inliner
+
+import (
+	"strings"
+	"testing"
+
+	"inliner/internal/config"
+	"inliner/internal/rules"
+)
+
+const warningsHTML = `<html><head><style>
+.hero { width: 100vw; background-image: url(hero.png); position: fixed }
+.badge { position: absolute; mix-blend-mode: multiply }
+.note { position: relative }
+</style></head><body>
+<div class="hero">Hero</div><span class="badge">New</span><p class="note">Note</p>
+</body></html>`
+
+// warningSeverities inlines warningsHTML and returns the severity of each
+// warning by rule ID
+func warningSeverities(t *testing.T, cfg config.Config) map[string]string {
+	t.Helper()
+	result, err := New(cfg).Inline(warningsHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	severities := make(map[string]string)
+	for _, warning := range result.Warnings {
+		severities[warning.Rule] = warning.Severity
+	}
+	return severities
+}
+
+func TestWarningsUseRegisteredRuleIDs(t *testing.T) {
+	cfg := config.Default()
+	cfg.TargetEmailClient = "outlook"
+	cfg.EmailClientOptimizations = false // Keep the properties it would filter out
+	severities := warningSeverities(t, cfg)
+
+	want := map[string]string{
+		"css-viewport-units":    "error",
+		"css-background-image":  "warning",
+		"css-position-fixed":    "error",
+		"css-position-absolute": "warning",
+		"css-position-relative": "warning",
+		"css-unsafe-property":   "info",
+	}
+	for id, severity := range want {
+		if severities[id] != severity {
+			t.Errorf("%s: severity %q, want %q (all: %v)", id, severities[id], severity, severities)
+		}
+	}
+
+	registry := rules.Default()
+	for id, severity := range severities {
+		rule, ok := registry.Lookup(id)
+		if !ok {
+			t.Errorf("warning rule %s is not registered, so config can't override it", id)
+			continue
+		}
+		if rule.DefaultSeverity != severity {
+			t.Errorf("%s: warned at %s, registered at %s", id, severity, rule.DefaultSeverity)
+		}
+	}
+}
+
+func TestWarningSeverityOverrides(t *testing.T) {
+	cfg := config.Default()
+	cfg.TargetEmailClient = "outlook"
+	cfg.EmailClientOptimizations = false // Keep the properties it would filter out
+	cfg.Rules = map[string]string{
+		"css-viewport-units":  rules.SeverityOff,
+		"css-position-fixed":  rules.SeverityInfo,
+		"css-unsafe-property": rules.SeverityError,
+	}
+	if err := rules.Default().ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig: %v", err)
+	}
+	severities := warningSeverities(t, cfg)
+
+	if severity, ok := severities["css-viewport-units"]; ok {
+		t.Errorf("css-viewport-units is off but was reported as %s", severity)
+	}
+	if severities["css-position-fixed"] != "info" || severities["css-unsafe-property"] != "error" {
+		t.Errorf("overridden severities not applied: %v", severities)
+	}
+	if severities["css-position-absolute"] != "warning" {
+		t.Errorf("css-position-absolute = %q, want its default warning", severities["css-position-absolute"])
+	}
+}
+
+func TestSizeWarningsCanBeTurnedOff(t *testing.T) {
+	cfg := config.Default()
+	cfg.SizeTargets = []string{"gmail"}
+	page := "<html><body><p>" + strings.Repeat("x", 110*1024) + "</p></body></html>"
+
+	result, err := New(cfg).Inline(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasWarning(result, "size-message-limit") {
+		t.Fatalf("no size-message-limit warning for a clipped message: %+v", result.Warnings)
+	}
+
+	cfg.Rules = map[string]string{"size-message-limit": rules.SeverityOff}
+	if result, err = New(cfg).Inline(page); err != nil {
+		t.Fatal(err)
+	}
+	if hasWarning(result, "size-message-limit") {
+		t.Error("size-message-limit is off but was reported")
+	}
+	if len(result.Size.Limits) == 0 || !result.Size.Limits[0].Exceeded {
+		t.Errorf("size limits = %+v, want the check itself still recorded", result.Size.Limits)
+	}
+}
+
+// hasWarning reports whether the result has a warning for the rule
+func hasWarning(result *InlineResult, rule string) bool {
+	for _, warning := range result.Warnings {
+		if warning.Rule == rule {
+			return true
+		}
+	}
+	return false
+}
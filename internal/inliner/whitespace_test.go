@@ -0,0 +1,55 @@
+package inliner
+
+import (
+	"testing"
+
+	"inliner/internal/config"
+)
+
+func TestOutputFormats(t *testing.T) {
+	page := "<html><head></head><body>\n<div>\n  <p>Hello   world</p>\n</div>\n<pre> a\n  b </pre>\n</body></html>"
+
+	tests := []struct {
+		name   string
+		config func(*config.Config)
+		want   string
+	}{
+		{
+			name:   "preserve",
+			config: func(c *config.Config) { c.OutputFormat = config.OutputPreserve },
+			want:   "<html><head></head><body>\n<div>\n  <p>Hello   world</p>\n</div>\n<pre> a\n  b </pre>\n</body></html>",
+		},
+		{
+			name:   "compact",
+			config: func(c *config.Config) { c.OutputFormat = config.OutputCompact },
+			want:   "<html><head></head><body><div><p>Hello world</p></div><pre> a\n  b </pre></body></html>",
+		},
+		{
+			name:   "pretty",
+			config: func(c *config.Config) { c.OutputFormat = config.OutputPretty; c.Indent = "\t" },
+			want:   "<html>\n\t<head></head>\n\t<body>\n\t\t<div>\n\t\t\t<p>Hello world</p>\n\t\t</div>\n\t\t<pre> a\n  b </pre>\n\t</body>\n</html>",
+		},
+		{
+			name:   "compact when whitespace is not preserved",
+			config: func(c *config.Config) { c.OutputFormat = ""; c.PreserveWhitespace = false },
+			want:   "<html><head></head><body><div><p>Hello world</p></div><pre> a\n  b </pre></body></html>",
+		},
+		{
+			name:   "format wins over preserve whitespace",
+			config: func(c *config.Config) { c.OutputFormat = config.OutputPreserve; c.PreserveWhitespace = false },
+			want:   "<html><head></head><body>\n<div>\n  <p>Hello   world</p>\n</div>\n<pre> a\n  b </pre>\n</body></html>",
+		},
+	}
+
+	for _, test := range tests {
+		cfg := config.Default()
+		test.config(&cfg)
+		got, err := New(cfg).InlineString(page)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got != test.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenReport covers a clean file, findings with and without lines, a fix
+// and a failed file
+func goldenReport() *Report {
+	r := New("inline")
+	r.Tool.Version = "test"
+	r.Files = []FileReport{
+		{
+			Path:   "welcome.html",
+			Status: "ok",
+			Stats:  &Stats{InlinedStyles: 12, PreservedRules: 2, CSSRulesParsed: 9, CSSRulesRemoved: 1, HTMLElementsProcessed: 40, SelectorsMatched: 7, ProcessingTimeMs: 3},
+			Findings: []Finding{
+				{RuleID: "img-alt", Severity: "error", Category: "accessibility", Message: `Image is missing alt text`, Element: "img", Reference: "WCAG 2.1 SC 1.1.1", Location: Location{Path: "welcome.html", Line: 14}},
+				{RuleID: "css-property", Severity: "warning", Category: "css", Message: `Property "position" is not supported in Outlook & Gmail`, Element: "div", Property: "position", Value: "absolute", Location: Location{Path: "welcome.html", Line: 21}},
+				{RuleID: "dark-mode-meta", Severity: "info", Category: "dark-mode", Message: "No color-scheme meta tag", Location: Location{Path: "welcome.html"}},
+			},
+			Fixes: []Fix{
+				{RuleID: "table-role", Element: "table", Description: `Added role="presentation"`, Location: Location{Path: "welcome.html", Line: 9}},
+			},
+		},
+		{Path: "receipt.html", Status: "ok", Stats: &Stats{InlinedStyles: 4, CSSRulesParsed: 4, HTMLElementsProcessed: 18, SelectorsMatched: 4, ProcessingTimeMs: 1}, Findings: []Finding{}},
+		{Path: "broken.html", Status: "failed", Error: "failed to read input: permission denied", Findings: []Finding{}},
+	}
+	r.RuleDocs = map[string]RuleDoc{
+		"img-alt": {Description: "Images need alt text", Docs: "Screen readers announce the alt text of images.", Severity: "error", Category: "accessibility", Reference: "WCAG 2.1 SC 1.1.1"},
+	}
+	return r
+}
+
+func TestWriteGolden(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatSARIF, FormatJUnit} {
+		t.Run(string(format), func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Write(&out, goldenReport(), format); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+
+			golden := filepath.Join("testdata", "report."+string(format)+".golden")
+			if *update {
+				if err := os.MkdirAll("testdata", 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, out.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("missing %s; run go test ./internal/report -update", golden)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("%s output differs from %s; run go test ./internal/report -update and review the diff\ngot:\n%s", format, golden, out.Bytes())
+			}
+		})
+	}
+}
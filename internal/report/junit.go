@@ -0,0 +1,132 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JUnit XML output: one test suite per file and one test case per finding, so
+// CI systems show each warning as a failed check
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// writeJUnit serializes the report as JUnit XML
+func writeJUnit(w io.Writer, r *Report) error {
+	suites := junitTestSuites{Name: r.Tool.Name}
+
+	for _, file := range r.Files {
+		suite := junitTestSuite{Name: file.Path}
+
+		switch {
+		case file.Status != "ok":
+			suite.Errors++
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      r.Mode,
+				ClassName: file.Path,
+				Error:     &junitFailure{Message: file.Error, Type: "failed", Body: file.Error},
+			})
+
+		case len(file.Findings) == 0:
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      r.Mode,
+				ClassName: file.Path,
+			})
+
+		default:
+			for _, finding := range file.Findings {
+				testCase := junitTestCase{
+					Name:      finding.RuleID,
+					ClassName: file.Path,
+				}
+
+				// Informational findings are reported but never fail the suite
+				if finding.Severity == "error" || finding.Severity == "warning" {
+					suite.Failures++
+					testCase.Failure = &junitFailure{
+						Message: finding.Message,
+						Type:    finding.Severity,
+						Body:    junitBody(finding),
+					}
+				} else {
+					testCase.SystemOut = junitBody(finding)
+				}
+
+				suite.TestCases = append(suite.TestCases, testCase)
+			}
+		}
+
+		suite.Tests = len(suite.TestCases)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitBody renders the details of a finding for the failure body
+func junitBody(finding Finding) string {
+	var body strings.Builder
+
+	location := finding.Location.Path
+	if finding.Location.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, finding.Location.Line)
+	}
+	fmt.Fprintf(&body, "%s [%s] %s", location, strings.ToUpper(finding.Severity), finding.Message)
+
+	if finding.Element != "" {
+		fmt.Fprintf(&body, "\nElement: %s", finding.Element)
+	}
+	if finding.Property != "" {
+		fmt.Fprintf(&body, "\nProperty: %s", finding.Property)
+	}
+	if finding.Value != "" {
+		fmt.Fprintf(&body, "\nValue: %s", finding.Value)
+	}
+	if finding.Reference != "" {
+		fmt.Fprintf(&body, "\nReference: %s", finding.Reference)
+	}
+
+	return body.String()
+}
@@ -0,0 +1,375 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"inliner/internal/inliner"
+	"inliner/internal/version"
+)
+
+// SchemaVersion is the version of the JSON report schema. It is bumped whenever
+// a field is removed or changes meaning; adding fields does not bump it.
+const SchemaVersion = "1"
+
+// Format selects how a report is serialized
+type Format string
+
+const (
+	FormatText  Format = "text"
+	FormatJSON  Format = "json"
+	FormatSARIF Format = "sarif"
+	FormatJUnit Format = "junit"
+)
+
+// Formats lists all supported report formats
+var Formats = []Format{FormatText, FormatJSON, FormatSARIF, FormatJUnit}
+
+// ParseFormat converts a format name to a Format
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if strings.EqualFold(name, string(format)) {
+			return format, nil
+		}
+	}
+
+	names := make([]string, len(Formats))
+	for i, format := range Formats {
+		names[i] = string(format)
+	}
+	return "", fmt.Errorf("invalid format: %s (valid: %s)", name, strings.Join(names, ", "))
+}
+
+// Report is the machine-readable result of an inliner run over one or more files
+type Report struct {
+	SchemaVersion string       `json:"schemaVersion"`
+	Tool          Tool         `json:"tool"`
+	Mode          string       `json:"mode"` // "inline" or "validate"
+	Files         []FileReport `json:"files"`
+	Summary       Summary      `json:"summary"`
+
+	// RuleDocs describes rules for formats that carry rule metadata (SARIF)
+	RuleDocs map[string]RuleDoc `json:"-"`
+}
+
+// RuleDoc is the documentation for a single rule ID
+type RuleDoc struct {
+	Description string
+	Docs        string
+	Severity    string // Default severity
+	Category    string
+	Reference   string
+}
+
+// Tool identifies the program that produced the report
+type Tool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// FileReport holds the findings and statistics for a single input
+type FileReport struct {
+	Path     string    `json:"path"`
+	Status   string    `json:"status"` // "ok" or "failed"
+	Error    string    `json:"error,omitempty"`
+	Stats    *Stats    `json:"stats,omitempty"`
+	Size     *Size     `json:"size,omitempty"`
+	Findings []Finding `json:"findings"`
+	Fixes    []Fix     `json:"fixes,omitempty"`
+}
+
+// Size reports the output size, its breakdown and the client limits checked
+type Size struct {
+	TotalBytes int           `json:"totalBytes"`
+	StyleBytes int           `json:"styleBytes"`
+	Breakdown  SizeBreakdown `json:"breakdown"`
+	Limits     []SizeLimit   `json:"limits"`
+}
+
+// SizeBreakdown mirrors inliner.SizeBreakdown
+type SizeBreakdown struct {
+	InlineStyles          int `json:"inlineStyles"`
+	DuplicateInlineStyles int `json:"duplicateInlineStyles"`
+	PreservedCSS          int `json:"preservedCss"`
+	Comments              int `json:"comments"`
+	ConditionalComments   int `json:"conditionalComments"`
+	Whitespace            int `json:"whitespace"`
+	DataURIs              int `json:"dataUris"`
+}
+
+// SizeLimit is a single limit check against a client profile
+type SizeLimit struct {
+	Client   string `json:"client"`
+	Kind     string `json:"kind"`
+	Limit    int    `json:"limit"`
+	Actual   int    `json:"actual"`
+	Exceeded bool   `json:"exceeded"`
+}
+
+// Fix is a change an autofix pass made to the document
+type Fix struct {
+	RuleID      string   `json:"ruleId"`
+	Element     string   `json:"element"`
+	Description string   `json:"description"`
+	Location    Location `json:"location"`
+}
+
+// Finding is a single warning or validation issue with a stable rule ID
+type Finding struct {
+	RuleID    string   `json:"ruleId"`
+	Severity  string   `json:"severity"` // "error", "warning", "info"
+	Category  string   `json:"category"` // "structure", "css", "attribute", ...
+	Message   string   `json:"message"`
+	Element   string   `json:"element,omitempty"`
+	Property  string   `json:"property,omitempty"`
+	Value     string   `json:"value,omitempty"`
+	Reference string   `json:"reference,omitempty"` // e.g. "WCAG 2.1 SC 1.1.1"
+	Location  Location `json:"location"`
+}
+
+// Location points at the source of a finding
+type Location struct {
+	Path string `json:"path"`
+	Line int    `json:"line,omitempty"` // 1-based, omitted when unknown
+}
+
+// Stats mirrors inliner.ProcessingStats plus the top-level result counters
+type Stats struct {
+	InlinedStyles         int   `json:"inlinedStyles"`
+	PreservedRules        int   `json:"preservedRules"`
+	CSSRulesParsed        int   `json:"cssRulesParsed"`
+	CSSRulesRemoved       int   `json:"cssRulesRemoved"`
+	HTMLElementsProcessed int   `json:"htmlElementsProcessed"`
+	SelectorsMatched      int   `json:"selectorsMatched"`
+	ProcessingTimeMs      int64 `json:"processingTimeMs"`
+}
+
+// Summary aggregates counts across all files in the report
+type Summary struct {
+	Files       int    `json:"files"`
+	FailedFiles int    `json:"failedFiles"`
+	Errors      int    `json:"errors"`
+	Warnings    int    `json:"warnings"`
+	Info        int    `json:"info"`
+	Stats       *Stats `json:"stats,omitempty"`
+}
+
+// New creates an empty report for the given mode
+func New(mode string) *Report {
+	return &Report{
+		SchemaVersion: SchemaVersion,
+		Tool:          Tool{Name: version.Name, Version: version.Version},
+		Mode:          mode,
+		Files:         []FileReport{},
+	}
+}
+
+// AddInlineResult records the outcome of inlining a single file
+func (r *Report) AddInlineResult(path string, result *inliner.InlineResult) {
+	file := FileReport{
+		Path:     path,
+		Status:   "ok",
+		Stats:    statsFromResult(result),
+		Size:     sizeFromResult(result),
+		Findings: make([]Finding, 0, len(result.Warnings)),
+	}
+
+	for _, warning := range result.Warnings {
+		file.Findings = append(file.Findings, FindingFromWarning(path, warning))
+	}
+
+	for _, fix := range result.Fixes {
+		file.Fixes = append(file.Fixes, Fix{
+			RuleID:      fix.Rule,
+			Element:     fix.Element,
+			Description: fix.Description,
+			Location:    Location{Path: path, Line: fix.Line},
+		})
+	}
+
+	r.Files = append(r.Files, file)
+}
+
+// AddValidation records the validation issues found in a single file
+func (r *Report) AddValidation(path string, issues []inliner.ValidationIssue) {
+	file := FileReport{
+		Path:     path,
+		Status:   "ok",
+		Findings: make([]Finding, 0, len(issues)),
+	}
+
+	for _, issue := range issues {
+		file.Findings = append(file.Findings, FindingFromIssue(path, issue))
+	}
+
+	r.Files = append(r.Files, file)
+}
+
+// AddFailure records a file that could not be read, processed or written
+func (r *Report) AddFailure(path string, err error) {
+	r.Files = append(r.Files, FileReport{
+		Path:     path,
+		Status:   "failed",
+		Error:    err.Error(),
+		Findings: []Finding{},
+	})
+}
+
+// FindingFromWarning converts a computed-style warning to a Finding
+func FindingFromWarning(path string, warning inliner.ValidationWarning) Finding {
+	return Finding{
+		RuleID:   ruleID(warning.Rule),
+		Severity: warning.Severity,
+		Category: "css",
+		Message:  warning.Message,
+		Element:  warning.Element,
+		Property: warning.Property,
+		Value:    warning.Value,
+		Location: Location{Path: path, Line: warning.Line},
+	}
+}
+
+// FindingFromIssue converts a validation issue to a Finding
+func FindingFromIssue(path string, issue inliner.ValidationIssue) Finding {
+	return Finding{
+		RuleID:    ruleID(issue.Rule),
+		Severity:  issue.Severity,
+		Category:  issue.Type,
+		Message:   issue.Message,
+		Element:   issue.Element,
+		Property:  issue.Property,
+		Reference: issue.Reference,
+		Location:  Location{Path: path, Line: issue.Line},
+	}
+}
+
+// ruleID falls back to a fixed identifier for findings produced without one
+func ruleID(id string) string {
+	if id == "" {
+		return "unknown"
+	}
+	return id
+}
+
+// statsFromResult extracts report statistics from an inline result
+func statsFromResult(result *inliner.InlineResult) *Stats {
+	return &Stats{
+		InlinedStyles:         result.InlinedStyles,
+		PreservedRules:        result.PreservedRules,
+		CSSRulesParsed:        result.ProcessingStats.CSSRulesParsed,
+		CSSRulesRemoved:       result.ProcessingStats.CSSRulesRemoved,
+		HTMLElementsProcessed: result.ProcessingStats.HTMLElementsProcessed,
+		SelectorsMatched:      result.ProcessingStats.SelectorsMatched,
+		ProcessingTimeMs:      result.ProcessingStats.ProcessingTimeMs,
+	}
+}
+
+// sizeFromResult extracts the size report from an inline result
+func sizeFromResult(result *inliner.InlineResult) *Size {
+	breakdown := result.Size.Breakdown
+	size := &Size{
+		TotalBytes: result.Size.TotalBytes,
+		StyleBytes: result.Size.StyleBytes,
+		Breakdown: SizeBreakdown{
+			InlineStyles:          breakdown.InlineStyles,
+			DuplicateInlineStyles: breakdown.DuplicateInlineStyles,
+			PreservedCSS:          breakdown.PreservedCSS,
+			Comments:              breakdown.Comments,
+			ConditionalComments:   breakdown.ConditionalComments,
+			Whitespace:            breakdown.Whitespace,
+			DataURIs:              breakdown.DataURIs,
+		},
+		Limits: []SizeLimit{},
+	}
+
+	for _, limit := range result.Size.Limits {
+		size.Limits = append(size.Limits, SizeLimit{
+			Client:   limit.Client,
+			Kind:     limit.Kind,
+			Limit:    limit.Limit,
+			Actual:   limit.Actual,
+			Exceeded: limit.Exceeded,
+		})
+	}
+
+	return size
+}
+
+// Finalize computes the summary from the recorded files
+func (r *Report) Finalize() {
+	summary := Summary{Files: len(r.Files)}
+
+	var total *Stats
+	for _, file := range r.Files {
+		if file.Status != "ok" {
+			summary.FailedFiles++
+		}
+
+		for _, finding := range file.Findings {
+			switch finding.Severity {
+			case "error":
+				summary.Errors++
+			case "warning":
+				summary.Warnings++
+			default:
+				summary.Info++
+			}
+		}
+
+		if file.Stats != nil {
+			if total == nil {
+				total = &Stats{}
+			}
+			total.InlinedStyles += file.Stats.InlinedStyles
+			total.PreservedRules += file.Stats.PreservedRules
+			total.CSSRulesParsed += file.Stats.CSSRulesParsed
+			total.CSSRulesRemoved += file.Stats.CSSRulesRemoved
+			total.HTMLElementsProcessed += file.Stats.HTMLElementsProcessed
+			total.SelectorsMatched += file.Stats.SelectorsMatched
+			total.ProcessingTimeMs += file.Stats.ProcessingTimeMs
+		}
+	}
+
+	summary.Stats = total
+	r.Summary = summary
+}
+
+// Write serializes the report in the requested format. Text output is produced
+// by the CLI directly and is not handled here.
+func Write(w io.Writer, r *Report, format Format) error {
+	r.Finalize()
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case FormatSARIF:
+		return writeSARIF(w, r)
+	case FormatJUnit:
+		return writeJUnit(w, r)
+	default:
+		return fmt.Errorf("unsupported report format: %s", format)
+	}
+}
+
+// ruleIDs returns the distinct rule IDs in the report, sorted
+func (r *Report) ruleIDs() []string {
+	seen := make(map[string]bool)
+	var ids []string
+
+	for _, file := range r.Files {
+		for _, finding := range file.Findings {
+			if !seen[finding.RuleID] {
+				seen[finding.RuleID] = true
+				ids = append(ids, finding.RuleID)
+			}
+		}
+	}
+
+	sort.Strings(ids)
+	return ids
+}
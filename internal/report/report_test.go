@@ -0,0 +1,21 @@
+package report
+
+import "testing"
+
+func TestFinalizeSumsStats(t *testing.T) {
+	r := New("inline")
+	r.Files = []FileReport{
+		{Path: "a.html", Status: "ok", Stats: &Stats{InlinedStyles: 2, CSSRulesParsed: 3, CSSRulesRemoved: 1}},
+		{Path: "b.html", Status: "ok", Stats: &Stats{InlinedStyles: 1, CSSRulesParsed: 4, CSSRulesRemoved: 2}},
+		{Path: "c.html", Status: "failed", Error: "unreadable"},
+	}
+	r.Finalize()
+
+	want := Stats{InlinedStyles: 3, CSSRulesParsed: 7, CSSRulesRemoved: 3}
+	if r.Summary.Stats == nil || *r.Summary.Stats != want {
+		t.Errorf("Summary.Stats = %+v, want %+v", r.Summary.Stats, want)
+	}
+	if r.Summary.Files != 3 || r.Summary.FailedFiles != 1 {
+		t.Errorf("Summary files = %d, failed = %d, want 3 and 1", r.Summary.Files, r.Summary.FailedFiles)
+	}
+}
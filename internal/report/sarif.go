@@ -0,0 +1,190 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// SARIF 2.1.0 output, the format consumed by code-scanning UIs
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Results     []sarifResult     `json:"results"`
+	Invocations []sarifInvocation `json:"invocations,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version"`
+	Rules   []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string              `json:"id"`
+	ShortDescription     sarifMessage        `json:"shortDescription"`
+	FullDescription      *sarifMessage       `json:"fullDescription,omitempty"`
+	DefaultConfiguration *sarifConfiguration `json:"defaultConfiguration,omitempty"`
+	Properties           *sarifProperties    `json:"properties,omitempty"`
+}
+
+type sarifProperties struct {
+	Tags []string `json:"tags,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+// writeSARIF serializes the report as a single SARIF run
+func writeSARIF(w io.Writer, r *Report) error {
+	ids := r.ruleIDs()
+	ruleIndex := make(map[string]int, len(ids))
+	rules := make([]sarifRule, len(ids))
+	for i, id := range ids {
+		ruleIndex[id] = i
+		rules[i] = sarifRule{ID: id, ShortDescription: sarifMessage{Text: describeRule(r, id)}}
+		if doc, ok := r.RuleDocs[id]; ok {
+			if doc.Docs != "" {
+				rules[i].FullDescription = &sarifMessage{Text: doc.Docs}
+			}
+			if doc.Severity != "" {
+				rules[i].DefaultConfiguration = &sarifConfiguration{Level: sarifLevel(doc.Severity)}
+			}
+			var tags []string
+			for _, tag := range []string{doc.Category, doc.Reference} {
+				if tag != "" {
+					tags = append(tags, tag)
+				}
+			}
+			if len(tags) > 0 {
+				rules[i].Properties = &sarifProperties{Tags: tags}
+			}
+		}
+	}
+
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: r.Tool.Name, Version: r.Tool.Version, Rules: rules}},
+		Results: []sarifResult{},
+	}
+
+	invocation := sarifInvocation{ExecutionSuccessful: r.Summary.FailedFiles == 0}
+
+	for _, file := range r.Files {
+		if file.Status != "ok" {
+			invocation.ToolExecutionNotifications = append(invocation.ToolExecutionNotifications, sarifNotification{
+				Level:     "error",
+				Message:   sarifMessage{Text: file.Error},
+				Locations: []sarifLocation{sarifLocationFor(Location{Path: file.Path})},
+			})
+			continue
+		}
+
+		for _, finding := range file.Findings {
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    finding.RuleID,
+				RuleIndex: ruleIndex[finding.RuleID],
+				Level:     sarifLevel(finding.Severity),
+				Message:   sarifMessage{Text: finding.Message},
+				Locations: []sarifLocation{sarifLocationFor(finding.Location)},
+			})
+		}
+	}
+
+	run.Invocations = []sarifInvocation{invocation}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{run}})
+}
+
+// sarifLocationFor converts a report location to a SARIF location
+func sarifLocationFor(location Location) sarifLocation {
+	physical := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: location.Path}}
+	if location.Line > 0 {
+		physical.Region = &sarifRegion{StartLine: location.Line}
+	}
+	return sarifLocation{PhysicalLocation: physical}
+}
+
+// sarifLevel maps our severities to SARIF result levels
+func sarifLevel(severity string) string {
+	switch severity {
+	case "error":
+		return "error"
+	case "warning":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// describeRule returns a short description for a rule, from its documentation
+// when available, otherwise from the first finding that references it
+func describeRule(r *Report, id string) string {
+	if doc, ok := r.RuleDocs[id]; ok && doc.Description != "" {
+		return doc.Description
+	}
+	for _, file := range r.Files {
+		for _, finding := range file.Findings {
+			if finding.RuleID == id {
+				return finding.Message
+			}
+		}
+	}
+	return id
+}
@@ -54,6 +54,11 @@ func (r *Resolver) findMatchingRules(node html.Node) ([]css.MatchResult, error)
 	var matches []css.MatchResult
 
 	for _, rule := range r.stylesheet.Rules {
+		// At-rules and rules inside @media/@supports never apply inline
+		if rule.IsAtRule() || rule.IsConditional() {
+			continue
+		}
+
 		// Check if the selector matches this element
 		isMatch, err := node.Matches(rule.Selector)
 		if err != nil {
@@ -319,12 +324,22 @@ func (r *Resolver) ValidateStyles(styles map[string]css.Declaration) []Validatio
 
 	compatibility := config.GetCompatibilityProfile(r.config.TargetEmailClient)
 
-	for property, declaration := range styles {
+	// Walk properties in sorted order so warnings are reported deterministically
+	properties := make([]string, 0, len(styles))
+	for property := range styles {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+
+	for _, property := range properties {
+		declaration := styles[property]
+
 		// Check for problematic property values
 		switch property {
 		case "background-image":
 			if strings.Contains(declaration.Value, "url(") && r.config.TargetEmailClient == "outlook" {
 				warnings = append(warnings, ValidationWarning{
+					Rule:     "css-background-image",
 					Property: property,
 					Value:    declaration.Value,
 					Message:  "Background images may not render in Outlook desktop",
@@ -335,6 +350,7 @@ func (r *Resolver) ValidateStyles(styles map[string]css.Declaration) []Validatio
 		case "width", "height":
 			if strings.Contains(declaration.Value, "vw") || strings.Contains(declaration.Value, "vh") {
 				warnings = append(warnings, ValidationWarning{
+					Rule:     "css-viewport-units",
 					Property: property,
 					Value:    declaration.Value,
 					Message:  "Viewport units not supported in email clients",
@@ -344,11 +360,13 @@ func (r *Resolver) ValidateStyles(styles map[string]css.Declaration) []Validatio
 
 		case "position":
 			if declaration.Value != "static" && compatibility.RequiresInlineStyles {
+				rule, severity := positionRule(declaration.Value)
 				warnings = append(warnings, ValidationWarning{
+					Rule:     rule,
 					Property: property,
 					Value:    declaration.Value,
 					Message:  "Positioning not supported in this email client",
-					Severity: "warning",
+					Severity: severity,
 				})
 			}
 		}
@@ -356,6 +374,7 @@ func (r *Resolver) ValidateStyles(styles map[string]css.Declaration) []Validatio
 		// Check for email-unsafe properties
 		if !css.IsEmailSafeProperty(property) {
 			warnings = append(warnings, ValidationWarning{
+				Rule:     "css-unsafe-property",
 				Property: property,
 				Value:    declaration.Value,
 				Message:  "Property may not be supported across all email clients",
@@ -367,8 +386,21 @@ func (r *Resolver) ValidateStyles(styles map[string]css.Declaration) []Validatio
 	return warnings
 }
 
+// positionRule returns the rule ID and severity for a position value, the
+// same ones the rules registry reports for it in <style> tags
+func positionRule(value string) (string, string) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "fixed", "sticky":
+		return "css-position-fixed", "error"
+	case "absolute":
+		return "css-position-absolute", "warning"
+	}
+	return "css-position-relative", "warning"
+}
+
 // ValidationWarning represents a potential issue with computed styles
 type ValidationWarning struct {
+	Rule     string // Stable rule identifier, e.g. "css-viewport-units"
 	Property string
 	Value    string
 	Message  string
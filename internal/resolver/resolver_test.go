@@ -0,0 +1,65 @@
+package resolver
+
+import (
+	"testing"
+
+	"inliner/internal/config"
+	"inliner/internal/css"
+	"inliner/internal/html"
+)
+
+// resolve parses a document and stylesheet and resolves the styles of the
+// element matching selector
+func resolve(t *testing.T, cssText, markup, selector string) map[string]css.Declaration {
+	t.Helper()
+	stylesheet, err := css.NewParser().Parse(cssText)
+	if err != nil {
+		t.Fatalf("Parse CSS: %v", err)
+	}
+	doc, err := html.NewParser().Parse(markup)
+	if err != nil {
+		t.Fatalf("Parse HTML: %v", err)
+	}
+	node, err := doc.QuerySelector(selector)
+	if err != nil {
+		t.Fatalf("QuerySelector(%q): %v", selector, err)
+	}
+	styles, err := New(stylesheet, config.Default()).ResolveStyles(node)
+	if err != nil {
+		t.Fatalf("ResolveStyles: %v", err)
+	}
+	return styles
+}
+
+// Rules in @media and @supports only apply under their condition, so they
+// stay in <style> tags instead of being inlined. Before the parser tracked
+// conditional groups, the second rule in a @media block was read as a
+// top-level rule and its color was inlined.
+func TestConditionalRulesNotInlined(t *testing.T) {
+	styles := resolve(t, `
+.a { color: red }
+@media (max-width: 600px) { .b { color: blue } .a { color: green } }
+@supports (display: grid) { .a { font-weight: bold } }
+`, `<html><body><p class="a b">text</p></body></html>`, "p")
+
+	if got := styles["color"].Value; got != "red" {
+		t.Errorf("color = %q, want red from the unconditional rule", got)
+	}
+	if _, ok := styles["font-weight"]; ok {
+		t.Errorf("font-weight from @supports was inlined")
+	}
+}
+
+func TestAtRulesNotInlined(t *testing.T) {
+	styles := resolve(t, `
+@font-face { font-family: Brand; src: url(brand.woff) }
+p { font-family: Brand, Arial }
+`, `<html><body><p>text</p></body></html>`, "p")
+
+	if got := styles["font-family"].Value; got != "Brand, Arial" {
+		t.Errorf("font-family = %q, want the rule's value", got)
+	}
+	if _, ok := styles["src"]; ok {
+		t.Errorf("@font-face src was inlined")
+	}
+}
@@ -0,0 +1,383 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"inliner/internal/css"
+	"inliner/internal/html"
+)
+
+// accessibilityRules returns the accessibility audit. Each rule references the
+// WCAG 2.1 success criterion it helps satisfy.
+func accessibilityRules() []Rule {
+	return []Rule{
+		{
+			ID:              "a11y-img-alt",
+			Category:        "accessibility",
+			DefaultSeverity: SeverityError,
+			Description:     "Images must have an alt attribute",
+			Docs: "Screen readers announce the file name of images without alt, and most clients " +
+				"block images by default, showing the alt text instead. Use alt=\"\" for decorative images.",
+			Reference: "WCAG 2.1 SC 1.1.1",
+			Check: func(ctx *Context) []Issue {
+				var issues []Issue
+				for _, img := range ctx.Elements("img") {
+					if _, ok := img.Attributes()["alt"]; !ok {
+						issues = append(issues, Issue{
+							Message: "<img> has no alt attribute; use alt=\"\" if it is decorative",
+							Element: "img",
+							Line:    img.SourceLine(),
+						})
+					}
+				}
+				return issues
+			},
+		},
+		{
+			ID:              "a11y-img-alt-quality",
+			Category:        "accessibility",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Image alt text should describe the image",
+			Docs: "Alt text that repeats the file name, says only \"image\", or runs to a paragraph " +
+				"does not help someone who cannot see the image.",
+			Reference: "WCAG 2.1 SC 1.1.1",
+			Options: map[string]string{
+				"max-length": "125",
+				"generic":    "image,img,photo,picture,graphic,banner,logo,icon,spacer,pixel,untitled",
+			},
+			Check: func(ctx *Context) []Issue {
+				maxLength := ctx.IntOption("max-length")
+				generic := make(map[string]bool)
+				for _, word := range ctx.ListOption("generic") {
+					generic[strings.ToLower(word)] = true
+				}
+
+				var issues []Issue
+				for _, img := range ctx.Elements("img[alt]") {
+					attrs := img.Attributes()
+					alt := strings.TrimSpace(attrs["alt"])
+					if alt == "" {
+						continue
+					}
+
+					message := ""
+					lower := strings.ToLower(alt)
+					switch {
+					case generic[lower]:
+						message = fmt.Sprintf("alt text %q is too generic to describe the image", alt)
+					case looksLikeFilename(lower) || lower == strings.ToLower(path.Base(attrs["src"])):
+						message = fmt.Sprintf("alt text %q is a file name", alt)
+					case maxLength > 0 && len(alt) > maxLength:
+						message = fmt.Sprintf("alt text is %d characters; keep it under %d", len(alt), maxLength)
+					}
+
+					if message != "" {
+						issues = append(issues, Issue{Message: message, Element: "img", Line: img.SourceLine()})
+					}
+				}
+				return issues
+			},
+		},
+		{
+			ID:              "a11y-html-lang",
+			Category:        "accessibility",
+			DefaultSeverity: SeverityError,
+			Description:     "<html> must declare the content language",
+			Docs: "Screen readers pick pronunciation rules from the lang attribute. Without it " +
+				"they read the email using the reader's default voice, which garbles other languages.",
+			Reference: "WCAG 2.1 SC 3.1.1",
+			Check: func(ctx *Context) []Issue {
+				root := ctx.Document.Root()
+				if strings.TrimSpace(root.Attributes()["lang"]) != "" {
+					return nil
+				}
+				return []Issue{{Message: "<html> has no lang attribute", Element: "html", Line: root.SourceLine()}}
+			},
+		},
+		{
+			ID:              "a11y-html-dir",
+			Category:        "accessibility",
+			DefaultSeverity: SeverityInfo,
+			Description:     "<html> should declare the text direction",
+			Docs: "Some webmail clients render the email inside their own right-to-left UI. An explicit " +
+				"dir attribute keeps the reading order of the content correct.",
+			Reference: "WCAG 2.1 SC 1.3.2",
+			Check: func(ctx *Context) []Issue {
+				root := ctx.Document.Root()
+				if strings.TrimSpace(root.Attributes()["dir"]) != "" {
+					return nil
+				}
+				return []Issue{{Message: "<html> has no dir attribute", Element: "html", Line: root.SourceLine()}}
+			},
+		},
+		{
+			ID:              "a11y-layout-table-role",
+			Category:        "accessibility",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Layout tables should have role=\"presentation\"",
+			Docs: "Screen readers announce row and column counts for every table. Tables used only " +
+				"for layout should carry role=\"presentation\" so they are read as plain content.",
+			Reference: "WCAG 2.1 SC 1.3.1",
+			Check: func(ctx *Context) []Issue {
+				var issues []Issue
+				for _, table := range ctx.Elements("table") {
+					if IsDataTable(table) {
+						continue
+					}
+					role := strings.ToLower(strings.TrimSpace(table.Attributes()["role"]))
+					if role != "presentation" && role != "none" {
+						issues = append(issues, Issue{
+							Message: "Layout table is missing role=\"presentation\"",
+							Element: "table",
+							Line:    table.SourceLine(),
+						})
+					}
+				}
+				return issues
+			},
+		},
+		{
+			ID:              "a11y-heading-order",
+			Category:        "accessibility",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Heading levels should not skip",
+			Docs: "Screen reader users navigate by headings. Jumping from <h1> to <h3> suggests " +
+				"missing structure and makes the outline confusing.",
+			Reference: "WCAG 2.1 SC 1.3.1",
+			Check: func(ctx *Context) []Issue {
+				var issues []Issue
+				previous := 0
+				for _, heading := range ctx.Elements("h1, h2, h3, h4, h5, h6") {
+					tag := strings.ToLower(heading.TagName())
+					level, _ := strconv.Atoi(tag[1:])
+					if previous > 0 && level > previous+1 {
+						issues = append(issues, Issue{
+							Message: fmt.Sprintf("Heading level skips from h%d to h%d", previous, level),
+							Element: tag,
+							Line:    heading.SourceLine(),
+						})
+					} else if previous == 0 && level > 2 {
+						issues = append(issues, Issue{
+							Message: fmt.Sprintf("First heading is h%d; start with h1 or h2", level),
+							Element: tag,
+							Line:    heading.SourceLine(),
+						})
+					}
+					previous = level
+				}
+				return issues
+			},
+		},
+		{
+			ID:              "a11y-link-text",
+			Category:        "accessibility",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Link text should describe the destination",
+			Docs: "Screen reader users often list all links out of context. \"Click here\" and " +
+				"\"read more\" give no hint of where a link goes.",
+			Reference: "WCAG 2.1 SC 2.4.4",
+			Options: map[string]string{
+				"phrases": "click here,here,click,read more,more,learn more,link,this link,go,details,continue",
+			},
+			Check: func(ctx *Context) []Issue {
+				vague := make(map[string]bool)
+				for _, phrase := range ctx.ListOption("phrases") {
+					vague[strings.ToLower(phrase)] = true
+				}
+
+				var issues []Issue
+				for _, link := range ctx.Elements("a[href]") {
+					text := normalizeText(link.Text())
+					if vague[strings.Trim(text, ".!?:>» ")] {
+						issues = append(issues, Issue{
+							Message: fmt.Sprintf("Link text %q does not describe the destination", text),
+							Element: "a",
+							Line:    link.SourceLine(),
+						})
+					}
+				}
+				return issues
+			},
+		},
+		{
+			ID:              "a11y-link-name",
+			Category:        "accessibility",
+			DefaultSeverity: SeverityError,
+			Description:     "Links must have an accessible name",
+			Docs: "A link with no text and no image alt text is announced as just \"link\" " +
+				"or by its URL. Give image links meaningful alt text or add aria-label.",
+			Reference: "WCAG 2.1 SC 2.4.4",
+			Check: func(ctx *Context) []Issue {
+				var issues []Issue
+				for _, link := range ctx.Elements("a[href]") {
+					attrs := link.Attributes()
+					if normalizeText(link.Text()) != "" || strings.TrimSpace(attrs["aria-label"]) != "" ||
+						strings.TrimSpace(attrs["title"]) != "" {
+						continue
+					}
+					if linkImagesHaveAlt(link) {
+						continue
+					}
+					issues = append(issues, Issue{
+						Message: "Link has no text, aria-label or image alt text",
+						Element: "a",
+						Line:    link.SourceLine(),
+					})
+				}
+				return issues
+			},
+		},
+		{
+			ID:              "a11y-title",
+			Category:        "accessibility",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Document should have a non-empty <title>",
+			Docs: "Screen readers announce the title when the email is opened in a browser or " +
+				"a \"view online\" page, and some clients show it in the window title.",
+			Reference: "WCAG 2.1 SC 2.4.2",
+			Check: func(ctx *Context) []Issue {
+				titles := ctx.Elements("title")
+				if len(titles) > 0 && strings.TrimSpace(titles[0].Text()) != "" {
+					return nil
+				}
+				return []Issue{{Message: "Missing or empty <title>", Element: "head"}}
+			},
+		},
+		{
+			ID:              "a11y-font-size",
+			Category:        "accessibility",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Text should not be smaller than the minimum font size",
+			Docs: "Small text is hard to read for people with low vision, and iOS enlarges text under " +
+				"13px on its own, breaking layouts. Compute sizes in px from inline and embedded CSS.",
+			Reference: "WCAG 2.1 SC 1.4.4",
+			Options:   map[string]string{"min-size": "13"},
+			Check: func(ctx *Context) []Issue {
+				minSize := float64(ctx.IntOption("min-size"))
+				var issues []Issue
+				for _, styled := range ctx.StyledElements() {
+					if !hasOwnText(styled) || styled.FontSize >= minSize {
+						continue
+					}
+					issues = append(issues, Issue{
+						Message:  fmt.Sprintf("Font size %.4gpx is below the %gpx minimum", styled.FontSize, minSize),
+						Element:  styled.Tag,
+						Property: "font-size",
+						Line:     styled.Node.SourceLine(),
+					})
+				}
+				return issues
+			},
+		},
+		{
+			ID:              "a11y-contrast",
+			Category:        "accessibility",
+			DefaultSeverity: SeverityError,
+			Description:     "Text must have sufficient color contrast",
+			Docs: "WCAG AA requires a contrast ratio of at least 4.5:1 for normal text and 3:1 for " +
+				"large text (24px, or 18.66px bold). Colors are resolved from inline styles, embedded " +
+				"CSS and bgcolor attributes, walking ancestors to find the background.",
+			Reference: "WCAG 2.1 SC 1.4.3",
+			Options:   map[string]string{"min-ratio": "4.5", "min-ratio-large": "3"},
+			Check: func(ctx *Context) []Issue {
+				minRatio, _ := strconv.ParseFloat(ctx.Option("min-ratio"), 64)
+				minRatioLarge, _ := strconv.ParseFloat(ctx.Option("min-ratio-large"), 64)
+
+				var issues []Issue
+				for _, styled := range ctx.StyledElements() {
+					if !hasOwnText(styled) {
+						continue
+					}
+
+					threshold := minRatio
+					if styled.FontSize >= 24 || (styled.Bold && styled.FontSize >= 18.66) {
+						threshold = minRatioLarge
+					}
+
+					ratio := css.ContrastRatio(styled.Color, styled.Background)
+					if ratio < threshold {
+						issues = append(issues, Issue{
+							Message: fmt.Sprintf("Contrast ratio %.2f:1 (%s on %s) is below %.4g:1",
+								ratio, styled.Color.Hex(), styled.Background.Hex(), threshold),
+							Element:  styled.Tag,
+							Property: "color",
+							Line:     styled.Node.SourceLine(),
+						})
+					}
+				}
+				return issues
+			},
+		},
+	}
+}
+
+// looksLikeFilename reports whether alt text is an image file name
+func looksLikeFilename(alt string) bool {
+	for _, ext := range []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg", ".bmp"} {
+		if strings.HasSuffix(alt, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDataTable decides whether a table holds data rather than layout: it has
+// header cells, a caption, a summary or an explicit table role
+func IsDataTable(table html.Node) bool {
+	attrs := table.Attributes()
+	role := strings.ToLower(attrs["role"])
+	if role == "table" || role == "grid" {
+		return true
+	}
+	if _, ok := attrs["summary"]; ok {
+		return true
+	}
+	return hasHeaderCells(table)
+}
+
+// hasHeaderCells reports whether a table has <th>, <thead> or <caption>
+// outside any nested table
+func hasHeaderCells(node html.Node) bool {
+	for _, child := range node.Children() {
+		switch strings.ToLower(child.TagName()) {
+		case "th", "thead", "caption":
+			return true
+		case "table":
+			continue
+		}
+		if hasHeaderCells(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkImagesHaveAlt reports whether a link contains an image with alt text
+func linkImagesHaveAlt(node html.Node) bool {
+	for _, child := range node.Children() {
+		if strings.EqualFold(child.TagName(), "img") && strings.TrimSpace(child.Attributes()["alt"]) != "" {
+			return true
+		}
+		if linkImagesHaveAlt(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeText collapses whitespace and lowercases text
+func normalizeText(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// hasOwnText reports whether the element directly contains visible text
+func hasOwnText(styled StyledElement) bool {
+	switch styled.Tag {
+	case "script", "style", "title", "head":
+		return false
+	}
+	return strings.TrimSpace(styled.Node.OwnText()) != ""
+}
@@ -0,0 +1,94 @@
+package rules
+
+import (
+	"strings"
+
+	"inliner/internal/css"
+)
+
+// builtinRules returns the default email ruleset in registration order
+func builtinRules() []Rule {
+	var rules []Rule
+	rules = append(rules, structureRules()...)
+	rules = append(rules, cssRules()...)
+	rules = append(rules, accessibilityRules()...)
+	rules = append(rules, darkModeRules()...)
+	rules = append(rules, inlineRules()...)
+	return rules
+}
+
+// elementRule builds a rule that reports every element matching selector
+func elementRule(id, category, severity, description, docs, selector, message string) Rule {
+	return Rule{
+		ID:              id,
+		Category:        category,
+		DefaultSeverity: severity,
+		Description:     description,
+		Docs:            docs,
+		Check: func(ctx *Context) []Issue {
+			var issues []Issue
+			for _, element := range ctx.Elements(selector) {
+				issues = append(issues, Issue{
+					Message: message,
+					Element: strings.ToLower(element.TagName()),
+					Line:    element.SourceLine(),
+				})
+			}
+			return issues
+		},
+	}
+}
+
+// declarationRule builds a rule that checks every declaration in <style> tags
+// and inline styles. match returns the message to report, or "" for no issue.
+func declarationRule(id, severity, description, docs string, match func(declaration css.Declaration) string) Rule {
+	return Rule{
+		ID:              id,
+		Category:        "css",
+		DefaultSeverity: severity,
+		Description:     description,
+		Docs:            docs,
+		Check: func(ctx *Context) []Issue {
+			var issues []Issue
+			ctx.EachDeclaration(func(site DeclarationSite) {
+				if message := match(site.Declaration); message != "" {
+					issues = append(issues, Issue{
+						Message:  message,
+						Element:  site.Element,
+						Property: site.Declaration.Property,
+						Line:     site.Line,
+					})
+				}
+			})
+			return issues
+		},
+	}
+}
+
+// atRuleRule builds a rule that reports every at-rule whose prelude starts with one of names
+func atRuleRule(id, severity, description, docs, message string, names ...string) Rule {
+	return Rule{
+		ID:              id,
+		Category:        "css",
+		DefaultSeverity: severity,
+		Description:     description,
+		Docs:            docs,
+		Check: func(ctx *Context) []Issue {
+			var issues []Issue
+			ctx.EachRule(func(sheet Sheet, rule css.Rule) {
+				selector := strings.ToLower(rule.Selector)
+				for _, name := range names {
+					if strings.HasPrefix(selector, name) {
+						issues = append(issues, Issue{
+							Message: message,
+							Element: "style",
+							Line:    ctx.RuleLine(sheet, rule),
+						})
+						return
+					}
+				}
+			})
+			return issues
+		},
+	}
+}
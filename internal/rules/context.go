@@ -0,0 +1,325 @@
+package rules
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"inliner/internal/config"
+	"inliner/internal/css"
+	"inliner/internal/html"
+	"inliner/internal/resolver"
+)
+
+// Context gives rules access to the parsed document and CSS
+type Context struct {
+	Document html.Document
+	Sheets   []Sheet // One per <style> tag, in document order
+	Config   config.Config
+
+	options map[string]string
+	styled  []StyledElement
+}
+
+// Sheet is the parsed content of a single <style> tag
+type Sheet struct {
+	Node       html.Node
+	Text       string
+	Stylesheet *css.Stylesheet
+}
+
+// DeclarationSite is a declaration together with where it was found
+type DeclarationSite struct {
+	Declaration css.Declaration
+	Rule        *css.Rule // Nil for inline style attributes
+	Element     string    // "style" for stylesheet rules, the tag name for inline styles
+	Line        int
+}
+
+// NewContext parses every <style> tag in the document and builds a rule context
+func NewContext(doc html.Document, parser *css.Parser, cfg config.Config) (*Context, error) {
+	ctx := &Context{Document: doc, Config: cfg}
+
+	styleTags, err := doc.GetStyleTags()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, styleTag := range styleTags {
+		text := styleTag.Text()
+		stylesheet, err := parser.Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		ctx.Sheets = append(ctx.Sheets, Sheet{Node: styleTag, Text: text, Stylesheet: stylesheet})
+	}
+
+	return ctx, nil
+}
+
+// Option returns the value of a rule option
+func (c *Context) Option(name string) string {
+	return c.options[name]
+}
+
+// IntOption returns a rule option parsed as an integer, 0 if it isn't one
+func (c *Context) IntOption(name string) int {
+	value, _ := strconv.Atoi(strings.TrimSpace(c.options[name]))
+	return value
+}
+
+// ListOption returns a comma-separated rule option as a list
+func (c *Context) ListOption(name string) []string {
+	var values []string
+	for _, value := range strings.Split(c.options[name], ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Elements returns all elements matching selector
+func (c *Context) Elements(selector string) []html.Node {
+	nodes, err := c.Document.QuerySelectorAll(selector)
+	if err != nil {
+		return nil
+	}
+	return nodes
+}
+
+// RuleLine maps a CSS rule to its line in the HTML source
+func (c *Context) RuleLine(sheet Sheet, rule css.Rule) int {
+	line := sheet.Node.SourceLine()
+	if line == 0 || rule.Line == 0 {
+		return line
+	}
+	// The style tag's text starts on the tag's own line
+	return line + rule.Line - 1
+}
+
+// EachDeclaration calls fn for every declaration in <style> tags and inline
+// style attributes, in document order with properties sorted within a rule
+func (c *Context) EachDeclaration(fn func(site DeclarationSite)) {
+	for _, sheet := range c.Sheets {
+		for i := range sheet.Stylesheet.Rules {
+			rule := &sheet.Stylesheet.Rules[i]
+			line := c.RuleLine(sheet, *rule)
+			for _, declaration := range sortedDeclarations(rule.Declarations) {
+				fn(DeclarationSite{Declaration: declaration, Rule: rule, Element: "style", Line: line})
+			}
+		}
+	}
+
+	for _, element := range c.Elements("[style]") {
+		tagName := strings.ToLower(element.TagName())
+		line := element.SourceLine()
+		for _, declaration := range sortedDeclarations(element.GetInlineStyle()) {
+			fn(DeclarationSite{Declaration: declaration, Element: tagName, Line: line})
+		}
+	}
+}
+
+// EachRule calls fn for every rule in every <style> tag
+func (c *Context) EachRule(fn func(sheet Sheet, rule css.Rule)) {
+	for _, sheet := range c.Sheets {
+		for _, rule := range sheet.Stylesheet.Rules {
+			fn(sheet, rule)
+		}
+	}
+}
+
+// StyledElement is a body element with its resolved visual styles. Colors and
+// sizes are inherited from ancestors the way a browser would compute them.
+type StyledElement struct {
+	Node       html.Node
+	Tag        string
+	Styles     map[string]css.Declaration // Styles resolved for this element alone
+	Color      css.Color                  // Opaque text color after blending
+	Background css.Color                  // Opaque background, from the nearest ancestor that sets one
+	FontSize   float64                    // Computed font size in px
+	Bold       bool
+}
+
+// defaultFontSize is the root font size clients use when none is set
+const defaultFontSize = 16
+
+// headingScale is the user-agent font size of headings relative to their parent
+var headingScale = map[string]float64{
+	"h1": 2, "h2": 1.5, "h3": 1.17, "h4": 1, "h5": 0.83, "h6": 0.67, "small": 0.83,
+}
+
+// StyledElements resolves styles for every element in <body>, in document
+// order. The result is computed once per context and shared by all rules.
+func (c *Context) StyledElements() []StyledElement {
+	if c.styled != nil {
+		return c.styled
+	}
+
+	styleResolver := resolver.New(c.combinedStylesheet(), c.Config)
+	parent := StyledElement{
+		Color:      css.Color{A: 1},
+		Background: css.Color{R: 255, G: 255, B: 255, A: 1},
+		FontSize:   defaultFontSize,
+	}
+
+	c.styled = []StyledElement{}
+	var walk func(node html.Node, parent StyledElement)
+	walk = func(node html.Node, parent StyledElement) {
+		for _, child := range node.Children() {
+			styled := c.styleElement(child, parent, styleResolver)
+			c.styled = append(c.styled, styled)
+			walk(child, styled)
+		}
+	}
+
+	body := c.Document.Body()
+	if body.TagName() != "" {
+		root := c.styleElement(body, parent, styleResolver)
+		c.styled = append(c.styled, root)
+		walk(body, root)
+	}
+
+	return c.styled
+}
+
+// styleElement computes an element's visual styles from its own declarations,
+// presentational attributes and its parent's computed values
+func (c *Context) styleElement(node html.Node, parent StyledElement, styleResolver *resolver.Resolver) StyledElement {
+	tag := strings.ToLower(node.TagName())
+	styles, err := styleResolver.ResolveStyles(node)
+	if err != nil {
+		styles = node.GetInlineStyle()
+	}
+	attrs := node.Attributes()
+
+	styled := StyledElement{
+		Node:       node,
+		Tag:        tag,
+		Styles:     styles,
+		Color:      parent.Color,
+		Background: parent.Background,
+		FontSize:   parent.FontSize,
+		Bold:       parent.Bold,
+	}
+
+	// Background: CSS first, then the bgcolor attribute still common in email
+	if declaration, ok := styles["background-color"]; ok {
+		if color, ok := css.BackgroundColor(declaration.Value); ok && color.A > 0 {
+			styled.Background = color.Blend(parent.Background)
+		}
+	} else if declaration, ok := styles["background"]; ok {
+		if color, ok := css.BackgroundColor(declaration.Value); ok && color.A > 0 {
+			styled.Background = color.Blend(parent.Background)
+		}
+	} else if bgcolor, ok := attrs["bgcolor"]; ok {
+		if color, ok := css.ParseColor(bgcolor); ok {
+			styled.Background = color.Blend(parent.Background)
+		}
+	}
+
+	// Text color: CSS, then <font color>, then the default link color
+	if declaration, ok := styles["color"]; ok {
+		if color, ok := css.ParseColor(declaration.Value); ok {
+			styled.Color = color.Blend(styled.Background)
+		}
+	} else if fontColor, ok := attrs["color"]; ok && tag == "font" {
+		if color, ok := css.ParseColor(fontColor); ok {
+			styled.Color = color.Blend(styled.Background)
+		}
+	} else if tag == "a" {
+		styled.Color = css.Color{R: 0, G: 0, B: 0xee, A: 1}
+	}
+
+	// Font size, relative to the parent for em and percentages
+	if scale, ok := headingScale[tag]; ok {
+		styled.FontSize = parent.FontSize * scale
+	}
+	if declaration, ok := styles["font-size"]; ok {
+		if size, ok := fontSizePx(declaration.Value, parent.FontSize); ok {
+			styled.FontSize = size
+		}
+	}
+
+	// Weight: bold tags, then font-weight
+	switch tag {
+	case "b", "strong", "th", "h1", "h2", "h3", "h4", "h5", "h6":
+		styled.Bold = true
+	}
+	if declaration, ok := styles["font-weight"]; ok {
+		weight := strings.ToLower(strings.TrimSpace(declaration.Value))
+		switch weight {
+		case "bold", "bolder":
+			styled.Bold = true
+		case "normal", "lighter":
+			styled.Bold = false
+		default:
+			if n, err := strconv.Atoi(weight); err == nil {
+				styled.Bold = n >= 600
+			}
+		}
+	}
+
+	return styled
+}
+
+// fontSizeKeywords maps absolute font-size keywords to px
+var fontSizeKeywords = map[string]float64{
+	"xx-small": 9, "x-small": 10, "small": 13, "medium": 16,
+	"large": 18, "x-large": 24, "xx-large": 32, "xxx-large": 48,
+}
+
+// fontSizePx converts a font-size value to px
+func fontSizePx(value string, parentSize float64) (float64, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if size, ok := fontSizeKeywords[value]; ok {
+		return size, true
+	}
+	switch value {
+	case "smaller":
+		return parentSize / 1.2, true
+	case "larger":
+		return parentSize * 1.2, true
+	}
+
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"px", 1}, {"pt", 4.0 / 3}, {"rem", defaultFontSize}, {"em", parentSize}, {"%", parentSize / 100},
+	}
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(value, unit.suffix), 64)
+			if err != nil {
+				return 0, false
+			}
+			return n * unit.scale, true
+		}
+	}
+	return 0, false
+}
+
+// combinedStylesheet merges every <style> tag into one stylesheet in document order
+func (c *Context) combinedStylesheet() *css.Stylesheet {
+	combined := &css.Stylesheet{}
+	for _, sheet := range c.Sheets {
+		for _, rule := range sheet.Stylesheet.Rules {
+			rule.SourceOrder = len(combined.Rules)
+			combined.Rules = append(combined.Rules, rule)
+		}
+	}
+	return combined
+}
+
+// sortedDeclarations returns declarations ordered by property name
+func sortedDeclarations(declarations map[string]css.Declaration) []css.Declaration {
+	sorted := make([]css.Declaration, 0, len(declarations))
+	for _, declaration := range declarations {
+		sorted = append(sorted, declaration)
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].Property < sorted[b].Property
+	})
+	return sorted
+}
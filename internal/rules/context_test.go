@@ -0,0 +1,156 @@
+package rules
+
+import (
+	"math"
+	"testing"
+
+	"inliner/internal/config"
+	"inliner/internal/css"
+	"inliner/internal/html"
+)
+
+// styledByID returns the computed styles of every element with an id in page
+func styledByID(t *testing.T, page string) map[string]StyledElement {
+	t.Helper()
+	doc, err := html.NewParser().Parse(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := NewContext(doc, css.NewParser(), config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[string]StyledElement{}
+	for _, styled := range ctx.StyledElements() {
+		if id := styled.Node.Attributes()["id"]; id != "" {
+			found[id] = styled
+		}
+	}
+	return found
+}
+
+func TestStyledElementsInheritBackground(t *testing.T) {
+	styled := styledByID(t, `<html><head><style>.panel { background: #333333 url(bg.png) no-repeat }</style></head><body>
+<table bgcolor="#000080" id="table"><tr><td id="cell"><p id="text" style="color: #ffffff">Hi</p></td></tr></table>
+<div class="panel"><span id="panel-text">Hi</span></div>
+<div style="background-color: rgba(0, 0, 0, 0.5)"><span id="translucent">Hi</span></div>
+<div style="background-color: transparent"><span id="transparent">Hi</span></div>
+<p id="default">Hi</p>
+</body></html>`)
+
+	tests := []struct {
+		id         string
+		background string
+		color      string
+	}{
+		{"table", "#000080", "#000000"},
+		{"cell", "#000080", "#000000"},
+		{"text", "#000080", "#ffffff"},
+		{"panel-text", "#333333", "#000000"},
+		{"translucent", "#808080", "#000000"},
+		{"transparent", "#ffffff", "#000000"},
+		{"default", "#ffffff", "#000000"},
+	}
+
+	for _, test := range tests {
+		element, ok := styled[test.id]
+		if !ok {
+			t.Errorf("#%s was not styled", test.id)
+			continue
+		}
+		if got := element.Background.Hex(); got != test.background {
+			t.Errorf("#%s background = %s, want %s", test.id, got, test.background)
+		}
+		if got := element.Color.Hex(); got != test.color {
+			t.Errorf("#%s color = %s, want %s", test.id, got, test.color)
+		}
+	}
+}
+
+func TestStyledElementsBlendTextColor(t *testing.T) {
+	styled := styledByID(t, `<html><body>
+<div style="background: #000000"><p id="faded" style="color: rgba(255, 255, 255, 0.5)">Hi</p></div>
+<font color="#ff0000" id="font">Hi</font>
+<a href="#" id="link">Hi</a>
+<a href="#" id="styled-link" style="color: hsl(120, 100%, 25%)">Hi</a>
+</body></html>`)
+
+	tests := map[string]string{
+		"faded":       "#808080",
+		"font":        "#ff0000",
+		"link":        "#0000ee",
+		"styled-link": "#008000",
+	}
+	for id, want := range tests {
+		if got := styled[id].Color.Hex(); got != want {
+			t.Errorf("#%s color = %s, want %s", id, got, want)
+		}
+		if styled[id].Color.A != 1 {
+			t.Errorf("#%s color alpha = %v, want opaque", id, styled[id].Color.A)
+		}
+	}
+
+	// The blended color is what contrast rules measure against
+	faded := styled["faded"]
+	if ratio := css.ContrastRatio(faded.Color, faded.Background); math.Abs(ratio-5.32) > 0.01 {
+		t.Errorf("faded text contrast = %.2f, want 5.32", ratio)
+	}
+}
+
+func TestStyledElementsInheritFontSize(t *testing.T) {
+	styled := styledByID(t, `<html><head><style>.big { font-size: 20px }</style></head><body>
+<p id="default">Hi</p>
+<div class="big" id="big"><span id="inherited">Hi</span><span id="em" style="font-size: 1.5em">Hi</span>
+<span id="percent" style="font-size: 50%"><b id="nested">Hi</b></span></div>
+<div style="font-size: 12pt"><span id="pt">Hi</span></div>
+<div style="font-size: 10px"><span id="rem" style="font-size: 2rem">Hi</span><h1 id="heading">Hi</h1></div>
+<p id="keyword" style="font-size: x-large">Hi</p>
+<p id="invalid" style="font-size: huge">Hi</p>
+</body></html>`)
+
+	tests := map[string]float64{
+		"default":   16,
+		"big":       20,
+		"inherited": 20,
+		"em":        30,
+		"percent":   10,
+		"nested":    10,
+		"pt":        16,
+		"rem":       32,
+		"heading":   20,
+		"keyword":   24,
+		"invalid":   16,
+	}
+	for id, want := range tests {
+		if got := styled[id].FontSize; math.Abs(got-want) > 0.01 {
+			t.Errorf("#%s font-size = %.2fpx, want %.2fpx", id, got, want)
+		}
+	}
+}
+
+func TestStyledElementsBold(t *testing.T) {
+	styled := styledByID(t, `<html><body>
+<p id="normal">Hi</p>
+<strong id="strong"><span id="inside">Hi</span></strong>
+<b><span id="unbold" style="font-weight: normal">Hi</span></b>
+<span id="numeric" style="font-weight: 700">Hi</span>
+<span id="light" style="font-weight: 500">Hi</span>
+<h2 id="heading">Hi</h2>
+</body></html>`)
+
+	tests := map[string]bool{
+		"normal":  false,
+		"strong":  true,
+		"inside":  true,
+		"unbold":  false,
+		"numeric": true,
+		"light":   false,
+		"heading": true,
+	}
+	for id, want := range tests {
+		if got := styled[id].Bold; got != want {
+			t.Errorf("#%s bold = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"inliner/internal/css"
+)
+
+var (
+	viewportUnitRegex   = regexp.MustCompile(`\d(vw|vh|vmin|vmax)\b`)
+	remUnitRegex        = regexp.MustCompile(`\drem\b`)
+	negativeLengthRegex = regexp.MustCompile(`(^|\s)-\d`)
+)
+
+// cssRules returns checks on declarations and at-rules in <style> tags and inline styles
+func cssRules() []Rule {
+	return []Rule{
+		declarationRule("css-position-fixed", SeverityError,
+			"position: fixed and sticky are unsupported",
+			"No email client supports fixed or sticky positioning; webmail clients strip it because it could overlay their own UI.",
+			func(d css.Declaration) string {
+				if d.Property == "position" && (valueIs(d, "fixed") || valueIs(d, "sticky")) {
+					return fmt.Sprintf("position: %s is not supported in email clients", d.Value)
+				}
+				return ""
+			}),
+		declarationRule("css-position-absolute", SeverityWarning,
+			"position: absolute is stripped by most clients",
+			"Gmail and Outlook remove position declarations, so absolutely positioned content falls back into normal flow.",
+			func(d css.Declaration) string {
+				if d.Property == "position" && valueIs(d, "absolute") {
+					return "position: absolute is removed by Gmail and Outlook"
+				}
+				return ""
+			}),
+		declarationRule("css-float", SeverityWarning,
+			"float is unreliable in Outlook",
+			"Outlook desktop ignores float on most elements. Use align attributes on tables instead.",
+			func(d css.Declaration) string {
+				if d.Property == "float" && !valueIs(d, "none") {
+					return "float is ignored by Outlook desktop; use table align attributes"
+				}
+				return ""
+			}),
+		declarationRule("css-flexbox", SeverityWarning,
+			"Flexbox is unsupported in Outlook and older Gmail apps",
+			"display: flex falls back to block layout in Outlook desktop and some Gmail apps.",
+			func(d css.Declaration) string {
+				if d.Property == "display" && (valueIs(d, "flex") || valueIs(d, "inline-flex")) {
+					return "Flexbox is not supported in Outlook desktop"
+				}
+				return ""
+			}),
+		declarationRule("css-grid", SeverityWarning,
+			"CSS grid is unsupported in most clients",
+			"display: grid is only supported by Apple Mail and a few webmail clients.",
+			func(d css.Declaration) string {
+				if d.Property == "display" && (valueIs(d, "grid") || valueIs(d, "inline-grid")) {
+					return "CSS grid is not supported in most email clients"
+				}
+				return ""
+			}),
+		declarationRule("css-viewport-units", SeverityError,
+			"Viewport units are unsupported",
+			"vw, vh, vmin and vmax resolve against the client's preview pane, not the email, and are dropped by Outlook and Gmail.",
+			func(d css.Declaration) string {
+				if viewportUnitRegex.MatchString(d.Value) {
+					return "Viewport units not supported in email clients"
+				}
+				return ""
+			}),
+		declarationRule("css-background-image", SeverityWarning,
+			"CSS background images need a VML fallback for Outlook",
+			"Outlook desktop ignores CSS background images. Provide a VML v:rect/v:fill fallback or a background attribute.",
+			func(d css.Declaration) string {
+				if (d.Property == "background-image" || d.Property == "background") && strings.Contains(d.Value, "url(") {
+					return "Background images may not render in Outlook desktop"
+				}
+				return ""
+			}),
+		declarationRule("css-box-shadow", SeverityInfo,
+			"box-shadow is unsupported in Outlook and Gmail apps",
+			"Shadows are dropped silently; make sure the design still works without them.",
+			func(d css.Declaration) string {
+				if d.Property == "box-shadow" && !valueIs(d, "none") {
+					return "box-shadow is not supported in Outlook or the Gmail apps"
+				}
+				return ""
+			}),
+		declarationRule("css-transform", SeverityWarning,
+			"CSS transforms are unsupported in most clients",
+			"Gmail and Outlook strip transform, so rotated or scaled content renders untransformed.",
+			func(d css.Declaration) string {
+				if d.Property == "transform" || strings.HasSuffix(d.Property, "-transform") {
+					return "transform is not supported in Gmail or Outlook"
+				}
+				return ""
+			}),
+		declarationRule("css-animation", SeverityInfo,
+			"Animations and transitions only run in a few clients",
+			"Only Apple Mail and some webmail clients run CSS animations. Make sure the first frame is a complete design.",
+			func(d css.Declaration) string {
+				if strings.HasPrefix(d.Property, "animation") || strings.HasPrefix(d.Property, "transition") ||
+					strings.Contains(d.Property, "-animation") || strings.Contains(d.Property, "-transition") {
+					return "CSS animations and transitions only run in a few email clients"
+				}
+				return ""
+			}),
+		atRuleRule("css-keyframes", SeverityInfo,
+			"@keyframes only run in a few clients",
+			"Keyframe animations are stripped by Gmail and Outlook.",
+			"@keyframes are stripped by Gmail and Outlook",
+			"@keyframes", "@-webkit-keyframes"),
+		atRuleRule("css-web-fonts", SeverityInfo,
+			"Web fonts need fallbacks",
+			"@font-face is only honoured by Apple Mail, iOS and some Android clients. Always declare a web-safe fallback in font-family.",
+			"@font-face only works in some email clients; declare web-safe fallbacks",
+			"@font-face"),
+		{
+			ID:              "css-import",
+			Category:        "css",
+			DefaultSeverity: SeverityWarning,
+			Description:     "@import is stripped by most clients",
+			Docs:            "Imported stylesheets are not fetched by Gmail or Outlook. Embed the CSS so it can be inlined.",
+			Check: func(ctx *Context) []Issue {
+				var issues []Issue
+				for _, sheet := range ctx.Sheets {
+					for _, url := range sheet.Stylesheet.Imports {
+						issues = append(issues, Issue{
+							Message: fmt.Sprintf("@import %q is not supported by most email clients", url),
+							Element: "style",
+							Line:    sheet.Node.SourceLine(),
+						})
+					}
+				}
+				return issues
+			},
+		},
+		declarationRule("css-max-width", SeverityInfo,
+			"max-width is ignored by Outlook desktop",
+			"Outlook desktop ignores max-width. Wrap fluid containers in a fixed-width table inside an MSO conditional comment.",
+			func(d css.Declaration) string {
+				if d.Property == "max-width" {
+					return "max-width is ignored by Outlook desktop; add an MSO ghost table"
+				}
+				return ""
+			}),
+		declarationRule("css-rem-units", SeverityInfo,
+			"rem units are unsupported in some clients",
+			"Outlook desktop and older webmail clients do not support rem. Use px for predictable sizing.",
+			func(d css.Declaration) string {
+				if remUnitRegex.MatchString(d.Value) {
+					return "rem units are not supported in all email clients; use px"
+				}
+				return ""
+			}),
+		declarationRule("css-calc", SeverityWarning,
+			"calc() is unsupported in many clients",
+			"Outlook and several webmail clients drop declarations that use calc().",
+			func(d css.Declaration) string {
+				if strings.Contains(strings.ToLower(d.Value), "calc(") {
+					return "calc() is not supported in Outlook and several webmail clients"
+				}
+				return ""
+			}),
+		declarationRule("css-custom-properties", SeverityWarning,
+			"CSS custom properties are unsupported in most clients",
+			"var() and custom properties are stripped by Gmail and Outlook, leaving the property unset.",
+			func(d css.Declaration) string {
+				if strings.HasPrefix(d.Property, "--") || strings.Contains(d.Value, "var(") {
+					return "CSS custom properties are not supported in Gmail or Outlook"
+				}
+				return ""
+			}),
+		declarationRule("css-negative-margin", SeverityWarning,
+			"Negative margins are stripped by some clients",
+			"Outlook.com and some webmail clients remove negative margins, which breaks overlapping layouts.",
+			func(d css.Declaration) string {
+				if strings.HasPrefix(d.Property, "margin") && negativeLengthRegex.MatchString(d.Value) {
+					return "Negative margins are removed by some email clients"
+				}
+				return ""
+			}),
+		declarationRule("css-border-radius", SeverityInfo,
+			"border-radius is ignored by Outlook desktop",
+			"Outlook desktop renders square corners. Use a VML roundrect for bulletproof rounded buttons.",
+			func(d css.Declaration) string {
+				if strings.HasPrefix(d.Property, "border-radius") || strings.HasSuffix(d.Property, "-radius") {
+					return "border-radius is ignored by Outlook desktop"
+				}
+				return ""
+			}),
+		declarationRule("css-opacity", SeverityInfo,
+			"opacity is unsupported in Outlook",
+			"Outlook desktop ignores opacity, so semi-transparent elements render fully opaque.",
+			func(d css.Declaration) string {
+				if d.Property == "opacity" {
+					return "opacity is ignored by Outlook desktop"
+				}
+				return ""
+			}),
+		declarationRule("css-overflow", SeverityInfo,
+			"overflow is unsupported in several clients",
+			"Gmail and Outlook ignore overflow, so clipped content is shown in full.",
+			func(d css.Declaration) string {
+				if strings.HasPrefix(d.Property, "overflow") && !valueIs(d, "visible") {
+					return "overflow is ignored by Gmail and Outlook"
+				}
+				return ""
+			}),
+		{
+			ID:              "css-style-size",
+			Category:        "css",
+			DefaultSeverity: SeverityWarning,
+			Description:     "<style> blocks should stay under Gmail's size limit",
+			Docs:            "Gmail discards an entire <style> block once it grows past roughly 16KB, taking media queries with it.",
+			Options:         map[string]string{"max-bytes": "16384"},
+			Check: func(ctx *Context) []Issue {
+				maxBytes := ctx.IntOption("max-bytes")
+				var issues []Issue
+				for _, sheet := range ctx.Sheets {
+					if maxBytes > 0 && len(sheet.Text) > maxBytes {
+						issues = append(issues, Issue{
+							Message: fmt.Sprintf("<style> block is %d bytes; Gmail drops blocks over %d bytes", len(sheet.Text), maxBytes),
+							Element: "style",
+							Line:    sheet.Node.SourceLine(),
+						})
+					}
+				}
+				return issues
+			},
+		},
+	}
+}
+
+// valueIs compares a declaration value case-insensitively
+func valueIs(declaration css.Declaration, value string) bool {
+	return strings.EqualFold(strings.TrimSpace(declaration.Value), value)
+}
@@ -0,0 +1,185 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"inliner/internal/css"
+)
+
+// darkModeRules returns the checks for dark color scheme support. Clients
+// either apply @media (prefers-color-scheme: dark) rules (Apple Mail,
+// Outlook for Mac), recolor the message themselves (Gmail apps, Outlook.com),
+// or both.
+func darkModeRules() []Rule {
+	return []Rule{
+		{
+			ID:              "dark-mode-important",
+			Category:        "dark-mode",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Dark color scheme declarations should be !important",
+			Docs: "Inlining moves the light colors into style attributes, which beat any rule in a " +
+				"<style> tag. Declarations in @media (prefers-color-scheme: dark) need !important to " +
+				"override them.",
+			Check: func(ctx *Context) []Issue {
+				var issues []Issue
+				ctx.EachRule(func(sheet Sheet, rule css.Rule) {
+					if rule.IsAtRule() || !rule.InDarkScheme() {
+						return
+					}
+					var properties []string
+					for _, declaration := range sortedDeclarations(rule.Declarations) {
+						if !declaration.Important {
+							properties = append(properties, declaration.Property)
+						}
+					}
+					if len(properties) > 0 {
+						issues = append(issues, Issue{
+							Message: fmt.Sprintf("%s sets %s without !important; inlined styles will override it",
+								rule.Selector, strings.Join(properties, ", ")),
+							Element: "style",
+							Line:    ctx.RuleLine(sheet, rule),
+						})
+					}
+				})
+				return issues
+			},
+		},
+		{
+			ID:              "dark-mode-meta",
+			Category:        "dark-mode",
+			DefaultSeverity: SeverityInfo,
+			Description:     "Dark color scheme styles should be declared with a color-scheme meta tag",
+			Docs: "Apple Mail only applies prefers-color-scheme: dark rules when the message declares " +
+				"support with <meta name=\"color-scheme\" content=\"light dark\">; without it the " +
+				"client may recolor the message itself. The -color-scheme option adds the tag.",
+			Check: func(ctx *Context) []Issue {
+				line := 0
+				ctx.EachRule(func(sheet Sheet, rule css.Rule) {
+					if line == 0 && rule.InDarkScheme() {
+						line = ctx.RuleLine(sheet, rule)
+						if line == 0 {
+							line = -1 // Found, line unknown
+						}
+					}
+				})
+				if line == 0 {
+					return nil
+				}
+				line = max(line, 0)
+
+				for _, meta := range ctx.Elements("meta[name]") {
+					attrs := meta.Attributes()
+					if !strings.EqualFold(strings.TrimSpace(attrs["name"]), "color-scheme") {
+						continue
+					}
+					if strings.Contains(strings.ToLower(attrs["content"]), "dark") {
+						return nil
+					}
+					return []Issue{{
+						Message: fmt.Sprintf("color-scheme meta tag content %q does not include dark, so dark color scheme styles may be ignored", attrs["content"]),
+						Element: "meta",
+						Line:    meta.SourceLine(),
+					}}
+				}
+				return []Issue{{
+					Message: "Dark color scheme styles without a <meta name=\"color-scheme\" content=\"light dark\"> tag may be ignored",
+					Element: "style",
+					Line:    line,
+				}}
+			},
+		},
+		{
+			ID:              "dark-mode-inversion",
+			Category:        "dark-mode",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Text colors should stay readable when a client forces dark mode",
+			Docs: "Clients that force dark mode invert lightness: some invert every color (Outlook.com), " +
+				"others only light backgrounds and dark text (Gmail apps). Mid-lightness pairs such as " +
+				"dark text on yellow can end up with little contrast. Both inversions are simulated on " +
+				"the resolved colors. The check only runs for messages that opt into dark mode: with " +
+				"the -dark-mode or -color-scheme options, prefers-color-scheme: dark rules or a " +
+				"color-scheme meta tag that includes dark.",
+			Options: map[string]string{"min-ratio": "3"},
+			Check: func(ctx *Context) []Issue {
+				if !targetsDarkMode(ctx) {
+					return nil
+				}
+				minRatio, _ := strconv.ParseFloat(ctx.Option("min-ratio"), 64)
+
+				var issues []Issue
+				for _, styled := range ctx.StyledElements() {
+					if !hasOwnText(styled) || css.ContrastRatio(styled.Color, styled.Background) < minRatio {
+						continue
+					}
+
+					for _, inversion := range []struct {
+						name              string
+						color, background css.Color
+					}{
+						{"full", invertLightness(styled.Color), invertLightness(styled.Background)},
+						{"partial", partialInversion(styled.Color, false), partialInversion(styled.Background, true)},
+					} {
+						ratio := css.ContrastRatio(inversion.color, inversion.background)
+						if ratio >= minRatio {
+							continue
+						}
+						issues = append(issues, Issue{
+							Message: fmt.Sprintf("%s on %s drops to %.2f:1 (%s on %s) under %s dark mode inversion",
+								styled.Color.Hex(), styled.Background.Hex(), ratio,
+								inversion.color.Hex(), inversion.background.Hex(), inversion.name),
+							Element:  styled.Tag,
+							Property: "color",
+							Line:     styled.Node.SourceLine(),
+						})
+						break
+					}
+				}
+				return issues
+			},
+		},
+	}
+}
+
+// targetsDarkMode reports whether the message is meant to support dark mode,
+// through the configuration, dark color scheme rules or a color-scheme meta
+// tag. Other messages are left to the client's own recoloring.
+func targetsDarkMode(ctx *Context) bool {
+	if ctx.Config.DarkMode || ctx.Config.ColorScheme != "" {
+		return true
+	}
+
+	dark := false
+	ctx.EachRule(func(sheet Sheet, rule css.Rule) {
+		dark = dark || rule.InDarkScheme()
+	})
+	if dark {
+		return true
+	}
+
+	for _, meta := range ctx.Elements("meta[name]") {
+		attrs := meta.Attributes()
+		if strings.EqualFold(strings.TrimSpace(attrs["name"]), "color-scheme") &&
+			strings.Contains(strings.ToLower(attrs["content"]), "dark") {
+			return true
+		}
+	}
+	return false
+}
+
+// invertLightness mirrors a color's HSL lightness, keeping its hue
+func invertLightness(c css.Color) css.Color {
+	_, _, lightness := c.HSL()
+	return c.WithLightness(1 - lightness)
+}
+
+// partialInversion inverts only light backgrounds or dark text, leaving
+// colors that already suit a dark theme alone
+func partialInversion(c css.Color, background bool) css.Color {
+	_, _, lightness := c.HSL()
+	if background == (lightness > 0.5) {
+		return invertLightness(c)
+	}
+	return c
+}
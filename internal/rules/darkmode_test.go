@@ -0,0 +1,67 @@
+package rules
+
+import (
+	"testing"
+
+	"inliner/internal/config"
+	"inliner/internal/css"
+	"inliner/internal/html"
+)
+
+// inversionIssues runs the built-in rules over page and returns the
+// dark-mode-inversion issues
+func inversionIssues(t *testing.T, page string, cfg config.Config) []Issue {
+	t.Helper()
+	doc, err := html.NewParser().Parse(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := NewContext(doc, css.NewParser(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := Default().Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found []Issue
+	for _, issue := range issues {
+		if issue.Rule == "dark-mode-inversion" {
+			found = append(found, issue)
+		}
+	}
+	return found
+}
+
+func TestDarkModeInversionOnlyForDarkModeMessages(t *testing.T) {
+	link := `<a href="https://example.com" style="color: #0000ee">Read more</a>`
+	page := func(head string) string {
+		return `<html><head>` + head + `</head><body><p>` + link + `</p></body></html>`
+	}
+
+	tests := []struct {
+		name  string
+		head  string
+		cfg   func(*config.Config)
+		flags bool
+	}{
+		{name: "light only", flags: false},
+		{name: "dark mode option", cfg: func(c *config.Config) { c.DarkMode = true }, flags: true},
+		{name: "color scheme option", cfg: func(c *config.Config) { c.ColorScheme = "light dark" }, flags: true},
+		{name: "dark scheme rules", head: `<style>@media (prefers-color-scheme: dark) { p { color: #fff !important } }</style>`, flags: true},
+		{name: "color-scheme meta", head: `<meta name="color-scheme" content="light dark">`, flags: true},
+		{name: "light color-scheme meta", head: `<meta name="color-scheme" content="light">`, flags: false},
+	}
+
+	for _, test := range tests {
+		cfg := config.Default()
+		if test.cfg != nil {
+			test.cfg(&cfg)
+		}
+		issues := inversionIssues(t, page(test.head), cfg)
+		if flagged := len(issues) > 0; flagged != test.flags {
+			t.Errorf("%s: flagged = %v (%+v), want %v", test.name, flagged, issues, test.flags)
+		}
+	}
+}
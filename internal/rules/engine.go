@@ -0,0 +1,135 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"inliner/internal/config"
+)
+
+// disableDirective is the comment prefix that turns rules off for a document:
+// <!-- inliner-disable --> disables every rule, <!-- inliner-disable a, b -->
+// only the listed ones
+const disableDirective = "inliner-disable"
+
+// Run checks the document against every enabled rule. Severity overrides and
+// options come from the context's config; unknown rule IDs, severities or
+// option names are reported as errors rather than silently ignored.
+func (r *Registry) Run(ctx *Context) ([]Issue, error) {
+	return r.RunContext(context.Background(), ctx)
+}
+
+// RunContext is Run, stopping with the context's error if it is done before
+// every rule has run
+func (r *Registry) RunContext(done context.Context, ctx *Context) ([]Issue, error) {
+	if err := r.ValidateConfig(ctx.Config); err != nil {
+		return nil, err
+	}
+
+	disabled, disableAll := r.disabledByComments(ctx)
+	if disableAll {
+		return nil, nil
+	}
+
+	var issues []Issue
+
+	for _, rule := range r.Rules() {
+		if err := done.Err(); err != nil {
+			return nil, err
+		}
+
+		severity := rule.DefaultSeverity
+		if override, ok := ctx.Config.Rules[rule.ID]; ok {
+			severity = override
+		}
+		if severity == SeverityOff || disabled[rule.ID] {
+			continue
+		}
+
+		ctx.options = mergeOptions(rule.Options, ctx.Config.RuleOptions[rule.ID])
+
+		for _, issue := range rule.Check(ctx) {
+			issue.Rule = rule.ID
+			issue.Severity = severity
+			if issue.Category == "" {
+				issue.Category = rule.Category
+			}
+			if issue.Reference == "" {
+				issue.Reference = rule.Reference
+			}
+			issues = append(issues, issue)
+		}
+	}
+
+	ctx.options = nil
+	return issues, nil
+}
+
+// ValidateConfig checks rule overrides and options against the registry
+func (r *Registry) ValidateConfig(cfg config.Config) error {
+	for id, severity := range cfg.Rules {
+		if _, ok := r.Lookup(id); !ok {
+			return fmt.Errorf("unknown rule in config: %s", id)
+		}
+		if severity != SeverityOff && !validSeverity(severity) {
+			return fmt.Errorf("invalid severity for rule %s: %s (valid: error, warning, info, off)", id, severity)
+		}
+	}
+
+	for id, options := range cfg.RuleOptions {
+		rule, ok := r.Lookup(id)
+		if !ok {
+			return fmt.Errorf("unknown rule in config: %s", id)
+		}
+		for name := range options {
+			if _, ok := rule.Options[name]; !ok {
+				return fmt.Errorf("unknown option for rule %s: %s", id, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// disabledByComments collects rules disabled by inliner-disable comments
+func (r *Registry) disabledByComments(ctx *Context) (map[string]bool, bool) {
+	disabled := make(map[string]bool)
+
+	for _, comment := range ctx.Document.Comments() {
+		text := strings.TrimSpace(comment)
+		if !strings.HasPrefix(text, disableDirective) {
+			continue
+		}
+
+		rest := strings.TrimPrefix(text, disableDirective)
+		if rest != "" && rest[0] != ' ' && rest[0] != '\t' && rest[0] != '\n' {
+			// Another directive sharing the prefix
+			continue
+		}
+
+		ids := strings.FieldsFunc(rest, func(c rune) bool {
+			return c == ',' || c == ' ' || c == '\t' || c == '\n'
+		})
+		if len(ids) == 0 {
+			return nil, true
+		}
+		for _, id := range ids {
+			disabled[id] = true
+		}
+	}
+
+	return disabled, false
+}
+
+// mergeOptions overlays configured option values on a rule's defaults
+func mergeOptions(defaults, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(defaults))
+	for name, value := range defaults {
+		merged[name] = value
+	}
+	for name, value := range overrides {
+		merged[name] = value
+	}
+	return merged
+}
@@ -0,0 +1,204 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"inliner/internal/config"
+	"inliner/internal/css"
+	"inliner/internal/html"
+)
+
+// testRegistry has two rules reporting every <p>, one with options that it
+// reports back in the message
+func testRegistry(t *testing.T) *Registry {
+	t.Helper()
+	registry := NewRegistry()
+	registry.MustRegister(elementRule("test-p", "structure", SeverityWarning, "Paragraphs", "", "p", "paragraph"))
+	registry.MustRegister(Rule{
+		ID:              "test-options",
+		Category:        "css",
+		DefaultSeverity: SeverityInfo,
+		Reference:       "Test 1.0",
+		Options:         map[string]string{"size": "10", "unit": "px"},
+		Check: func(ctx *Context) []Issue {
+			return []Issue{{Message: ctx.Option("size") + ctx.Option("unit")}}
+		},
+	})
+	return registry
+}
+
+// run checks page against registry with cfg
+func run(t *testing.T, registry *Registry, page string, cfg config.Config) []Issue {
+	t.Helper()
+	doc, err := html.NewParser().Parse(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := NewContext(doc, css.NewParser(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := registry.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return issues
+}
+
+// summarize lists issues as "rule:severity:message"
+func summarize(issues []Issue) []string {
+	var out []string
+	for _, issue := range issues {
+		out = append(out, issue.Rule+":"+issue.Severity+":"+issue.Message)
+	}
+	return out
+}
+
+func TestRunSeverityOverrides(t *testing.T) {
+	page := `<html><body><p>a</p></body></html>`
+
+	tests := []struct {
+		name  string
+		rules map[string]string
+		want  []string
+	}{
+		{"defaults", nil, []string{"test-p:warning:paragraph", "test-options:info:10px"}},
+		{"override", map[string]string{"test-p": SeverityError}, []string{"test-p:error:paragraph", "test-options:info:10px"}},
+		{"off", map[string]string{"test-options": SeverityOff}, []string{"test-p:warning:paragraph"}},
+	}
+
+	for _, test := range tests {
+		cfg := config.Default()
+		cfg.Rules = test.rules
+		if got := summarize(run(t, testRegistry(t), page, cfg)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: issues = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRunFillsRuleFields(t *testing.T) {
+	issues := run(t, testRegistry(t), `<html><body>
+<p>a</p></body></html>`, config.Default())
+
+	p := issues[0]
+	if p.Rule != "test-p" || p.Category != "structure" || p.Element != "p" || p.Line != 2 {
+		t.Errorf("test-p issue = %+v", p)
+	}
+	if options := issues[1]; options.Category != "css" || options.Reference != "Test 1.0" {
+		t.Errorf("test-options issue = %+v, want the rule's category and reference", options)
+	}
+}
+
+func TestRunMergesOptions(t *testing.T) {
+	cfg := config.Default()
+	cfg.RuleOptions = map[string]map[string]string{"test-options": {"size": "20"}}
+
+	got := summarize(run(t, testRegistry(t), `<html><body></body></html>`, cfg))
+	if want := []string{"test-options:info:20px"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("issues = %q, want %q (configured size, default unit)", got, want)
+	}
+}
+
+func TestRunDisableComments(t *testing.T) {
+	tests := []struct {
+		comment string
+		want    []string
+	}{
+		{"<!-- inliner-disable -->", nil},
+		{"<!-- inliner-disable test-p -->", []string{"test-options:info:10px"}},
+		{"<!--inliner-disable test-p,\ttest-options-->", nil},
+		{"<!-- inliner-disabled test-p -->", []string{"test-p:warning:paragraph", "test-options:info:10px"}},
+		{"<!-- please inliner-disable test-p -->", []string{"test-p:warning:paragraph", "test-options:info:10px"}},
+	}
+
+	for _, test := range tests {
+		page := `<html><body>` + test.comment + `<p>a</p></body></html>`
+		if got := summarize(run(t, testRegistry(t), page, config.Default())); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: issues = %q, want %q", test.comment, got, test.want)
+		}
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   map[string]string
+		options map[string]map[string]string
+		want    string
+	}{
+		{"valid", map[string]string{"test-p": "off", "test-options": "error"}, map[string]map[string]string{"test-options": {"unit": "em"}}, ""},
+		{"unknown rule", map[string]string{"test-q": "off"}, nil, "unknown rule in config: test-q"},
+		{"bad severity", map[string]string{"test-p": "fatal"}, nil, "invalid severity for rule test-p: fatal (valid: error, warning, info, off)"},
+		{"unknown option rule", nil, map[string]map[string]string{"test-q": {"size": "1"}}, "unknown rule in config: test-q"},
+		{"unknown option", nil, map[string]map[string]string{"test-options": {"colour": "red"}}, "unknown option for rule test-options: colour"},
+	}
+
+	for _, test := range tests {
+		cfg := config.Default()
+		cfg.Rules, cfg.RuleOptions = test.rules, test.options
+		err := testRegistry(t).ValidateConfig(cfg)
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		case test.want != "" && (err == nil || err.Error() != test.want):
+			t.Errorf("%s: error = %v, want %q", test.name, err, test.want)
+		}
+	}
+
+	// Run refuses the same configurations rather than ignoring them
+	cfg := config.Default()
+	cfg.Rules = map[string]string{"test-q": "off"}
+	doc, _ := html.NewParser().Parse(`<html></html>`)
+	ctx, _ := NewContext(doc, css.NewParser(), cfg)
+	if _, err := testRegistry(t).Run(ctx); err == nil {
+		t.Error("Run accepted an unknown rule override")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	check := func(ctx *Context) []Issue { return nil }
+	tests := []struct {
+		rule Rule
+		want string
+	}{
+		{Rule{DefaultSeverity: SeverityInfo, Check: check}, "rule has no ID"},
+		{Rule{ID: "x", DefaultSeverity: SeverityInfo}, "rule x has no check function"},
+		{Rule{ID: "x", DefaultSeverity: SeverityOff, Check: check}, "rule x has invalid default severity: off"},
+		{Rule{ID: "test-p", DefaultSeverity: SeverityInfo, Check: check}, "rule test-p is already registered"},
+	}
+
+	for _, test := range tests {
+		if err := testRegistry(t).Register(test.rule); err == nil || err.Error() != test.want {
+			t.Errorf("Register(%q) error = %v, want %q", test.rule.ID, err, test.want)
+		}
+	}
+}
+
+func TestRunContextCancelled(t *testing.T) {
+	doc, _ := html.NewParser().Parse(`<html><body><p>a</p></body></html>`)
+	ctx, err := NewContext(doc, css.NewParser(), config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := testRegistry(t).RunContext(done, ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("RunContext error = %v, want context.Canceled", err)
+	}
+}
+
+func TestDefaultRulesAreDocumented(t *testing.T) {
+	for _, rule := range Default().Rules() {
+		if rule.Category == "" || rule.Description == "" || rule.Docs == "" {
+			t.Errorf("%s: category %q, description %q, docs %q; want all set", rule.ID, rule.Category, rule.Description, rule.Docs)
+		}
+		if strings.ToLower(rule.ID) != rule.ID || strings.ContainsAny(rule.ID, " _") {
+			t.Errorf("rule ID %q is not kebab-case", rule.ID)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package rules
+
+// inlineRules returns the problems the inliner reports while applying
+// styles, rather than Validate. They are registered so that config files
+// can change their severity or turn them off like any other rule; their
+// checks find nothing, and the inliner keeps each warning's own severity
+// unless it is overridden.
+func inlineRules() []Rule {
+	return []Rule{
+		inlineRule("css-unsafe-property", "css", SeverityInfo,
+			"Inlined properties should be supported across email clients",
+			"The inlined styles use a property outside the set that email clients reliably support."),
+		inlineRule("css-position-relative", "css", SeverityWarning,
+			"Positioning is unsupported by clients that require inline styles",
+			"Gmail and Outlook strip position, so offsets relative to the element are ignored."),
+		inlineRule("stylesheet-load", "css", SeverityWarning,
+			"Linked and imported stylesheets should load",
+			"A <link rel=\"stylesheet\"> or @import could not be loaded, so its rules were not inlined "+
+				"and the reference is left in the output."),
+		inlineRule("size-message-limit", "size", SeverityError,
+			"The message should stay under each target client's size limit",
+			"Gmail clips messages over about 102KB behind a \"View entire message\" link, hiding the rest "+
+				"of the content and any tracking pixel. Within 10% of the limit is reported as a warning."),
+		inlineRule("size-stylesheet-limit", "size", SeverityError,
+			"<style> blocks should stay under each target client's stylesheet limit",
+			"Clients with a stylesheet limit discard styles beyond it. Within 10% of the limit is reported "+
+				"as a warning."),
+		inlineRule("vml-button", "vml", SeverityWarning,
+			"Elements marked for a VML button need a size",
+			"data-inliner=\"vml-button\" needs a width and a height or line-height in px to draw the "+
+				"Outlook v:roundrect; without them the element gets no fallback."),
+		inlineRule("vml-background", "vml", SeverityWarning,
+			"Elements marked for a VML background need a background image and a width",
+			"data-inliner=\"vml-background\" needs a background image and a width in px, on a cell or "+
+				"block rather than table rows, to draw the Outlook v:rect."),
+	}
+}
+
+// inlineRule builds a rule reported by the inliner rather than Validate
+func inlineRule(id, category, severity, description, docs string) Rule {
+	return Rule{
+		ID:              id,
+		Category:        category,
+		DefaultSeverity: severity,
+		Description:     description,
+		Docs:            docs,
+		Check:           func(ctx *Context) []Issue { return nil },
+	}
+}
@@ -0,0 +1,160 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Severity levels, from most to least severe
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+
+	// SeverityOff disables a rule when used as an override
+	SeverityOff = "off"
+)
+
+// Rule describes a single lint check run against the parsed document and CSS
+type Rule struct {
+	ID              string            // Stable identifier used in reports, config and disable comments
+	Category        string            // "structure", "css", "attribute", ...
+	DefaultSeverity string            // Severity used unless overridden in config
+	Description     string            // One-line summary
+	Docs            string            // Longer explanation of why the check matters
+	Reference       string            // Standard the rule enforces, e.g. "WCAG 2.1 SC 1.1.1"
+	Options         map[string]string // Option name -> default value
+	Check           func(ctx *Context) []Issue
+}
+
+// Issue is a single problem found by a rule
+type Issue struct {
+	Rule      string
+	Category  string
+	Severity  string
+	Message   string
+	Element   string
+	Property  string
+	Line      int
+	Reference string // Defaults to the rule's Reference
+}
+
+// Registry holds rules in registration order. It is safe for concurrent
+// use; rules registered while checks are running apply to later runs.
+type Registry struct {
+	mu    sync.RWMutex
+	rules []Rule
+	index map[string]int
+}
+
+// NewRegistry creates an empty rule registry
+func NewRegistry() *Registry {
+	return &Registry{index: make(map[string]int)}
+}
+
+// Default returns a new registry populated with the built-in email checks
+func Default() *Registry {
+	registry := NewRegistry()
+	for _, rule := range builtinRules() {
+		registry.MustRegister(rule)
+	}
+	return registry
+}
+
+// Register adds a rule to the registry
+func (r *Registry) Register(rule Rule) error {
+	if rule.ID == "" {
+		return fmt.Errorf("rule has no ID")
+	}
+	if rule.Check == nil {
+		return fmt.Errorf("rule %s has no check function", rule.ID)
+	}
+	if !validSeverity(rule.DefaultSeverity) {
+		return fmt.Errorf("rule %s has invalid default severity: %s", rule.ID, rule.DefaultSeverity)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.index[rule.ID]; exists {
+		return fmt.Errorf("rule %s is already registered", rule.ID)
+	}
+
+	r.index[rule.ID] = len(r.rules)
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+// MustRegister adds a rule to the registry and panics on failure
+func (r *Registry) MustRegister(rule Rule) {
+	if err := r.Register(rule); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the rule with the given ID
+func (r *Registry) Lookup(id string) (Rule, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i, ok := r.index[id]
+	if !ok {
+		return Rule{}, false
+	}
+	return r.rules[i], true
+}
+
+// Rules returns all registered rules in registration order
+func (r *Registry) Rules() []Rule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := make([]Rule, len(r.rules))
+	copy(rules, r.rules)
+	return rules
+}
+
+// IDs returns all registered rule IDs, sorted
+func (r *Registry) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.rules))
+	for _, rule := range r.rules {
+		ids = append(ids, rule.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// validSeverity checks that a severity is one a rule can report at
+func validSeverity(severity string) bool {
+	switch severity {
+	case SeverityError, SeverityWarning, SeverityInfo:
+		return true
+	}
+	return false
+}
+
+// ParseOverrides parses "rule-id=severity" pairs separated by commas, as used
+// by the -rules flag
+func ParseOverrides(spec string) (map[string]string, error) {
+	overrides := make(map[string]string)
+
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		id, severity, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid rule override %q (expected rule-id=severity)", pair)
+		}
+		overrides[strings.TrimSpace(id)] = strings.ToLower(strings.TrimSpace(severity))
+	}
+
+	return overrides, nil
+}
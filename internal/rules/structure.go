@@ -0,0 +1,381 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// templateTagRegex matches ESP merge tags, which are resolved at send time
+var templateTagRegex = regexp.MustCompile(`\{\{|\{%|\*\||%%|\[\[|<%|\$\{`)
+
+// pixelValueRegex extracts a leading pixel or unitless number
+var pixelValueRegex = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)\s*(px)?\s*$`)
+
+// structureRules returns checks on the document structure and attributes
+func structureRules() []Rule {
+	return []Rule{
+		{
+			ID:              "layout-table",
+			Category:        "structure",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Email body should use a table-based layout",
+			Docs: "Outlook desktop renders with the Word engine, which ignores most CSS layout. " +
+				"Tables are the only layout primitive that renders consistently across clients.",
+			Check: func(ctx *Context) []Issue {
+				if len(ctx.Elements("body table")) > 0 {
+					return nil
+				}
+				return []Issue{{
+					Message: "Email should use table-based layout for better client compatibility",
+					Element: "body",
+					Line:    ctx.Document.Body().SourceLine(),
+				}}
+			},
+		},
+		{
+			ID:              "doctype-missing",
+			Category:        "structure",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Document should declare a doctype",
+			Docs: "Without a doctype clients render in quirks mode, which changes table sizing, " +
+				"image gaps and font inheritance differently in every client.",
+			Check: func(ctx *Context) []Issue {
+				if ctx.Document.Doctype() != "" {
+					return nil
+				}
+				return []Issue{{Message: "Missing <!DOCTYPE>; clients will render in quirks mode", Element: "html"}}
+			},
+		},
+		{
+			ID:              "viewport-meta-missing",
+			Category:        "structure",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Document should have a viewport meta tag",
+			Docs: "Mobile clients such as iOS Mail scale the email down to a desktop width " +
+				"unless a viewport meta tag sets width=device-width.",
+			Check: func(ctx *Context) []Issue {
+				if len(ctx.Elements(`meta[name="viewport" i]`)) > 0 {
+					return nil
+				}
+				return []Issue{{Message: "Missing <meta name=\"viewport\">; mobile clients will zoom out", Element: "head"}}
+			},
+		},
+		{
+			ID:              "charset-missing",
+			Category:        "structure",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Document should declare its character encoding",
+			Docs: "Some clients and ESPs guess the encoding when none is declared, " +
+				"which garbles non-ASCII characters such as curly quotes and currency symbols.",
+			Check: func(ctx *Context) []Issue {
+				if len(ctx.Elements("meta[charset]")) > 0 {
+					return nil
+				}
+				for _, meta := range ctx.Elements("meta[http-equiv]") {
+					attrs := meta.Attributes()
+					if strings.EqualFold(attrs["http-equiv"], "content-type") &&
+						strings.Contains(strings.ToLower(attrs["content"]), "charset") {
+						return nil
+					}
+				}
+				return []Issue{{Message: "Missing charset declaration (<meta charset> or Content-Type)", Element: "head"}}
+			},
+		},
+		elementRule("script-tag", "structure", SeverityError,
+			"Scripts are stripped by every email client",
+			"No email client executes JavaScript; many spam filters penalise messages that contain it.",
+			"script", "<script> is removed by all email clients and may trigger spam filters"),
+		elementRule("form-elements", "structure", SeverityWarning,
+			"Forms are unsupported or disabled in most clients",
+			"Gmail, Outlook and many webmail clients disable form submission or strip form controls.",
+			"form, input, select, textarea", "Form elements are unsupported in most email clients"),
+		elementRule("embedded-content", "structure", SeverityError,
+			"Embedded frames and plugins are stripped",
+			"iframes, objects and embeds are removed by every major client for security reasons.",
+			"iframe, object, embed, applet", "Embedded content is stripped by email clients"),
+		elementRule("media-elements", "structure", SeverityWarning,
+			"Video and audio only play in a few clients",
+			"Apple Mail plays <video>, but Gmail and Outlook show nothing. Provide a fallback image.",
+			"video, audio", "<video>/<audio> only plays in Apple Mail; provide a fallback image"),
+		elementRule("inline-svg", "structure", SeverityWarning,
+			"Inline SVG is not rendered by Gmail or Outlook",
+			"Gmail strips <svg> elements and Outlook desktop ignores them. Use PNG images instead.",
+			"svg", "Inline <svg> is not supported by Gmail or Outlook"),
+		elementRule("external-stylesheet", "structure", SeverityWarning,
+			"Linked stylesheets are stripped",
+			"Almost every client removes <link rel=\"stylesheet\">. Styles must be embedded or inlined.",
+			`link[rel="stylesheet" i]`, "External stylesheets are removed by email clients; embed the CSS instead"),
+		elementRule("base-tag", "structure", SeverityWarning,
+			"<base> is ignored by most clients",
+			"Clients ignore or strip <base>, so relative URLs it was meant to resolve break.",
+			"base", "<base> is ignored by most email clients; use absolute URLs"),
+		{
+			ID:              "meta-refresh",
+			Category:        "structure",
+			DefaultSeverity: SeverityError,
+			Description:     "Meta refresh redirects are blocked",
+			Docs:            "Redirecting meta tags are stripped and commonly flagged as phishing by spam filters.",
+			Check: func(ctx *Context) []Issue {
+				var issues []Issue
+				for _, meta := range ctx.Elements("meta[http-equiv]") {
+					if strings.EqualFold(meta.Attributes()["http-equiv"], "refresh") {
+						issues = append(issues, Issue{
+							Message: "<meta http-equiv=\"refresh\"> is blocked and flagged by spam filters",
+							Element: "meta",
+							Line:    meta.SourceLine(),
+						})
+					}
+				}
+				return issues
+			},
+		},
+		{
+			ID:              "nested-tables",
+			Category:        "structure",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Tables should not be nested too deeply",
+			Docs: "Deeply nested tables slow down rendering on mobile clients and have been observed " +
+				"to break layouts in Outlook. Flatten the layout where possible.",
+			Options: map[string]string{"max-depth": "8"},
+			Check: func(ctx *Context) []Issue {
+				maxDepth := ctx.IntOption("max-depth")
+				var issues []Issue
+				for _, table := range ctx.Elements("table") {
+					depth := 0
+					for parent := table.Parent(); parent != nil; parent = parent.Parent() {
+						if strings.EqualFold(parent.TagName(), "table") {
+							depth++
+						}
+					}
+					// Report only the tables that cross the limit, not every descendant
+					if depth == maxDepth {
+						issues = append(issues, Issue{
+							Message: fmt.Sprintf("Table nesting depth exceeds %d", maxDepth),
+							Element: "table",
+							Line:    table.SourceLine(),
+						})
+					}
+				}
+				return issues
+			},
+		},
+		{
+			ID:              "table-width",
+			Category:        "structure",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Tables should not be wider than the email viewport",
+			Docs: "Most clients display email in a pane around 600-800px wide. Wider tables force " +
+				"horizontal scrolling on desktop and zooming on mobile.",
+			Options: map[string]string{"max-width": "800"},
+			Check: func(ctx *Context) []Issue {
+				maxWidth := float64(ctx.IntOption("max-width"))
+				var issues []Issue
+				for _, table := range ctx.Elements("table") {
+					width := table.Attributes()["width"]
+					if style, ok := table.GetInlineStyle()["width"]; ok {
+						width = style.Value
+					}
+					if px, ok := pixelValue(width); ok && px > maxWidth {
+						issues = append(issues, Issue{
+							Message: fmt.Sprintf("Table width %s exceeds %dpx", width, int(maxWidth)),
+							Element: "table",
+							Line:    table.SourceLine(),
+						})
+					}
+				}
+				return issues
+			},
+		},
+		{
+			ID:              "image-dimensions",
+			Category:        "attribute",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Images should have width and height attributes",
+			Docs: "Outlook desktop ignores CSS dimensions on images and renders them at their " +
+				"natural size. The width attribute is the only reliable way to size an image.",
+			Check: func(ctx *Context) []Issue {
+				var issues []Issue
+				for _, img := range ctx.Elements("img") {
+					attrs := img.Attributes()
+					if _, ok := attrs["width"]; !ok {
+						issues = append(issues, Issue{
+							Message: "<img> has no width attribute; Outlook renders it at its natural size",
+							Element: "img",
+							Line:    img.SourceLine(),
+						})
+					}
+				}
+				return issues
+			},
+		},
+		{
+			ID:              "image-data-uri",
+			Category:        "attribute",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Images should not be embedded as data URIs",
+			Docs: "Gmail and Outlook block data: URIs in image sources, and base64 images inflate " +
+				"the message towards Gmail's clipping limit. Host images or attach them via cid:.",
+			Check: func(ctx *Context) []Issue {
+				return attributeIssues(ctx, "img[src]", "src", func(value string) string {
+					if strings.HasPrefix(strings.ToLower(strings.TrimSpace(value)), "data:") {
+						return "Data URI images are blocked by Gmail and Outlook"
+					}
+					return ""
+				})
+			},
+		},
+		{
+			ID:              "image-format",
+			Category:        "attribute",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Images should use widely supported formats",
+			Docs:            "SVG, WebP, AVIF and HEIC are not displayed by Outlook desktop and several webmail clients. Use PNG, JPEG or GIF.",
+			Check: func(ctx *Context) []Issue {
+				return attributeIssues(ctx, "img[src]", "src", func(value string) string {
+					path := strings.ToLower(value)
+					if i := strings.IndexAny(path, "?#"); i >= 0 {
+						path = path[:i]
+					}
+					for _, ext := range []string{".svg", ".webp", ".avif", ".heic"} {
+						if strings.HasSuffix(path, ext) {
+							return fmt.Sprintf("%s images are not supported by all email clients", strings.TrimPrefix(ext, "."))
+						}
+					}
+					return ""
+				})
+			},
+		},
+		{
+			ID:              "relative-url",
+			Category:        "attribute",
+			DefaultSeverity: SeverityError,
+			Description:     "Links and images must use absolute URLs",
+			Docs: "An email has no base URL, so relative links and image sources resolve to nothing. " +
+				"Merge tags are ignored because they are expanded by the ESP at send time.",
+			Check: func(ctx *Context) []Issue {
+				check := func(value string) string {
+					if isRelativeURL(value) {
+						return fmt.Sprintf("Relative URL %q will not resolve in email clients", value)
+					}
+					return ""
+				}
+				issues := attributeIssues(ctx, "a[href]", "href", check)
+				issues = append(issues, attributeIssues(ctx, "img[src]", "src", check)...)
+				issues = append(issues, attributeIssues(ctx, "[background]", "background", check)...)
+				return issues
+			},
+		},
+		{
+			ID:              "empty-link",
+			Category:        "attribute",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Links should have a destination",
+			Docs:            "Links without an href, or with href=\"#\", do nothing when clicked and are often stripped by clients.",
+			Check: func(ctx *Context) []Issue {
+				var issues []Issue
+				for _, link := range ctx.Elements("a") {
+					attrs := link.Attributes()
+					href, hasHref := attrs["href"]
+					if _, isTarget := attrs["name"]; isTarget && !hasHref {
+						continue // Named anchor used as a jump target
+					}
+					if href = strings.TrimSpace(href); href == "" || href == "#" {
+						issues = append(issues, Issue{
+							Message: "Link has no destination",
+							Element: "a",
+							Line:    link.SourceLine(),
+						})
+					}
+				}
+				return issues
+			},
+		},
+		{
+			ID:              "javascript-url",
+			Category:        "attribute",
+			DefaultSeverity: SeverityError,
+			Description:     "javascript: URLs are blocked",
+			Docs:            "Clients strip javascript: links and spam filters treat them as malicious.",
+			Check: func(ctx *Context) []Issue {
+				return attributeIssues(ctx, "a[href]", "href", func(value string) string {
+					if strings.HasPrefix(strings.ToLower(strings.TrimSpace(value)), "javascript:") {
+						return "javascript: URLs are blocked by email clients"
+					}
+					return ""
+				})
+			},
+		},
+		{
+			ID:              "duplicate-id",
+			Category:        "attribute",
+			DefaultSeverity: SeverityWarning,
+			Description:     "Element IDs should be unique",
+			Docs: "Duplicate IDs make #id selectors in preserved CSS apply unpredictably, and " +
+				"webmail clients that rewrite IDs may drop the duplicates.",
+			Check: func(ctx *Context) []Issue {
+				var issues []Issue
+				seen := make(map[string]bool)
+				for _, element := range ctx.Elements("[id]") {
+					id := element.ID()
+					if id == "" {
+						continue
+					}
+					if seen[id] {
+						issues = append(issues, Issue{
+							Message: fmt.Sprintf("Duplicate id %q", id),
+							Element: strings.ToLower(element.TagName()),
+							Line:    element.SourceLine(),
+						})
+					}
+					seen[id] = true
+				}
+				return issues
+			},
+		},
+	}
+}
+
+// attributeIssues reports elements whose attribute value fails check
+func attributeIssues(ctx *Context, selector, attribute string, check func(value string) string) []Issue {
+	var issues []Issue
+	for _, element := range ctx.Elements(selector) {
+		if message := check(element.Attributes()[attribute]); message != "" {
+			issues = append(issues, Issue{
+				Message: message,
+				Element: strings.ToLower(element.TagName()),
+				Line:    element.SourceLine(),
+			})
+		}
+	}
+	return issues
+}
+
+// isRelativeURL reports whether a URL would need a base URL to resolve
+func isRelativeURL(value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.HasPrefix(value, "#") || strings.HasPrefix(value, "//") {
+		return false
+	}
+	if templateTagRegex.MatchString(value) {
+		return false
+	}
+	if colon := strings.Index(value, ":"); colon > 0 {
+		scheme := value[:colon]
+		if !strings.ContainsAny(scheme, "/?#") {
+			return false // Has a scheme: http, https, mailto, tel, cid, data, ...
+		}
+	}
+	return true
+}
+
+// pixelValue parses "600", "600px" or "600.5px" as a pixel count
+func pixelValue(value string) (float64, bool) {
+	match := pixelValueRegex.FindStringSubmatch(value)
+	if match == nil {
+		return 0, false
+	}
+	px, err := strconv.ParseFloat(match[1], 64)
+	return px, err == nil
+}
@@ -0,0 +1,8 @@
+package version
+
+// Version is the inliner release version, reported by the CLI and embedded in
+// machine-readable reports
+const Version = "0.2.0"
+
+// Name is the tool name used in reports
+const Name = "inliner"
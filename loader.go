@@ -0,0 +1,107 @@
+package inliner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxStylesheetBytes caps how much of an external stylesheet is read
+const maxStylesheetBytes = 4 << 20
+
+// Loader fetches the external stylesheets referenced by
+// <link rel="stylesheet"> and @import. href is as written in the document,
+// or resolved against the importing stylesheet for nested @import rules.
+// Implementations must be safe for concurrent use.
+type Loader interface {
+	Load(ctx context.Context, href string) (string, error)
+}
+
+// LoaderFunc adapts a function to the Loader interface
+type LoaderFunc func(ctx context.Context, href string) (string, error)
+
+// Load calls f(ctx, href)
+func (f LoaderFunc) Load(ctx context.Context, href string) (string, error) {
+	return f(ctx, href)
+}
+
+// FileLoader loads stylesheets from files under dir. Relative hrefs are
+// resolved against dir; absolute URLs and paths that escape dir are refused.
+func FileLoader(dir string) Loader {
+	return LoaderFunc(func(ctx context.Context, href string) (string, error) {
+		parsed, err := url.Parse(href)
+		if err != nil {
+			return "", fmt.Errorf("invalid stylesheet href %q: %w", href, err)
+		}
+		if parsed.Scheme != "" || parsed.Host != "" {
+			return "", fmt.Errorf("refusing to load remote stylesheet %q from files", href)
+		}
+
+		root, err := os.OpenRoot(dir)
+		if err != nil {
+			return "", err
+		}
+		defer root.Close()
+
+		file, err := root.Open(filepath.FromSlash(strings.TrimPrefix(parsed.Path, "/")))
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+
+		return readStylesheet(file)
+	})
+}
+
+// HTTPLoader loads http and https stylesheets with client, or
+// http.DefaultClient when client is nil. Protocol-relative hrefs use https.
+func HTTPLoader(client *http.Client) Loader {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return LoaderFunc(func(ctx context.Context, href string) (string, error) {
+		if strings.HasPrefix(href, "//") {
+			href = "https:" + href
+		}
+		parsed, err := url.Parse(href)
+		if err != nil {
+			return "", fmt.Errorf("invalid stylesheet href %q: %w", href, err)
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			return "", fmt.Errorf("refusing to load non-HTTP stylesheet %q", href)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("GET %s: %s", parsed, resp.Status)
+		}
+		return readStylesheet(resp.Body)
+	})
+}
+
+// readStylesheet reads at most maxStylesheetBytes of CSS
+func readStylesheet(r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxStylesheetBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxStylesheetBytes {
+		return "", fmt.Errorf("stylesheet larger than %d bytes", maxStylesheetBytes)
+	}
+	return string(data), nil
+}
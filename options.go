@@ -0,0 +1,112 @@
+package inliner
+
+import (
+	"fmt"
+
+	engine "inliner/internal/inliner"
+)
+
+// Option configures an Inliner created by New
+type Option func(*settings) error
+
+// settings collects the options passed to New
+type settings struct {
+	config   Config
+	loader   Loader
+	rules    []Rule
+	pipeline []func(*engine.Pipeline) error
+}
+
+// WithConfig replaces the whole configuration. Options after it adjust
+// the replacement.
+func WithConfig(cfg Config) Option {
+	return func(s *settings) error {
+		s.config = cfg
+		return nil
+	}
+}
+
+// WithTargetClient sets the email client to optimize for
+func WithTargetClient(client string) Option {
+	return func(s *settings) error {
+		s.config.TargetClient = client
+		return nil
+	}
+}
+
+// WithMinify turns output minification on or off
+func WithMinify(minify bool) Option {
+	return func(s *settings) error {
+		s.config.Minify = minify
+		return nil
+	}
+}
+
+// WithOutputFormat sets the output whitespace format and, for
+// OutputPretty, the indentation unit
+func WithOutputFormat(format, indent string) Option {
+	return func(s *settings) error {
+		switch format {
+		case OutputPreserve, OutputPretty, OutputCompact:
+		default:
+			return fmt.Errorf("invalid output format %q (want preserve, pretty or compact)", format)
+		}
+		s.config.OutputFormat = format
+		if indent != "" {
+			s.config.Indent = indent
+		}
+		return nil
+	}
+}
+
+// WithAccessibilityFixes turns the accessibility autofix pass on, adding
+// lang to <html> when it is missing and lang is non-empty
+func WithAccessibilityFixes(lang string) Option {
+	return func(s *settings) error {
+		s.config.AccessibilityFixes = true
+		s.config.DefaultLang = lang
+		return nil
+	}
+}
+
+// WithDarkMode keeps dark color scheme rules, adds their Outlook.com
+// variants and, when colorScheme is non-empty (e.g. "light dark"), adds the
+// color-scheme meta tags
+func WithDarkMode(colorScheme string) Option {
+	return func(s *settings) error {
+		s.config.DarkMode = true
+		s.config.ColorScheme = colorScheme
+		return nil
+	}
+}
+
+// WithRuleSeverity overrides the severity of a lint rule: "error",
+// "warning", "info" or "off"
+func WithRuleSeverity(id, severity string) Option {
+	return func(s *settings) error {
+		severities := make(map[string]string, len(s.config.RuleSeverities)+1)
+		for ruleID, value := range s.config.RuleSeverities {
+			severities[ruleID] = value
+		}
+		severities[id] = severity
+		s.config.RuleSeverities = severities
+		return nil
+	}
+}
+
+// WithLoader sets the loader for <link rel="stylesheet"> and @import.
+// Without one, external stylesheets are left in the output untouched.
+func WithLoader(loader Loader) Option {
+	return func(s *settings) error {
+		s.loader = loader
+		return nil
+	}
+}
+
+// WithRules registers custom lint rules, run by Validate after the built-in ones
+func WithRules(rules ...Rule) Option {
+	return func(s *settings) error {
+		s.rules = append(s.rules, rules...)
+		return nil
+	}
+}
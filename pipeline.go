@@ -0,0 +1,164 @@
+package inliner
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	engine "inliner/internal/inliner"
+)
+
+// Built-in pipeline stages, in their default order.
+//
+// Parsing and serializing the HTML are not stages and cannot be hooked,
+// removed or reordered: every stage works on the parsed document, so the
+// pipeline starts once it exists, and the serialized output is what Inline
+// returns and InlineReader writes, after the size checks have run against
+// it. Hook before StageExtractCSS to see the document as parsed and after
+// StageStripDirectives to make the final changes; to transform the raw
+// markup, do so before passing it to Inline or InlineReader.
+const (
+	StageExtractCSS         = engine.StageExtractCSS         // Parse <style>, <link> and @import CSS
+	StageInlineStyles       = engine.StageInlineStyles       // Apply the cascade to style attributes
+	StageVMLFallbacks       = engine.StageVMLFallbacks       // data-inliner="vml-button" and "vml-background"
+	StageAccessibilityFixes = engine.StageAccessibilityFixes // Config.AccessibilityFixes
+	StageDarkMode           = engine.StageDarkMode           // Config.ColorScheme meta tags
+	StageStyleTags          = engine.StageStyleTags          // Rewrite <style> tags with preserved rules
+	StageMinify             = engine.StageMinify             // Config.Minify
+	StageFormatWhitespace   = engine.StageFormatWhitespace   // Config.OutputFormat
+	StageStripDirectives    = engine.StageStripDirectives    // Remove data-inliner attributes
+)
+
+// HookFunc transforms the document at a point in the pipeline, e.g. to
+// rewrite image URLs or inject a tracking pixel
+type HookFunc func(ctx context.Context, doc *Document) error
+
+// Document is the document being inlined, as seen by pipeline hooks
+type Document struct {
+	state *engine.State
+}
+
+// Elements returns the elements matching a CSS selector, in document order
+func (d *Document) Elements(selector string) []Element {
+	nodes, err := d.state.Document.QuerySelectorAll(selector)
+	if err != nil {
+		return nil
+	}
+	elements := make([]Element, len(nodes))
+	for j, node := range nodes {
+		elements[j] = Element{node: node}
+	}
+	return elements
+}
+
+// CSSRule is a read-only copy of a parsed CSS rule
+type CSSRule struct {
+	Selector     string            // Selector, or the prelude of an at-rule such as @font-face
+	AtRules      []string          // Enclosing @media/@supports preludes, outermost first
+	Declarations map[string]string // Property -> value, with " !important" where set
+}
+
+// CSSRules returns the rules parsed by the extract-css stage, in cascade order
+func (d *Document) CSSRules() []CSSRule {
+	if d.state.Stylesheet == nil {
+		return nil
+	}
+
+	rules := make([]CSSRule, len(d.state.Stylesheet.Rules))
+	for j, rule := range d.state.Stylesheet.Rules {
+		declarations := make(map[string]string, len(rule.Declarations))
+		for property, declaration := range rule.Declarations {
+			value := declaration.Value
+			if declaration.Important {
+				value += " !important"
+			}
+			declarations[property] = value
+		}
+		rules[j] = CSSRule{
+			Selector:     rule.Selector,
+			AtRules:      append([]string(nil), rule.AtRules...),
+			Declarations: declarations,
+		}
+	}
+	return rules
+}
+
+// errNilHook is returned for hooks and stages without a function
+var errNilHook = errors.New("nil hook function")
+
+// adaptHook wraps a public hook as an engine stage function
+func adaptHook(hook HookFunc) engine.StageFunc {
+	return func(state *engine.State) error {
+		return hook(state.Context, &Document{state: state})
+	}
+}
+
+// WithBeforeStage runs hook before the named stage
+func WithBeforeStage(stage string, hook HookFunc) Option {
+	return func(s *settings) error {
+		if hook == nil {
+			return errNilHook
+		}
+		s.pipeline = append(s.pipeline, func(p *engine.Pipeline) error {
+			return p.Before(stage, adaptHook(hook))
+		})
+		return nil
+	}
+}
+
+// WithAfterStage runs hook after the named stage
+func WithAfterStage(stage string, hook HookFunc) Option {
+	return func(s *settings) error {
+		if hook == nil {
+			return errNilHook
+		}
+		s.pipeline = append(s.pipeline, func(p *engine.Pipeline) error {
+			return p.After(stage, adaptHook(hook))
+		})
+		return nil
+	}
+}
+
+// WithStage adds a custom stage after the named one; an empty after runs
+// it first. Other options can then hook it or reorder it by name.
+func WithStage(after, name string, run HookFunc) Option {
+	return func(s *settings) error {
+		if run == nil {
+			return errNilHook
+		}
+		s.pipeline = append(s.pipeline, func(p *engine.Pipeline) error {
+			return p.Insert(after, engine.Stage{Name: name, Run: adaptHook(run)})
+		})
+		return nil
+	}
+}
+
+// WithoutStage disables a stage
+func WithoutStage(name string) Option {
+	return func(s *settings) error {
+		s.pipeline = append(s.pipeline, func(p *engine.Pipeline) error {
+			return p.Remove(name)
+		})
+		return nil
+	}
+}
+
+// WithStageOrder sets the order stages run in; every stage must be listed once
+func WithStageOrder(names ...string) Option {
+	return func(s *settings) error {
+		s.pipeline = append(s.pipeline, func(p *engine.Pipeline) error {
+			return p.Reorder(names...)
+		})
+		return nil
+	}
+}
+
+// Stages returns the names of the stages the inliner runs, in order
+func (in *Inliner) Stages() []string {
+	return in.engine.Pipeline().Stages()
+}
+
+// stageList formats stage names for error messages
+func stageList(names []string) string {
+	return strings.Join(names, ", ")
+}
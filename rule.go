@@ -0,0 +1,142 @@
+package inliner
+
+import (
+	"strings"
+
+	"inliner/internal/html"
+	"inliner/internal/rules"
+)
+
+// Severity levels for issues, warnings and rule overrides
+const (
+	SeverityError   = rules.SeverityError
+	SeverityWarning = rules.SeverityWarning
+	SeverityInfo    = rules.SeverityInfo
+	SeverityOff     = rules.SeverityOff
+)
+
+// Rule is a custom lint rule run by Validate alongside the built-in rules.
+// Its severity can be overridden and it can be disabled like any other rule.
+type Rule struct {
+	ID          string            // Stable identifier used in reports, config and disable comments
+	Category    string            // "structure", "css", "accessibility", ...
+	Severity    string            // Default severity: SeverityError, SeverityWarning or SeverityInfo
+	Description string            // One-line summary
+	Reference   string            // Standard the rule enforces, if any
+	Options     map[string]string // Option name -> default value
+	Check       func(ctx *RuleContext) []Issue
+}
+
+// RuleContext gives a rule read access to the document being validated
+type RuleContext struct {
+	ctx *rules.Context
+}
+
+// Elements returns the elements matching a CSS selector, in document order
+func (c *RuleContext) Elements(selector string) []Element {
+	nodes := c.ctx.Elements(selector)
+	elements := make([]Element, len(nodes))
+	for j, node := range nodes {
+		elements[j] = Element{node: node}
+	}
+	return elements
+}
+
+// Stylesheets returns the text of each <style> tag, in document order
+func (c *RuleContext) Stylesheets() []string {
+	texts := make([]string, len(c.ctx.Sheets))
+	for j, sheet := range c.ctx.Sheets {
+		texts[j] = sheet.Text
+	}
+	return texts
+}
+
+// Option returns the configured value of a rule option, or its default
+func (c *RuleContext) Option(name string) string {
+	return c.ctx.Option(name)
+}
+
+// Element is a view of an HTML element. Rule checks must treat it as
+// read-only; pipeline hooks may modify it.
+type Element struct {
+	node html.Node
+}
+
+// Tag returns the lower-case tag name
+func (e Element) Tag() string {
+	return strings.ToLower(e.node.TagName())
+}
+
+// Attr returns an attribute value and whether it is present
+func (e Element) Attr(name string) (string, bool) {
+	value, ok := e.node.Attributes()[name]
+	return value, ok
+}
+
+// Text returns the text content of the element and its descendants
+func (e Element) Text() string {
+	return e.node.Text()
+}
+
+// Style returns the value of a property in the element's style attribute
+func (e Element) Style(property string) string {
+	return e.node.GetInlineStyle()[strings.ToLower(property)].Value
+}
+
+// Line returns the 1-based source line of the element, 0 if unknown
+func (e Element) Line() int {
+	return e.node.SourceLine()
+}
+
+// toInternal adapts a public rule to the engine's rule registry
+func (r Rule) toInternal() rules.Rule {
+	rule := rules.Rule{
+		ID:              r.ID,
+		Category:        r.Category,
+		DefaultSeverity: r.Severity,
+		Description:     r.Description,
+		Reference:       r.Reference,
+		Options:         r.Options,
+	}
+	if r.Check == nil {
+		return rule // Rejected by the registry
+	}
+
+	check := r.Check
+	rule.Check = func(ctx *rules.Context) []rules.Issue {
+		found := check(&RuleContext{ctx: ctx})
+		issues := make([]rules.Issue, len(found))
+		for j, issue := range found {
+			issues[j] = rules.Issue{
+				Category:  issue.Category,
+				Message:   issue.Message,
+				Element:   issue.Element,
+				Property:  issue.Property,
+				Line:      issue.Line,
+				Reference: issue.Reference,
+			}
+		}
+		return issues
+	}
+	return rule
+}
+
+// SetAttr sets an attribute
+func (e Element) SetAttr(name, value string) error {
+	return e.node.SetAttribute(name, value)
+}
+
+// RemoveAttr removes an attribute
+func (e Element) RemoveAttr(name string) error {
+	return e.node.RemoveAttribute(name)
+}
+
+// AppendHTML parses HTML and appends it to the element's children
+func (e Element) AppendHTML(content string) error {
+	return e.node.AppendHTML(content)
+}
+
+// Remove removes the element and its descendants from the document
+func (e Element) Remove() error {
+	return e.node.Remove()
+}